@@ -1,18 +1,19 @@
-// Manages AWS Auth v4 requests to DynamoDB.
+// Manages AWS Auth v4 requests to DynamoDB and DynamoDB Streams.
 // See http://docs.aws.amazon.com/general/latest/gr/signature-version-4.html
 // for more information on v4 signed requests. For examples, see any of
 // the package in the `endpoints` directory.
 package auth_v4
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"github.com/smugmug/godynamo/auth_v4/tasks"
 	"github.com/smugmug/godynamo/aws_const"
 	"github.com/smugmug/godynamo/conf"
-	"hash"
+	"github.com/smugmug/godynamo/credentials"
 	"hash/crc32"
 	"io"
 	"io/ioutil"
@@ -27,17 +28,26 @@ const (
 		"management functions in package conf_iam, such as GoIAM"
 )
 
-// Client for executing requests.
+// ErrCredentials is wrapped by the errors of requests that could not be signed for want
+// of credentials. Retrying such a request will not help.
+var ErrCredentials = errors.New("auth_v4: no credentials")
+
+// Client for executing requests, unless the conf provides its own HTTPClient.
 var Client *http.Client
 
 // Initialize package-scoped client.
 func init() {
+	Client = NewHTTPClient()
+}
+
+// NewHTTPClient returns a client with the default godynamo transport settings.
+func NewHTTPClient() *http.Client {
 	// The timeout seems too-long, but it accomodates the exponential decay retry loop.
 	// Programs using this can either change this directly or use goroutine timeouts
 	// to impose a local minimum.
 	tr := &http.Transport{MaxIdleConnsPerHost: 250,
 		ResponseHeaderTimeout: time.Duration(20) * time.Second}
-	Client = &http.Client{Transport: tr}
+	return &http.Client{Transport: tr}
 }
 
 // GetRespReqID retrieves the unique identifier from the AWS Response
@@ -76,87 +86,110 @@ func MatchCheckSum(response http.Response, respbody []byte) (bool, error) {
 	return true, nil
 }
 
-// rawReqAll takes each parameter independently, forms and signs the request, and returns the
-// result (and error codes).
-func rawReqAll(reqJSON []byte, amzTarget string, useIAM bool, url, host, port, zone, IAMSecret, IAMAccessKey, IAMToken, authSecret, authAccessKey string) ([]byte, string, int, error) {
+// Service describes the AWS service a request is signed for and sent to. Requests are
+// made to DynamoDB unless a Service is attached to their context with WithService.
+type Service struct {
+	// Name is the service name of the credential scope, e.g. "dynamodb".
+	Name string
+	// The endpoint the request is sent to; Host and Port are signed.
+	URL  string
+	Host string
+	Port string
+	// Zone is the region of the credential scope.
+	Zone string
+}
+
+// DynamoDBService returns the Service for the DynamoDB endpoint of c.
+func DynamoDBService(c *conf.AWS_Conf) Service {
+	c.ConfLock.RLock()
+	defer c.ConfLock.RUnlock()
+	return Service{
+		Name: strings.ToLower(aws_const.DYNAMODB),
+		URL:  c.Network.DynamoDB.URL,
+		Host: c.Network.DynamoDB.Host,
+		Port: c.Network.DynamoDB.Port,
+		Zone: c.Network.DynamoDB.Zone,
+	}
+}
+
+type serviceKey struct{}
+
+// WithService returns a copy of ctx that sends and signs requests for s rather than
+// the DynamoDB endpoint of the conf. Empty fields of s take their DynamoDB values.
+func WithService(ctx context.Context, s Service) context.Context {
+	return context.WithValue(ctx, serviceKey{}, s)
+}
+
+// ServiceFromContext returns the Service attached to ctx with WithService, if any.
+func ServiceFromContext(ctx context.Context) (Service, bool) {
+	s, ok := ctx.Value(serviceKey{}).(Service)
+	return s, ok
+}
+
+// serviceFor returns the Service a request with ctx is made to.
+func serviceFor(ctx context.Context, c *conf.AWS_Conf) Service {
+	s := DynamoDBService(c)
+	o, ok := ServiceFromContext(ctx)
+	if !ok {
+		return s
+	}
+	if o.Name != "" {
+		s.Name = o.Name
+	}
+	if o.URL != "" {
+		s.URL = o.URL
+	}
+	if o.Host != "" {
+		s.Host = o.Host
+	}
+	if o.Port != "" {
+		s.Port = o.Port
+	}
+	if o.Zone != "" {
+		s.Zone = o.Zone
+	}
+	return s
+}
+
+// rawReqAll forms the request to svc and signs it with v, sends it with client, and
+// returns the result (and error codes). useIAM requires v to carry a session token.
+// The request is bound to ctx, so cancelling ctx or reaching its deadline will abort
+// the request in flight.
+func rawReqAll(ctx context.Context, client *http.Client, reqJSON []byte, amzTarget string, svc Service, v credentials.Value, useIAM bool) ([]byte, string, int, error) {
+	if v.SecretAccessKey == "" {
+		return nil, "", 0, fmt.Errorf("auth_v4.rawReqAll: no Secret defined; %s: %w", IAM_WARN_MESSAGE, ErrCredentials)
+	}
+	if v.AccessKeyID == "" {
+		return nil, "", 0, fmt.Errorf("auth_v4.rawReqAll: no Access Key defined; %s: %w", IAM_WARN_MESSAGE, ErrCredentials)
+	}
+	if useIAM == true && v.SessionToken == "" {
+		return nil, "", 0, fmt.Errorf("auth_v4.rawReqAll: no Token defined; %s: %w", IAM_WARN_MESSAGE, ErrCredentials)
+	}
 
 	// initialize req with body reader
-	body := strings.NewReader(string(reqJSON))
-	request, req_err := http.NewRequest(aws_const.METHOD, url, body)
+	request, req_err := http.NewRequestWithContext(ctx, aws_const.METHOD, svc.URL, bytes.NewReader(reqJSON))
 	if req_err != nil {
 		e := fmt.Sprintf("auth_v4.rawReqAll:failed init conn %s", req_err.Error())
 		return nil, "", 0, errors.New(e)
 	}
+	// sign the host and port of the conf, which may be omitted from url
+	request.Host = svc.Host + ":" + svc.Port
 
 	// add headers
 	// content type
-	request.Header.Add(aws_const.CONTENT_TYPE_HDR, aws_const.CTYPE)
+	request.Header.Set(aws_const.CONTENT_TYPE_HDR, aws_const.CTYPE)
 	// amz target
-	request.Header.Add(aws_const.AMZ_TARGET_HDR, amzTarget)
-	// dates
-	now := time.Now()
-	request.Header.Add(aws_const.X_AMZ_DATE_HDR,
-		now.UTC().Format(aws_const.ISO8601FMT_CONDENSED))
+	request.Header.Set(aws_const.AMZ_TARGET_HDR, amzTarget)
 
 	// encode request json payload
-	var h256 hash.Hash = sha256.New()
-	h256.Write(reqJSON)
-	hexPayload := string(hex.EncodeToString([]byte(h256.Sum(nil))))
-
-	// create the various signed formats aws uses for v4 signed reqs
-	service := strings.ToLower(aws_const.DYNAMODB)
-	canonical_request := tasks.CanonicalRequest(
-		host,
-		port,
-		request.Header.Get(aws_const.X_AMZ_DATE_HDR),
-		request.Header.Get(aws_const.AMZ_TARGET_HDR),
-		hexPayload)
-	str2sign := tasks.String2Sign(now, canonical_request,
-		zone,
-		service)
-
-	// obtain the aws secret credential from the global Auth or from IAM
-	var secret string
-	if useIAM == true {
-		secret = IAMSecret
-	} else {
-		secret = authSecret
-	}
-	if secret == "" {
-		panic("auth_v4.rawReqAll: no Secret defined; " + IAM_WARN_MESSAGE)
-	}
-
-	signature := tasks.MakeSignature(str2sign, zone, service, secret)
-
-	// obtain the aws accessKey credential from the global Auth or from IAM
-	// if using IAM, read the token while we have the lock
-	var accessKey, token string
-	if useIAM == true {
-		accessKey = IAMAccessKey
-		token = IAMToken
-	} else {
-		accessKey = authAccessKey
-	}
-	if accessKey == "" {
-		panic("auth_v4.rawReqAll: no Access Key defined; " + IAM_WARN_MESSAGE)
-	}
-
-	v4auth := "AWS4-HMAC-SHA256 Credential=" + accessKey +
-		"/" + now.UTC().Format(aws_const.ISODATEFMT) + "/" +
-		zone + "/" + service + "/aws4_request," +
-		"SignedHeaders=content-type;host;x-amz-date;x-amz-target," +
-		"Signature=" + signature
-
-	request.Header.Add("Authorization", v4auth)
-	if useIAM == true {
-		if token == "" {
-			panic("auth_v4.rawReqAll: no Token defined;" + IAM_WARN_MESSAGE)
-		}
-		request.Header.Add(aws_const.X_AMZ_SECURITY_TOKEN_HDR, token)
+	payload := sha256.Sum256(reqJSON)
+	sign_err := NewSigner(v, svc.Name, svc.Zone).sign(request, time.Now(), hex.EncodeToString(payload[:]))
+	if sign_err != nil {
+		return nil, "", 0, sign_err
 	}
 
 	// where we finally send req to aws
-	response, rsp_err := Client.Do(request)
+	response, rsp_err := client.Do(request)
 
 	if rsp_err != nil {
 		return nil, "", 0, rsp_err
@@ -177,34 +210,54 @@ func rawReqAll(reqJSON []byte, amzTarget string, useIAM bool, url, host, port, z
 	return respbody, amz_requestid, response.StatusCode, nil
 }
 
-// RawReqWithConf will sign and transmit the request to the AWS DynamoDB endpoint.
+// RawReqWithContext will sign and transmit the request to the AWS DynamoDB endpoint,
+// or to the Service attached to ctx with WithService.
+// ctx bounds the lifetime of the request
 // reqJSON is the json request
 // amzTarget is the dynamoDB endpoint
 // c is the configuration struct
 // returns []byte respBody, string aws reqID, int http code, error
-func RawReqWithConf(reqJSON []byte, amzTarget string, c *conf.AWS_Conf) ([]byte, string, int, error) {
+func RawReqWithContext(ctx context.Context, reqJSON []byte, amzTarget string, c *conf.AWS_Conf) ([]byte, string, int, error) {
+	if ctx == nil {
+		return nil, "", 0, errors.New("auth_v4.RawReqWithContext: ctx is nil")
+	}
 	if !conf.IsValid(c) {
-		return nil, "", 0, errors.New("auth_v4.RawReqWithConf: conf not valid")
-	}
-	// shadow conf vars in a read lock to minimize contention
-	var our_c conf.AWS_Conf
-	cp_err := our_c.Copy(c)
-	if cp_err != nil {
-		return nil, "", 0, cp_err
-	}
-	return rawReqAll(
-		reqJSON,
-		amzTarget,
-		our_c.UseIAM,
-		our_c.Network.DynamoDB.URL,
-		our_c.Network.DynamoDB.Host,
-		our_c.Network.DynamoDB.Port,
-		our_c.Network.DynamoDB.Zone,
-		our_c.IAM.Credentials.Secret,
-		our_c.IAM.Credentials.AccessKey,
-		our_c.IAM.Credentials.Token,
-		our_c.Auth.Secret,
-		our_c.Auth.AccessKey)
+		return nil, "", 0, errors.New("auth_v4.RawReqWithContext: conf not valid")
+	}
+	// read the conf vars the request needs in a read lock to minimize contention
+	c.ConfLock.RLock()
+	client := c.HTTPClient
+	provider := c.Credentials
+	use_iam := c.UseIAM
+	var v credentials.Value
+	if use_iam == true {
+		v = credentials.Value{AccessKeyID: c.IAM.Credentials.AccessKey,
+			SecretAccessKey: c.IAM.Credentials.Secret, SessionToken: c.IAM.Credentials.Token}
+	} else {
+		v = credentials.Value{AccessKeyID: c.Auth.AccessKey, SecretAccessKey: c.Auth.Secret}
+	}
+	c.ConfLock.RUnlock()
+	if client == nil {
+		client = Client
+	}
+	if provider != nil {
+		var cred_err error
+		v, cred_err = provider.Retrieve(ctx)
+		if cred_err != nil {
+			if ctx_err := ctx.Err(); ctx_err != nil {
+				return nil, "", 0, ctx_err
+			}
+			return nil, "", 0, fmt.Errorf("auth_v4.RawReqWithContext: %s: %w", cred_err.Error(), ErrCredentials)
+		}
+		use_iam = false
+	}
+	return rawReqAll(ctx, client, reqJSON, amzTarget, serviceFor(ctx, c), v, use_iam)
+}
+
+// RawReqWithConf will sign and transmit the request to the AWS DynamoDB endpoint.
+// It is the same as RawReqWithContext with a background context.
+func RawReqWithConf(reqJSON []byte, amzTarget string, c *conf.AWS_Conf) ([]byte, string, int, error) {
+	return RawReqWithContext(context.Background(), reqJSON, amzTarget, c)
 }
 
 // RawReq will sign and transmit the request to the AWS DynamoDB endpoint.
@@ -225,3 +278,9 @@ func Req(reqJSON []byte, amzTarget string) ([]byte, string, int, error) {
 func ReqWithConf(reqJSON []byte, amzTarget string, c *conf.AWS_Conf) ([]byte, string, int, error) {
 	return RawReqWithConf(reqJSON, amzTarget, c)
 }
+
+// ReqWithContext is just a wrapper for RawReqWithContext if we need to massage data
+// before dispatch. Uses parameterized conf and context.
+func ReqWithContext(ctx context.Context, reqJSON []byte, amzTarget string, c *conf.AWS_Conf) ([]byte, string, int, error) {
+	return RawReqWithContext(ctx, reqJSON, amzTarget, c)
+}
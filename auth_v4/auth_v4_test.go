@@ -0,0 +1,46 @@
+package auth_v4
+
+import (
+	"github.com/smugmug/godynamo/conf"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const benchGetItem = `{"TableName":"Thread","Key":{"ForumName":{"S":"Amazon DynamoDB"},"Subject":{"S":"DynamoDB Thread 1"}},"ConsistentRead":true}`
+
+// answerTransport answers every request with an empty item, without a network round
+// trip, so that benchmarks measure the work of the client alone.
+type answerTransport struct{}
+
+func (answerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	io.Copy(io.Discard, r.Body)
+	r.Body.Close()
+	h := make(http.Header)
+	h.Set("X-Amzn-Requestid", "BENCHREQID")
+	return &http.Response{StatusCode: http.StatusOK, Header: h, Body: io.NopCloser(strings.NewReader(`{}`)),
+		ContentLength: 2, Request: r}, nil
+}
+
+// BenchmarkGetItem measures the requests a client can sign and dispatch, as a service
+// sending many small GetItem requests does.
+func BenchmarkGetItem(b *testing.B) {
+	c := new(conf.AWS_Conf)
+	c.Auth.AccessKey = "myAccessKey"
+	c.Auth.Secret = "mySecret"
+	c.Network.DynamoDB.URL = "http://127.0.0.1:8000"
+	c.Network.DynamoDB.Host = "127.0.0.1"
+	c.Network.DynamoDB.Port = "8000"
+	c.Network.DynamoDB.Zone = "us-east-1"
+	c.HTTPClient = &http.Client{Transport: answerTransport{}}
+	c.Initialized = true
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, _, code, err := RawReqWithConf([]byte(benchGetItem), "DynamoDB_20120810.GetItem", c); err != nil || code != http.StatusOK {
+				b.Fatal(code, err)
+			}
+		}
+	})
+}
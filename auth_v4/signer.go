@@ -0,0 +1,196 @@
+package auth_v4
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/auth_v4/tasks"
+	"github.com/smugmug/godynamo/aws_const"
+	"github.com/smugmug/godynamo/credentials"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ALGORITHM = "AWS4-HMAC-SHA256"
+	// signed in place of the hash of a body that is not signed
+	UNSIGNED_PAYLOAD = "UNSIGNED-PAYLOAD"
+	// the header S3 expects the hash of the body in
+	CONTENT_SHA256_HDR = "X-Amz-Content-Sha256"
+	// the longest a presigned URL may be valid
+	PRESIGN_MAX = 7 * 24 * time.Hour
+)
+
+// unsignedHeaders are never signed, as clients and proxies may change them in transit.
+var unsignedHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+}
+
+// Signer signs http requests to any AWS service with signature version 4, by adding an
+// Authorization header or by presigning a URL. Every header of the request is signed,
+// except Authorization, User-Agent and X-Amzn-Trace-Id.
+//
+// example use:
+//
+//	s := auth_v4.NewSigner(v, "sqs", "us-east-1")
+//	req, _ := http.NewRequest("GET", "https://sqs.us-east-1.amazonaws.com/?Action=ListQueues", nil)
+//	err := s.Sign(req, time.Now())
+type Signer struct {
+	Credentials credentials.Value
+	// The service signed for, such as "dynamodb" or "s3".
+	Service string
+	// The region signed for, such as "us-east-1".
+	Region string
+	// If set, the body is not signed.
+	UnsignedPayload bool
+	// If set, the path is signed as it is escaped rather than normalized and escaped
+	// again. Set it for S3.
+	DisableURIPathEscaping bool
+	// If set, the hash of the body is sent in X-Amz-Content-Sha256. Set it for S3.
+	PayloadHashHeader bool
+}
+
+// NewSigner returns a Signer of the requests to service in region with v.
+func NewSigner(v credentials.Value, service, region string) *Signer {
+	return &Signer{Credentials: v, Service: service, Region: region}
+}
+
+// Sign adds the X-Amz-Date, X-Amz-Security-Token (for temporary credentials) and
+// Authorization headers to r, signing it as of t. The body of r is read to hash it, and
+// replaced if r cannot reproduce it with GetBody.
+func (s *Signer) Sign(r *http.Request, t time.Time) error {
+	if s == nil || r == nil || r.URL == nil {
+		return errors.New("auth_v4.(Signer)Sign: receiver or r is nil")
+	}
+	payload, payload_err := s.payloadHash(r)
+	if payload_err != nil {
+		return payload_err
+	}
+	return s.sign(r, t, payload)
+}
+
+// sign signs r as Sign does, with the hash of its body already computed.
+func (s *Signer) sign(r *http.Request, t time.Time, payload string) error {
+	if !s.Credentials.HasKeys() {
+		return fmt.Errorf("auth_v4.(Signer)Sign: no Access Key or Secret defined: %w", ErrCredentials)
+	}
+	r.Header.Del("Authorization")
+	r.Header.Set(aws_const.X_AMZ_DATE_HDR, t.UTC().Format(aws_const.ISO8601FMT_CONDENSED))
+	if s.Credentials.SessionToken != "" {
+		r.Header.Set(aws_const.X_AMZ_SECURITY_TOKEN_HDR, s.Credentials.SessionToken)
+	}
+	if s.PayloadHashHeader {
+		r.Header.Set(CONTENT_SHA256_HDR, payload)
+	}
+	headers := signedHeaders(r)
+	signature := s.signature(r, headers, payload, t)
+	r.Header.Set("Authorization", ALGORITHM+" Credential="+s.Credentials.AccessKeyID+"/"+s.scope(t)+
+		", SignedHeaders="+strings.Join(headers, ";")+", Signature="+signature)
+	return nil
+}
+
+// Presign returns the URL of r with a signature in its query string, valid for expires
+// from t. Any headers set on r are signed and must be sent with the URL. r is not
+// changed.
+func (s *Signer) Presign(r *http.Request, expires time.Duration, t time.Time) (string, error) {
+	if s == nil || r == nil || r.URL == nil {
+		return "", errors.New("auth_v4.(Signer)Presign: receiver or r is nil")
+	}
+	if !s.Credentials.HasKeys() {
+		return "", fmt.Errorf("auth_v4.(Signer)Presign: no Access Key or Secret defined: %w", ErrCredentials)
+	}
+	if expires < time.Second || expires > PRESIGN_MAX {
+		e := fmt.Sprintf("auth_v4.(Signer)Presign: expires must be between 1s and %v, not %v", PRESIGN_MAX, expires)
+		return "", errors.New(e)
+	}
+	payload := UNSIGNED_PAYLOAD
+	if !s.UnsignedPayload {
+		var payload_err error
+		payload, payload_err = s.payloadHash(r)
+		if payload_err != nil {
+			return "", payload_err
+		}
+	}
+	p := r.Clone(r.Context())
+	p.Header.Del("Authorization")
+	headers := signedHeaders(p)
+	q := p.URL.Query()
+	q.Set("X-Amz-Algorithm", ALGORITHM)
+	q.Set("X-Amz-Credential", s.Credentials.AccessKeyID+"/"+s.scope(t))
+	q.Set("X-Amz-Date", t.UTC().Format(aws_const.ISO8601FMT_CONDENSED))
+	q.Set("X-Amz-Expires", strconv.Itoa(int(expires/time.Second)))
+	q.Set("X-Amz-SignedHeaders", strings.Join(headers, ";"))
+	if s.Credentials.SessionToken != "" {
+		q.Set("X-Amz-Security-Token", s.Credentials.SessionToken)
+	}
+	p.URL.RawQuery = q.Encode()
+	signature := s.signature(p, headers, payload, t)
+	p.URL.RawQuery += "&X-Amz-Signature=" + signature
+	return p.URL.String(), nil
+}
+
+// scope returns the credential scope of a signature made at t.
+func (s *Signer) scope(t time.Time) string {
+	return t.UTC().Format(aws_const.ISODATEFMT) + "/" + s.Region + "/" + s.Service + "/aws4_request"
+}
+
+// signature returns the signature of r and the headers named, made at t.
+func (s *Signer) signature(r *http.Request, headers []string, payload string, t time.Time) string {
+	canonical_request := tasks.CanonicalHTTPRequest(r, headers, payload, !s.DisableURIPathEscaping)
+	str2sign := tasks.String2Sign(t, canonical_request, s.Region, s.Service)
+	return tasks.SignWithKey(tasks.CachedSigningKey(t, s.Region, s.Service, s.Credentials.SecretAccessKey), str2sign)
+}
+
+// payloadHash returns the hex sha256 of the body of r, or UNSIGNED_PAYLOAD.
+func (s *Signer) payloadHash(r *http.Request) (string, error) {
+	if s.UnsignedPayload {
+		return UNSIGNED_PAYLOAD, nil
+	}
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		var read_err error
+		if r.GetBody != nil {
+			rc, get_err := r.GetBody()
+			if get_err != nil {
+				return "", get_err
+			}
+			body, read_err = io.ReadAll(rc)
+			rc.Close()
+		} else {
+			body, read_err = io.ReadAll(r.Body)
+			r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(body)), nil
+			}
+		}
+		if read_err != nil {
+			e := fmt.Sprintf("auth_v4.(Signer)Sign: cannot read the body: %s", read_err.Error())
+			return "", errors.New(e)
+		}
+	}
+	h := sha256.Sum256(body)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// signedHeaders returns the lower case names of the headers of r to sign, sorted.
+func signedHeaders(r *http.Request) []string {
+	headers := make([]string, 1, len(r.Header)+1)
+	headers[0] = "host"
+	for k := range r.Header {
+		lk := strings.ToLower(k)
+		if !unsignedHeaders[lk] && lk != "host" {
+			headers = append(headers, lk)
+		}
+	}
+	sort.Strings(headers)
+	return headers
+}
@@ -0,0 +1,133 @@
+package tasks
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// CanonicalHTTPRequest will create the aws v4 `canonical request` of any http request,
+// signing the headers named in signedHeaders, which must be lower case and sorted.
+// hexPayload is the hex sha256 of the body, or UNSIGNED-PAYLOAD. If escapePath is set,
+// the path is normalized and URI-encoded, as every service but S3 expects; otherwise it
+// is used as it was escaped.
+func CanonicalHTTPRequest(r *http.Request, signedHeaders []string, hexPayload string, escapePath bool) string {
+	var b strings.Builder
+	b.Grow(256 + len(hexPayload) + len(r.URL.RawQuery))
+	b.WriteString(r.Method)
+	b.WriteByte('\n')
+	b.WriteString(CanonicalURI(r.URL, escapePath))
+	b.WriteByte('\n')
+	b.WriteString(CanonicalQuery(r.URL.RawQuery))
+	b.WriteByte('\n')
+	for _, h := range signedHeaders {
+		b.WriteString(h)
+		b.WriteByte(':')
+		if h == "host" {
+			b.WriteString(host(r))
+		} else {
+			for i, v := range r.Header[http.CanonicalHeaderKey(h)] {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				writeTrimmed(&b, v)
+			}
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	b.WriteString(strings.Join(signedHeaders, ";"))
+	b.WriteByte('\n')
+	b.WriteString(hexPayload)
+	return b.String()
+}
+
+// writeTrimmed writes v to b without its leading and trailing spaces, and with each run
+// of spaces within it collapsed to one.
+func writeTrimmed(b *strings.Builder, v string) {
+	v = strings.TrimSpace(v)
+	space := false
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteByte(c)
+	}
+}
+
+// host returns the host a request is sent to.
+func host(r *http.Request) string {
+	if r.Host != "" {
+		return r.Host
+	}
+	return r.URL.Host
+}
+
+// CanonicalURI returns the path of u as it is signed. See CanonicalHTTPRequest.
+func CanonicalURI(u *url.URL, escapePath bool) string {
+	if !escapePath {
+		p := u.EscapedPath()
+		if p == "" {
+			return "/"
+		}
+		return p
+	}
+	p := u.Path
+	if p == "" {
+		return "/"
+	}
+	cleaned := path.Clean(p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return URIEncode(cleaned, false)
+}
+
+// CanonicalQuery returns the query string rawQuery sorted and encoded as it is signed.
+func CanonicalQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, _ := url.ParseQuery(rawQuery)
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		vs := values[k]
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, URIEncode(k, true)+"="+URIEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// URIEncode percent-encodes every byte of s but the unreserved characters of RFC 3986,
+// and '/' unless encodeSlash is set.
+func URIEncode(s string, encodeSlash bool) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&15])
+	}
+	return b.String()
+}
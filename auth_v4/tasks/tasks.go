@@ -10,14 +10,45 @@ import (
 	"github.com/smugmug/godynamo/aws_const"
 	"hash"
 	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	// the most signing keys cached; the cache is emptied when it is full
+	SIGNING_KEY_CACHE_MAX = 64
+)
+
+// signingKeyID identifies the signing keys of a secret, zone and service. The secret is
+// kept as its SHA-256, so that the cache holds no plaintext secrets.
+type signingKeyID struct {
+	secret        [sha256.Size]byte
+	zone, service string
+}
+
+// signingKeyEntry is a cached signing key and the UTC day it was derived for.
+type signingKeyEntry struct {
+	year, day int
+	key       []byte
+}
+
+// signingKeys caches the signing keys of the current day.
+var signingKeys = struct {
+	sync.RWMutex
+	m map[signingKeyID]signingKeyEntry
+}{m: make(map[signingKeyID]signingKeyEntry)}
+
 // MakeSignature returns a auth_v4 signature from the `string to sign` variable.
 // May be useful for creating v4 requests for services other than DynamoDB.
 func MakeSignature(string2sign, zone, service, secret string) string {
 	kCredentials, _ := cacheable_hmacs(zone, service, secret)
-	var kSigning_hmac_sha256 hash.Hash = hmac.New(sha256.New, kCredentials)
+	return SignWithKey(kCredentials, string2sign)
+}
+
+// SignWithKey returns the auth_v4 signature of the `string to sign` with a key made by
+// SigningKey.
+func SignWithKey(key []byte, string2sign string) string {
+	var kSigning_hmac_sha256 hash.Hash = hmac.New(sha256.New, key)
 	kSigning_hmac_sha256.Write([]byte(string2sign))
 	kSigning := kSigning_hmac_sha256.Sum(nil)
 	return hex.EncodeToString(kSigning)
@@ -27,7 +58,37 @@ func MakeSignature(string2sign, zone, service, secret string) string {
 // that describes its time of creation.
 func cacheable_hmacs(zone, service, secret string) ([]byte, string) {
 	t := time.Now().UTC()
-	gmt_yyyymmdd := t.Format(aws_const.ISODATEFMT)
+	return CachedSigningKey(t, zone, service, secret), t.Format(aws_const.ISODATEFMT)
+}
+
+// CachedSigningKey returns SigningKey(t, zone, service, secret), deriving it only once
+// per UTC day. A rotated secret has keys of its own, so they are derived anew.
+// The returned slice must not be modified.
+func CachedSigningKey(t time.Time, zone, service, secret string) []byte {
+	t = t.UTC()
+	year, day := t.Year(), t.YearDay()
+	id := signingKeyID{secret: sha256.Sum256([]byte(secret)), zone: zone, service: service}
+	signingKeys.RLock()
+	e, ok := signingKeys.m[id]
+	signingKeys.RUnlock()
+	if ok && e.year == year && e.day == day {
+		return e.key
+	}
+	key := SigningKey(t, zone, service, secret)
+	signingKeys.Lock()
+	if len(signingKeys.m) >= SIGNING_KEY_CACHE_MAX {
+		// the keys of rotated secrets are dropped along with the others
+		signingKeys.m = make(map[signingKeyID]signingKeyEntry)
+	}
+	signingKeys.m[id] = signingKeyEntry{year: year, day: day, key: key}
+	signingKeys.Unlock()
+	return key
+}
+
+// SigningKey derives the key that signs the requests to service in zone on the UTC
+// date of t.
+func SigningKey(t time.Time, zone, service, secret string) []byte {
+	gmt_yyyymmdd := t.UTC().Format(aws_const.ISODATEFMT)
 
 	init_secret := []byte("AWS4" + secret)
 	var kDate_hmac_sha256 hash.Hash = hmac.New(sha256.New, init_secret)
@@ -44,7 +105,7 @@ func cacheable_hmacs(zone, service, secret string) ([]byte, string) {
 
 	var kCredentials_hmac_sha256 hash.Hash = hmac.New(sha256.New, kService)
 	kCredentials_hmac_sha256.Write([]byte("aws4_request"))
-	return kCredentials_hmac_sha256.Sum(nil), gmt_yyyymmdd
+	return kCredentials_hmac_sha256.Sum(nil)
 }
 
 // CanonicalRequest will create the aws v4 `canonical request`.
@@ -1,6 +1,7 @@
 package tasks
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"hash"
@@ -45,3 +46,44 @@ func TestStringToSign(t *testing.T) {
 		t.Errorf("string 2 sign unexpected")
 	}
 }
+
+func TestCachedSigningKey(t *testing.T) {
+	now := time.Date(2015, 8, 30, 23, 59, 59, 0, time.UTC)
+	key := CachedSigningKey(now, "us-east-1", "dynamodb", "secret")
+	if !bytes.Equal(key, SigningKey(now, "us-east-1", "dynamodb", "secret")) {
+		t.Errorf("cached signing key unexpected")
+	}
+	if !bytes.Equal(key, CachedSigningKey(now.Add(-time.Hour), "us-east-1", "dynamodb", "secret")) {
+		t.Errorf("signing key should be the same within a day")
+	}
+	tomorrow := now.Add(time.Second)
+	if !bytes.Equal(CachedSigningKey(tomorrow, "us-east-1", "dynamodb", "secret"),
+		SigningKey(tomorrow, "us-east-1", "dynamodb", "secret")) || bytes.Equal(key, SigningKey(tomorrow, "us-east-1", "dynamodb", "secret")) {
+		t.Errorf("signing key should roll over at midnight")
+	}
+	if bytes.Equal(key, CachedSigningKey(now, "us-east-1", "dynamodb", "rotated")) {
+		t.Errorf("a rotated secret should have its own signing key")
+	}
+	signingKeys.RLock()
+	_, ok := signingKeys.m[signingKeyID{secret: sha256.Sum256([]byte("secret")), zone: "us-east-1", service: "dynamodb"}]
+	signingKeys.RUnlock()
+	if !ok {
+		t.Errorf("signing keys should be cached by the hash of the secret")
+	}
+}
+
+func BenchmarkSigningKey(b *testing.B) {
+	now := time.Now()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		SigningKey(now, "us-east-1", "dynamodb", "mySecret")
+	}
+}
+
+func BenchmarkCachedSigningKey(b *testing.B) {
+	now := time.Now()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		CachedSigningKey(now, "us-east-1", "dynamodb", "mySecret")
+	}
+}
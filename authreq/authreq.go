@@ -4,16 +4,16 @@ package authreq
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/smugmug/godynamo/auth_v4"
-	"github.com/smugmug/godynamo/aws_const"
 	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/dynamoerr"
 	ep "github.com/smugmug/godynamo/endpoint"
+	"github.com/smugmug/godynamo/retry"
 	"log"
-	"math"
-	"math/rand"
 	"net/http"
 	"time"
 )
@@ -27,142 +27,281 @@ const (
 // Stipulate the current authorization version.
 const AUTH_VERSION = AUTH_V4
 
-var (
-	exceeded_msg_bytes, unrecognized_client_msg_bytes, throttling_msg_bytes []byte
-)
-
 func init() {
 	if AUTH_VERSION != AUTH_V4 {
 		panic("authreq: only v4 authentication is enabled")
 	}
-	// convert these to []byte so we can search within responses
-	exceeded_msg_bytes = []byte(aws_const.EXCEEDED_MSG)
-	unrecognized_client_msg_bytes = []byte(aws_const.UNRECOGNIZED_CLIENT_MSG)
-	throttling_msg_bytes = []byte(aws_const.THROTTLING_MSG)
 }
 
 // RetryReq_V4 sends a retry-able request using an ep.Endpoint structure and v4 auth.
 // Uses the global conf.
 func RetryReq_V4(v ep.Endpoint, amzTarget string) ([]byte, int, error) {
-	if !conf.IsValid(&conf.Vals) {
-		return nil, 0, errors.New("authreq.RetryReq_V4: conf not valid")
-	}
-	reqJSON, json_err := json.Marshal(v)
-	if json_err != nil {
-		return nil, 0, json_err
-	}
-	return retryReq(reqJSON, amzTarget, &conf.Vals)
+	return RetryReq_V4WithContext(context.Background(), v, amzTarget, &conf.Vals)
 }
 
 // RetryReq_V4 sends a retry-able request using a JSON serialized request and v4 auth.
 // Uses the global conf.
 func RetryReqJSON_V4(reqJSON []byte, amzTarget string) ([]byte, int, error) {
-	if !conf.IsValid(&conf.Vals) {
-		return nil, 0, errors.New("authreq.RetryReqJSON_V4: conf not valid")
-	}
-	return retryReq(reqJSON, amzTarget, &conf.Vals)
+	return RetryReqJSON_V4WithContext(context.Background(), reqJSON, amzTarget, &conf.Vals)
 }
 
 // RetryReq_V4 sends a retry-able request using an ep.Endpoint structure and v4 auth.
 // Uses a parameterized conf.
 func RetryReq_V4WithConf(v ep.Endpoint, amzTarget string, c *conf.AWS_Conf) ([]byte, int, error) {
+	return RetryReq_V4WithContext(context.Background(), v, amzTarget, c)
+}
+
+// RetryReq_V4 sends a retry-able request using a JSON serialized request and v4 auth.
+// Uses a parameterized conf.
+func RetryReqJSON_V4WithConf(reqJSON []byte, amzTarget string, c *conf.AWS_Conf) ([]byte, int, error) {
+	return RetryReqJSON_V4WithContext(context.Background(), reqJSON, amzTarget, c)
+}
+
+// RetryReq_V4WithContext sends a retry-able request using an ep.Endpoint structure and v4 auth.
+// Uses a parameterized conf. The retry loop is abandoned as soon as ctx is done.
+func RetryReq_V4WithContext(ctx context.Context, v ep.Endpoint, amzTarget string, c *conf.AWS_Conf) ([]byte, int, error) {
+	if ctx == nil {
+		return nil, 0, errors.New("authreq.RetryReq_V4WithContext: ctx is nil")
+	}
 	if !conf.IsValid(c) {
-		return nil, 0, errors.New("authreq.RetryReqV4WithConf: conf not valid")
+		return nil, 0, errors.New("authreq.RetryReq_V4WithContext: conf not valid")
 	}
 	reqJSON, json_err := json.Marshal(v)
 	if json_err != nil {
 		return nil, 0, json_err
 	}
-	return retryReq(reqJSON, amzTarget, c)
+	return retryReq(ctx, reqJSON, amzTarget, c)
 }
 
-// RetryReq_V4 sends a retry-able request using a JSON serialized request and v4 auth.
-// Uses a parameterized conf.
-func RetryReqJSON_V4WithConf(reqJSON []byte, amzTarget string, c *conf.AWS_Conf) ([]byte, int, error) {
+// RetryReqJSON_V4WithContext sends a retry-able request using a JSON serialized request and v4 auth.
+// Uses a parameterized conf. The retry loop is abandoned as soon as ctx is done.
+func RetryReqJSON_V4WithContext(ctx context.Context, reqJSON []byte, amzTarget string, c *conf.AWS_Conf) ([]byte, int, error) {
+	if ctx == nil {
+		return nil, 0, errors.New("authreq.RetryReqJSON_V4WithContext: ctx is nil")
+	}
+	if !conf.IsValid(c) {
+		return nil, 0, errors.New("authreq.RetryReqJSON_V4WithContext: conf not valid")
+	}
+	return retryReq(ctx, reqJSON, amzTarget, c)
+}
+
+// DoWithContext marshals req, sends it as RetryReqJSON_V4WithContext does and decodes
+// a successful response into resp. Error responses are returned as a *dynamoerr.APIError.
+// The Do methods of the endpoint packages and of godynamo.Client send their requests
+// through it.
+func DoWithContext(ctx context.Context, req interface{}, amzTarget string, c *conf.AWS_Conf, resp interface{}) error {
+	if ctx == nil {
+		return errors.New("authreq.DoWithContext: ctx is nil")
+	}
 	if !conf.IsValid(c) {
-		return nil, 0, errors.New("authreq.RetryReqJSON_V4WithConf: conf not valid")
+		return errors.New("authreq.DoWithContext: conf not valid")
+	}
+	reqJSON, json_err := json.Marshal(req)
+	if json_err != nil {
+		return json_err
+	}
+	body, code, err := retryReq(WithAPIErrors(ctx), reqJSON, amzTarget, c)
+	return ep.DecodeResponse(body, code, err, resp)
+}
+
+// sleepCtx waits for d to elapse. It returns early with an error if ctx is done first,
+// or immediately if ctx has a deadline that will pass before d elapses, as there is no
+// point in sleeping only to be cancelled before the next attempt can be made.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if deadline, has_deadline := ctx.Deadline(); has_deadline && time.Now().Add(d).After(deadline) {
+		return context.DeadlineExceeded
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// RetryError is returned when a request could not be completed within the retry policy,
+// or when the caller's context ended while retrying. It lists every attempt made.
+// The body and code of the last attempt are returned alongside it.
+type RetryError struct {
+	// The amzTarget of the request.
+	Target string
+	// Every attempt made, in order.
+	Attempts []retry.Attempt
+	// Cause is set if retrying was cut short by the context.
+	Cause error
+}
+
+func (e *RetryError) Error() string {
+	if e == nil {
+		return "authreq.RetryError: nil"
+	}
+	var b bytes.Buffer
+	if e.Cause != nil {
+		fmt.Fprintf(&b, "authreq.retryReq: %s aborted after %d attempts: %s",
+			e.Target, len(e.Attempts), e.Cause.Error())
+	} else {
+		fmt.Fprintf(&b, "authreq.retryReq: failed retries on %s after %d attempts",
+			e.Target, len(e.Attempts))
 	}
-	return retryReq(reqJSON, amzTarget, c)
+	for _, a := range e.Attempts {
+		fmt.Fprintf(&b, "; #%d code:%d reqid:%s elapsed:%v", a.Number, a.Code, a.RequestID, a.Elapsed)
+		if a.Err != nil {
+			fmt.Fprintf(&b, " err:%s", a.Err.Error())
+		}
+	}
+	return b.String()
+}
+
+// Unwrap returns the Cause if set, or else the transport error of the last attempt,
+// or else the *dynamoerr.APIError decoded from the last response, if any.
+func (e *RetryError) Unwrap() error {
+	if e == nil {
+		return nil
+	}
+	if e.Cause != nil {
+		return e.Cause
+	}
+	last := e.Last()
+	if last == nil {
+		return nil
+	}
+	if last.Err != nil {
+		return last.Err
+	}
+	if api_err := dynamoerr.New(last.Body, last.Code, last.RequestID); api_err != nil {
+		return api_err
+	}
+	return nil
+}
+
+// Last returns the final attempt, or nil if there were none.
+func (e *RetryError) Last() *retry.Attempt {
+	if e == nil || len(e.Attempts) == 0 {
+		return nil
+	}
+	return &e.Attempts[len(e.Attempts)-1]
+}
+
+type apiErrorsKey struct{}
+
+// WithAPIErrors returns a copy of ctx that requests error responses be returned as a
+// *dynamoerr.APIError, as if conf.AWS_Conf.APIErrors were set.
+func WithAPIErrors(ctx context.Context) context.Context {
+	return context.WithValue(ctx, apiErrorsKey{}, true)
+}
+
+// settings are the per-request options resolved from the context and the conf.
+type settings struct {
+	policy    retry.RetryPolicy
+	apiErrors bool
+	logger    conf.Logger
+	hooks     conf.Hooks
+}
+
+// settingsFor reads the options for a request. A retry policy or typed error flag set on
+// the context takes precedence over the conf. Unset values take the package defaults.
+func settingsFor(ctx context.Context, c *conf.AWS_Conf) settings {
+	c.ConfLock.RLock()
+	s := settings{
+		policy:    c.RetryPolicy,
+		apiErrors: c.APIErrors,
+		logger:    c.Logger,
+		hooks:     c.Hooks,
+	}
+	c.ConfLock.RUnlock()
+	if p, ok := retry.PolicyFromContext(ctx); ok {
+		s.policy = p
+	}
+	if v, ok := ctx.Value(apiErrorsKey{}).(bool); ok && v {
+		s.apiErrors = true
+	}
+	if s.policy == nil {
+		s.policy = retry.Default
+	}
+	if s.logger == nil {
+		s.logger = log.Default()
+	}
+	return s
 }
 
-// Implement exponential backoff for the req above in the case of 5xx errors
-// from aws. Algorithm is lifted from AWS docs.
+// Retry the req above in the case of 5xx errors and throttling from aws, as decided by
+// the retry.RetryPolicy in effect (see settingsFor). Each attempt is bound to ctx,
+// and the backoff sleeps abort as soon as ctx is done.
+// If the policy gives up while the last attempt is still a failure, its body and code
+// are returned along with a *RetryError. Other error responses are returned with a nil
+// error, unless typed errors were requested (see WithAPIErrors), in which case a
+// *dynamoerr.APIError is returned with them.
 // returns []byte respBody, int httpcode, error
-func retryReq(reqJSON []byte, amzTarget string, c *conf.AWS_Conf) ([]byte, int, error) {
+func retryReq(ctx context.Context, reqJSON []byte, amzTarget string, c *conf.AWS_Conf) ([]byte, int, error) {
 	// conf.IsValid has already been established by caller
-	resp_body, amz_requestid, code, resp_err := auth_v4.ReqWithConf(reqJSON, amzTarget, c)
-	shouldRetry := false
-	if resp_err != nil {
-		e := fmt.Sprintf("authreq.retryReq:0 "+
-			" try AuthReq Fail:%s (reqid:%s)", resp_err.Error(), amz_requestid)
-		log.Printf("authreq.retryReq: call err %s\n", e)
-		shouldRetry = true
-	}
-	// see:
-	// http://docs.aws.amazon.com/amazondynamodb/latest/developerguide/ErrorHandling.html
-	if code >= http.StatusInternalServerError {
-		shouldRetry = true // all 5xx codes are deemed retryable by amazon
-	}
-	if code == http.StatusBadRequest {
-		if bytes.Contains(resp_body, exceeded_msg_bytes) {
-			log.Printf("authreq.retryReq THROUGHPUT WARNING RETRY\n")
-			shouldRetry = true
-		} else if bytes.Contains(resp_body, unrecognized_client_msg_bytes) {
-			log.Printf("authreq.retryReq CLIENT WARNING RETRY\n")
-			shouldRetry = true
-		} else if bytes.Contains(resp_body, throttling_msg_bytes) {
-			log.Printf("authreq.retryReq THROUGHPUT WARNING RETRY\n")
-			shouldRetry = true
-		} else {
-			log.Printf("authreq.retryReq un-retryable err: %s\n%s (reqid:%s)\n",
-				string(resp_body), string(reqJSON), amz_requestid)
-			shouldRetry = false
-		}
+	if ctx_err := ctx.Err(); ctx_err != nil {
+		return nil, 0, fmt.Errorf("authreq.retryReq: %s not sent: %w", amzTarget, ctx_err)
 	}
-	if !shouldRetry {
-		// not retryable
-		return resp_body, code, resp_err
-	} else {
-		// retry the request RETRIES time in the case of a 5xx
-		// response, with an exponentially decayed sleep interval
-
-		// seed our rand number generator g
-		g := rand.New(rand.NewSource(time.Now().UnixNano()))
-		for i := 1; i < aws_const.RETRIES; i++ {
-			// get random delay from range
-			// [0..4**i*100 ms)
-			log.Printf("authreq.retryReq: BEGIN SLEEP %v (code:%v) (REQ:%s) (reqid:%s)",
-				time.Now(), code, string(reqJSON), amz_requestid)
-			r := time.Millisecond *
-				time.Duration(g.Int63n(int64(
-					math.Pow(4, float64(i)))*
-					100))
-			time.Sleep(r)
-			log.Printf("authreq.retryReq END SLEEP %v\n", time.Now())
-			shouldRetry = false
-			resp_body, amz_requestid, code, resp_err := auth_v4.ReqWithConf(reqJSON, amzTarget, c)
-			if resp_err != nil {
-				_ = fmt.Sprintf("authreq.retryReq:1 "+
-					" try AuthReq Fail:%s (reqid:%s)", resp_err.Error(), amz_requestid)
-				shouldRetry = true
+	opts := settingsFor(ctx, c)
+	policy := opts.policy
+	attempts := make([]retry.Attempt, 0, 1)
+	start := time.Now()
+	var prev_delay time.Duration
+	for n := 1; ; n++ {
+		if opts.hooks.BeforeRequest != nil {
+			opts.hooks.BeforeRequest(ctx, amzTarget, reqJSON)
+		}
+		resp_body, amz_requestid, code, resp_err := auth_v4.ReqWithContext(ctx, reqJSON, amzTarget, c)
+		attempts = append(attempts, retry.Attempt{
+			Number:    n,
+			Code:      code,
+			Body:      resp_body,
+			Err:       resp_err,
+			RequestID: amz_requestid,
+			Elapsed:   time.Since(start),
+			PrevDelay: prev_delay,
+		})
+		a := &attempts[len(attempts)-1]
+		if opts.hooks.AfterResponse != nil {
+			opts.hooks.AfterResponse(ctx, amzTarget, a)
+		}
+		if resp_err != nil {
+			if ctx_err := ctx.Err(); ctx_err != nil {
+				// the request failed because the caller gave up on it
+				return resp_body, code, &RetryError{Target: amzTarget, Attempts: attempts, Cause: ctx_err}
 			}
-			if code >= http.StatusInternalServerError {
-				shouldRetry = true
+			if errors.Is(resp_err, auth_v4.ErrCredentials) {
+				// the request was never sent
+				return resp_body, code, resp_err
 			}
-			if code == http.StatusBadRequest {
-				if bytes.Contains(resp_body, exceeded_msg_bytes) {
-					log.Printf("authreq.retryReq THROUGHPUT WARNING RETRY\n")
-					shouldRetry = true
-				}
+			opts.logger.Printf("authreq.retryReq: call err %d try AuthReq Fail:%s (reqid:%s)\n",
+				n, resp_err.Error(), amz_requestid)
+		}
+		if policy.ShouldRetry(a) {
+			delay := policy.NextDelay(a)
+			opts.logger.Printf("authreq.retryReq: BEGIN SLEEP %v %v (code:%v) (REQ:%s) (reqid:%s)",
+				time.Now(), delay, code, string(reqJSON), amz_requestid)
+			sleep_err := sleepCtx(ctx, delay)
+			if sleep_err != nil {
+				return resp_body, code, &RetryError{Target: amzTarget, Attempts: attempts, Cause: sleep_err}
 			}
-			if !shouldRetry {
-				// worked! no need to retry
-				log.Printf("authreq.retryReq RETRY LOOP SUCCESS")
-				return resp_body, code, resp_err
+			opts.logger.Printf("authreq.retryReq END SLEEP %v\n", time.Now())
+			prev_delay = delay
+			continue
+		}
+		if retry.Retryable(a) {
+			// the policy has given up on a request that is still failing
+			return resp_body, code, &RetryError{Target: amzTarget, Attempts: attempts}
+		}
+		retry.RecordSuccess(policy, a)
+		if n > 1 {
+			opts.logger.Printf("authreq.retryReq RETRY LOOP SUCCESS")
+		} else if code == http.StatusBadRequest {
+			opts.logger.Printf("authreq.retryReq un-retryable err: %s\n%s (reqid:%s)\n",
+				string(resp_body), string(reqJSON), amz_requestid)
+		}
+		if resp_err == nil && opts.apiErrors {
+			if api_err := dynamoerr.New(resp_body, code, amz_requestid); api_err != nil {
+				return resp_body, code, api_err
 			}
 		}
-		e := fmt.Sprintf("authreq.retryReq: failed retries on %s:%s",
-			amzTarget, string(reqJSON))
-		return nil, 0, errors.New(e)
+		return resp_body, code, resp_err
 	}
 }
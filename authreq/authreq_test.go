@@ -0,0 +1,190 @@
+package authreq
+
+import (
+	"context"
+	"errors"
+	"github.com/smugmug/godynamo/auth_v4"
+	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/credentials"
+	"github.com/smugmug/godynamo/dynamoerr"
+	"github.com/smugmug/godynamo/retry"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testConf returns a conf pointed at a local server that answers every request with
+// the given code and body.
+func testConf(t *testing.T, code int, body string, calls *int32) (*conf.AWS_Conf, func()) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		w.Header().Set("X-Amzn-Requestid", "TESTREQID")
+		w.WriteHeader(code)
+		w.Write([]byte(body))
+	}))
+	u, u_err := url.Parse(srv.URL)
+	if u_err != nil {
+		t.Fatal(u_err)
+	}
+	c := new(conf.AWS_Conf)
+	c.Auth.AccessKey = "myAccessKey"
+	c.Auth.Secret = "mySecret"
+	c.Network.DynamoDB.URL = srv.URL
+	c.Network.DynamoDB.Host = u.Hostname()
+	c.Network.DynamoDB.Port = u.Port()
+	c.Network.DynamoDB.Zone = "us-east-1"
+	c.Initialized = true
+	return c, srv.Close
+}
+
+func TestRetryErrorListsAttempts(t *testing.T) {
+	var calls int32
+	c, done := testConf(t, http.StatusInternalServerError, `{"message":"boom"}`, &calls)
+	defer done()
+	p := retry.NewFullJitter()
+	p.MaxAttempts = 3
+	p.Base = time.Millisecond
+	c.RetryPolicy = p
+	body, code, err := RetryReqJSON_V4WithConf([]byte(`{}`), "DynamoDB_20120810.GetItem", c)
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+	if code != http.StatusInternalServerError || string(body) != `{"message":"boom"}` {
+		t.Errorf("last response should be returned, got %d %s", code, string(body))
+	}
+	var retry_err *RetryError
+	if !errors.As(err, &retry_err) {
+		t.Fatalf("expected *RetryError, got %v", err)
+	}
+	if len(retry_err.Attempts) != 3 || retry_err.Last().RequestID != "TESTREQID" {
+		t.Errorf("attempts not recorded: %s", retry_err.Error())
+	}
+}
+
+func TestContextPolicyOverridesConf(t *testing.T) {
+	var calls int32
+	c, done := testConf(t, http.StatusServiceUnavailable, `{}`, &calls)
+	defer done()
+	ctx := retry.WithPolicy(context.Background(), retry.NoRetry{})
+	_, _, err := RetryReqJSON_V4WithContext(ctx, []byte(`{}`), "DynamoDB_20120810.GetItem", c)
+	if calls != 1 {
+		t.Errorf("expected 1 attempt, got %d", calls)
+	}
+	if err == nil {
+		t.Errorf("exhausted retries should be an error")
+	}
+}
+
+func TestUnretryableReturnsBody(t *testing.T) {
+	var calls int32
+	b := `{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`
+	c, done := testConf(t, http.StatusBadRequest, b, &calls)
+	defer done()
+	body, code, err := RetryReqJSON_V4WithConf([]byte(`{}`), "DynamoDB_20120810.PutItem", c)
+	if err != nil || code != http.StatusBadRequest || string(body) != b || calls != 1 {
+		t.Errorf("unexpected result %d %s %v (calls:%d)", code, string(body), err, calls)
+	}
+}
+
+func TestCancelDuringBackoff(t *testing.T) {
+	var calls int32
+	c, done := testConf(t, http.StatusInternalServerError, `{}`, &calls)
+	defer done()
+	p := retry.NewFullJitter()
+	p.Base = time.Hour
+	c.RetryPolicy = p
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := RetryReqJSON_V4WithContext(ctx, []byte(`{}`), "DynamoDB_20120810.GetItem", c)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("backoff was not abandoned")
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	var calls int32
+	b := `{"__type":"com.amazonaws.dynamodb.v20120810#ResourceNotFoundException","message":"Requested resource not found"}`
+	c, done := testConf(t, http.StatusBadRequest, b, &calls)
+	defer done()
+	_, _, err := RetryReqJSON_V4WithContext(WithAPIErrors(context.Background()), []byte(`{}`), "DynamoDB_20120810.GetItem", c)
+	if !errors.Is(err, dynamoerr.ErrResourceNotFound) {
+		t.Errorf("expected ErrResourceNotFound, got %v", err)
+	}
+	var api_err *dynamoerr.APIError
+	if !errors.As(err, &api_err) || api_err.RequestID != "TESTREQID" {
+		t.Errorf("expected request id in APIError, got %v", err)
+	}
+	c.APIErrors = true
+	body, _, err := RetryReqJSON_V4WithConf([]byte(`{}`), "DynamoDB_20120810.GetItem", c)
+	if !errors.Is(err, dynamoerr.ErrResourceNotFound) || string(body) != b {
+		t.Errorf("conf should enable typed errors, got %v", err)
+	}
+}
+
+func TestRetryErrorUnwrapsAPIError(t *testing.T) {
+	var calls int32
+	b := `{"__type":"com.amazonaws.dynamodb.v20120810#ProvisionedThroughputExceededException","message":"slow down"}`
+	c, done := testConf(t, http.StatusBadRequest, b, &calls)
+	defer done()
+	p := retry.NewFullJitter()
+	p.MaxAttempts = 2
+	p.Base = time.Millisecond
+	c.RetryPolicy = p
+	_, _, err := RetryReqJSON_V4WithConf([]byte(`{}`), "DynamoDB_20120810.GetItem", c)
+	if calls != 2 || !errors.Is(err, dynamoerr.ErrThrottled) {
+		t.Errorf("expected throttling after 2 attempts, got %v (calls:%d)", err, calls)
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	var calls int32
+	c, done := testConf(t, http.StatusOK, `{}`, &calls)
+	defer done()
+	// missing keys fail the request rather than panicking, and are not retried
+	c.Auth.Secret = ""
+	_, _, err := RetryReqJSON_V4WithConf([]byte(`{}`), "DynamoDB_20120810.GetItem", c)
+	if !errors.Is(err, auth_v4.ErrCredentials) || calls != 0 {
+		t.Errorf("expected ErrCredentials without a request, got %v (calls:%d)", err, calls)
+	}
+	c.Credentials = &credentials.StaticProvider{Value: credentials.Value{AccessKeyID: "k", SecretAccessKey: "s"}}
+	_, code, err := RetryReqJSON_V4WithConf([]byte(`{}`), "DynamoDB_20120810.GetItem", c)
+	if err != nil || code != http.StatusOK || calls != 1 {
+		t.Errorf("expected the provider's credentials to be used, got %d %v (calls:%d)", code, err, calls)
+	}
+	c.Credentials = credentials.NewChain()
+	_, _, err = RetryReqJSON_V4WithConf([]byte(`{}`), "DynamoDB_20120810.GetItem", c)
+	if !errors.Is(err, auth_v4.ErrCredentials) || calls != 1 {
+		t.Errorf("expected ErrCredentials without a request, got %v (calls:%d)", err, calls)
+	}
+}
+
+func TestDoWithContext(t *testing.T) {
+	var calls int32
+	c, done := testConf(t, http.StatusOK, `{"TableNames":["Forum"]}`, &calls)
+	defer done()
+	var resp struct {
+		TableNames []string
+	}
+	err := DoWithContext(context.Background(), map[string]int{"Limit": 1}, "DynamoDB_20120810.ListTables", c, &resp)
+	if err != nil || len(resp.TableNames) != 1 || resp.TableNames[0] != "Forum" {
+		t.Errorf("should decode, got %v %v", resp, err)
+	}
+	b := `{"__type":"com.amazonaws.dynamodb.v20120810#ResourceNotFoundException","message":"Requested resource not found"}`
+	c, done = testConf(t, http.StatusBadRequest, b, &calls)
+	defer done()
+	err = DoWithContext(context.Background(), map[string]int{"Limit": 1}, "DynamoDB_20120810.ListTables", c, &resp)
+	var api_err *dynamoerr.APIError
+	if !errors.As(err, &api_err) {
+		t.Errorf("expected an APIError without setting APIErrors, got %v", err)
+	}
+	if DoWithContext(context.Background(), map[string]int{"Limit": 1}, "DynamoDB_20120810.ListTables", new(conf.AWS_Conf), &resp) == nil {
+		t.Errorf("uninitialized conf should be an error")
+	}
+}
@@ -15,6 +15,9 @@ const (
 	DATE_HDR                 = "Date"
 	CURRENT_API_VERSION      = "DynamoDB_20120810"
 	ENDPOINT_PREFIX          = CURRENT_API_VERSION + "."
+	STREAMS_API_VERSION      = "DynamoDBStreams_20120810"
+	STREAMS_ENDPOINT_PREFIX  = STREAMS_API_VERSION + "."
+	STREAMS_HOST_PREFIX      = "streams."
 	X_AMZ_DATE_HDR           = "X-Amz-Date"
 	X_AMZ_SECURITY_TOKEN_HDR = "X-Amz-Security-Token"
 	X_AMZN_AUTHORIZATION_HDR = "X-Amzn-Authorization"
@@ -0,0 +1,426 @@
+package godynamo
+
+import (
+	"context"
+	"errors"
+	"github.com/smugmug/godynamo/auth_v4"
+	"github.com/smugmug/godynamo/authreq"
+	"github.com/smugmug/godynamo/conf"
+	ep "github.com/smugmug/godynamo/endpoint"
+	batch_get_item "github.com/smugmug/godynamo/endpoints/batch_get_item"
+	batch_write_item "github.com/smugmug/godynamo/endpoints/batch_write_item"
+	create_table "github.com/smugmug/godynamo/endpoints/create_table"
+	delete_item "github.com/smugmug/godynamo/endpoints/delete_item"
+	delete_table "github.com/smugmug/godynamo/endpoints/delete_table"
+	describe_table "github.com/smugmug/godynamo/endpoints/describe_table"
+	get_item "github.com/smugmug/godynamo/endpoints/get_item"
+	list_tables "github.com/smugmug/godynamo/endpoints/list_tables"
+	put_item "github.com/smugmug/godynamo/endpoints/put_item"
+	query "github.com/smugmug/godynamo/endpoints/query"
+	scan "github.com/smugmug/godynamo/endpoints/scan"
+	transact_get_items "github.com/smugmug/godynamo/endpoints/transact_get_items"
+	transact_write_items "github.com/smugmug/godynamo/endpoints/transact_write_items"
+	update_item "github.com/smugmug/godynamo/endpoints/update_item"
+	update_table "github.com/smugmug/godynamo/endpoints/update_table"
+	"github.com/smugmug/godynamo/retry"
+	"github.com/smugmug/godynamo/streams"
+	"github.com/smugmug/godynamo/types/item"
+	"net/http"
+)
+
+// Client owns a conf, an http client and the retry, logging and hook policies used to
+// talk to DynamoDB, so that several clients with different credentials and transports
+// may be used in one program without touching conf.Vals or auth_v4.Client.
+//
+// The methods of Client send their requests with Client.Conf() through
+// authreq.DoWithContext, as the Do methods of the endpoint packages do.
+type Client struct {
+	conf *conf.AWS_Conf
+}
+
+type options struct {
+	conf       *conf.AWS_Conf
+	httpClient *http.Client
+	policy     retry.RetryPolicy
+	logger     conf.Logger
+	hooks      *conf.Hooks
+}
+
+// Option configures a Client in NewClient.
+type Option func(*options)
+
+// WithConf sets the conf the client is built from. It is copied, so later changes to c
+// are not seen by the client. If not set, conf.Vals is copied.
+func WithConf(c *conf.AWS_Conf) Option {
+	return func(o *options) {
+		o.conf = c
+	}
+}
+
+// WithHTTPClient sets the http client used to send requests.
+// If not set, the client gets its own transport from auth_v4.NewHTTPClient.
+func WithHTTPClient(h *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = h
+	}
+}
+
+// WithTransport sets the RoundTripper used to send requests.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(o *options) {
+		o.httpClient = &http.Client{Transport: rt}
+	}
+}
+
+// WithRetryPolicy sets the retry policy. If not set, the policy of the conf is kept.
+func WithRetryPolicy(p retry.RetryPolicy) Option {
+	return func(o *options) {
+		o.policy = p
+	}
+}
+
+// WithLogger sets the logger. If not set, the logger of the conf is kept.
+func WithLogger(l conf.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithHooks sets the callbacks invoked around each attempt.
+func WithHooks(h conf.Hooks) Option {
+	return func(o *options) {
+		o.hooks = &h
+	}
+}
+
+// NewClient returns a Client configured with opts.
+func NewClient(opts ...Option) (*Client, error) {
+	var o options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	src := o.conf
+	if src == nil {
+		src = &conf.Vals
+	}
+	c := new(conf.AWS_Conf)
+	cp_err := c.Copy(src)
+	if cp_err != nil {
+		return nil, cp_err
+	}
+	if !conf.IsValid(c) {
+		return nil, errors.New("godynamo.NewClient: conf is not valid")
+	}
+	if o.httpClient != nil {
+		c.HTTPClient = o.httpClient
+	} else if c.HTTPClient == nil {
+		c.HTTPClient = auth_v4.NewHTTPClient()
+	}
+	if o.policy != nil {
+		c.RetryPolicy = o.policy
+	}
+	if o.logger != nil {
+		c.Logger = o.logger
+	}
+	if o.hooks != nil {
+		c.Hooks = *o.hooks
+	}
+	return &Client{conf: c}, nil
+}
+
+// Conf returns the conf owned by the client. Use its ConfLock when changing it,
+// for example to rotate credentials with CredentialsFromRoles.
+func (cl *Client) Conf() *conf.AWS_Conf {
+	if cl == nil {
+		return nil
+	}
+	return cl.conf
+}
+
+// EndpointReq sends any endpoint request and returns the raw body and code.
+func (cl *Client) EndpointReq(ctx context.Context, e ep.Endpoint) ([]byte, int, error) {
+	if cl == nil || e == nil {
+		return nil, 0, errors.New("godynamo.(Client)EndpointReq: cl or e is nil")
+	}
+	return e.EndpointReqWithContext(ctx, cl.conf)
+}
+
+func (cl *Client) BatchGetItem(ctx context.Context, req *batch_get_item.BatchGetItem) (*batch_get_item.Response, error) {
+	if cl == nil || req == nil {
+		return nil, errors.New("godynamo.(Client)BatchGetItem: cl or req is nil")
+	}
+	resp := batch_get_item.NewResponse()
+	if err := authreq.DoWithContext(ctx, req, batch_get_item.BATCHGET_ENDPOINT, cl.conf, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// DoBatchGet splits and retries a BatchGetItem request of any size,
+// see batch_get_item.DoBatchGetWithContext.
+func (cl *Client) DoBatchGet(ctx context.Context, req *batch_get_item.BatchGetItem) ([]byte, int, error) {
+	if cl == nil {
+		return nil, 0, errors.New("godynamo.(Client)DoBatchGet: receiver is nil")
+	}
+	return req.DoBatchGetWithContext(ctx, cl.conf)
+}
+
+// DoBatchGetResult is the same as DoBatchGet but reports the keys that could not be read,
+// and bounds its requests by o if not nil, see batch_get_item.DoBatchGetResultWithOptions.
+func (cl *Client) DoBatchGetResult(ctx context.Context, req *batch_get_item.BatchGetItem, o *batch_get_item.Options) (*batch_get_item.Result, error) {
+	if cl == nil {
+		return nil, errors.New("godynamo.(Client)DoBatchGetResult: receiver is nil")
+	}
+	return req.DoBatchGetResultWithOptions(ctx, o, cl.conf)
+}
+
+func (cl *Client) BatchWriteItem(ctx context.Context, req *batch_write_item.BatchWriteItem) (*batch_write_item.Response, error) {
+	if cl == nil || req == nil {
+		return nil, errors.New("godynamo.(Client)BatchWriteItem: cl or req is nil")
+	}
+	resp := batch_write_item.NewResponse()
+	if err := authreq.DoWithContext(ctx, req, batch_write_item.BATCHWRITE_ENDPOINT, cl.conf, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// DoBatchWrite splits and retries a BatchWriteItem request of any size,
+// see batch_write_item.DoBatchWriteWithContext.
+func (cl *Client) DoBatchWrite(ctx context.Context, req *batch_write_item.BatchWriteItem) ([]byte, int, error) {
+	if cl == nil {
+		return nil, 0, errors.New("godynamo.(Client)DoBatchWrite: receiver is nil")
+	}
+	return req.DoBatchWriteWithContext(ctx, cl.conf)
+}
+
+// DoBatchWriteResult is the same as DoBatchWrite but reports the requests that could not
+// be written, and bounds its requests by o if not nil,
+// see batch_write_item.DoBatchWriteResultWithOptions.
+func (cl *Client) DoBatchWriteResult(ctx context.Context, req *batch_write_item.BatchWriteItem, o *batch_write_item.Options) (*batch_write_item.Result, error) {
+	if cl == nil {
+		return nil, errors.New("godynamo.(Client)DoBatchWriteResult: receiver is nil")
+	}
+	return req.DoBatchWriteResultWithOptions(ctx, o, cl.conf)
+}
+
+// NewBatchWriter returns a batch_write_item.Writer that sends its batches with the
+// conf of the client.
+func (cl *Client) NewBatchWriter(cfg batch_write_item.WriterConfig) (*batch_write_item.Writer, error) {
+	if cl == nil {
+		return nil, errors.New("godynamo.(Client)NewBatchWriter: receiver is nil")
+	}
+	return batch_write_item.NewWriter(cfg, cl.conf)
+}
+
+func (cl *Client) CreateTable(ctx context.Context, req *create_table.CreateTable) (*create_table.Response, error) {
+	if cl == nil || req == nil {
+		return nil, errors.New("godynamo.(Client)CreateTable: cl or req is nil")
+	}
+	resp := create_table.NewResponse()
+	if err := authreq.DoWithContext(ctx, req, create_table.CREATETABLE_ENDPOINT, cl.conf, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (cl *Client) DeleteItem(ctx context.Context, req *delete_item.DeleteItem) (*delete_item.Response, error) {
+	if cl == nil || req == nil {
+		return nil, errors.New("godynamo.(Client)DeleteItem: cl or req is nil")
+	}
+	resp := delete_item.NewResponse()
+	if err := authreq.DoWithContext(ctx, req, delete_item.DELETEITEM_ENDPOINT, cl.conf, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (cl *Client) DeleteTable(ctx context.Context, req *delete_table.DeleteTable) (*delete_table.Response, error) {
+	if cl == nil || req == nil {
+		return nil, errors.New("godynamo.(Client)DeleteTable: cl or req is nil")
+	}
+	resp := delete_table.NewResponse()
+	if err := authreq.DoWithContext(ctx, req, delete_table.DELETETABLE_ENDPOINT, cl.conf, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (cl *Client) DescribeTable(ctx context.Context, req *describe_table.DescribeTable) (*describe_table.Response, error) {
+	if cl == nil || req == nil {
+		return nil, errors.New("godynamo.(Client)DescribeTable: cl or req is nil")
+	}
+	resp := describe_table.NewResponse()
+	if err := authreq.DoWithContext(ctx, req, describe_table.DESCTABLE_ENDPOINT, cl.conf, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// PollTableStatus polls a table for a specific status, see describe_table.PollTableStatusWithContext.
+func (cl *Client) PollTableStatus(ctx context.Context, tablename string, status string, tries int) (bool, error) {
+	if cl == nil {
+		return false, errors.New("godynamo.(Client)PollTableStatus: receiver is nil")
+	}
+	return describe_table.PollTableStatusWithContext(ctx, tablename, status, tries, cl.conf)
+}
+
+func (cl *Client) GetItem(ctx context.Context, req *get_item.GetItem) (*get_item.Response, error) {
+	if cl == nil || req == nil {
+		return nil, errors.New("godynamo.(Client)GetItem: cl or req is nil")
+	}
+	resp := get_item.NewResponse()
+	if err := authreq.DoWithContext(ctx, req, get_item.GETITEM_ENDPOINT, cl.conf, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (cl *Client) ListTables(ctx context.Context, req *list_tables.ListTables) (*list_tables.Response, error) {
+	if cl == nil || req == nil {
+		return nil, errors.New("godynamo.(Client)ListTables: cl or req is nil")
+	}
+	resp := list_tables.NewResponse()
+	if err := authreq.DoWithContext(ctx, req, list_tables.LISTTABLE_ENDPOINT, cl.conf, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ListTablesPaginator follows the pages of a ListTables, see list_tables.NewListTablesPaginator.
+func (cl *Client) ListTablesPaginator(req *list_tables.ListTables) *list_tables.ListTablesPaginator {
+	if cl == nil {
+		return list_tables.NewListTablesPaginator(req, nil)
+	}
+	return list_tables.NewListTablesPaginator(req, cl.conf)
+}
+
+func (cl *Client) PutItem(ctx context.Context, req *put_item.PutItem) (*put_item.Response, error) {
+	if cl == nil || req == nil {
+		return nil, errors.New("godynamo.(Client)PutItem: cl or req is nil")
+	}
+	resp := put_item.NewResponse()
+	if err := authreq.DoWithContext(ctx, req, put_item.PUTITEM_ENDPOINT, cl.conf, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (cl *Client) Query(ctx context.Context, req *query.Query) (*query.Response, error) {
+	if cl == nil || req == nil {
+		return nil, errors.New("godynamo.(Client)Query: cl or req is nil")
+	}
+	resp := query.NewResponse()
+	if err := authreq.DoWithContext(ctx, req, query.QUERY_ENDPOINT, cl.conf, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// QueryPaginator follows the pages of a Query, see query.NewQueryPaginator.
+func (cl *Client) QueryPaginator(req *query.Query) *query.QueryPaginator {
+	if cl == nil {
+		return query.NewQueryPaginator(req, nil)
+	}
+	return query.NewQueryPaginator(req, cl.conf)
+}
+
+func (cl *Client) Scan(ctx context.Context, req *scan.Scan) (*scan.Response, error) {
+	if cl == nil || req == nil {
+		return nil, errors.New("godynamo.(Client)Scan: cl or req is nil")
+	}
+	resp := scan.NewResponse()
+	if err := authreq.DoWithContext(ctx, req, scan.SCAN_ENDPOINT, cl.conf, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ScanPaginator follows the pages of a Scan, see scan.NewScanPaginator.
+func (cl *Client) ScanPaginator(req *scan.Scan) *scan.ScanPaginator {
+	if cl == nil {
+		return scan.NewScanPaginator(req, nil)
+	}
+	return scan.NewScanPaginator(req, cl.conf)
+}
+
+// ParallelScan scans s as segments segments at once, see scan.ParallelScan.
+func (cl *Client) ParallelScan(ctx context.Context, s *scan.Scan, segments, workers int, fn func(item.Item) error) ([]scan.SegmentProgress, error) {
+	if cl == nil {
+		return nil, errors.New("godynamo.(Client)ParallelScan: receiver is nil")
+	}
+	ps := scan.ParallelScanner{Conf: cl.conf}
+	return ps.Scan(ctx, s, segments, workers, fn)
+}
+
+func (cl *Client) TransactGetItems(ctx context.Context, req *transact_get_items.TransactGetItems) (*transact_get_items.Response, error) {
+	if cl == nil || req == nil {
+		return nil, errors.New("godynamo.(Client)TransactGetItems: cl or req is nil")
+	}
+	resp := transact_get_items.NewResponse()
+	if err := authreq.DoWithContext(ctx, req, transact_get_items.TRANSACTGETITEMS_ENDPOINT, cl.conf, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (cl *Client) TransactWriteItems(ctx context.Context, req *transact_write_items.TransactWriteItems) (*transact_write_items.Response, error) {
+	if cl == nil {
+		return nil, errors.New("godynamo.(Client)TransactWriteItems: receiver is nil")
+	}
+	return req.DoWithContext(ctx, cl.conf)
+}
+
+func (cl *Client) UpdateItem(ctx context.Context, req *update_item.UpdateItem) (*update_item.Response, error) {
+	if cl == nil || req == nil {
+		return nil, errors.New("godynamo.(Client)UpdateItem: cl or req is nil")
+	}
+	resp := update_item.NewResponse()
+	if err := authreq.DoWithContext(ctx, req, update_item.UPDATEITEM_ENDPOINT, cl.conf, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (cl *Client) UpdateTable(ctx context.Context, req *update_table.UpdateTable) (*update_table.Response, error) {
+	if cl == nil || req == nil {
+		return nil, errors.New("godynamo.(Client)UpdateTable: cl or req is nil")
+	}
+	resp := update_table.NewResponse()
+	if err := authreq.DoWithContext(ctx, req, update_table.UPDATETABLE_ENDPOINT, cl.conf, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// The DynamoDB Streams endpoints are sent to the streams endpoint of the conf.
+
+func (cl *Client) ListStreams(ctx context.Context, req *streams.ListStreams) (*streams.ListStreamsResponse, error) {
+	if cl == nil {
+		return nil, errors.New("godynamo.(Client)ListStreams: receiver is nil")
+	}
+	return req.DoWithContext(ctx, cl.conf)
+}
+
+func (cl *Client) DescribeStream(ctx context.Context, req *streams.DescribeStream) (*streams.DescribeStreamResponse, error) {
+	if cl == nil {
+		return nil, errors.New("godynamo.(Client)DescribeStream: receiver is nil")
+	}
+	return req.DoWithContext(ctx, cl.conf)
+}
+
+func (cl *Client) GetShardIterator(ctx context.Context, req *streams.GetShardIterator) (*streams.GetShardIteratorResponse, error) {
+	if cl == nil {
+		return nil, errors.New("godynamo.(Client)GetShardIterator: receiver is nil")
+	}
+	return req.DoWithContext(ctx, cl.conf)
+}
+
+func (cl *Client) GetRecords(ctx context.Context, req *streams.GetRecords) (*streams.GetRecordsResponse, error) {
+	if cl == nil {
+		return nil, errors.New("godynamo.(Client)GetRecords: receiver is nil")
+	}
+	return req.DoWithContext(ctx, cl.conf)
+}
@@ -0,0 +1,92 @@
+package godynamo
+
+import (
+	"context"
+	"fmt"
+	"github.com/smugmug/godynamo/conf"
+	list_tables "github.com/smugmug/godynamo/endpoints/list_tables"
+	"github.com/smugmug/godynamo/retry"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func testServer(t *testing.T, table string) (*httptest.Server, *conf.AWS_Conf) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amzn-Requestid", "TESTREQID")
+		fmt.Fprintf(w, `{"TableNames":["%s"]}`, table)
+	}))
+	u, u_err := url.Parse(srv.URL)
+	if u_err != nil {
+		t.Fatal(u_err)
+	}
+	c := new(conf.AWS_Conf)
+	c.Auth.AccessKey = "myAccessKey"
+	c.Auth.Secret = "mySecret"
+	c.Network.DynamoDB.URL = srv.URL
+	c.Network.DynamoDB.Host = u.Hostname()
+	c.Network.DynamoDB.Port = u.Port()
+	c.Network.DynamoDB.Zone = "us-east-1"
+	c.Initialized = true
+	return srv, c
+}
+
+type countingLogger struct {
+	n int32
+}
+
+func (l *countingLogger) Printf(format string, v ...interface{}) {
+	atomic.AddInt32(&l.n, 1)
+}
+
+func TestClientsCoexist(t *testing.T) {
+	srv1, c1 := testServer(t, "one")
+	defer srv1.Close()
+	srv2, c2 := testServer(t, "two")
+	defer srv2.Close()
+
+	var before, after int32
+	hooks := conf.Hooks{
+		BeforeRequest: func(ctx context.Context, amzTarget string, reqJSON []byte) {
+			atomic.AddInt32(&before, 1)
+		},
+		AfterResponse: func(ctx context.Context, amzTarget string, a *retry.Attempt) {
+			atomic.AddInt32(&after, 1)
+		},
+	}
+	cl1, err := NewClient(WithConf(c1), WithHooks(hooks), WithRetryPolicy(retry.NoRetry{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl2, err := NewClient(WithConf(c2), WithTransport(http.DefaultTransport), WithLogger(new(countingLogger)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cl1.Conf().HTTPClient == cl2.Conf().HTTPClient {
+		t.Errorf("clients should not share an http client")
+	}
+	if c1.HTTPClient != nil || c1.RetryPolicy != nil {
+		t.Errorf("the source conf should not be modified")
+	}
+	for i, cl := range []*Client{cl1, cl2} {
+		resp, resp_err := cl.ListTables(context.Background(), new(list_tables.ListTables))
+		if resp_err != nil {
+			t.Fatal(resp_err)
+		}
+		want := []string{"one", "two"}[i]
+		if len(resp.TableNames) != 1 || resp.TableNames[0] != want {
+			t.Errorf("client %d: expected %s, got %v", i, want, resp.TableNames)
+		}
+	}
+	if before != 1 || after != 1 {
+		t.Errorf("hooks should run once for the first client only, got %d %d", before, after)
+	}
+}
+
+func TestNewClientInvalidConf(t *testing.T) {
+	if _, err := NewClient(WithConf(new(conf.AWS_Conf))); err == nil {
+		t.Errorf("uninitialized conf should be an error")
+	}
+}
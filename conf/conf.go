@@ -8,14 +8,24 @@
 package conf
 
 import (
+	"context"
 	"errors"
 	roles "github.com/smugmug/goawsroles/roles"
+	"github.com/smugmug/godynamo/aws_const"
+	"github.com/smugmug/godynamo/credentials"
+	"github.com/smugmug/godynamo/retry"
+	"net/http"
+	"strings"
 	"sync"
+	"time"
 )
 
 const (
-	CONF_NAME          = "aws-config.json"
-	ROLE_PROVIDER_FILE = "file"
+	CONF_NAME                  = "aws-config.json"
+	ROLE_PROVIDER_FILE         = "file"
+	ROLE_PROVIDER_CHAIN        = "chain"
+	ROLE_PROVIDER_ASSUME_ROLE  = "assume_role"
+	ROLE_PROVIDER_WEB_IDENTITY = "web_identity"
 )
 
 // SDK_conf_File roughly matches the format as used by recent amazon SDKs, plus some additions.
@@ -50,9 +60,41 @@ type SDK_conf_file struct {
 				Use_iam bool
 				// The role provider is described in the goawsroles package.
 				// See: https://github.com/smugmug/goawsroles/
-				// Currently the only support is for the "file" provider, whereby
-				// roles data is written to local files.
+				// The "file" provider reads roles data written to local files.
+				// The "chain" provider tries the keys in this conf, the AWS_* environment
+				// variables, the shared credentials file, a credential_process command
+				// and the instance metadata endpoint, in that order; see the
+				// credentials package.
+				// The "assume_role" provider assumes Role_arn with STS AssumeRole,
+				// using the credentials of the "chain" provider.
+				// The "web_identity" provider assumes Role_arn with STS
+				// AssumeRoleWithWebIdentity, using the token in Web_identity_token_file.
 				Role_provider string
+				// If using the "chain" or "assume_role" role provider, the profile of the
+				// shared credentials file; AWS_PROFILE or "default" if not set.
+				Profile string
+				// If using the "assume_role" or "web_identity" role provider, the role
+				// to assume; for "web_identity", AWS_ROLE_ARN if not set.
+				Role_arn string
+				// Optional; identifies the session in the logs of the role's account.
+				Role_session_name string
+				// Optional; set if the role requires it of the accounts that assume it.
+				External_id string
+				// Optional; how long the credentials of the role last, in seconds.
+				Duration_seconds int
+				// If using the "web_identity" role provider, the file holding the
+				// token; AWS_WEB_IDENTITY_TOKEN_FILE if not set.
+				Web_identity_token_file string
+				// Optional; the STS endpoint. If not set, the regional endpoint of
+				// Sts_region is used, or the global endpoint.
+				Sts_endpoint string
+				// Optional; the region of STS.
+				Sts_region string
+				// Optional; if using the "chain", "assume_role" or "web_identity" role
+				// provider, how many seconds before expiry to refresh the credentials,
+				// and how many seconds to wait after a failed refresh.
+				Refresh_window_seconds int
+				Refresh_retry_seconds  int
 				// The identifier (filename, etc) for the IAM Access Key
 				Access_key string
 				// The identifier (filename, etc) for the IAM Secret Key
@@ -66,9 +108,35 @@ type SDK_conf_file struct {
 				Watch bool
 			}
 		}
+		// Optional; if Host is not set, the DynamoDB Streams endpoint is derived
+		// from the Dynamo_db settings.
+		Dynamo_db_streams struct {
+			// Your DynamoDB Streams hostname.
+			Host string
+			// Typically http or https, will have "://" appended.
+			Scheme string
+			// Port should correspond to the scheme.
+			Port int
+			// Your aws zone, if it differs from the Dynamo_db zone.
+			Zone string
+		}
 	}
 }
 
+// Logger is satisfied by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Hooks are optional callbacks invoked around every attempt sent to DynamoDB,
+// including retries. They must be safe for concurrent use.
+type Hooks struct {
+	// BeforeRequest is called before each attempt is sent.
+	BeforeRequest func(ctx context.Context, amzTarget string, reqJSON []byte)
+	// AfterResponse is called after each attempt completes.
+	AfterResponse func(ctx context.Context, amzTarget string, a *retry.Attempt)
+}
+
 // AWS_Conf is the structure used internally in godynamo.
 type AWS_Conf struct {
 	// Set to true if this struct is populated correctly.
@@ -91,6 +159,14 @@ type AWS_Conf struct {
 			Zone      string
 			URL       string
 		}
+		// DynamoDB Streams connection data, used by the streams package.
+		DynamoDBStreams struct {
+			Host   string
+			Scheme string
+			Port   string
+			Zone   string
+			URL    string
+		}
 	}
 	// If using syslogd
 	UseSysLog bool
@@ -100,6 +176,21 @@ type AWS_Conf struct {
 	IAM struct {
 		RoleProvider string
 		Watch        bool
+		// The profile read by the "chain" and "assume_role" role providers
+		Profile string
+		// The name of the provider the "chain" role provider took the credentials
+		// from, for diagnostics
+		Provider string
+		// The role assumed by the "assume_role" and "web_identity" role providers
+		STS struct {
+			RoleARN              string
+			RoleSessionName      string
+			ExternalID           string
+			Duration             time.Duration
+			WebIdentityTokenFile string
+			Endpoint             string
+			Region               string
+		}
 		// Tells you where the credentials can be read from
 		File struct {
 			AccessKey string
@@ -114,8 +205,35 @@ type AWS_Conf struct {
 			AccessKey string
 			Secret    string
 			Token     string
+			// When the credentials expire; zero if unknown
+			Expires time.Time
 		}
+		// How long before expiring credentials of the "chain", "assume_role" and
+		// "web_identity" role providers expire to refresh them, and how long to wait
+		// after a failed refresh; credentials.REFRESH_WINDOW and
+		// credentials.REFRESH_RETRY if 0.
+		RefreshWindow time.Duration
+		RefreshRetry  time.Duration
+		// If set, OnError is called with the errors of refreshing or watching the IAM
+		// credentials, which are otherwise logged. The last good credentials stay in use.
+		OnError func(error)
 	}
+	// If set, requests are signed with the credentials of this provider in place of
+	// Auth and IAM.Credentials. Wrap a provider of expiring credentials in a
+	// credentials.Cache so that they are refreshed before they expire.
+	Credentials credentials.Provider
+	// The retry policy used by authreq. If nil, retry.Default is used.
+	// A policy attached to the request context with retry.WithPolicy takes precedence.
+	RetryPolicy retry.RetryPolicy
+	// If true, error responses from DynamoDB are returned as a *dynamoerr.APIError
+	// alongside the body, rather than as a body with a nil error.
+	APIErrors bool
+	// The http client used to send requests. If nil, auth_v4.Client is used.
+	HTTPClient *http.Client
+	// The logger used by authreq. If nil, the standard log package is used.
+	Logger Logger
+	// Callbacks invoked around each attempt.
+	Hooks Hooks
 	// Lock used when accessing IAM values, which will change during execution.
 	// other values will persist for program duration so they can be read without locking.
 	ConfLock sync.RWMutex
@@ -134,6 +252,12 @@ func (c *AWS_Conf) Copy(s *AWS_Conf) error {
 	c.UseSysLog = s.UseSysLog
 	c.UseIAM = s.UseIAM
 	c.IAM = s.IAM
+	c.Credentials = s.Credentials
+	c.RetryPolicy = s.RetryPolicy
+	c.APIErrors = s.APIErrors
+	c.HTTPClient = s.HTTPClient
+	c.Logger = s.Logger
+	c.Hooks = s.Hooks
 	s.ConfLock.RUnlock()
 	c.ConfLock.Unlock()
 	return nil
@@ -194,3 +318,13 @@ func IsValid(c *AWS_Conf) bool {
 var (
 	Vals AWS_Conf
 )
+
+// StreamsHost returns the DynamoDB Streams hostname paired with the DynamoDB hostname
+// dynamo_host. AWS serves streams from the "streams." subdomain of the regional DynamoDB
+// host; any other host, such as a local DynamoDB, is assumed to serve both.
+func StreamsHost(dynamo_host string) string {
+	if strings.HasPrefix(dynamo_host, "dynamodb.") && strings.HasSuffix(dynamo_host, ".amazonaws.com") {
+		return aws_const.STREAMS_HOST_PREFIX + dynamo_host
+	}
+	return dynamo_host
+}
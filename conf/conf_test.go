@@ -29,3 +29,16 @@ func TestRolesMaster(t *testing.T) {
 		t.Errorf("UseIAM should be false")
 	}
 }
+
+func TestStreamsHost(t *testing.T) {
+	hosts := map[string]string{
+		"dynamodb.us-east-1.amazonaws.com": "streams.dynamodb.us-east-1.amazonaws.com",
+		"localhost":                        "localhost",
+		"dynamodb.internal":                "dynamodb.internal",
+	}
+	for host, expected := range hosts {
+		if s := StreamsHost(host); s != expected {
+			t.Errorf("StreamsHost(%s): expected %s, got %s", host, expected, s)
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 )
 
 // ReadConfFile will attempt to read in the conf file path passed as a parameter
@@ -68,6 +69,31 @@ func ReadConfFile(conf_file string) (*conf.AWS_Conf, error) {
 		return nil, errors.New("conf_file.ReadConfFile: conf.Network.DynamoDB.URL malformed")
 	}
 
+	// the streams endpoint defaults to the one paired with the dynamo endpoint
+	streams := cf.Services.Dynamo_db_streams
+	c.Network.DynamoDBStreams.Host = streams.Host
+	if c.Network.DynamoDBStreams.Host == "" {
+		c.Network.DynamoDBStreams.Host = conf.StreamsHost(c.Network.DynamoDB.Host)
+	}
+	c.Network.DynamoDBStreams.Zone = c.Network.DynamoDB.Zone
+	if streams.Zone != "" {
+		c.Network.DynamoDBStreams.Zone = streams.Zone
+	}
+	c.Network.DynamoDBStreams.Scheme = scheme
+	if streams.Scheme != "" {
+		c.Network.DynamoDBStreams.Scheme = streams.Scheme
+	}
+	c.Network.DynamoDBStreams.Port = port
+	if streams.Port != 0 {
+		c.Network.DynamoDBStreams.Port = strconv.Itoa(streams.Port)
+	}
+	c.Network.DynamoDBStreams.URL = c.Network.DynamoDBStreams.Scheme + "://" +
+		c.Network.DynamoDBStreams.Host + ":" + c.Network.DynamoDBStreams.Port
+	_, streams_url_err := url.Parse(c.Network.DynamoDBStreams.URL)
+	if streams_url_err != nil {
+		return nil, errors.New("conf_file.ReadConfFile: conf.Network.DynamoDBStreams.URL malformed")
+	}
+
 	// If set to true, programs that are written with godynamo may
 	// opt to launch the keepalive goroutine to keep conns open.
 	c.Network.DynamoDB.KeepAlive = cf.Services.Dynamo_db.KeepAlive
@@ -80,6 +106,16 @@ func ReadConfFile(conf_file string) (*conf.AWS_Conf, error) {
 		c.IAM.File.AccessKey = cf.Services.Dynamo_db.IAM.Access_key
 		c.IAM.File.Secret = cf.Services.Dynamo_db.IAM.Secret_key
 		c.IAM.File.Token = cf.Services.Dynamo_db.IAM.Token
+		c.IAM.Profile = cf.Services.Dynamo_db.IAM.Profile
+		c.IAM.STS.RoleARN = cf.Services.Dynamo_db.IAM.Role_arn
+		c.IAM.STS.RoleSessionName = cf.Services.Dynamo_db.IAM.Role_session_name
+		c.IAM.STS.ExternalID = cf.Services.Dynamo_db.IAM.External_id
+		c.IAM.STS.Duration = time.Duration(cf.Services.Dynamo_db.IAM.Duration_seconds) * time.Second
+		c.IAM.STS.WebIdentityTokenFile = cf.Services.Dynamo_db.IAM.Web_identity_token_file
+		c.IAM.STS.Endpoint = cf.Services.Dynamo_db.IAM.Sts_endpoint
+		c.IAM.STS.Region = cf.Services.Dynamo_db.IAM.Sts_region
+		c.IAM.RefreshWindow = time.Duration(cf.Services.Dynamo_db.IAM.Refresh_window_seconds) * time.Second
+		c.IAM.RefreshRetry = time.Duration(cf.Services.Dynamo_db.IAM.Refresh_retry_seconds) * time.Second
 		if cf.Services.Dynamo_db.IAM.Watch == true {
 			c.IAM.Watch = true
 		} else {
@@ -13,9 +13,11 @@
 package conf_iam
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	conf "github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/credentials"
 	roles_files "github.com/smugmug/goawsroles/roles_files"
 	"log"
 	"time"
@@ -36,12 +38,162 @@ func AssignCredentialsToConf(rf *roles_files.RolesFiles, c *conf.AWS_Conf) error
 	c.IAM.Credentials.AccessKey = accessKey
 	c.IAM.Credentials.Secret = secret
 	c.IAM.Credentials.Token = token
+	c.IAM.Credentials.Expires = time.Time{}
 	c.ConfLock.Unlock()
 	e := fmt.Sprintf("IAM credentials assigned at %v", time.Now())
 	log.Printf(e)
 	return nil
 }
 
+// AssignValueToConf will safely copy the credentials in v to the conf c. Temporary
+// credentials, which carry a session token, are assigned as IAM credentials; long-term
+// keys replace the Auth keys of c.
+func AssignValueToConf(v credentials.Value, c *conf.AWS_Conf) error {
+	if c == nil {
+		return errors.New("conf_iam.AssignValueToConf: c is nil")
+	}
+	if !v.HasKeys() {
+		return errors.New("conf_iam.AssignValueToConf: v has no keys")
+	}
+	c.ConfLock.Lock()
+	if v.SessionToken != "" {
+		c.IAM.Credentials.AccessKey = v.AccessKeyID
+		c.IAM.Credentials.Secret = v.SecretAccessKey
+		c.IAM.Credentials.Token = v.SessionToken
+		c.IAM.Credentials.Expires = v.Expires
+		c.UseIAM = true
+	} else {
+		c.Auth.AccessKey = v.AccessKeyID
+		c.Auth.Secret = v.SecretAccessKey
+		c.IAM.Credentials.AccessKey = ""
+		c.IAM.Credentials.Secret = ""
+		c.IAM.Credentials.Token = ""
+		c.IAM.Credentials.Expires = time.Time{}
+		c.UseIAM = false
+	}
+	c.IAM.Provider = v.ProviderName
+	c.ConfLock.Unlock()
+	logf(c, "credentials from provider %s assigned at %v", v.ProviderName, time.Now())
+	return nil
+}
+
+// ProviderToConf sets the credentials of conf c to a credentials.Cache of p, so that
+// expiring credentials are refreshed c.IAM.RefreshWindow before they expire. The first
+// credentials are read before it returns. They, and those of every later refresh, are
+// assigned to c as AssignValueToConf does, so c.IAM.Provider, c.IAM.Credentials and
+// c.Auth follow the Cache; requests are signed with the Cache itself. Errors of later
+// refreshes are passed to c.IAM.OnError.
+func ProviderToConf(ctx context.Context, p credentials.Provider, c *conf.AWS_Conf) error {
+	if p == nil || c == nil {
+		return errors.New("conf_iam.ProviderToConf: p or c is nil")
+	}
+	c.ConfLock.RLock()
+	cache := newCache(p, c)
+	c.ConfLock.RUnlock()
+	cache.OnError = func(err error) {
+		reportError(c, err)
+	}
+	v, err := cache.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("conf_iam.ProviderToConf: %w", err)
+	}
+	assign_err := AssignValueToConf(v, c)
+	if assign_err != nil {
+		return assign_err
+	}
+	// set once the first refresh is done, as the refreshes read it
+	cache.OnRefresh = func(v credentials.Value) {
+		if assign_err := AssignValueToConf(v, c); assign_err != nil {
+			reportError(c, assign_err)
+		}
+	}
+	c.ConfLock.Lock()
+	c.Credentials = cache
+	c.ConfLock.Unlock()
+	return nil
+}
+
+// ChainToConf calls ProviderToConf with the default credentials chain, as configured by
+// the Auth keys and IAM.Profile of c.
+func ChainToConf(ctx context.Context, c *conf.AWS_Conf) error {
+	if c == nil {
+		return errors.New("conf_iam.ChainToConf: c is nil")
+	}
+	c.ConfLock.RLock()
+	chain := credentials.NewDefaultChain(c.Auth.AccessKey, c.Auth.Secret, c.IAM.Profile)
+	c.ConfLock.RUnlock()
+	return ProviderToConf(ctx, chain, c)
+}
+
+// STSToConf calls ProviderToConf with a provider of the role in c.IAM.STS: a
+// credentials.AssumeRoleProvider using the credentials of the default chain if the role
+// provider of c is conf.ROLE_PROVIDER_ASSUME_ROLE, or a credentials.WebIdentityProvider
+// if it is conf.ROLE_PROVIDER_WEB_IDENTITY.
+func STSToConf(ctx context.Context, c *conf.AWS_Conf) error {
+	if c == nil {
+		return errors.New("conf_iam.STSToConf: c is nil")
+	}
+	var p credentials.Provider
+	c.ConfLock.RLock()
+	sts := credentials.STS{Endpoint: c.IAM.STS.Endpoint, Region: c.IAM.STS.Region}
+	switch c.IAM.RoleProvider {
+	case conf.ROLE_PROVIDER_ASSUME_ROLE:
+		p = &credentials.AssumeRoleProvider{
+			STS:             sts,
+			Source:          newCache(credentials.NewDefaultChain(c.Auth.AccessKey, c.Auth.Secret, c.IAM.Profile), c),
+			RoleARN:         c.IAM.STS.RoleARN,
+			RoleSessionName: c.IAM.STS.RoleSessionName,
+			ExternalID:      c.IAM.STS.ExternalID,
+			Duration:        c.IAM.STS.Duration,
+		}
+	case conf.ROLE_PROVIDER_WEB_IDENTITY:
+		p = &credentials.WebIdentityProvider{
+			STS:             sts,
+			RoleARN:         c.IAM.STS.RoleARN,
+			RoleSessionName: c.IAM.STS.RoleSessionName,
+			TokenFile:       c.IAM.STS.WebIdentityTokenFile,
+			Duration:        c.IAM.STS.Duration,
+		}
+	}
+	role_provider := c.IAM.RoleProvider
+	c.ConfLock.RUnlock()
+	if p == nil {
+		e := fmt.Sprintf("conf_iam.STSToConf: role provider %s does not use STS", role_provider)
+		return errors.New(e)
+	}
+	return ProviderToConf(ctx, p, c)
+}
+
+// newCache returns a credentials.Cache of p refreshing with the IAM.RefreshWindow and
+// IAM.RefreshRetry of c. The caller holds c.ConfLock.
+func newCache(p credentials.Provider, c *conf.AWS_Conf) *credentials.Cache {
+	cache := credentials.NewCache(p)
+	cache.Window = c.IAM.RefreshWindow
+	cache.RetryInterval = c.IAM.RefreshRetry
+	return cache
+}
+
+// reportError passes err to the IAM.OnError of c, or logs it if that is not set.
+func reportError(c *conf.AWS_Conf, err error) {
+	c.ConfLock.RLock()
+	on_err := c.IAM.OnError
+	c.ConfLock.RUnlock()
+	if on_err != nil {
+		on_err(err)
+		return
+	}
+	logf(c, "%v", err)
+}
+
+// logf logs with the Logger of c, or with the standard logger if it is not set.
+func logf(c *conf.AWS_Conf, format string, v ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
 // AssignCredentials will safely copy the credentials data from rf to the global conf.Vals.
 func AssignCredentials(rf *roles_files.RolesFiles) error {
 	return AssignCredentialsToConf(rf, &conf.Vals)
@@ -99,12 +251,16 @@ func WatchIAM(rf *roles_files.RolesFiles, watch_err_chan chan error) {
 	WatchIAMToConf(rf, &conf.Vals, watch_err_chan)
 }
 
-// GoIAMToConf is a convenience wrapper for callers using roles_files instantiation of the roles interface.
+// GoIAMToConf is a convenience wrapper for callers using roles_files instantiation of the roles interface,
+// the credentials chain if the role provider of c is conf.ROLE_PROVIDER_CHAIN, or STS if it is
+// conf.ROLE_PROVIDER_ASSUME_ROLE or conf.ROLE_PROVIDER_WEB_IDENTITY.
 // First there is a blocking read on the roles files to get the initial roles information. Then the
 // file notification watcher will run as a goroutine, and resetting conf c's roles
 // values. If IAM Credentials are ready for use, the parameter chan `ready_chan` will receive a true
 // value, otherwise false. A false value on this chan should indicate to a caller that another auth
-// mechanism (for example, hardocded credentials) should be used.
+// mechanism (for example, hardocded credentials) should be used. Errors of the watcher or of
+// refreshing the chain's credentials are passed to c.IAM.OnError, and the last good
+// credentials stay in use.
 func GoIAMToConf(c *conf.AWS_Conf, ready_chan chan bool) {
 	if c == nil {
 		log.Printf("conf_iam.GoIAMToConf: c is nil")
@@ -112,10 +268,30 @@ func GoIAMToConf(c *conf.AWS_Conf, ready_chan chan bool) {
 		return
 	}
 	use_iam := false
+	role_provider := ""
 	c.ConfLock.RLock()
 	use_iam = c.UseIAM
+	role_provider = c.IAM.RoleProvider
 	c.ConfLock.RUnlock()
-	if use_iam == true {
+	if use_iam == true && (role_provider == conf.ROLE_PROVIDER_CHAIN ||
+		role_provider == conf.ROLE_PROVIDER_ASSUME_ROLE || role_provider == conf.ROLE_PROVIDER_WEB_IDENTITY) {
+		var chain_err error
+		if role_provider == conf.ROLE_PROVIDER_CHAIN {
+			chain_err = ChainToConf(context.Background(), c)
+		} else {
+			chain_err = STSToConf(context.Background(), c)
+		}
+		if chain_err != nil {
+			logf(c, "%v", chain_err)
+			c.ConfLock.Lock()
+			c.UseIAM = false
+			c.ConfLock.Unlock()
+			ready_chan <- false
+			return
+		}
+		// requests are now signed with the refreshed credentials of the provider
+		ready_chan <- true
+	} else if use_iam == true {
 		rf := roles_files.NewRolesFiles()
 		watching := false
 		c.ConfLock.RLock()
@@ -142,15 +318,10 @@ func GoIAMToConf(c *conf.AWS_Conf, ready_chan chan bool) {
 			watch_err := make(chan error)
 			go WatchIAMToConf(rf, c, watch_err)
 			go func() {
-				select {
-				case err := <-watch_err:
+				for err := range watch_err {
 					if err != nil {
-						log.Printf(err.Error())
-						// caller can fall back to hard-coded perms
-						// or live with the panic
-						c.ConfLock.Lock()
-						c.UseIAM = false
-						c.ConfLock.Unlock()
+						// the last credentials read stay in use
+						reportError(c, err)
 					}
 				}
 			}()
@@ -0,0 +1,97 @@
+package conf_iam
+
+import (
+	"context"
+	"fmt"
+	conf "github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/credentials"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rotatingProvider returns a new session token on every call.
+type rotatingProvider struct {
+	lock  sync.Mutex
+	calls int
+}
+
+func (p *rotatingProvider) Name() string {
+	return "rotating"
+}
+
+func (p *rotatingProvider) Retrieve(ctx context.Context) (credentials.Value, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.calls++
+	return credentials.Value{AccessKeyID: "id", SecretAccessKey: "secret", SessionToken: fmt.Sprintf("token%d", p.calls),
+		Expires: time.Now().Add(time.Hour), ProviderName: "rotating"}, nil
+}
+
+// testLogger records the lines logged.
+type testLogger struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+func (l *testLogger) Printf(format string, v ...interface{}) {
+	l.lock.Lock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+	l.lock.Unlock()
+}
+
+func TestProviderToConf(t *testing.T) {
+	c := new(conf.AWS_Conf)
+	c.IAM.RefreshWindow = 15 * time.Minute
+	c.IAM.RefreshRetry = time.Minute
+	p := &credentials.StaticProvider{Value: credentials.Value{AccessKeyID: "id", SecretAccessKey: "secret",
+		SessionToken: "token", Expires: time.Now().Add(time.Hour)}}
+	if err := ProviderToConf(context.Background(), p, c); err != nil {
+		t.Fatal(err)
+	}
+	cache, ok := c.Credentials.(*credentials.Cache)
+	if !ok || cache.Window != 15*time.Minute || cache.RetryInterval != time.Minute {
+		t.Errorf("the cache should refresh with the window of the conf, got %+v", c.Credentials)
+	}
+	if !c.UseIAM || c.IAM.Credentials.Token != "token" {
+		t.Errorf("the credentials should be assigned to the conf")
+	}
+}
+
+func TestProviderToConfRefresh(t *testing.T) {
+	c := new(conf.AWS_Conf)
+	l := new(testLogger)
+	c.Logger = l
+	// the credentials are always within the window, so every Retrieve refreshes them
+	c.IAM.RefreshWindow = 2 * time.Hour
+	p := new(rotatingProvider)
+	if err := ProviderToConf(context.Background(), p, c); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Credentials.Retrieve(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// the second Retrieve waits for nothing, so wait for the background refresh
+	deadline := time.Now().Add(time.Second)
+	for {
+		c.ConfLock.RLock()
+		token := c.IAM.Credentials.Token
+		c.ConfLock.RUnlock()
+		if token == "token2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the refreshed credentials should be assigned to the conf, got %s", token)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if c.IAM.Provider != "rotating" {
+		t.Errorf("the provider should be recorded, got %s", c.IAM.Provider)
+	}
+	l.lock.Lock()
+	if len(l.lines) != 2 || !strings.Contains(l.lines[1], "rotating") {
+		t.Errorf("the assignments should be logged with the conf Logger, got %v", l.lines)
+	}
+	l.lock.Unlock()
+}
@@ -0,0 +1,176 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// how long before credentials expire that a Cache refreshes them unless set
+	REFRESH_WINDOW = 5 * time.Minute
+	// how long a Cache waits after a failed refresh before trying again in the
+	// background unless set
+	REFRESH_RETRY = 10 * time.Second
+	// how long a refresh may take
+	REFRESH_TIMEOUT = time.Minute
+)
+
+// Cache holds the credentials of a Provider until they are about to expire.
+//
+// Credentials that expire are refreshed Window before they do, in the background, while
+// the current ones are still returned. Concurrent callers share a single refresh. If a
+// refresh fails, the last good credentials are returned until they expire, the error is
+// passed to OnError, and the refresh is tried again after RetryInterval. Only once no
+// valid credentials are left does Retrieve wait for a refresh and return its error.
+//
+// example use:
+//
+//	c.Credentials = credentials.NewCache(credentials.NewDefaultChain("", "", ""))
+type Cache struct {
+	Provider Provider
+	// How long before expiry to refresh; REFRESH_WINDOW if 0.
+	Window time.Duration
+	// How long to wait after a failed background refresh; REFRESH_RETRY if 0.
+	RetryInterval time.Duration
+	// If set, OnError is called with the error of every failed refresh. Calls are not
+	// concurrent.
+	OnError func(error)
+	// If set, OnRefresh is called with the credentials of every successful refresh,
+	// before Retrieve returns them. Calls are not concurrent.
+	OnRefresh func(Value)
+
+	lock  sync.Mutex
+	value Value
+	have  bool
+	// closed once the refresh in flight completes; nil if there is none
+	refreshing chan struct{}
+	// the error of the last refresh, and when to try again after it
+	err      error
+	retry_at time.Time
+}
+
+// NewCache returns a Cache of the credentials of p.
+func NewCache(p Provider) *Cache {
+	return &Cache{Provider: p}
+}
+
+// Name returns the name of the Provider of the Cache.
+func (c *Cache) Name() string {
+	if c == nil || c.Provider == nil {
+		return ""
+	}
+	return c.Provider.Name()
+}
+
+// Retrieve returns the cached credentials, refreshing them as described for Cache. If
+// there are none that are valid, it waits for a refresh or for ctx to be done.
+func (c *Cache) Retrieve(ctx context.Context) (Value, error) {
+	if c == nil || c.Provider == nil {
+		return Value{}, errors.New("credentials.(Cache)Retrieve: receiver or Provider is nil")
+	}
+	c.lock.Lock()
+	now := time.Now()
+	if c.have && !expired(c.value, now) {
+		if c.due(now) {
+			c.refresh()
+		}
+		v := c.value
+		c.lock.Unlock()
+		return v, nil
+	}
+	done := c.refresh()
+	c.lock.Unlock()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return Value{}, ctx.Err()
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.have && !expired(c.value, time.Now()) {
+		return c.value, nil
+	}
+	if c.err != nil {
+		return Value{}, c.err
+	}
+	return Value{}, fmt.Errorf("credentials.(Cache)Retrieve: the credentials of %s expired", c.Provider.Name())
+}
+
+// Expire drops the cached credentials, so that the next Retrieve refreshes them. Use it
+// when the credentials are rejected before they were due to expire.
+func (c *Cache) Expire() {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	c.have = false
+	c.lock.Unlock()
+}
+
+// due reports whether the cached credentials should be refreshed in the background. The
+// lock must be held.
+func (c *Cache) due(now time.Time) bool {
+	if c.value.Expires.IsZero() || (c.err != nil && now.Before(c.retry_at)) {
+		return false
+	}
+	window := c.Window
+	if window <= 0 {
+		window = REFRESH_WINDOW
+	}
+	return !now.Before(c.value.Expires.Add(-window))
+}
+
+// refresh starts a refresh unless one is in flight, returning a channel closed once it
+// completes. The lock must be held.
+func (c *Cache) refresh() chan struct{} {
+	if c.refreshing != nil {
+		return c.refreshing
+	}
+	done := make(chan struct{})
+	c.refreshing = done
+	// the refresh is shared, so it is not bound to the context of any one caller
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), REFRESH_TIMEOUT)
+		v, err := c.Provider.Retrieve(ctx)
+		cancel()
+		if err == nil && !v.HasKeys() {
+			err = errors.New("credentials: the credentials have no keys")
+		}
+		if err == nil && expired(v, time.Now()) {
+			err = errors.New("credentials: the credentials have expired")
+		}
+		if err != nil {
+			err = fmt.Errorf("credentials: refresh from %s: %w", c.Provider.Name(), err)
+			// called while the refresh is still in flight so that calls are not concurrent
+			if c.OnError != nil {
+				c.OnError(err)
+			}
+		} else if c.OnRefresh != nil {
+			c.OnRefresh(v)
+		}
+		c.lock.Lock()
+		if err == nil {
+			c.value = v
+			c.have = true
+		} else {
+			retry := c.RetryInterval
+			if retry <= 0 {
+				retry = REFRESH_RETRY
+			}
+			c.retry_at = time.Now().Add(retry)
+		}
+		c.err = err
+		c.refreshing = nil
+		c.lock.Unlock()
+		close(done)
+	}()
+	return done
+}
+
+// expired reports whether v has expired at now.
+func expired(v Value, now time.Time) bool {
+	return !v.Expires.IsZero() && !now.Before(v.Expires)
+}
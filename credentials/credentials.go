@@ -0,0 +1,207 @@
+// Implements the sources of the AWS credentials used to sign requests.
+//
+// A Provider retrieves credentials from one source. A Chain tries several in order and
+// uses the first that has credentials, remembering which one it was. NewDefaultChain
+// follows the order of the AWS SDKs: static keys, the environment, the shared
+// credentials file, a credential_process command and the instance metadata endpoint.
+//
+// example use:
+//
+//	chain := credentials.NewDefaultChain(c.Auth.AccessKey, c.Auth.Secret, "")
+//	v, err := chain.Retrieve(ctx)
+//	if err == nil {
+//		log.Printf("using credentials from %s", v.ProviderName)
+//	}
+package credentials
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	STATIC_PROVIDER = "static"
+	ENV_PROVIDER    = "env"
+
+	ENV_ACCESS_KEY_ID     = "AWS_ACCESS_KEY_ID"
+	ENV_SECRET_ACCESS_KEY = "AWS_SECRET_ACCESS_KEY"
+	ENV_SESSION_TOKEN     = "AWS_SESSION_TOKEN"
+	// older names, read if the above are not set
+	ENV_ACCESS_KEY = "AWS_ACCESS_KEY"
+	ENV_SECRET_KEY = "AWS_SECRET_KEY"
+)
+
+// ErrNoCredentials is returned by a Provider whose source holds no credentials, as
+// opposed to credentials it could not read.
+var ErrNoCredentials = errors.New("credentials: no credentials found")
+
+// Value is a set of AWS credentials.
+type Value struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// Set for temporary credentials.
+	SessionToken string
+	// When temporary credentials expire; zero if they do not.
+	Expires time.Time
+	// The name of the Provider that retrieved the credentials.
+	ProviderName string
+}
+
+// HasKeys reports whether v has an access key and a secret.
+func (v Value) HasKeys() bool {
+	return v.AccessKeyID != "" && v.SecretAccessKey != ""
+}
+
+// Provider retrieves credentials from one source.
+type Provider interface {
+	// Name identifies the provider in a Value and in errors.
+	Name() string
+	// Retrieve returns the credentials of the source, or ErrNoCredentials if it has none.
+	Retrieve(ctx context.Context) (Value, error)
+}
+
+// StaticProvider returns fixed credentials, such as the keys of a conf.
+type StaticProvider struct {
+	Value Value
+}
+
+func (p *StaticProvider) Name() string {
+	return STATIC_PROVIDER
+}
+
+func (p *StaticProvider) Retrieve(ctx context.Context) (Value, error) {
+	if p == nil || !p.Value.HasKeys() {
+		return Value{}, ErrNoCredentials
+	}
+	v := p.Value
+	v.ProviderName = STATIC_PROVIDER
+	return v, nil
+}
+
+// EnvProvider reads credentials from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN environment variables.
+type EnvProvider struct{}
+
+func (p *EnvProvider) Name() string {
+	return ENV_PROVIDER
+}
+
+func (p *EnvProvider) Retrieve(ctx context.Context) (Value, error) {
+	v := Value{
+		AccessKeyID:     getenv(ENV_ACCESS_KEY_ID, ENV_ACCESS_KEY),
+		SecretAccessKey: getenv(ENV_SECRET_ACCESS_KEY, ENV_SECRET_KEY),
+		SessionToken:    os.Getenv(ENV_SESSION_TOKEN),
+		ProviderName:    ENV_PROVIDER,
+	}
+	if !v.HasKeys() {
+		return Value{}, ErrNoCredentials
+	}
+	return v, nil
+}
+
+// getenv returns the first of the environment variables names that is set.
+func getenv(names ...string) string {
+	for _, n := range names {
+		if v := os.Getenv(n); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// ChainError lists why each provider of a Chain returned no credentials.
+type ChainError struct {
+	Errors map[string]error
+	// the providers in the order they were tried
+	Names []string
+}
+
+func (e *ChainError) Error() string {
+	parts := make([]string, 0, len(e.Names))
+	for _, n := range e.Names {
+		parts = append(parts, n+": "+e.Errors[n].Error())
+	}
+	return "credentials: no provider in the chain has credentials (" + strings.Join(parts, "; ") + ")"
+}
+
+// Is reports whether every provider had no credentials, as opposed to failing.
+func (e *ChainError) Is(target error) bool {
+	if target != ErrNoCredentials {
+		return false
+	}
+	for _, err := range e.Errors {
+		if !errors.Is(err, ErrNoCredentials) {
+			return false
+		}
+	}
+	return true
+}
+
+// Chain tries its providers in order and returns the credentials of the first that has
+// them.
+type Chain struct {
+	Providers []Provider
+	lock      sync.Mutex
+	chosen    string
+}
+
+// NewChain returns a Chain of providers.
+func NewChain(providers ...Provider) *Chain {
+	return &Chain{Providers: providers}
+}
+
+// NewDefaultChain returns a Chain of a StaticProvider of the access key and secret, the
+// environment, the shared credentials file, a credential_process command and the
+// instance metadata endpoint. The shared file and the command are read from profile,
+// or from AWS_PROFILE or "default" if profile is "".
+func NewDefaultChain(access_key, secret, profile string) *Chain {
+	return NewChain(
+		&StaticProvider{Value: Value{AccessKeyID: access_key, SecretAccessKey: secret}},
+		&EnvProvider{},
+		&SharedFileProvider{Profile: profile},
+		&ProcessProvider{Profile: profile},
+		&MetadataProvider{},
+	)
+}
+
+func (c *Chain) Name() string {
+	return "chain"
+}
+
+// Retrieve returns the credentials of the first provider that has them. If none does,
+// a *ChainError is returned.
+func (c *Chain) Retrieve(ctx context.Context) (Value, error) {
+	if c == nil {
+		return Value{}, errors.New("credentials.(Chain)Retrieve: receiver is nil")
+	}
+	chain_err := &ChainError{Errors: make(map[string]error)}
+	for _, p := range c.Providers {
+		v, err := p.Retrieve(ctx)
+		if err == nil {
+			c.lock.Lock()
+			c.chosen = p.Name()
+			c.lock.Unlock()
+			return v, nil
+		}
+		if ctx_err := ctx.Err(); ctx_err != nil {
+			return Value{}, ctx_err
+		}
+		chain_err.Names = append(chain_err.Names, p.Name())
+		chain_err.Errors[p.Name()] = err
+	}
+	return Value{}, chain_err
+}
+
+// Chosen returns the name of the provider of the last credentials retrieved, or "".
+func (c *Chain) Chosen() string {
+	if c == nil {
+		return ""
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.chosen
+}
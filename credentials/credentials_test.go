@@ -0,0 +1,386 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/aws_const"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// isolate clears the environment the providers read, pointing HOME at an empty dir.
+func isolate(t *testing.T) string {
+	dir := t.TempDir()
+	for _, env := range []string{ENV_ACCESS_KEY_ID, ENV_SECRET_ACCESS_KEY, ENV_SESSION_TOKEN,
+		ENV_ACCESS_KEY, ENV_SECRET_KEY, ENV_SHARED_CREDENTIALS_FILE, ENV_CONFIG_FILE, ENV_PROFILE,
+		ENV_METADATA_ENDPOINT} {
+		t.Setenv(env, "")
+	}
+	t.Setenv("HOME", dir)
+	t.Setenv(ENV_METADATA_DISABLED, "true")
+	return dir
+}
+
+func write(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	home := isolate(t)
+	ctx := context.Background()
+	chain := NewDefaultChain("", "", "")
+	_, err := chain.Retrieve(ctx)
+	var chain_err *ChainError
+	if !errors.As(err, &chain_err) || !errors.Is(err, ErrNoCredentials) || len(chain_err.Names) != 5 {
+		t.Fatalf("expected no credentials from every provider, got %v", err)
+	}
+
+	write(t, filepath.Join(home, ".aws", "credentials"), `
+# a comment
+[default]
+aws_access_key_id = fileKey
+aws_secret_access_key = fileSecret
+
+[other]
+aws_access_key_id=otherKey
+aws_secret_access_key=otherSecret
+aws_session_token=otherToken
+`)
+	v, err := chain.Retrieve(ctx)
+	if err != nil || v.AccessKeyID != "fileKey" || chain.Chosen() != SHARED_FILE_PROVIDER {
+		t.Errorf("expected the shared file, got %+v from %s: %v", v, chain.Chosen(), err)
+	}
+	v, err = NewDefaultChain("", "", "other").Retrieve(ctx)
+	if err != nil || v.SessionToken != "otherToken" {
+		t.Errorf("expected the other profile, got %+v: %v", v, err)
+	}
+
+	t.Setenv(ENV_ACCESS_KEY_ID, "envKey")
+	t.Setenv(ENV_SECRET_ACCESS_KEY, "envSecret")
+	if v, err = chain.Retrieve(ctx); err != nil || v.AccessKeyID != "envKey" || v.ProviderName != ENV_PROVIDER {
+		t.Errorf("expected the environment, got %+v: %v", v, err)
+	}
+
+	chain = NewDefaultChain("confKey", "confSecret", "")
+	if v, err = chain.Retrieve(ctx); err != nil || v.AccessKeyID != "confKey" || chain.Chosen() != STATIC_PROVIDER {
+		t.Errorf("expected the static keys, got %+v: %v", v, err)
+	}
+}
+
+func TestSharedFileErrors(t *testing.T) {
+	home := isolate(t)
+	path := filepath.Join(home, "creds")
+	write(t, path, "[default]\nnot a key\n")
+	p := &SharedFileProvider{Filename: path}
+	if _, err := p.Retrieve(context.Background()); err == nil || errors.Is(err, ErrNoCredentials) {
+		t.Errorf("a malformed file should fail, got %v", err)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	home := isolate(t)
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	out := fmt.Sprintf(`{"Version":1,"AccessKeyId":"procKey","SecretAccessKey":"procSecret","SessionToken":"procToken","Expiration":"%s"}`,
+		expires.Format(time.RFC3339))
+	write(t, filepath.Join(home, ".aws", "config"), "[profile proc]\ncredential_process = echo '"+out+"'\n")
+	p := &ProcessProvider{Profile: "proc"}
+	v, err := p.Retrieve(context.Background())
+	if err != nil || v.AccessKeyID != "procKey" || v.SessionToken != "procToken" || !v.Expires.Equal(expires) {
+		t.Errorf("unexpected process credentials %+v: %v", v, err)
+	}
+	p = &ProcessProvider{Command: "echo oops >&2; exit 3"}
+	if _, err = p.Retrieve(context.Background()); err == nil {
+		t.Errorf("a failing command should fail")
+	}
+	p = &ProcessProvider{Profile: "missing"}
+	if _, err = p.Retrieve(context.Background()); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("a profile without a command has no credentials, got %v", err)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	isolate(t)
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && r.URL.Path == metadataTokenPath {
+			if r.Header.Get(metadataTokenTTLHeader) == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte("sessiontoken"))
+			return
+		}
+		if r.Header.Get(metadataTokenHeader) != "sessiontoken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case metadataCredentialsPath:
+			w.Write([]byte("myrole\n"))
+		case metadataCredentialsPath + "myrole":
+			fmt.Fprintf(w, `{"Code":"Success","AccessKeyId":"mdKey","SecretAccessKey":"mdSecret","Token":"mdToken","Expiration":"%s"}`,
+				expires.Format(time.RFC3339))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv(ENV_METADATA_DISABLED, "")
+	p := &MetadataProvider{Endpoint: srv.URL}
+	v, err := p.Retrieve(context.Background())
+	if err != nil || v.AccessKeyID != "mdKey" || v.SessionToken != "mdToken" || !v.Expires.Equal(expires) {
+		t.Errorf("unexpected metadata credentials %+v: %v", v, err)
+	}
+
+	// the chain reaches the endpoint last
+	t.Setenv(ENV_METADATA_ENDPOINT, srv.URL)
+	chain := NewDefaultChain("", "", "")
+	if v, err = chain.Retrieve(context.Background()); err != nil || chain.Chosen() != METADATA_PROVIDER {
+		t.Errorf("expected the metadata endpoint, got %+v: %v", v, err)
+	}
+
+	// an endpoint that cannot be reached has no credentials
+	srv.Close()
+	if _, err = p.Retrieve(context.Background()); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected no credentials, got %v", err)
+	}
+}
+
+// countingProvider returns credentials that expire after ttl, numbered by the calls made,
+// or fails while fail is set.
+type countingProvider struct {
+	lock    sync.Mutex
+	calls   int
+	ttl     time.Duration
+	fail    bool
+	release chan struct{}
+}
+
+func (p *countingProvider) Name() string {
+	return "counting"
+}
+
+func (p *countingProvider) Retrieve(ctx context.Context) (Value, error) {
+	if p.release != nil {
+		<-p.release
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.calls++
+	if p.fail {
+		return Value{}, errors.New("transient")
+	}
+	return Value{AccessKeyID: fmt.Sprintf("key%d", p.calls), SecretAccessKey: "secret",
+		Expires: time.Now().Add(p.ttl)}, nil
+}
+
+func TestCacheSingleFlight(t *testing.T) {
+	p := &countingProvider{ttl: time.Hour, release: make(chan struct{})}
+	c := NewCache(p)
+	var wg sync.WaitGroup
+	keys := make([]string, 10)
+	for i := range keys {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := c.Retrieve(context.Background())
+			if err != nil {
+				t.Error(err)
+			}
+			keys[i] = v.AccessKeyID
+		}(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(p.release)
+	wg.Wait()
+	for _, k := range keys {
+		if k != "key1" {
+			t.Errorf("expected every caller to share the first refresh, got %v", keys)
+			break
+		}
+	}
+	if p.calls != 1 {
+		t.Errorf("expected 1 refresh, got %d", p.calls)
+	}
+	if v, _ := c.Retrieve(context.Background()); v.AccessKeyID != "key1" || p.calls != 1 {
+		t.Errorf("credentials that are not due should be cached")
+	}
+}
+
+func TestCacheRefresh(t *testing.T) {
+	p := &countingProvider{ttl: 300 * time.Millisecond}
+	var errs []error
+	var errs_lock sync.Mutex
+	c := &Cache{Provider: p, Window: 200 * time.Millisecond, RetryInterval: time.Hour,
+		OnError: func(err error) {
+			errs_lock.Lock()
+			errs = append(errs, err)
+			errs_lock.Unlock()
+		}}
+	ctx := context.Background()
+	if v, err := c.Retrieve(ctx); err != nil || v.AccessKeyID != "key1" {
+		t.Fatalf("unexpected credentials %+v: %v", v, err)
+	}
+	// within the window, the current credentials are returned while they are refreshed
+	time.Sleep(150 * time.Millisecond)
+	if v, err := c.Retrieve(ctx); err != nil || v.AccessKeyID != "key1" {
+		t.Errorf("expected the current credentials, got %+v: %v", v, err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if v, err := c.Retrieve(ctx); err != nil || v.AccessKeyID != "key2" {
+		t.Errorf("expected the refreshed credentials, got %+v: %v", v, err)
+	}
+
+	// a failed refresh keeps the last good credentials until they expire
+	p.lock.Lock()
+	p.fail = true
+	p.lock.Unlock()
+	time.Sleep(150 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if v, err := c.Retrieve(ctx); err != nil || v.AccessKeyID != "key2" {
+			t.Errorf("expected the last good credentials, got %+v: %v", v, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	errs_lock.Lock()
+	if len(errs) != 1 {
+		t.Errorf("expected one failed refresh before RetryInterval, got %v", errs)
+	}
+	errs_lock.Unlock()
+	time.Sleep(200 * time.Millisecond)
+	if _, err := c.Retrieve(ctx); err == nil {
+		t.Errorf("expired credentials that cannot be refreshed should fail")
+	}
+
+	p.lock.Lock()
+	p.fail = false
+	p.lock.Unlock()
+	if v, err := c.Retrieve(ctx); err != nil || !v.HasKeys() {
+		t.Errorf("expected the credentials to recover, got %+v: %v", v, err)
+	}
+}
+
+// fakeSTS answers AssumeRole and AssumeRoleWithWebIdentity, checking the form and the
+// credential scope and signed headers of AssumeRole requests, and counting the calls.
+// TestSignSTS checks the signature itself.
+func fakeSTS(t *testing.T, calls *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		body, _ := io.ReadAll(r.Body)
+		form, _ := url.ParseQuery(string(body))
+		action := form.Get("Action")
+		if form.Get("Version") != STS_VERSION || form.Get("RoleArn") != "arn:aws:iam::123456789012:role/dynamo" {
+			t.Errorf("unexpected form %v", form)
+		}
+		if action == "AssumeRole" {
+			date, _ := time.Parse(aws_const.ISO8601FMT_CONDENSED, r.Header.Get(aws_const.X_AMZ_DATE_HDR))
+			scope := "Credential=srcKey/" + date.Format(aws_const.ISODATEFMT) + "/eu-west-1/sts/aws4_request, " +
+				"SignedHeaders=content-type;host;x-amz-date;x-amz-security-token, Signature="
+			if r.Header.Get(aws_const.X_AMZ_SECURITY_TOKEN_HDR) != "srcToken" || date.IsZero() ||
+				!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "+scope) {
+				t.Errorf("unexpected signature %s", r.Header.Get("Authorization"))
+			}
+			if form.Get("ExternalId") != "ext" || form.Get("DurationSeconds") != "900" {
+				t.Errorf("unexpected form %v", form)
+			}
+		} else if action == "AssumeRoleWithWebIdentity" {
+			if r.Header.Get("Authorization") != "" {
+				t.Errorf("web identity requests are not signed")
+			}
+			if form.Get("WebIdentityToken") != "thetoken" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>InvalidIdentityToken</Code><Message>bad token</Message></Error><RequestId>r1</RequestId></ErrorResponse>`))
+				return
+			}
+		}
+		fmt.Fprintf(w, `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><%[1]sResult><Credentials>`+
+			`<AccessKeyId>%[2]s</AccessKeyId><SecretAccessKey>roleSecret</SecretAccessKey><SessionToken>roleToken</SessionToken>`+
+			`<Expiration>%[3]s</Expiration></Credentials></%[1]sResult></%[1]sResponse>`,
+			action, action+"Key", time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+}
+
+// The Authorization header expected of this request was computed independently of
+// this package, following the SigV4 documentation step by step.
+func TestSignSTS(t *testing.T) {
+	const body = "Action=AssumeRole&RoleArn=arn%3Aaws%3Aiam%3A%3A123456789012%3Arole%2Fdynamo&RoleSessionName=session&Version=2011-06-15"
+	r, _ := http.NewRequest(http.MethodPost, "https://sts.eu-west-1.amazonaws.com/", strings.NewReader(body))
+	r.Header.Set(aws_const.CONTENT_TYPE_HDR, stsContentType)
+	v := Value{AccessKeyID: "srcKey", SecretAccessKey: "srcSecret", SessionToken: "srcToken"}
+	signSTS(r, []byte(body), "eu-west-1", v, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	const expected = "AWS4-HMAC-SHA256 Credential=srcKey/20150830/eu-west-1/sts/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date;x-amz-security-token, " +
+		"Signature=599952a336f157a21a04150bacb2f4177db58b739fb54cd6280401c9719ed44c"
+	if r.Header.Get("Authorization") != expected {
+		t.Errorf("unexpected signature %s", r.Header.Get("Authorization"))
+	}
+	if r.Header.Get(aws_const.X_AMZ_DATE_HDR) != "20150830T123600Z" || r.Header.Get(aws_const.X_AMZ_SECURITY_TOKEN_HDR) != "srcToken" {
+		t.Errorf("unexpected headers %v", r.Header)
+	}
+}
+
+func TestAssumeRole(t *testing.T) {
+	calls := 0
+	srv := fakeSTS(t, &calls)
+	defer srv.Close()
+	p := &AssumeRoleProvider{
+		STS:        STS{Endpoint: srv.URL, Region: "eu-west-1"},
+		Source:     &StaticProvider{Value: Value{AccessKeyID: "srcKey", SecretAccessKey: "srcSecret", SessionToken: "srcToken"}},
+		RoleARN:    "arn:aws:iam::123456789012:role/dynamo",
+		ExternalID: "ext",
+		Duration:   15 * time.Minute,
+	}
+	c := NewCache(p)
+	for i := 0; i < 2; i++ {
+		v, err := c.Retrieve(context.Background())
+		if err != nil || v.AccessKeyID != "AssumeRoleKey" || v.SessionToken != "roleToken" ||
+			v.ProviderName != ASSUME_ROLE_PROVIDER || time.Until(v.Expires) < 59*time.Minute {
+			t.Errorf("unexpected credentials %+v: %v", v, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the credentials to be cached, got %d calls", calls)
+	}
+}
+
+func TestWebIdentity(t *testing.T) {
+	home := isolate(t)
+	calls := 0
+	srv := fakeSTS(t, &calls)
+	defer srv.Close()
+	token := filepath.Join(home, "token")
+	write(t, token, "thetoken\n")
+	t.Setenv(ENV_ROLE_ARN, "arn:aws:iam::123456789012:role/dynamo")
+	t.Setenv(ENV_WEB_IDENTITY_TOKEN_FILE, token)
+	p := &WebIdentityProvider{STS: STS{Endpoint: srv.URL}}
+	v, err := p.Retrieve(context.Background())
+	if err != nil || v.AccessKeyID != "AssumeRoleWithWebIdentityKey" || v.ProviderName != WEB_IDENTITY_PROVIDER {
+		t.Errorf("unexpected credentials %+v: %v", v, err)
+	}
+
+	write(t, token, "expired")
+	_, err = p.Retrieve(context.Background())
+	var sts_err *STSError
+	if !errors.As(err, &sts_err) || sts_err.Code != "InvalidIdentityToken" || sts_err.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected an STSError, got %v", err)
+	}
+
+	t.Setenv(ENV_WEB_IDENTITY_TOKEN_FILE, "")
+	if _, err = p.Retrieve(context.Background()); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected no credentials without a token file, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	METADATA_PROVIDER = "metadata"
+	// the instance metadata endpoint of EC2
+	METADATA_ENDPOINT = "http://169.254.169.254"
+	// how long each request to the endpoint may take unless set
+	METADATA_TIMEOUT = time.Second
+	// how long a session token of the endpoint lasts
+	METADATA_TOKEN_TTL = "21600"
+
+	ENV_METADATA_ENDPOINT = "AWS_EC2_METADATA_SERVICE_ENDPOINT"
+	ENV_METADATA_DISABLED = "AWS_EC2_METADATA_DISABLED"
+
+	metadataTokenPath       = "/latest/api/token"
+	metadataCredentialsPath = "/latest/meta-data/iam/security-credentials/"
+	metadataTokenHeader     = "X-Aws-Ec2-Metadata-Token"
+	metadataTokenTTLHeader  = "X-Aws-Ec2-Metadata-Token-Ttl-Seconds"
+)
+
+// MetadataProvider reads the credentials of the role of an EC2 instance from the instance
+// metadata endpoint. A session token is requested first as IMDSv2 requires, and the
+// requests are made without one if the endpoint does not issue them.
+type MetadataProvider struct {
+	// The endpoint; AWS_EC2_METADATA_SERVICE_ENDPOINT or METADATA_ENDPOINT if "".
+	Endpoint string
+	// The client of the requests. If nil, a client with a timeout of METADATA_TIMEOUT
+	// is used, so that the chain moves on quickly off EC2.
+	Client *http.Client
+}
+
+// metadataCredentials is the document of a role.
+type metadataCredentials struct {
+	Code            string
+	Message         string
+	AccessKeyId     string
+	SecretAccessKey string
+	Token           string
+	Expiration      time.Time
+}
+
+func (p *MetadataProvider) Name() string {
+	return METADATA_PROVIDER
+}
+
+func (p *MetadataProvider) Retrieve(ctx context.Context) (Value, error) {
+	if p == nil {
+		return Value{}, errors.New("credentials.(MetadataProvider)Retrieve: receiver is nil")
+	}
+	if strings.EqualFold(os.Getenv(ENV_METADATA_DISABLED), "true") {
+		return Value{}, ErrNoCredentials
+	}
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		endpoint = getenv(ENV_METADATA_ENDPOINT)
+	}
+	if endpoint == "" {
+		endpoint = METADATA_ENDPOINT
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: METADATA_TIMEOUT}
+	}
+	token := ""
+	req, req_err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint+metadataTokenPath, nil)
+	if req_err != nil {
+		return Value{}, req_err
+	}
+	req.Header.Set(metadataTokenTTLHeader, METADATA_TOKEN_TTL)
+	body, code, err := metadataDo(client, req)
+	if err != nil {
+		return Value{}, err
+	}
+	if code == http.StatusOK {
+		token = string(body)
+	}
+	get := func(path string) ([]byte, error) {
+		req, req_err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+path, nil)
+		if req_err != nil {
+			return nil, req_err
+		}
+		if token != "" {
+			req.Header.Set(metadataTokenHeader, token)
+		}
+		body, code, err := metadataDo(client, req)
+		if err != nil {
+			return nil, err
+		}
+		if code == http.StatusNotFound {
+			return nil, ErrNoCredentials
+		}
+		if code != http.StatusOK {
+			e := fmt.Sprintf("credentials.(MetadataProvider)Retrieve: %s: code %d", path, code)
+			return nil, errors.New(e)
+		}
+		return body, nil
+	}
+	roles, roles_err := get(metadataCredentialsPath)
+	if roles_err != nil {
+		return Value{}, roles_err
+	}
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+	if role == "" {
+		return Value{}, ErrNoCredentials
+	}
+	doc, doc_err := get(metadataCredentialsPath + role)
+	if doc_err != nil {
+		return Value{}, doc_err
+	}
+	var mc metadataCredentials
+	if um_err := json.Unmarshal(doc, &mc); um_err != nil {
+		e := fmt.Sprintf("credentials.(MetadataProvider)Retrieve: cannot unmarshal the credentials of %s: %s", role, um_err.Error())
+		return Value{}, errors.New(e)
+	}
+	if mc.Code != "Success" {
+		e := fmt.Sprintf("credentials.(MetadataProvider)Retrieve: role %s: %s %s", role, mc.Code, mc.Message)
+		return Value{}, errors.New(e)
+	}
+	return Value{
+		AccessKeyID:     mc.AccessKeyId,
+		SecretAccessKey: mc.SecretAccessKey,
+		SessionToken:    mc.Token,
+		Expires:         mc.Expiration,
+		ProviderName:    METADATA_PROVIDER,
+	}, nil
+}
+
+// metadataDo sends req, returning the body and code of the response. An endpoint that
+// cannot be reached has no credentials.
+func metadataDo(client *http.Client, req *http.Request) ([]byte, int, error) {
+	resp, do_err := client.Do(req)
+	if do_err != nil {
+		if ctx_err := req.Context().Err(); ctx_err != nil {
+			return nil, 0, ctx_err
+		}
+		return nil, 0, fmt.Errorf("credentials: metadata endpoint unreachable: %s: %w", do_err.Error(), ErrNoCredentials)
+	}
+	defer resp.Body.Close()
+	body, read_err := io.ReadAll(resp.Body)
+	if read_err != nil {
+		return nil, 0, read_err
+	}
+	return body, resp.StatusCode, nil
+}
@@ -0,0 +1,117 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+const (
+	PROCESS_PROVIDER = "process"
+	// how long the command may run unless set
+	PROCESS_TIMEOUT = time.Minute
+)
+
+// ProcessProvider runs a command that prints credentials as JSON, in the format of the
+// credential_process setting of the AWS shared config file.
+type ProcessProvider struct {
+	// The command, run with sh -c. If "", the credential_process of the profile is read
+	// from the shared credentials file, then from the shared config file
+	// (AWS_CONFIG_FILE or ~/.aws/config).
+	Command string
+	// The profile to read the command from; AWS_PROFILE or "default" if "".
+	Profile string
+	// How long the command may run; PROCESS_TIMEOUT if 0.
+	Timeout time.Duration
+}
+
+// processOutput is the JSON printed by a credential_process command.
+type processOutput struct {
+	Version         int
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      *time.Time
+}
+
+func (p *ProcessProvider) Name() string {
+	return PROCESS_PROVIDER
+}
+
+// command returns the command of p, or the credential_process of its profile.
+func (p *ProcessProvider) command() (string, error) {
+	if p.Command != "" {
+		return p.Command, nil
+	}
+	profile := profileName(p.Profile)
+	creds, creds_err := readProfile(sharedFilename(ENV_SHARED_CREDENTIALS_FILE, "credentials"), profile)
+	if creds_err == nil && creds["credential_process"] != "" {
+		return creds["credential_process"], nil
+	} else if creds_err != nil && !errors.Is(creds_err, ErrNoCredentials) {
+		return "", creds_err
+	}
+	// profiles of the config file other than the default are named "profile name"
+	section := profile
+	if profile != DEFAULT_PROFILE {
+		section = "profile " + profile
+	}
+	config, config_err := readProfile(sharedFilename(ENV_CONFIG_FILE, "config"), section)
+	if config_err != nil {
+		return "", config_err
+	}
+	if config["credential_process"] == "" {
+		return "", ErrNoCredentials
+	}
+	return config["credential_process"], nil
+}
+
+func (p *ProcessProvider) Retrieve(ctx context.Context) (Value, error) {
+	if p == nil {
+		return Value{}, errors.New("credentials.(ProcessProvider)Retrieve: receiver is nil")
+	}
+	command, command_err := p.command()
+	if command_err != nil {
+		return Value{}, command_err
+	}
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = PROCESS_TIMEOUT
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if run_err := cmd.Run(); run_err != nil {
+		e := fmt.Sprintf("credentials.(ProcessProvider)Retrieve: %s: %s: %s", command, run_err.Error(), stderr.String())
+		return Value{}, errors.New(e)
+	}
+	var out processOutput
+	if um_err := json.Unmarshal(stdout.Bytes(), &out); um_err != nil {
+		e := fmt.Sprintf("credentials.(ProcessProvider)Retrieve: %s: cannot unmarshal output: %s", command, um_err.Error())
+		return Value{}, errors.New(e)
+	}
+	if out.Version != 1 {
+		e := fmt.Sprintf("credentials.(ProcessProvider)Retrieve: %s: unsupported Version %d", command, out.Version)
+		return Value{}, errors.New(e)
+	}
+	v := Value{
+		AccessKeyID:     out.AccessKeyId,
+		SecretAccessKey: out.SecretAccessKey,
+		SessionToken:    out.SessionToken,
+		ProviderName:    PROCESS_PROVIDER,
+	}
+	if out.Expiration != nil {
+		v.Expires = *out.Expiration
+	}
+	if !v.HasKeys() {
+		e := fmt.Sprintf("credentials.(ProcessProvider)Retrieve: %s: output has no keys", command)
+		return Value{}, errors.New(e)
+	}
+	return v, nil
+}
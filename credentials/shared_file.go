@@ -0,0 +1,141 @@
+package credentials
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	SHARED_FILE_PROVIDER = "shared_file"
+
+	ENV_SHARED_CREDENTIALS_FILE = "AWS_SHARED_CREDENTIALS_FILE"
+	ENV_CONFIG_FILE             = "AWS_CONFIG_FILE"
+	ENV_PROFILE                 = "AWS_PROFILE"
+	DEFAULT_PROFILE             = "default"
+)
+
+// SharedFileProvider reads the aws_access_key_id, aws_secret_access_key and
+// aws_session_token of a profile of the shared credentials file.
+type SharedFileProvider struct {
+	// The file to read; AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials if "".
+	Filename string
+	// The profile to read; AWS_PROFILE or "default" if "".
+	Profile string
+}
+
+func (p *SharedFileProvider) Name() string {
+	return SHARED_FILE_PROVIDER
+}
+
+func (p *SharedFileProvider) Retrieve(ctx context.Context) (Value, error) {
+	if p == nil {
+		return Value{}, errors.New("credentials.(SharedFileProvider)Retrieve: receiver is nil")
+	}
+	filename := p.Filename
+	if filename == "" {
+		filename = sharedFilename(ENV_SHARED_CREDENTIALS_FILE, "credentials")
+	}
+	profile := profileName(p.Profile)
+	section, read_err := readProfile(filename, profile)
+	if read_err != nil {
+		return Value{}, read_err
+	}
+	v := Value{
+		AccessKeyID:     section["aws_access_key_id"],
+		SecretAccessKey: section["aws_secret_access_key"],
+		SessionToken:    section["aws_session_token"],
+		ProviderName:    SHARED_FILE_PROVIDER,
+	}
+	if !v.HasKeys() {
+		return Value{}, ErrNoCredentials
+	}
+	return v, nil
+}
+
+// profileName returns profile, or the profile of the environment if it is "".
+func profileName(profile string) string {
+	if profile != "" {
+		return profile
+	}
+	if env := os.Getenv(ENV_PROFILE); env != "" {
+		return env
+	}
+	return DEFAULT_PROFILE
+}
+
+// sharedFilename returns the file named by the environment variable env, or the file
+// base in ~/.aws.
+func sharedFilename(env, base string) string {
+	if f := os.Getenv(env); f != "" {
+		return f
+	}
+	home, home_err := os.UserHomeDir()
+	if home_err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".aws", base)
+}
+
+// readProfile returns the keys of the section named section of the INI file filename,
+// or ErrNoCredentials if the file or the section does not exist.
+func readProfile(filename, section string) (map[string]string, error) {
+	if filename == "" {
+		return nil, ErrNoCredentials
+	}
+	f, open_err := os.Open(filename)
+	if errors.Is(open_err, os.ErrNotExist) {
+		return nil, ErrNoCredentials
+	} else if open_err != nil {
+		return nil, open_err
+	}
+	defer f.Close()
+	sections, parse_err := parseINI(f)
+	if parse_err != nil {
+		return nil, fmt.Errorf("credentials: %s: %w", filename, parse_err)
+	}
+	keys, ok := sections[section]
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	return keys, nil
+}
+
+// parseINI reads the sections of an INI file as the AWS shared files use it: "[name]"
+// starts a section, "key = value" sets a key of it, and lines starting with # or ; are
+// comments.
+func parseINI(f *os.File) (map[string]map[string]string, error) {
+	sections := make(map[string]map[string]string)
+	var cur map[string]string
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+			continue
+		case line[0] == '[':
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section", n)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if _, seen := sections[name]; !seen {
+				sections[name] = make(map[string]string)
+			}
+			cur = sections[name]
+		default:
+			eq := strings.IndexByte(line, '=')
+			if eq < 0 {
+				return nil, fmt.Errorf("line %d: expected key = value", n)
+			}
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: key outside a section", n)
+			}
+			cur[strings.ToLower(strings.TrimSpace(line[:eq]))] = strings.TrimSpace(line[eq+1:])
+		}
+	}
+	return sections, s.Err()
+}
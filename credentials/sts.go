@@ -0,0 +1,289 @@
+package credentials
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/auth_v4/tasks"
+	"github.com/smugmug/godynamo/aws_const"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ASSUME_ROLE_PROVIDER  = "assume_role"
+	WEB_IDENTITY_PROVIDER = "web_identity"
+	// the global STS endpoint, used unless an endpoint or region is set
+	STS_ENDPOINT = "https://sts.amazonaws.com"
+	// the region requests to the global STS endpoint are signed for
+	STS_REGION  = "us-east-1"
+	STS_VERSION = "2011-06-15"
+	// how long each request to STS may take unless a client is set
+	STS_TIMEOUT = 30 * time.Second
+
+	ENV_ROLE_ARN                = "AWS_ROLE_ARN"
+	ENV_ROLE_SESSION_NAME       = "AWS_ROLE_SESSION_NAME"
+	ENV_WEB_IDENTITY_TOKEN_FILE = "AWS_WEB_IDENTITY_TOKEN_FILE"
+
+	stsService     = "sts"
+	stsContentType = "application/x-www-form-urlencoded; charset=utf-8"
+)
+
+// STS is where the STS requests of AssumeRoleProvider and WebIdentityProvider are sent.
+type STS struct {
+	// The endpoint; https://sts.Region.amazonaws.com if "" and Region is set, otherwise
+	// STS_ENDPOINT.
+	Endpoint string
+	// The region requests are signed for; STS_REGION if "".
+	Region string
+	// The client of the requests. If nil, a client with a timeout of STS_TIMEOUT is used.
+	Client *http.Client
+}
+
+// STSError is an error response of STS.
+type STSError struct {
+	StatusCode int
+	Type       string `xml:"Error>Type"`
+	Code       string `xml:"Error>Code"`
+	Message    string `xml:"Error>Message"`
+	RequestID  string `xml:"RequestId"`
+}
+
+func (e *STSError) Error() string {
+	return fmt.Sprintf("credentials: sts: %s: %s (code %d, reqid:%s)", e.Code, e.Message, e.StatusCode, e.RequestID)
+}
+
+// stsCredentials are the credentials of an STS response.
+type stsCredentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// stsResponse is the response of AssumeRole or AssumeRoleWithWebIdentity.
+type stsResponse struct {
+	AssumeRole  stsCredentials `xml:"AssumeRoleResult>Credentials"`
+	WebIdentity stsCredentials `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+}
+
+// AssumeRoleProvider returns the temporary credentials of a role, obtained with the STS
+// AssumeRole action signed with the credentials of Source. Wrap it in a Cache to reuse
+// the credentials until they are about to expire.
+//
+// example use:
+//
+//	p := &credentials.AssumeRoleProvider{
+//		Source:  credentials.NewDefaultChain("", "", ""),
+//		RoleARN: "arn:aws:iam::123456789012:role/dynamo",
+//	}
+//	c.Credentials = credentials.NewCache(p)
+type AssumeRoleProvider struct {
+	STS
+	// The credentials that assume the role.
+	Source Provider
+	// The role to assume.
+	RoleARN string
+	// Identifies the session in the logs of the role's account; generated if "".
+	RoleSessionName string
+	// Set if the role requires it of the accounts that assume it.
+	ExternalID string
+	// How long the credentials last; the default of STS if 0.
+	Duration time.Duration
+}
+
+func (p *AssumeRoleProvider) Name() string {
+	return ASSUME_ROLE_PROVIDER
+}
+
+func (p *AssumeRoleProvider) Retrieve(ctx context.Context) (Value, error) {
+	if p == nil || p.Source == nil {
+		return Value{}, errors.New("credentials.(AssumeRoleProvider)Retrieve: receiver or Source is nil")
+	}
+	if p.RoleARN == "" {
+		return Value{}, errors.New("credentials.(AssumeRoleProvider)Retrieve: RoleARN is not set")
+	}
+	source, source_err := p.Source.Retrieve(ctx)
+	if source_err != nil {
+		e := fmt.Sprintf("credentials.(AssumeRoleProvider)Retrieve: no credentials to assume %s with: %s",
+			p.RoleARN, source_err.Error())
+		return Value{}, errors.New(e)
+	}
+	params := url.Values{}
+	params.Set("Action", "AssumeRole")
+	params.Set("RoleArn", p.RoleARN)
+	params.Set("RoleSessionName", sessionName(p.RoleSessionName))
+	if p.ExternalID != "" {
+		params.Set("ExternalId", p.ExternalID)
+	}
+	if p.Duration > 0 {
+		params.Set("DurationSeconds", strconv.Itoa(int(p.Duration/time.Second)))
+	}
+	resp, err := p.STS.do(ctx, params, &source)
+	if err != nil {
+		return Value{}, err
+	}
+	return resp.AssumeRole.value(ASSUME_ROLE_PROVIDER)
+}
+
+// WebIdentityProvider returns the temporary credentials of a role, obtained with the STS
+// AssumeRoleWithWebIdentity action from an OpenID Connect token, such as the service
+// account token of a Kubernetes pod. The request is not signed; the token authenticates
+// it. The token file is read for every request, as its writer may rotate it. Wrap the
+// provider in a Cache to reuse the credentials until they are about to expire.
+type WebIdentityProvider struct {
+	STS
+	// The role to assume; AWS_ROLE_ARN if "".
+	RoleARN string
+	// Identifies the session; AWS_ROLE_SESSION_NAME, or generated, if "".
+	RoleSessionName string
+	// The file holding the token; AWS_WEB_IDENTITY_TOKEN_FILE if "".
+	TokenFile string
+	// How long the credentials last; the default of STS if 0.
+	Duration time.Duration
+}
+
+func (p *WebIdentityProvider) Name() string {
+	return WEB_IDENTITY_PROVIDER
+}
+
+func (p *WebIdentityProvider) Retrieve(ctx context.Context) (Value, error) {
+	if p == nil {
+		return Value{}, errors.New("credentials.(WebIdentityProvider)Retrieve: receiver is nil")
+	}
+	role_arn := p.RoleARN
+	if role_arn == "" {
+		role_arn = os.Getenv(ENV_ROLE_ARN)
+	}
+	token_file := p.TokenFile
+	if token_file == "" {
+		token_file = os.Getenv(ENV_WEB_IDENTITY_TOKEN_FILE)
+	}
+	if role_arn == "" || token_file == "" {
+		return Value{}, ErrNoCredentials
+	}
+	token, read_err := os.ReadFile(token_file)
+	if read_err != nil {
+		e := fmt.Sprintf("credentials.(WebIdentityProvider)Retrieve: cannot read the token: %s", read_err.Error())
+		return Value{}, errors.New(e)
+	}
+	session_name := p.RoleSessionName
+	if session_name == "" {
+		session_name = os.Getenv(ENV_ROLE_SESSION_NAME)
+	}
+	params := url.Values{}
+	params.Set("Action", "AssumeRoleWithWebIdentity")
+	params.Set("RoleArn", role_arn)
+	params.Set("RoleSessionName", sessionName(session_name))
+	params.Set("WebIdentityToken", strings.TrimSpace(string(token)))
+	if p.Duration > 0 {
+		params.Set("DurationSeconds", strconv.Itoa(int(p.Duration/time.Second)))
+	}
+	resp, err := p.STS.do(ctx, params, nil)
+	if err != nil {
+		return Value{}, err
+	}
+	return resp.WebIdentity.value(WEB_IDENTITY_PROVIDER)
+}
+
+// sessionName returns name, or a name unique to this process and time if it is "".
+func sessionName(name string) string {
+	if name != "" {
+		return name
+	}
+	return fmt.Sprintf("godynamo-%d-%d", os.Getpid(), time.Now().UnixNano())
+}
+
+// value returns the credentials of an STS response.
+func (sc stsCredentials) value(provider string) (Value, error) {
+	v := Value{
+		AccessKeyID:     sc.AccessKeyId,
+		SecretAccessKey: sc.SecretAccessKey,
+		SessionToken:    sc.SessionToken,
+		Expires:         sc.Expiration,
+		ProviderName:    provider,
+	}
+	if !v.HasKeys() {
+		return Value{}, errors.New("credentials: sts: the response has no credentials")
+	}
+	return v, nil
+}
+
+// do sends the action in params to STS, signed with v4 by signer unless it is nil.
+func (s *STS) do(ctx context.Context, params url.Values, signer *Value) (*stsResponse, error) {
+	region := s.Region
+	endpoint := s.Endpoint
+	if endpoint == "" && region != "" {
+		endpoint = "https://sts." + region + ".amazonaws.com"
+	}
+	if endpoint == "" {
+		endpoint = STS_ENDPOINT
+	}
+	if region == "" {
+		region = STS_REGION
+	}
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: STS_TIMEOUT}
+	}
+	params.Set("Version", STS_VERSION)
+	body := params.Encode()
+	req, req_err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/",
+		strings.NewReader(body))
+	if req_err != nil {
+		return nil, req_err
+	}
+	req.Header.Set(aws_const.CONTENT_TYPE_HDR, stsContentType)
+	if signer != nil {
+		signSTS(req, []byte(body), region, *signer, time.Now())
+	}
+	resp, do_err := client.Do(req)
+	if do_err != nil {
+		return nil, do_err
+	}
+	defer resp.Body.Close()
+	resp_body, read_err := io.ReadAll(resp.Body)
+	if read_err != nil {
+		return nil, read_err
+	}
+	if resp.StatusCode != http.StatusOK {
+		sts_err := &STSError{StatusCode: resp.StatusCode}
+		if um_err := xml.Unmarshal(resp_body, sts_err); um_err != nil || sts_err.Code == "" {
+			sts_err.Code = http.StatusText(resp.StatusCode)
+			sts_err.Message = string(resp_body)
+		}
+		return nil, sts_err
+	}
+	var sts_resp stsResponse
+	if um_err := xml.Unmarshal(resp_body, &sts_resp); um_err != nil {
+		e := fmt.Sprintf("credentials: sts: cannot unmarshal the response: %s", um_err.Error())
+		return nil, errors.New(e)
+	}
+	return &sts_resp, nil
+}
+
+// signSTS adds the v4 signature of v to req, a form POST to STS. auth_v4.Signer cannot be
+// used here, as auth_v4 depends on this package through conf.
+func signSTS(req *http.Request, body []byte, region string, v Value, now time.Time) {
+	req.Header.Set(aws_const.X_AMZ_DATE_HDR, now.UTC().Format(aws_const.ISO8601FMT_CONDENSED))
+	signed_headers := []string{"content-type", "host", "x-amz-date"}
+	if v.SessionToken != "" {
+		req.Header.Set(aws_const.X_AMZ_SECURITY_TOKEN_HDR, v.SessionToken)
+		signed_headers = append(signed_headers, "x-amz-security-token")
+	}
+	payload := sha256.Sum256(body)
+	canonical_request := tasks.CanonicalHTTPRequest(req, signed_headers, hex.EncodeToString(payload[:]), true)
+	str2sign := tasks.String2Sign(now, canonical_request, region, stsService)
+	signature := tasks.SignWithKey(tasks.CachedSigningKey(now, region, stsService, v.SecretAccessKey), str2sign)
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+v.AccessKeyID+
+		"/"+now.UTC().Format(aws_const.ISODATEFMT)+"/"+region+"/"+stsService+"/aws4_request, "+
+		"SignedHeaders="+strings.Join(signed_headers, ";")+", Signature="+signature)
+}
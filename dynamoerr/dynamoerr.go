@@ -0,0 +1,194 @@
+// Implements typed errors for DynamoDB error responses.
+//
+// DynamoDB reports errors in 4xx and 5xx responses with a JSON body such as:
+//
+//	{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException",
+//	 "message":"The conditional request failed"}
+//
+// New decodes these into an *APIError, which may be tested with errors.Is against the
+// sentinel values in this package, or extracted with errors.As:
+//
+//	if errors.Is(err, dynamoerr.ErrConditionalCheckFailed) { ... }
+//
+// See http://docs.aws.amazon.com/amazondynamodb/latest/developerguide/ErrorHandling.html
+package dynamoerr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"net/http"
+	"strings"
+)
+
+// Error codes reported by DynamoDB in the __type field.
+const (
+	CONDITIONAL_CHECK_FAILED        = "ConditionalCheckFailedException"
+	RESOURCE_NOT_FOUND              = "ResourceNotFoundException"
+	RESOURCE_IN_USE                 = "ResourceInUseException"
+	PROVISIONED_THROUGHPUT_EXCEEDED = "ProvisionedThroughputExceededException"
+	THROTTLING                      = "ThrottlingException"
+	REQUEST_LIMIT_EXCEEDED          = "RequestLimitExceeded"
+	UNRECOGNIZED_CLIENT             = "UnrecognizedClientException"
+	VALIDATION                      = "ValidationException"
+	TRANSACTION_CANCELED            = "TransactionCanceledException"
+	TRANSACTION_IN_PROGRESS         = "TransactionInProgressException"
+	IDEMPOTENT_PARAMETER_MISMATCH   = "IdempotentParameterMismatchException"
+	ITEM_COLLECTION_SIZE_LIMIT      = "ItemCollectionSizeLimitExceededException"
+	INTERNAL_SERVER_ERROR           = "InternalServerError"
+	LIMIT_EXCEEDED                  = "LimitExceededException"
+	SERIALIZATION                   = "SerializationException"
+	EXPIRED_ITERATOR                = "ExpiredIteratorException"
+	TRIMMED_DATA_ACCESS             = "TrimmedDataAccessException"
+)
+
+// Codes of the CancellationReasons of a TransactionCanceledException, one per action of
+// the transaction. Actions that did not cause the cancellation have the code NONE.
+const (
+	REASON_NONE                            = "None"
+	REASON_CONDITIONAL_CHECK_FAILED        = "ConditionalCheckFailed"
+	REASON_ITEM_COLLECTION_SIZE_LIMIT      = "ItemCollectionSizeLimitExceeded"
+	REASON_TRANSACTION_CONFLICT            = "TransactionConflict"
+	REASON_PROVISIONED_THROUGHPUT_EXCEEDED = "ProvisionedThroughputExceeded"
+	REASON_THROTTLING                      = "ThrottlingError"
+	REASON_VALIDATION                      = "ValidationError"
+)
+
+// Sentinel errors for use with errors.Is. An *APIError matches a sentinel
+// if its Code is one of the codes the sentinel stands for.
+var (
+	ErrConditionalCheckFailed  = errors.New("dynamoerr: conditional check failed")
+	ErrResourceNotFound        = errors.New("dynamoerr: resource not found")
+	ErrThrottled               = errors.New("dynamoerr: throttled")
+	ErrValidation              = errors.New("dynamoerr: validation error")
+	ErrTransactionCanceled     = errors.New("dynamoerr: transaction canceled")
+	ErrItemCollectionSizeLimit = errors.New("dynamoerr: item collection size limit exceeded")
+	ErrExpiredIterator         = errors.New("dynamoerr: shard iterator expired")
+	ErrTrimmedDataAccess       = errors.New("dynamoerr: stream records trimmed")
+)
+
+var sentinel_codes = map[error][]string{
+	ErrConditionalCheckFailed:  {CONDITIONAL_CHECK_FAILED},
+	ErrResourceNotFound:        {RESOURCE_NOT_FOUND},
+	ErrThrottled:               {PROVISIONED_THROUGHPUT_EXCEEDED, THROTTLING, REQUEST_LIMIT_EXCEEDED},
+	ErrValidation:              {VALIDATION},
+	ErrTransactionCanceled:     {TRANSACTION_CANCELED},
+	ErrItemCollectionSizeLimit: {ITEM_COLLECTION_SIZE_LIMIT},
+	ErrExpiredIterator:         {EXPIRED_ITERATOR},
+	ErrTrimmedDataAccess:       {TRIMMED_DATA_ACCESS},
+}
+
+// APIError is an error response from DynamoDB.
+type APIError struct {
+	// Code is the short exception name, e.g. "ResourceNotFoundException".
+	Code string
+	// Message is the human readable message from the response, if any.
+	Message string
+	// StatusCode is the http status code of the response.
+	StatusCode int
+	// RequestID is the X-Amzn-Requestid of the response.
+	RequestID string
+	// CancellationReasons are set for a TransactionCanceledException, in the order of the
+	// actions of the transaction.
+	CancellationReasons []CancellationReason
+}
+
+// CancellationReason explains the part one action of a transaction played in its
+// cancellation. Item is the item as it was when its condition failed, if the action
+// asked for it with ReturnValuesOnConditionCheckFailure.
+type CancellationReason struct {
+	Code    string
+	Message string                           `json:",omitempty"`
+	Item    attributevalue.AttributeValueMap `json:",omitempty"`
+}
+
+// errorBody is the wire format of DynamoDB errors. Some services capitalize Message.
+type errorBody struct {
+	Type                string               `json:"__type"`
+	Message             string               `json:"message"`
+	MessageUpper        string               `json:"Message"`
+	CancellationReasons []CancellationReason `json:"CancellationReasons"`
+}
+
+// New returns an *APIError describing the response, or nil if code is not an http error.
+// If the body cannot be decoded, the Code is empty and the Message is the raw body.
+func New(body []byte, code int, requestID string) *APIError {
+	if code < http.StatusBadRequest {
+		return nil
+	}
+	e := &APIError{StatusCode: code, RequestID: requestID}
+	var b errorBody
+	if um_err := json.Unmarshal(body, &b); um_err != nil {
+		e.Message = string(body)
+		return e
+	}
+	e.Code = b.Type
+	if i := strings.LastIndex(b.Type, "#"); i != -1 {
+		e.Code = b.Type[i+1:]
+	}
+	e.Message = b.Message
+	if e.Message == "" {
+		e.Message = b.MessageUpper
+	}
+	e.CancellationReasons = b.CancellationReasons
+	return e
+}
+
+// CanceledBy returns the indexes of the transaction actions that caused it to be
+// canceled, that is, those whose CancellationReason has a code other than REASON_NONE.
+func (e *APIError) CanceledBy() []int {
+	if e == nil {
+		return nil
+	}
+	var idx []int
+	for i, r := range e.CancellationReasons {
+		if r.Code != "" && r.Code != REASON_NONE {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+func (e *APIError) Error() string {
+	if e == nil {
+		return "dynamoerr.APIError: nil"
+	}
+	code := e.Code
+	if code == "" {
+		code = http.StatusText(e.StatusCode)
+	}
+	return fmt.Sprintf("dynamoerr: %s (%d, reqid:%s): %s", code, e.StatusCode, e.RequestID, e.Message)
+}
+
+// Is reports whether target is a sentinel covering e.Code, or an *APIError with the same Code.
+func (e *APIError) Is(target error) bool {
+	if e == nil {
+		return false
+	}
+	if t, ok := target.(*APIError); ok {
+		return t != nil && t.Code == e.Code
+	}
+	for _, c := range sentinel_codes[target] {
+		if c == e.Code {
+			return true
+		}
+	}
+	return false
+}
+
+// Throttled reports whether the request was rejected for exceeding throughput or request limits.
+func (e *APIError) Throttled() bool {
+	return e != nil && errors.Is(e, ErrThrottled)
+}
+
+// Retryable reports whether AWS considers the error transient: any 5xx, throttling,
+// or an UnrecognizedClientException, which may be raised while credentials rotate.
+func (e *APIError) Retryable() bool {
+	if e == nil {
+		return false
+	}
+	return e.StatusCode >= http.StatusInternalServerError ||
+		e.Throttled() ||
+		e.Code == UNRECOGNIZED_CLIENT
+}
@@ -0,0 +1,90 @@
+package dynamoerr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	s := `{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`
+	e := New([]byte(s), http.StatusBadRequest, "REQID")
+	if e == nil {
+		t.Fatalf("expected an APIError")
+	}
+	if e.Code != CONDITIONAL_CHECK_FAILED || e.Message != "The conditional request failed" ||
+		e.StatusCode != http.StatusBadRequest || e.RequestID != "REQID" {
+		t.Errorf("bad decode: %#v", e)
+	}
+	if New([]byte(`{}`), http.StatusOK, "") != nil {
+		t.Errorf("200 should not be an error")
+	}
+	raw := New([]byte(`<html>`), http.StatusBadGateway, "")
+	if raw == nil || raw.Code != "" || raw.Message != "<html>" {
+		t.Errorf("undecodable body should be kept as message: %#v", raw)
+	}
+}
+
+func TestIs(t *testing.T) {
+	cases := []struct {
+		body     string
+		sentinel error
+	}{
+		{`{"__type":"com.amazonaws.dynamodb.v20120810#ResourceNotFoundException","message":"Requested resource not found"}`, ErrResourceNotFound},
+		{`{"__type":"com.amazonaws.dynamodb.v20120810#ProvisionedThroughputExceededException","message":"x"}`, ErrThrottled},
+		{`{"__type":"com.amazon.coral.availability#ThrottlingException","message":"x"}`, ErrThrottled},
+		{`{"__type":"com.amazonaws.dynamodb.v20120810#RequestLimitExceeded","message":"x"}`, ErrThrottled},
+		{`{"__type":"com.amazon.coral.validate#ValidationException","message":"x"}`, ErrValidation},
+		{`{"__type":"com.amazonaws.dynamodb.v20120810#TransactionCanceledException","Message":"x"}`, ErrTransactionCanceled},
+		{`{"__type":"com.amazonaws.dynamodb.v20120810#ItemCollectionSizeLimitExceededException","message":"x"}`, ErrItemCollectionSizeLimit},
+	}
+	for _, c := range cases {
+		err := fmt.Errorf("wrapped: %w", New([]byte(c.body), http.StatusBadRequest, ""))
+		if !errors.Is(err, c.sentinel) {
+			t.Errorf("%s should match %v", c.body, c.sentinel)
+		}
+		if errors.Is(err, ErrConditionalCheckFailed) {
+			t.Errorf("%s should not match ErrConditionalCheckFailed", c.body)
+		}
+		var api_err *APIError
+		if !errors.As(err, &api_err) || api_err.Code == "" || api_err.Message == "" {
+			t.Errorf("%s should be extracted with errors.As", c.body)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	throttled := New([]byte(`{"__type":"com.amazon.coral.availability#ThrottlingException"}`), http.StatusBadRequest, "")
+	if !throttled.Retryable() || !throttled.Throttled() {
+		t.Errorf("throttling should be retryable")
+	}
+	if !New(nil, http.StatusInternalServerError, "").Retryable() {
+		t.Errorf("5xx should be retryable")
+	}
+	if New([]byte(`{"__type":"com.amazon.coral.validate#ValidationException"}`), http.StatusBadRequest, "").Retryable() {
+		t.Errorf("validation should not be retryable")
+	}
+	var nil_err *APIError
+	if nil_err.Retryable() {
+		t.Errorf("nil should not be retryable")
+	}
+}
+
+func TestCancellationReasons(t *testing.T) {
+	s := `{"__type":"com.amazonaws.dynamodb.v20120810#TransactionCanceledException",` +
+		`"Message":"Transaction cancelled, please refer cancellation reasons for specific reasons [None, ConditionalCheckFailed]",` +
+		`"CancellationReasons":[{"Code":"None"},{"Code":"ConditionalCheckFailed","Message":"The conditional request failed",` +
+		`"Item":{"OrderId":{"S":"1"}}}]}`
+	e := New([]byte(s), http.StatusBadRequest, "")
+	if !errors.Is(e, ErrTransactionCanceled) || len(e.CancellationReasons) != 2 {
+		t.Fatalf("bad decode: %#v", e)
+	}
+	r := e.CancellationReasons[1]
+	if r.Code != REASON_CONDITIONAL_CHECK_FAILED || r.Item["OrderId"].S != "1" {
+		t.Errorf("bad reason: %#v", r)
+	}
+	if idx := e.CanceledBy(); len(idx) != 1 || idx[0] != 1 {
+		t.Errorf("unexpected CanceledBy %v", idx)
+	}
+}
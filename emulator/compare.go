@@ -0,0 +1,79 @@
+package emulator
+
+import (
+	"errors"
+	"github.com/smugmug/godynamo/expression"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/aws_strings"
+	"github.com/smugmug/godynamo/types/cast"
+)
+
+// canonical returns the form of a key value items are stored and ordered by; it is equal
+// for equal values of the scalar types.
+func canonical(a *attributevalue.AttributeValue) string {
+	switch expression.TypeOf(a) {
+	case aws_strings.S:
+		return aws_strings.S + ":" + a.S
+	case aws_strings.N:
+		n, n_err := cast.AWSParseNumber(a.N)
+		if n_err != nil {
+			return aws_strings.N + ":" + a.N
+		}
+		return aws_strings.N + ":" + n
+	case aws_strings.B:
+		return aws_strings.B + ":" + a.B
+	}
+	return ""
+}
+
+// validValue checks the rules DynamoDB applies to every stored value.
+// Empty lists and maps are rejected as they cannot be represented by AttributeValue.
+func validValue(a *attributevalue.AttributeValue) error {
+	if a == nil || !a.Valid() || expression.TypeOf(a) == "" {
+		return errors.New("One or more parameter values were invalid: " +
+			"An AttributeValue may not contain an empty string or more than one type")
+	}
+	switch expression.TypeOf(a) {
+	case aws_strings.N:
+		if _, n_err := cast.AWSParseNumber(a.N); n_err != nil {
+			return errors.New("The parameter cannot be converted to a numeric value: " + a.N)
+		}
+	case aws_strings.NS:
+		for _, n := range a.NS {
+			if _, n_err := cast.AWSParseNumber(n); n_err != nil {
+				return errors.New("The parameter cannot be converted to a numeric value: " + n)
+			}
+		}
+	case aws_strings.L:
+		for _, v := range a.L {
+			if v_err := validValue(v); v_err != nil {
+				return v_err
+			}
+		}
+	case aws_strings.M:
+		for _, v := range a.M {
+			if v_err := validValue(v); v_err != nil {
+				return v_err
+			}
+		}
+	}
+	return nil
+}
+
+// copyValue returns a deep copy of a.
+func copyValue(a *attributevalue.AttributeValue) *attributevalue.AttributeValue {
+	c := attributevalue.NewAttributeValue()
+	if a != nil {
+		_ = a.Copy(c)
+	}
+	return c
+}
+
+// copyItem returns a deep copy of m.
+func copyItem(m attributevalue.AttributeValueMap) attributevalue.AttributeValueMap {
+	c := attributevalue.NewAttributeValueMap()
+	for k, v := range m {
+		c[k] = copyValue(v)
+	}
+	return c
+}
@@ -0,0 +1,192 @@
+package emulator
+
+import (
+	"fmt"
+	"github.com/smugmug/godynamo/expression"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/aws_strings"
+	"github.com/smugmug/godynamo/types/condition"
+	"github.com/smugmug/godynamo/types/expected"
+)
+
+const (
+	OP_AND = "AND"
+	OP_OR  = "OR"
+)
+
+// operandCount is the number of AttributeValueList entries each operator takes, or -1 for IN.
+var operandCount = map[string]int{
+	aws_strings.OP_EQ:           1,
+	aws_strings.OP_NE:           1,
+	aws_strings.OP_LE:           1,
+	aws_strings.OP_LT:           1,
+	aws_strings.OP_GE:           1,
+	aws_strings.OP_GT:           1,
+	aws_strings.OP_NULL:         0,
+	aws_strings.OP_NOT_NULL:     0,
+	aws_strings.OP_CONTAINS:     1,
+	aws_strings.OP_NOT_CONTAINS: 1,
+	aws_strings.OP_BEGINS_WITH:  1,
+	aws_strings.OP_IN:           -1,
+	aws_strings.OP_BETWEEN:      2,
+}
+
+// checkOperands validates the operator and number of operands of a condition.
+func checkOperands(op string, avl []*attributevalue.AttributeValue) *opError {
+	n, ok := operandCount[op]
+	if !ok {
+		return validation("Invalid ComparisonOperator: " + op)
+	}
+	if (n >= 0 && len(avl) != n) || (n < 0 && len(avl) == 0) {
+		e := fmt.Sprintf("One or more parameter values were invalid: "+
+			"Invalid number of argument(s) for the %s ComparisonOperator", op)
+		return validation(e)
+	}
+	for _, v := range avl {
+		if v_err := validValue(v); v_err != nil {
+			return validation(v_err.Error())
+		}
+	}
+	return nil
+}
+
+// compare evaluates the comparison operator op for the attribute value v, which is nil
+// if the attribute does not exist. The operands must have been checked with checkOperands.
+func compare(op string, v *attributevalue.AttributeValue, avl []*attributevalue.AttributeValue) bool {
+	switch op {
+	case aws_strings.OP_NULL:
+		return v == nil
+	case aws_strings.OP_NOT_NULL:
+		return v != nil
+	}
+	if v == nil {
+		return false
+	}
+	switch op {
+	case aws_strings.OP_EQ:
+		return expression.Equal(v, avl[0])
+	case aws_strings.OP_NE:
+		return !expression.Equal(v, avl[0])
+	case aws_strings.OP_LE, aws_strings.OP_LT, aws_strings.OP_GE, aws_strings.OP_GT:
+		c, ok := expression.Compare(v, avl[0])
+		if !ok {
+			return false
+		}
+		switch op {
+		case aws_strings.OP_LE:
+			return c <= 0
+		case aws_strings.OP_LT:
+			return c < 0
+		case aws_strings.OP_GE:
+			return c >= 0
+		}
+		return c > 0
+	case aws_strings.OP_BETWEEN:
+		lo, lo_ok := expression.Compare(v, avl[0])
+		hi, hi_ok := expression.Compare(v, avl[1])
+		return lo_ok && hi_ok && lo >= 0 && hi <= 0
+	case aws_strings.OP_BEGINS_WITH:
+		return expression.BeginsWith(v, avl[0])
+	case aws_strings.OP_CONTAINS:
+		return expression.Contains(v, avl[0])
+	case aws_strings.OP_NOT_CONTAINS:
+		return !expression.Contains(v, avl[0])
+	case aws_strings.OP_IN:
+		for _, a := range avl {
+			if expression.Equal(v, a) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkConditionalOperator validates the ConditionalOperator field of a request.
+func checkConditionalOperator(op string) *opError {
+	if op != "" && op != OP_AND && op != OP_OR {
+		return validation("Invalid ConditionalOperator: " + op)
+	}
+	return nil
+}
+
+// matchConditions evaluates QueryFilter or ScanFilter conditions against it.
+func matchConditions(it attributevalue.AttributeValueMap, cs condition.Conditions, conditional_op string) bool {
+	if len(cs) == 0 {
+		return true
+	}
+	for name, c := range cs {
+		ok := compare(c.ComparisonOperator, it[name], c.AttributeValueList)
+		if conditional_op == OP_OR && ok {
+			return true
+		}
+		if conditional_op != OP_OR && !ok {
+			return false
+		}
+	}
+	return conditional_op != OP_OR
+}
+
+// checkConditions validates QueryFilter or ScanFilter conditions.
+func checkConditions(cs condition.Conditions) *opError {
+	for _, c := range cs {
+		if c == nil {
+			return validation("Condition may not be null")
+		}
+		if op_err := checkOperands(c.ComparisonOperator, c.AttributeValueList); op_err != nil {
+			return op_err
+		}
+	}
+	return nil
+}
+
+// checkExpected validates the Expected constraints of a write.
+func checkExpected(ex expected.Expected) *opError {
+	for name, c := range ex {
+		if c == nil {
+			return validation("Expected may not be null")
+		}
+		if c.ComparisonOperator != "" {
+			if op_err := checkOperands(c.ComparisonOperator, c.AttributeValueList); op_err != nil {
+				return op_err
+			}
+			continue
+		}
+		has_value := c.Value != nil && expression.TypeOf(c.Value) != ""
+		if c.Exists != nil && !*c.Exists && has_value {
+			return validation("One or more parameter values were invalid: " +
+				"Value cannot be used when Exists is false for Attribute: " + name)
+		}
+		if (c.Exists == nil || *c.Exists) && !has_value {
+			return validation("One or more parameter values were invalid: " +
+				"Value must be provided when Exists is true for Attribute: " + name)
+		}
+	}
+	return nil
+}
+
+// matchExpected evaluates Expected constraints against the current item, which is nil
+// if it does not exist.
+func matchExpected(it attributevalue.AttributeValueMap, ex expected.Expected, conditional_op string) bool {
+	if len(ex) == 0 {
+		return true
+	}
+	for name, c := range ex {
+		v := it[name]
+		var ok bool
+		switch {
+		case c.ComparisonOperator != "":
+			ok = compare(c.ComparisonOperator, v, c.AttributeValueList)
+		case c.Exists != nil && !*c.Exists:
+			ok = v == nil
+		default:
+			ok = v != nil && expression.Equal(v, c.Value)
+		}
+		if conditional_op == OP_OR && ok {
+			return true
+		}
+		if conditional_op != OP_OR && !ok {
+			return false
+		}
+	}
+	return conditional_op != OP_OR
+}
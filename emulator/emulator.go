@@ -0,0 +1,280 @@
+// Implements an in-process DynamoDB emulator for tests.
+//
+// The emulator is an httptest.Server that speaks the DynamoDB_20120810 JSON protocol
+// for the table and item endpoints supported by godynamo, storing tables in memory.
+// It enforces key schemas, maintains local and global secondary indexes and paginates
+// Query, Scan and ListTables results with LastEvaluatedKey. Condition, filter, key
+// condition, projection and update expressions are evaluated with the expression package.
+// TransactWriteItems and TransactGetItems are atomic, and a canceled transaction reports
+// CancellationReasons; ClientRequestTokens are honored for TOKEN_TTL.
+// Tables may have a stream, which is served with the DynamoDBStreams_20120810 protocol
+// from the same url; SplitShard splits its open shard.
+// Requests are not authenticated beyond checking for an Authorization header, and
+// provisioned throughput is recorded but never enforced.
+//
+// example use:
+//
+//	e := emulator.New()
+//	defer e.Close()
+//	c := e.Conf()
+//	resp, err := get_item.NewGetItem().DoWithConf(c)
+package emulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/smugmug/godynamo/aws_const"
+	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/dynamoerr"
+	"github.com/smugmug/godynamo/expression"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// ERROR_PREFIX is prepended to the error code in the __type field of error responses.
+	ERROR_PREFIX = "com.amazonaws.dynamodb.v20120810#"
+	// ZONE is the region reported in the conf returned by Conf.
+	ZONE = "us-east-1"
+)
+
+// opError is an error response of an operation.
+type opError struct {
+	code    string
+	message string
+	status  int
+	// the CancellationReasons of a TransactionCanceledException
+	reasons []dynamoerr.CancellationReason
+}
+
+func validation(msg string) *opError {
+	return &opError{code: dynamoerr.VALIDATION, message: msg, status: http.StatusBadRequest}
+}
+
+func notFound(msg string) *opError {
+	return &opError{code: dynamoerr.RESOURCE_NOT_FOUND, message: msg, status: http.StatusBadRequest}
+}
+
+func inUse(msg string) *opError {
+	return &opError{code: dynamoerr.RESOURCE_IN_USE, message: msg, status: http.StatusBadRequest}
+}
+
+func conditionFailed() *opError {
+	return &opError{code: dynamoerr.CONDITIONAL_CHECK_FAILED,
+		message: "The conditional request failed", status: http.StatusBadRequest}
+}
+
+// operation handles the JSON body of one request and returns the value to encode as the response.
+type operation func(e *Emulator, body []byte) (interface{}, *opError)
+
+var operations = map[string]operation{
+	"CreateTable":        (*Emulator).createTable,
+	"DescribeTable":      (*Emulator).describeTable,
+	"ListTables":         (*Emulator).listTables,
+	"DeleteTable":        (*Emulator).deleteTable,
+	"UpdateTable":        (*Emulator).updateTable,
+	"PutItem":            (*Emulator).putItem,
+	"GetItem":            (*Emulator).getItem,
+	"UpdateItem":         (*Emulator).updateItem,
+	"DeleteItem":         (*Emulator).deleteItem,
+	"Query":              (*Emulator).query,
+	"Scan":               (*Emulator).scan,
+	"BatchGetItem":       (*Emulator).batchGetItem,
+	"BatchWriteItem":     (*Emulator).batchWriteItem,
+	"TransactGetItems":   (*Emulator).transactGetItems,
+	"TransactWriteItems": (*Emulator).transactWriteItems,
+}
+
+// streamOperations are the operations of the DynamoDB Streams API.
+var streamOperations = map[string]operation{
+	"ListStreams":      (*Emulator).listStreams,
+	"DescribeStream":   (*Emulator).describeStream,
+	"GetShardIterator": (*Emulator).getShardIterator,
+	"GetRecords":       (*Emulator).getRecords,
+}
+
+// Emulator is an in-memory DynamoDB served over http.
+type Emulator struct {
+	lock   sync.RWMutex
+	tables map[string]*table
+	srv    *httptest.Server
+	reqid  uint64
+	// TransactWriteItems requests by ClientRequestToken
+	transactions map[string]*transaction
+	// every stream, in order of creation
+	streams      []*stream
+	streamsByArn map[string]*stream
+	// the last stream record sequence number and shard number issued
+	seq      uint64
+	shardSeq uint64
+}
+
+// New starts an emulator with no tables. Call Close when done.
+func New() *Emulator {
+	e := &Emulator{tables: make(map[string]*table), transactions: make(map[string]*transaction),
+		streamsByArn: make(map[string]*stream)}
+	e.srv = httptest.NewServer(e)
+	return e
+}
+
+// Close shuts down the server.
+func (e *Emulator) Close() {
+	if e == nil || e.srv == nil {
+		return
+	}
+	e.srv.Close()
+}
+
+// URL returns the base url of the server.
+func (e *Emulator) URL() string {
+	if e == nil || e.srv == nil {
+		return ""
+	}
+	return e.srv.URL
+}
+
+// Conf returns a new initialized conf that sends requests to the emulator with
+// placeholder credentials.
+func (e *Emulator) Conf() *conf.AWS_Conf {
+	if e == nil || e.srv == nil {
+		return nil
+	}
+	c := new(conf.AWS_Conf)
+	c.Auth.AccessKey = "emulatorAccessKey"
+	c.Auth.Secret = "emulatorSecret"
+	c.Network.DynamoDB.URL = e.srv.URL
+	if u, u_err := url.Parse(e.srv.URL); u_err == nil {
+		c.Network.DynamoDB.Host = u.Hostname()
+		c.Network.DynamoDB.Port = u.Port()
+		c.Network.DynamoDB.Scheme = u.Scheme
+	}
+	c.Network.DynamoDB.Zone = ZONE
+	c.HTTPClient = e.srv.Client()
+	c.Initialized = true
+	return c
+}
+
+// ServeHTTP dispatches a request on its X-Amz-Target header.
+func (e *Emulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := atomic.AddUint64(&e.reqid, 1)
+	w.Header().Set("X-Amzn-Requestid", fmt.Sprintf("EMULATOR%016d", id))
+	w.Header().Set(aws_const.CONTENT_TYPE_HDR, aws_const.CTYPE)
+	if r.Method != aws_const.METHOD {
+		writeError(w, &opError{code: dynamoerr.SERIALIZATION,
+			message: "only POST is supported", status: http.StatusBadRequest})
+		return
+	}
+	if r.Header.Get("Authorization") == "" {
+		writeError(w, &opError{code: "MissingAuthenticationTokenException",
+			message: "Request is missing Authentication Token", status: http.StatusBadRequest})
+		return
+	}
+	target := r.Header.Get(aws_const.AMZ_TARGET_HDR)
+	var op operation
+	ok := false
+	switch {
+	case strings.HasPrefix(target, aws_const.ENDPOINT_PREFIX):
+		op, ok = operations[strings.TrimPrefix(target, aws_const.ENDPOINT_PREFIX)]
+	case strings.HasPrefix(target, aws_const.STREAMS_ENDPOINT_PREFIX):
+		op, ok = streamOperations[strings.TrimPrefix(target, aws_const.STREAMS_ENDPOINT_PREFIX)]
+	}
+	if !ok {
+		writeError(w, &opError{code: "UnknownOperationException",
+			message: "unknown target " + target, status: http.StatusBadRequest})
+		return
+	}
+	body, body_err := ioutil.ReadAll(r.Body)
+	if body_err != nil {
+		writeError(w, &opError{code: dynamoerr.SERIALIZATION,
+			message: body_err.Error(), status: http.StatusBadRequest})
+		return
+	}
+	resp, op_err := op(e, body)
+	if op_err != nil {
+		writeError(w, op_err)
+		return
+	}
+	resp_json, json_err := json.Marshal(resp)
+	if json_err != nil {
+		writeError(w, &opError{code: dynamoerr.INTERNAL_SERVER_ERROR,
+			message: json_err.Error(), status: http.StatusInternalServerError})
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp_json)
+}
+
+func writeError(w http.ResponseWriter, op_err *opError) {
+	body := map[string]interface{}{
+		"__type":  ERROR_PREFIX + op_err.code,
+		"message": op_err.message}
+	if op_err.reasons != nil {
+		body["CancellationReasons"] = op_err.reasons
+	}
+	b, _ := json.Marshal(body)
+	w.WriteHeader(op_err.status)
+	w.Write(b)
+}
+
+// decode unmarshals a request body.
+func decode(body []byte, v interface{}) *opError {
+	if um_err := json.Unmarshal(body, v); um_err != nil {
+		return &opError{code: dynamoerr.SERIALIZATION, message: um_err.Error(), status: http.StatusBadRequest}
+	}
+	return nil
+}
+
+// lookup returns the named table. The caller must hold the lock.
+func (e *Emulator) lookup(name string) (*table, *opError) {
+	if name == "" {
+		return nil, validation("TableName must be specified")
+	}
+	t, ok := e.tables[name]
+	if !ok {
+		return nil, notFound("Requested resource not found: Table: " + name + " not found")
+	}
+	return t, nil
+}
+
+// parse parses the expressions of a request. As DynamoDB does, it rejects requests that
+// mix expressions with the non-expression parameters they replace; legacy maps the names
+// of those parameters to whether they are set.
+func parse(x expression.Expressions, legacy map[string]bool) (*expression.Parsed, *opError) {
+	exprs := make([]string, 0)
+	for name, set := range map[string]bool{
+		expression.CONDITION_EXPRESSION:     x.ConditionExpression != "",
+		expression.FILTER_EXPRESSION:        x.FilterExpression != "",
+		expression.KEY_CONDITION_EXPRESSION: x.KeyConditionExpression != "",
+		expression.PROJECTION_EXPRESSION:    x.ProjectionExpression != "",
+		expression.UPDATE_EXPRESSION:        x.UpdateExpression != "",
+	} {
+		if set {
+			exprs = append(exprs, name)
+		}
+	}
+	params := make([]string, 0)
+	for name, set := range legacy {
+		if set {
+			params = append(params, name)
+		}
+	}
+	if len(exprs) != 0 && len(params) != 0 {
+		sort.Strings(exprs)
+		sort.Strings(params)
+		e := fmt.Sprintf("Can not use both expression and non-expression parameters in the same request: "+
+			"Non-expression parameters: {%s} Expression parameters: {%s}",
+			strings.Join(params, ", "), strings.Join(exprs, ", "))
+		return nil, validation(e)
+	}
+	pd, p_err := x.Parse()
+	if p_err != nil {
+		return nil, validation(p_err.Error())
+	}
+	return pd, nil
+}
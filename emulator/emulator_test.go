@@ -0,0 +1,537 @@
+package emulator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/dynamoerr"
+	batch_get_item "github.com/smugmug/godynamo/endpoints/batch_get_item"
+	batch_write_item "github.com/smugmug/godynamo/endpoints/batch_write_item"
+	create_table "github.com/smugmug/godynamo/endpoints/create_table"
+	delete_item "github.com/smugmug/godynamo/endpoints/delete_item"
+	delete_table "github.com/smugmug/godynamo/endpoints/delete_table"
+	describe_table "github.com/smugmug/godynamo/endpoints/describe_table"
+	get_item "github.com/smugmug/godynamo/endpoints/get_item"
+	list_tables "github.com/smugmug/godynamo/endpoints/list_tables"
+	put_item "github.com/smugmug/godynamo/endpoints/put_item"
+	query "github.com/smugmug/godynamo/endpoints/query"
+	scan "github.com/smugmug/godynamo/endpoints/scan"
+	transact_get_items "github.com/smugmug/godynamo/endpoints/transact_get_items"
+	transact_write_items "github.com/smugmug/godynamo/endpoints/transact_write_items"
+	update_item "github.com/smugmug/godynamo/endpoints/update_item"
+	update_table "github.com/smugmug/godynamo/endpoints/update_table"
+	"github.com/smugmug/godynamo/streams"
+	"github.com/smugmug/godynamo/types/expected"
+	"github.com/smugmug/godynamo/types/streamspecification"
+	"net/http"
+	"testing"
+)
+
+const threadTable = `{"TableName":"Thread",
+ "AttributeDefinitions":[{"AttributeName":"ForumName","AttributeType":"S"},{"AttributeName":"Subject","AttributeType":"S"},{"AttributeName":"LastPostDateTime","AttributeType":"S"},{"AttributeName":"Author","AttributeType":"S"}],
+ "KeySchema":[{"AttributeName":"ForumName","KeyType":"HASH"},{"AttributeName":"Subject","KeyType":"RANGE"}],
+ "LocalSecondaryIndexes":[{"IndexName":"LastPostIndex","KeySchema":[{"AttributeName":"ForumName","KeyType":"HASH"},{"AttributeName":"LastPostDateTime","KeyType":"RANGE"}],"Projection":{"ProjectionType":"KEYS_ONLY"}}],
+ "GlobalSecondaryIndexes":[{"IndexName":"AuthorIndex","KeySchema":[{"AttributeName":"Author","KeyType":"HASH"}],"Projection":{"ProjectionType":"INCLUDE","NonKeyAttributes":["Views"]},"ProvisionedThroughput":{"ReadCapacityUnits":1,"WriteCapacityUnits":1}}],
+ "ProvisionedThroughput":{"ReadCapacityUnits":5,"WriteCapacityUnits":5}}`
+
+// setup starts an emulator with the Thread table and n items in the forum "F".
+func setup(t *testing.T, n int) (*Emulator, *conf.AWS_Conf) {
+	e := New()
+	c := e.Conf()
+	var ct create_table.CreateTable
+	if um_err := json.Unmarshal([]byte(threadTable), &ct); um_err != nil {
+		t.Fatal(um_err)
+	}
+	if _, err := ct.DoWithConf(c); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		var p put_item.PutItem
+		s := fmt.Sprintf(`{"TableName":"Thread","Item":{"ForumName":{"S":"F"},"Subject":{"S":"s%02d"},"LastPostDateTime":{"S":"%02d"},"Views":{"N":"%d"},"Body":{"S":"b"}}}`, i, n-i, i)
+		if i%2 == 0 {
+			s = fmt.Sprintf(`{"TableName":"Thread","Item":{"ForumName":{"S":"F"},"Subject":{"S":"s%02d"},"LastPostDateTime":{"S":"%02d"},"Views":{"N":"%d"},"Body":{"S":"b"},"Author":{"S":"a"}}}`, i, n-i, i)
+		}
+		if um_err := json.Unmarshal([]byte(s), &p); um_err != nil {
+			t.Fatal(um_err)
+		}
+		if _, err := p.DoWithConf(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return e, c
+}
+
+func TestTables(t *testing.T) {
+	e, c := setup(t, 3)
+	defer e.Close()
+	d := describe_table.NewDescribeTable()
+	d.TableName = "Thread"
+	desc, err := d.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Table.TableStatus != STATUS_ACTIVE || desc.Table.ItemCount != 3 ||
+		len(desc.Table.LocalSecondaryIndexes) != 1 || len(desc.Table.GlobalSecondaryIndexes) != 1 {
+		t.Errorf("unexpected description %+v", desc.Table)
+	}
+	if desc.Table.GlobalSecondaryIndexes[0].ItemCount != 2 {
+		t.Errorf("the global index should be sparse, got %d items", desc.Table.GlobalSecondaryIndexes[0].ItemCount)
+	}
+	var ct create_table.CreateTable
+	json.Unmarshal([]byte(threadTable), &ct)
+	if _, err := ct.DoWithConf(c); err == nil {
+		t.Errorf("creating an existing table should fail")
+	}
+	ct.TableName = "Other"
+	if _, err := ct.DoWithConf(c); err != nil {
+		t.Fatal(err)
+	}
+	l := list_tables.ListTables{Limit: 1}
+	lr, err := l.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lr.TableNames) != 1 || lr.TableNames[0] != "Other" || lr.LastEvaluatedTableName != "Other" {
+		t.Errorf("unexpected first page %+v", lr)
+	}
+	l.ExclusiveStartTableName = lr.LastEvaluatedTableName
+	lr, err = l.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lr.TableNames) != 1 || lr.TableNames[0] != "Thread" || lr.LastEvaluatedTableName != "" {
+		t.Errorf("unexpected second page %+v", lr)
+	}
+	u := update_table.NewUpdateTable()
+	u.TableName = "Thread"
+	u.GlobalSecondaryIndexUpdates.IndexName = "AuthorIndex"
+	u.GlobalSecondaryIndexUpdates.ProvisionedThroughput.ReadCapacityUnits = 9
+	u.GlobalSecondaryIndexUpdates.ProvisionedThroughput.WriteCapacityUnits = 9
+	ur, err := u.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ur.TableDescription.GlobalSecondaryIndexes[0].ProvisionedThroughput.ReadCapacityUnits != 9 {
+		t.Errorf("index throughput not updated")
+	}
+	dt := delete_table.NewDeleteTable()
+	dt.TableName = "Other"
+	if _, err := dt.DoWithConf(c); err != nil {
+		t.Fatal(err)
+	}
+	d.TableName = "Other"
+	if _, err := d.DoWithConf(c); !errors.Is(err, dynamoerr.ErrResourceNotFound) {
+		t.Errorf("expected ErrResourceNotFound, got %v", err)
+	}
+}
+
+func TestItems(t *testing.T) {
+	e, c := setup(t, 1)
+	defer e.Close()
+	var g get_item.GetItem
+	json.Unmarshal([]byte(`{"TableName":"Thread","Key":{"ForumName":{"S":"F"},"Subject":{"S":"s00"}},"AttributesToGet":["Views"]}`), &g)
+	gr, err := g.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gr.Item) != 1 || gr.Item["Views"].N != "0" {
+		t.Errorf("unexpected item %v", gr.Item)
+	}
+
+	var p put_item.PutItem
+	json.Unmarshal([]byte(`{"TableName":"Thread","Item":{"ForumName":{"S":"F"},"Subject":{"S":"s00"}},"Expected":{"Subject":{"Exists":false}}}`), &p)
+	if _, err := p.DoWithConf(c); !errors.Is(err, dynamoerr.ErrConditionalCheckFailed) {
+		t.Errorf("expected ErrConditionalCheckFailed, got %v", err)
+	}
+	json.Unmarshal([]byte(`{"TableName":"Thread","Item":{"ForumName":{"N":"1"},"Subject":{"S":"s00"}}}`), &p)
+	if _, err := p.DoWithConf(c); !errors.Is(err, dynamoerr.ErrValidation) {
+		t.Errorf("expected ErrValidation for a key type mismatch, got %v", err)
+	}
+
+	var u update_item.UpdateItem
+	json.Unmarshal([]byte(`{"TableName":"Thread","Key":{"ForumName":{"S":"F"},"Subject":{"S":"s00"}},
+ "AttributeUpdates":{"Views":{"Action":"ADD","Value":{"N":"2.5"}},"Tags":{"Action":"ADD","Value":{"SS":["x","y"]}},"Body":{"Action":"DELETE"}},
+ "Expected":{"Views":{"ComparisonOperator":"LT","AttributeValueList":[{"N":"1"}]}},"ReturnValues":"ALL_NEW"}`), &u)
+	ur, err := u.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ur.Attributes["Views"].N != "2.5" || len(ur.Attributes["Tags"].SS) != 2 || ur.Attributes["Body"] != nil {
+		t.Errorf("unexpected update result %v", ur.Attributes)
+	}
+	if _, err := u.DoWithConf(c); !errors.Is(err, dynamoerr.ErrConditionalCheckFailed) {
+		t.Errorf("expected ErrConditionalCheckFailed, got %v", err)
+	}
+
+	var d delete_item.DeleteItem
+	json.Unmarshal([]byte(`{"TableName":"Thread","Key":{"ForumName":{"S":"F"},"Subject":{"S":"s00"}},"ReturnValues":"ALL_OLD"}`), &d)
+	dr, err := d.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dr.Attributes["Views"].N != "2.5" {
+		t.Errorf("unexpected old item %v", dr.Attributes)
+	}
+	gr, err = g.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gr.Item) != 0 {
+		t.Errorf("item should be deleted, got %v", gr.Item)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	e, c := setup(t, 10)
+	defer e.Close()
+	var q query.Query
+	json.Unmarshal([]byte(`{"TableName":"Thread","Limit":3,"ScanIndexForward":false,
+ "KeyConditions":{"ForumName":{"ComparisonOperator":"EQ","AttributeValueList":[{"S":"F"}]},"Subject":{"ComparisonOperator":"GE","AttributeValueList":[{"S":"s02"}]}},
+ "QueryFilter":{"Views":{"ComparisonOperator":"NE","AttributeValueList":[{"N":"5"}]}}}`), &q)
+	var subjects []string
+	pages := 0
+	for {
+		qr, err := q.DoWithConf(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, it := range qr.Items {
+			subjects = append(subjects, it["Subject"].S)
+		}
+		if len(qr.LastEvaluatedKey) == 0 {
+			break
+		}
+		q.ExclusiveStartKey = qr.LastEvaluatedKey
+	}
+	if fmt.Sprint(subjects) != "[s09 s08 s07 s06 s04 s03 s02]" || pages != 3 {
+		t.Errorf("unexpected subjects %v in %d pages", subjects, pages)
+	}
+
+	var lsi query.Query
+	json.Unmarshal([]byte(`{"TableName":"Thread","IndexName":"LastPostIndex","Limit":1,
+ "KeyConditions":{"ForumName":{"ComparisonOperator":"EQ","AttributeValueList":[{"S":"F"}]}}}`), &lsi)
+	lr, err := lsi.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lr.Items) != 1 || lr.Items[0]["Subject"].S != "s09" || lr.Items[0]["Views"] != nil ||
+		lr.LastEvaluatedKey["LastPostDateTime"].S != "01" {
+		t.Errorf("unexpected local index page %+v", lr)
+	}
+
+	var gsi query.Query
+	json.Unmarshal([]byte(`{"TableName":"Thread","IndexName":"AuthorIndex","Select":"COUNT",
+ "KeyConditions":{"Author":{"ComparisonOperator":"EQ","AttributeValueList":[{"S":"a"}]}}}`), &gsi)
+	gr, err := gsi.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gr.Count != 5 || len(gr.Items) != 0 {
+		t.Errorf("unexpected global index count %+v", gr)
+	}
+
+	var bad query.Query
+	json.Unmarshal([]byte(`{"TableName":"Thread","KeyConditions":{"Subject":{"ComparisonOperator":"EQ","AttributeValueList":[{"S":"s01"}]}}}`), &bad)
+	if _, err := bad.DoWithConf(c); !errors.Is(err, dynamoerr.ErrValidation) {
+		t.Errorf("expected ErrValidation without a hash key condition, got %v", err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	e, c := setup(t, 10)
+	defer e.Close()
+	total := 0
+	for seg := uint64(0); seg < 3; seg++ {
+		var s scan.Scan
+		json.Unmarshal([]byte(`{"TableName":"Thread","Limit":2,"ScanFilter":{"Views":{"ComparisonOperator":"BETWEEN","AttributeValueList":[{"N":"2"},{"N":"7"}]}}}`), &s)
+		s.Segment = seg
+		s.TotalSegments = 3
+		for {
+			sr, err := s.DoWithConf(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			total += int(sr.Count)
+			if len(sr.LastEvaluatedKey) == 0 {
+				break
+			}
+			s.ExclusiveStartKey = sr.LastEvaluatedKey
+		}
+	}
+	if total != 6 {
+		t.Errorf("expected 6 items across segments, got %d", total)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	e, c := setup(t, 0)
+	defer e.Close()
+	var w batch_write_item.BatchWriteItem
+	json.Unmarshal([]byte(`{"RequestItems":{"Thread":[
+ {"PutRequest":{"Item":{"ForumName":{"S":"F"},"Subject":{"S":"a"}}}},
+ {"PutRequest":{"Item":{"ForumName":{"S":"F"},"Subject":{"S":"b"}}}}]}}`), &w)
+	if _, err := w.DoWithConf(c); err != nil {
+		t.Fatal(err)
+	}
+	var g batch_get_item.BatchGetItem
+	json.Unmarshal([]byte(`{"RequestItems":{"Thread":{"Keys":[
+ {"ForumName":{"S":"F"},"Subject":{"S":"a"}},{"ForumName":{"S":"F"},"Subject":{"S":"b"}},{"ForumName":{"S":"F"},"Subject":{"S":"c"}}]}}}`), &g)
+	gr, err := g.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gr.Responses["Thread"]) != 2 || len(gr.UnprocessedKeys) != 0 {
+		t.Errorf("unexpected batch get %+v", gr)
+	}
+	json.Unmarshal([]byte(`{"RequestItems":{"Thread":[
+ {"DeleteRequest":{"Key":{"ForumName":{"S":"F"},"Subject":{"S":"a"}}}},
+ {"PutRequest":{"Item":{"ForumName":{"S":"F"},"Subject":{"S":"a"}}}}]}}`), &w)
+	if _, err := w.DoWithConf(c); !errors.Is(err, dynamoerr.ErrValidation) {
+		t.Errorf("expected ErrValidation for duplicate keys, got %v", err)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	e, c := setup(t, 3)
+	defer e.Close()
+	var w transact_write_items.TransactWriteItems
+	json.Unmarshal([]byte(`{"ClientRequestToken":"T1","TransactItems":[
+ {"ConditionCheck":{"TableName":"Thread","Key":{"ForumName":{"S":"F"},"Subject":{"S":"s00"}},"ConditionExpression":"attribute_exists(Body)"}},
+ {"Put":{"TableName":"Thread","Item":{"ForumName":{"S":"F"},"Subject":{"S":"new"}},"ConditionExpression":"attribute_not_exists(Subject)"}},
+ {"Update":{"TableName":"Thread","Key":{"ForumName":{"S":"F"},"Subject":{"S":"s01"}},"UpdateExpression":"SET #v = #v + :one",
+  "ExpressionAttributeNames":{"#v":"Views"},"ExpressionAttributeValues":{":one":{"N":"1"}}}},
+ {"Delete":{"TableName":"Thread","Key":{"ForumName":{"S":"F"},"Subject":{"S":"s02"}}}}]}`), &w)
+	if _, err := w.DoWithConf(c); err != nil {
+		t.Fatal(err)
+	}
+	// the same token and request succeed again without being applied twice
+	if _, err := w.DoWithConf(c); err != nil {
+		t.Fatal(err)
+	}
+	var g transact_get_items.TransactGetItems
+	json.Unmarshal([]byte(`{"TransactItems":[
+ {"Get":{"TableName":"Thread","Key":{"ForumName":{"S":"F"},"Subject":{"S":"s01"}},"ProjectionExpression":"#v","ExpressionAttributeNames":{"#v":"Views"}}},
+ {"Get":{"TableName":"Thread","Key":{"ForumName":{"S":"F"},"Subject":{"S":"s02"}}}},
+ {"Get":{"TableName":"Thread","Key":{"ForumName":{"S":"F"},"Subject":{"S":"new"}}}}]}`), &g)
+	gr, err := g.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gr.Responses) != 3 || gr.Responses[0].Item["Views"].N != "2" || len(gr.Responses[0].Item) != 1 ||
+		len(gr.Responses[1].Item) != 0 || gr.Responses[2].Item["Subject"].S != "new" {
+		t.Errorf("unexpected transact get %+v", gr)
+	}
+
+	w.TransactItems = w.TransactItems[:1]
+	if _, err := w.DoWithConf(c); !errors.Is(err, &dynamoerr.APIError{Code: dynamoerr.IDEMPOTENT_PARAMETER_MISMATCH}) {
+		t.Errorf("expected IdempotentParameterMismatchException, got %v", err)
+	}
+
+	// the put fails its condition, so the update is not applied either
+	w = transact_write_items.TransactWriteItems{}
+	json.Unmarshal([]byte(`{"TransactItems":[
+ {"Update":{"TableName":"Thread","Key":{"ForumName":{"S":"F"},"Subject":{"S":"s01"}},"UpdateExpression":"SET #v = :zero",
+  "ExpressionAttributeNames":{"#v":"Views"},"ExpressionAttributeValues":{":zero":{"N":"0"}}}},
+ {"Put":{"TableName":"Thread","Item":{"ForumName":{"S":"F"},"Subject":{"S":"new"}},"ConditionExpression":"attribute_not_exists(Subject)",
+  "ReturnValuesOnConditionCheckFailure":"ALL_OLD"}}]}`), &w)
+	_, err = w.DoWithConf(c)
+	var api_err *dynamoerr.APIError
+	if !errors.Is(err, dynamoerr.ErrTransactionCanceled) || !errors.As(err, &api_err) {
+		t.Fatalf("expected ErrTransactionCanceled, got %v", err)
+	}
+	if idx := api_err.CanceledBy(); len(idx) != 1 || idx[0] != 1 ||
+		api_err.CancellationReasons[0].Code != dynamoerr.REASON_NONE ||
+		api_err.CancellationReasons[1].Item["Subject"].S != "new" {
+		t.Errorf("unexpected cancellation reasons %+v", api_err.CancellationReasons)
+	}
+	if gr, _ := g.DoWithConf(c); gr.Responses[0].Item["Views"].N != "2" {
+		t.Errorf("canceled transaction was applied")
+	}
+
+	w = transact_write_items.TransactWriteItems{}
+	json.Unmarshal([]byte(`{"TransactItems":[
+ {"Delete":{"TableName":"Thread","Key":{"ForumName":{"S":"F"},"Subject":{"S":"s01"}}}},
+ {"ConditionCheck":{"TableName":"Thread","Key":{"ForumName":{"S":"F"},"Subject":{"S":"s01"}},"ConditionExpression":"attribute_exists(Body)"}}]}`), &w)
+	if _, err := w.DoWithConf(c); !errors.Is(err, dynamoerr.ErrValidation) {
+		t.Errorf("expected ErrValidation for two actions on one item, got %v", err)
+	}
+}
+
+func TestExpressions(t *testing.T) {
+	e, c := setup(t, 10)
+	defer e.Close()
+	var u update_item.UpdateItem
+	json.Unmarshal([]byte(`{"TableName":"Thread","Key":{"ForumName":{"S":"F"},"Subject":{"S":"s03"}},
+ "UpdateExpression":"SET #v = #v + :one, Tags = :tags REMOVE Body","ConditionExpression":"attribute_exists(Body)",
+ "ExpressionAttributeNames":{"#v":"Views"},"ExpressionAttributeValues":{":one":{"N":"1"},":tags":{"SS":["x"]}},
+ "ReturnValues":"UPDATED_NEW"}`), &u)
+	if err := u.ValidateExpressions(); err != nil {
+		t.Fatal(err)
+	}
+	ur, err := u.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ur.Attributes) != 2 || ur.Attributes["Views"].N != "4" || len(ur.Attributes["Tags"].SS) != 1 {
+		t.Errorf("unexpected update result %v", ur.Attributes)
+	}
+	if _, err := u.DoWithConf(c); !errors.Is(err, dynamoerr.ErrConditionalCheckFailed) {
+		t.Errorf("expected ErrConditionalCheckFailed once Body is removed, got %v", err)
+	}
+	u.ConditionExpression = ""
+	u.UpdateExpression = "SET Subject = :tags, #v = :one"
+	if _, err := u.DoWithConf(c); !errors.Is(err, dynamoerr.ErrValidation) {
+		t.Errorf("expected ErrValidation for updating a key attribute, got %v", err)
+	}
+
+	var p put_item.PutItem
+	json.Unmarshal([]byte(`{"TableName":"Thread","Item":{"ForumName":{"S":"F"},"Subject":{"S":"s03"}},
+ "ConditionExpression":"attribute_not_exists(Subject)"}`), &p)
+	if _, err := p.DoWithConf(c); !errors.Is(err, dynamoerr.ErrConditionalCheckFailed) {
+		t.Errorf("expected ErrConditionalCheckFailed, got %v", err)
+	}
+	p.Expected = expected.Expected{"Subject": {Exists: new(bool)}}
+	if _, err := p.DoWithConf(c); !errors.Is(err, dynamoerr.ErrValidation) {
+		t.Errorf("expected ErrValidation for mixing Expected and ConditionExpression, got %v", err)
+	}
+
+	var g get_item.GetItem
+	json.Unmarshal([]byte(`{"TableName":"Thread","Key":{"ForumName":{"S":"F"},"Subject":{"S":"s03"}},
+ "ProjectionExpression":"Tags, #v","ExpressionAttributeNames":{"#v":"Views"}}`), &g)
+	gr, err := g.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gr.Item) != 2 || gr.Item["Views"].N != "4" {
+		t.Errorf("unexpected projected item %v", gr.Item)
+	}
+
+	var q query.Query
+	json.Unmarshal([]byte(`{"TableName":"Thread","KeyConditionExpression":"ForumName = :f AND Subject BETWEEN :lo AND :hi",
+ "FilterExpression":"#v > :n","ProjectionExpression":"Subject","ExpressionAttributeNames":{"#v":"Views"},
+ "ExpressionAttributeValues":{":f":{"S":"F"},":lo":{"S":"s02"},":hi":{"S":"s06"},":n":{"N":"3"}}}`), &q)
+	qr, err := q.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qr.Count != 4 || qr.ScannedCount != 5 || len(qr.Items[0]) != 1 {
+		t.Errorf("unexpected query result %+v", qr)
+	}
+	q.KeyConditionExpression = "Subject = :lo"
+	if _, err := q.DoWithConf(c); !errors.Is(err, dynamoerr.ErrValidation) {
+		t.Errorf("expected ErrValidation without a hash key condition, got %v", err)
+	}
+
+	var s scan.Scan
+	json.Unmarshal([]byte(`{"TableName":"Thread","FilterExpression":"attribute_exists(Author)",
+ "ExpressionAttributeValues":{":unused":{"S":"x"}}}`), &s)
+	if _, err := s.DoWithConf(c); !errors.Is(err, dynamoerr.ErrValidation) {
+		t.Errorf("expected ErrValidation for an unused value, got %v", err)
+	}
+	s.ExpressionAttributeValues = nil
+	sr, err := s.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sr.Count != 5 {
+		t.Errorf("expected 5 items with an Author, got %d", sr.Count)
+	}
+}
+
+func TestStreams(t *testing.T) {
+	e := New()
+	defer e.Close()
+	c := e.Conf()
+	var ct create_table.CreateTable
+	json.Unmarshal([]byte(threadTable), &ct)
+	ct.StreamSpecification = streamspecification.NewStreamSpecification()
+	ct.StreamSpecification.StreamEnabled = true
+	ct.StreamSpecification.StreamViewType = streams.NEW_AND_OLD_IMAGES
+	resp, err := ct.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arn := resp.TableDescription.LatestStreamArn
+	if arn == "" {
+		t.Fatal("expected a stream arn")
+	}
+	put := func(subject, views string) {
+		var p put_item.PutItem
+		s := fmt.Sprintf(`{"TableName":"Thread","Item":{"ForumName":{"S":"F"},"Subject":{"S":"%s"},"Views":{"N":"%s"}}}`, subject, views)
+		json.Unmarshal([]byte(s), &p)
+		if _, p_err := p.DoWithConf(c); p_err != nil {
+			t.Fatal(p_err)
+		}
+	}
+	put("a", "1")
+	put("a", "1") // unchanged, no record
+	put("a", "2")
+	if split_err := e.SplitShard("Thread"); split_err != nil {
+		t.Fatal(split_err)
+	}
+	var d delete_item.DeleteItem
+	json.Unmarshal([]byte(`{"TableName":"Thread","Key":{"ForumName":{"S":"F"},"Subject":{"S":"a"}}}`), &d)
+	if _, d_err := d.DoWithConf(c); d_err != nil {
+		t.Fatal(d_err)
+	}
+
+	desc, err := streams.NewDescribeStream(arn).DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards := desc.StreamDescription.Shards
+	if desc.StreamDescription.StreamStatus != streams.ENABLED || len(shards) != 2 ||
+		!shards[0].Closed() || shards[1].Closed() || shards[1].ParentShardId != shards[0].ShardId {
+		t.Fatalf("unexpected description %+v", desc.StreamDescription)
+	}
+	read := func(shard_id string) []streams.Record {
+		it, it_err := streams.NewGetShardIterator(arn, shard_id, streams.TRIM_HORIZON).DoWithConf(c)
+		if it_err != nil {
+			t.Fatal(it_err)
+		}
+		r, r_err := streams.NewGetRecords(it.ShardIterator).DoWithConf(c)
+		if r_err != nil {
+			t.Fatal(r_err)
+		}
+		if shard_id == shards[0].ShardId && r.NextShardIterator != "" {
+			t.Errorf("a closed shard read to its end should have no next iterator")
+		}
+		return r.Records
+	}
+	parent := read(shards[0].ShardId)
+	if len(parent) != 2 || parent[0].EventName != streams.INSERT || parent[1].EventName != streams.MODIFY ||
+		parent[1].Dynamodb.OldImage["Views"].N != "1" || parent[1].Dynamodb.NewImage["Views"].N != "2" {
+		t.Errorf("unexpected parent records %+v", parent)
+	}
+	child := read(shards[1].ShardId)
+	if len(child) != 1 || child[0].EventName != streams.REMOVE || child[0].Dynamodb.NewImage != nil ||
+		child[0].Dynamodb.SequenceNumber <= parent[1].Dynamodb.SequenceNumber {
+		t.Errorf("unexpected child records %+v", child)
+	}
+
+	after := streams.NewGetShardIterator(arn, shards[0].ShardId, streams.AFTER_SEQUENCE_NUMBER)
+	after.SequenceNumber = parent[0].Dynamodb.SequenceNumber
+	it, err := after.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := streams.NewGetRecords(it.ShardIterator).DoWithConf(c)
+	if err != nil || len(r.Records) != 1 || r.Records[0].EventName != streams.MODIFY {
+		t.Errorf("AFTER_SEQUENCE_NUMBER should start at the second record, got %+v %v", r, err)
+	}
+}
+
+func TestUnauthenticated(t *testing.T) {
+	e := New()
+	defer e.Close()
+	resp, err := http.Post(e.URL(), "application/x-amz-json-1.0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 without an Authorization header, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,479 @@
+package emulator
+
+import (
+	"fmt"
+	batch_get_item "github.com/smugmug/godynamo/endpoints/batch_get_item"
+	batch_write_item "github.com/smugmug/godynamo/endpoints/batch_write_item"
+	delete_item "github.com/smugmug/godynamo/endpoints/delete_item"
+	get_item "github.com/smugmug/godynamo/endpoints/get_item"
+	put_item "github.com/smugmug/godynamo/endpoints/put_item"
+	update_item "github.com/smugmug/godynamo/endpoints/update_item"
+	"github.com/smugmug/godynamo/expression"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/aws_strings"
+	"github.com/smugmug/godynamo/types/capacity"
+	"github.com/smugmug/godynamo/types/item"
+	"github.com/smugmug/godynamo/types/itemcollectionmetrics"
+)
+
+// consumed returns the ConsumedCapacity to report for a request on table, or nil if it
+// was not requested. Every request is reported as consuming one unit.
+func consumed(return_consumed string, table string) *capacity.ConsumedCapacity {
+	if return_consumed == "" || return_consumed == aws_strings.RETVAL_NONE {
+		return nil
+	}
+	c := capacity.NewConsumedCapacity()
+	c.TableName = table
+	c.CapacityUnits = 1
+	return c
+}
+
+// selectAttributes returns the named attributes of it or those selected by projection, or
+// a copy of it if neither is given.
+func selectAttributes(it attributevalue.AttributeValueMap, names []string,
+	projection *expression.Projection) attributevalue.AttributeValueMap {
+	if projection != nil {
+		return attributevalue.AttributeValueMap(projection.Apply(item.Item(it)))
+	}
+	if len(names) == 0 {
+		return copyItem(it)
+	}
+	s := attributevalue.NewAttributeValueMap()
+	for _, name := range names {
+		if v, ok := it[name]; ok {
+			s[name] = copyValue(v)
+		}
+	}
+	return s
+}
+
+// checkReturnValues validates the ReturnValues of a PutItem or DeleteItem request.
+func checkReturnValues(rv string) *opError {
+	if rv != "" && rv != aws_strings.RETVAL_NONE && rv != aws_strings.RETVAL_ALL_OLD {
+		return validation("Return values set to invalid value: " + rv)
+	}
+	return nil
+}
+
+type getItemResponse struct {
+	Item             attributevalue.AttributeValueMap `json:",omitempty"`
+	ConsumedCapacity *capacity.ConsumedCapacity       `json:",omitempty"`
+}
+
+func (e *Emulator) getItem(body []byte) (interface{}, *opError) {
+	var req get_item.GetItem
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	pd, p_err := parse(expression.Expressions{
+		ProjectionExpression:     req.ProjectionExpression,
+		ExpressionAttributeNames: req.ExpressionAttributeNames,
+	}, map[string]bool{"AttributesToGet": len(req.AttributesToGet) != 0})
+	if p_err != nil {
+		return nil, p_err
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	t, t_err := e.lookup(req.TableName)
+	if t_err != nil {
+		return nil, t_err
+	}
+	key := attributevalue.AttributeValueMap(req.Key)
+	if k_err := t.checkKey(key); k_err != nil {
+		return nil, validation(k_err.Error())
+	}
+	resp := getItemResponse{ConsumedCapacity: consumed(req.ReturnConsumedCapacity, t.name)}
+	if it := t.get(key); it != nil {
+		resp.Item = selectAttributes(it, req.AttributesToGet, pd.Projection)
+	}
+	return resp, nil
+}
+
+func (e *Emulator) putItem(body []byte) (interface{}, *opError) {
+	var req put_item.PutItem
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	if rv_err := checkReturnValues(req.ReturnValues); rv_err != nil {
+		return nil, rv_err
+	}
+	if ex_err := checkExpected(req.Expected); ex_err != nil {
+		return nil, ex_err
+	}
+	if co_err := checkConditionalOperator(req.ConditionalOperator); co_err != nil {
+		return nil, co_err
+	}
+	pd, p_err := parse(expression.Expressions{
+		ConditionExpression:       req.ConditionExpression,
+		ExpressionAttributeNames:  req.ExpressionAttributeNames,
+		ExpressionAttributeValues: req.ExpressionAttributeValues,
+	}, map[string]bool{"Expected": len(req.Expected) != 0, "ConditionalOperator": req.ConditionalOperator != ""})
+	if p_err != nil {
+		return nil, p_err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	t, t_err := e.lookup(req.TableName)
+	if t_err != nil {
+		return nil, t_err
+	}
+	it := attributevalue.AttributeValueMap(req.Item)
+	if it_err := t.checkItem(it); it_err != nil {
+		return nil, validation(it_err.Error())
+	}
+	if cur := t.get(it); !matchExpected(cur, req.Expected, req.ConditionalOperator) ||
+		!pd.Condition.Evaluate(item.Item(cur)) {
+		return nil, conditionFailed()
+	}
+	old := t.put(it)
+	resp := put_item.Response{ConsumedCapacity: consumed(req.ReturnConsumedCapacity, t.name)}
+	if req.ReturnValues == aws_strings.RETVAL_ALL_OLD && old != nil {
+		resp.Attributes = old
+	}
+	return resp, nil
+}
+
+func (e *Emulator) deleteItem(body []byte) (interface{}, *opError) {
+	var req delete_item.DeleteItem
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	if rv_err := checkReturnValues(req.ReturnValues); rv_err != nil {
+		return nil, rv_err
+	}
+	if ex_err := checkExpected(req.Expected); ex_err != nil {
+		return nil, ex_err
+	}
+	if co_err := checkConditionalOperator(req.ConditionalOperator); co_err != nil {
+		return nil, co_err
+	}
+	pd, p_err := parse(expression.Expressions{
+		ConditionExpression:       req.ConditionExpression,
+		ExpressionAttributeNames:  req.ExpressionAttributeNames,
+		ExpressionAttributeValues: req.ExpressionAttributeValues,
+	}, map[string]bool{"Expected": len(req.Expected) != 0, "ConditionalOperator": req.ConditionalOperator != ""})
+	if p_err != nil {
+		return nil, p_err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	t, t_err := e.lookup(req.TableName)
+	if t_err != nil {
+		return nil, t_err
+	}
+	key := attributevalue.AttributeValueMap(req.Key)
+	if k_err := t.checkKey(key); k_err != nil {
+		return nil, validation(k_err.Error())
+	}
+	if cur := t.get(key); !matchExpected(cur, req.Expected, req.ConditionalOperator) ||
+		!pd.Condition.Evaluate(item.Item(cur)) {
+		return nil, conditionFailed()
+	}
+	old := t.remove(key)
+	resp := delete_item.Response{ConsumedCapacity: consumed(req.ReturnConsumedCapacity, t.name)}
+	if req.ReturnValues == aws_strings.RETVAL_ALL_OLD && old != nil {
+		resp.Attributes = old
+	}
+	return resp, nil
+}
+
+// applyUpdate applies one AttributeUpdates action to it.
+func applyUpdate(it attributevalue.AttributeValueMap, name string, u *attributevalue.AttributeValueUpdate) *opError {
+	if u == nil {
+		return validation("AttributeUpdates may not contain null values")
+	}
+	has_value := u.Value != nil && expression.TypeOf(u.Value) != ""
+	if has_value {
+		if v_err := validValue(u.Value); v_err != nil {
+			return validation(v_err.Error())
+		}
+	}
+	action := u.Action
+	if action == "" {
+		action = aws_strings.ACTION_PUT
+	}
+	cur := it[name]
+	switch action {
+	case aws_strings.ACTION_PUT:
+		if !has_value {
+			return validation("Only DELETE action is allowed when no attribute value is specified")
+		}
+		it[name] = copyValue(u.Value)
+	case aws_strings.ACTION_DEL:
+		if !has_value {
+			delete(it, name)
+			return nil
+		}
+		t := expression.TypeOf(u.Value)
+		if t != aws_strings.SS && t != aws_strings.NS && t != aws_strings.BS {
+			return validation("DELETE action with value is not supported for the type " + t)
+		}
+		if cur != nil && expression.TypeOf(cur) != t {
+			return validation("Type mismatch for attribute to update")
+		}
+		kept, d_err := expression.DeleteValue(cur, u.Value)
+		if d_err != nil {
+			return validation(d_err.Error())
+		}
+		if kept == nil {
+			delete(it, name)
+		} else {
+			it[name] = kept
+		}
+	case aws_strings.ACTION_ADD:
+		if !has_value {
+			return validation("ADD action requires a value")
+		}
+		t := expression.TypeOf(u.Value)
+		if cur != nil && expression.TypeOf(cur) != t {
+			return validation("Type mismatch for attribute to update")
+		}
+		if t != aws_strings.N && t != aws_strings.SS && t != aws_strings.NS && t != aws_strings.BS {
+			return validation("ADD action is only supported for numbers and sets")
+		}
+		sum, a_err := expression.AddValue(cur, u.Value)
+		if a_err != nil {
+			return validation(a_err.Error())
+		}
+		it[name] = sum
+	default:
+		return validation("Invalid Action: " + action)
+	}
+	return nil
+}
+
+func (e *Emulator) updateItem(body []byte) (interface{}, *opError) {
+	var req update_item.UpdateItem
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	switch req.ReturnValues {
+	case "", aws_strings.RETVAL_NONE, aws_strings.RETVAL_ALL_OLD, aws_strings.RETVAL_ALL_NEW,
+		aws_strings.RETVAL_UPDATED_OLD, aws_strings.RETVAL_UPDATED_NEW:
+	default:
+		return nil, validation("Return values set to invalid value: " + req.ReturnValues)
+	}
+	if ex_err := checkExpected(req.Expected); ex_err != nil {
+		return nil, ex_err
+	}
+	if co_err := checkConditionalOperator(req.ConditionalOperator); co_err != nil {
+		return nil, co_err
+	}
+	pd, p_err := parse(expression.Expressions{
+		ConditionExpression:       req.ConditionExpression,
+		UpdateExpression:          req.UpdateExpression,
+		ExpressionAttributeNames:  req.ExpressionAttributeNames,
+		ExpressionAttributeValues: req.ExpressionAttributeValues,
+	}, map[string]bool{"AttributeUpdates": len(req.AttributeUpdates) != 0,
+		"Expected": len(req.Expected) != 0, "ConditionalOperator": req.ConditionalOperator != ""})
+	if p_err != nil {
+		return nil, p_err
+	}
+	// the names of the top level attributes written by the update
+	names := make([]string, 0, len(req.AttributeUpdates))
+	for name := range req.AttributeUpdates {
+		names = append(names, name)
+	}
+	if pd.Update != nil {
+		names = pd.Update.Attributes()
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	t, t_err := e.lookup(req.TableName)
+	if t_err != nil {
+		return nil, t_err
+	}
+	key := attributevalue.AttributeValueMap(req.Key)
+	if k_err := t.checkKey(key); k_err != nil {
+		return nil, validation(k_err.Error())
+	}
+	for _, name := range names {
+		if _, is_key := key[name]; is_key {
+			e := fmt.Sprintf("One or more parameter values were invalid: "+
+				"Cannot update attribute %s. This attribute is part of the key", name)
+			return nil, validation(e)
+		}
+	}
+	old := t.get(key)
+	if !matchExpected(old, req.Expected, req.ConditionalOperator) || !pd.Condition.Evaluate(item.Item(old)) {
+		return nil, conditionFailed()
+	}
+	updated := copyItem(key)
+	if old != nil {
+		updated = copyItem(old)
+	}
+	for name, u := range req.AttributeUpdates {
+		if u_err := applyUpdate(updated, name, u); u_err != nil {
+			return nil, u_err
+		}
+	}
+	if pd.Update != nil {
+		applied, a_err := pd.Update.Apply(item.Item(updated))
+		if a_err != nil {
+			return nil, validation(a_err.Error())
+		}
+		updated = attributevalue.AttributeValueMap(applied)
+	}
+	if it_err := t.checkItem(updated); it_err != nil {
+		return nil, validation(it_err.Error())
+	}
+	t.put(updated)
+	resp := update_item.Response{ConsumedCapacity: consumed(req.ReturnConsumedCapacity, t.name)}
+	switch req.ReturnValues {
+	case aws_strings.RETVAL_ALL_OLD:
+		if old != nil {
+			resp.Attributes = old
+		}
+	case aws_strings.RETVAL_ALL_NEW:
+		resp.Attributes = copyItem(updated)
+	case aws_strings.RETVAL_UPDATED_OLD, aws_strings.RETVAL_UPDATED_NEW:
+		src := updated
+		if req.ReturnValues == aws_strings.RETVAL_UPDATED_OLD {
+			src = old
+		}
+		if a := selectAttributes(src, names, nil); len(a) != 0 && len(names) != 0 {
+			resp.Attributes = a
+		}
+	}
+	return resp, nil
+}
+
+type batchGetItemResponse struct {
+	ConsumedCapacity []*capacity.ConsumedCapacity `json:",omitempty"`
+	Responses        map[string][]attributevalue.AttributeValueMap
+	UnprocessedKeys  batch_get_item.Table2Requests
+}
+
+func (e *Emulator) batchGetItem(body []byte) (interface{}, *opError) {
+	var req batch_get_item.BatchGetItem
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	n := 0
+	projections := make(map[string]*expression.Projection)
+	for name, ri := range req.RequestItems {
+		if ri == nil || len(ri.Keys) == 0 {
+			return nil, validation("Keys must be specified for each table in RequestItems")
+		}
+		pd, p_err := parse(expression.Expressions{
+			ProjectionExpression:     ri.ProjectionExpression,
+			ExpressionAttributeNames: ri.ExpressionAttributeNames,
+		}, map[string]bool{"AttributesToGet": len(ri.AttributesToGet) != 0})
+		if p_err != nil {
+			return nil, p_err
+		}
+		projections[name] = pd.Projection
+		n += len(ri.Keys)
+	}
+	if n == 0 || n > batch_get_item.QUERY_LIM {
+		e := fmt.Sprintf("Too many items requested for the BatchGetItem call: %d", n)
+		return nil, validation(e)
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	resp := batchGetItemResponse{
+		Responses:       make(map[string][]attributevalue.AttributeValueMap),
+		UnprocessedKeys: make(batch_get_item.Table2Requests),
+	}
+	for name, ri := range req.RequestItems {
+		t, t_err := e.lookup(name)
+		if t_err != nil {
+			return nil, t_err
+		}
+		seen := make(map[string]bool)
+		for _, k := range ri.Keys {
+			key := attributevalue.AttributeValueMap(k)
+			if k_err := t.checkKey(key); k_err != nil {
+				return nil, validation(k_err.Error())
+			}
+			enc := encodeKey(key, t.keySchema)
+			if seen[enc] {
+				return nil, validation("Provided list of item keys contains duplicates")
+			}
+			seen[enc] = true
+		}
+		items := make([]attributevalue.AttributeValueMap, 0, len(ri.Keys))
+		for _, k := range ri.Keys {
+			if it := t.get(attributevalue.AttributeValueMap(k)); it != nil {
+				items = append(items, selectAttributes(it, ri.AttributesToGet, projections[name]))
+			}
+		}
+		resp.Responses[name] = items
+		if c := consumed(req.ReturnConsumedCapacity, name); c != nil {
+			resp.ConsumedCapacity = append(resp.ConsumedCapacity, c)
+		}
+	}
+	return resp, nil
+}
+
+type batchWriteItemResponse struct {
+	ConsumedCapacity      []*capacity.ConsumedCapacity `json:",omitempty"`
+	ItemCollectionMetrics itemcollectionmetrics.ItemCollectionMetricsMap
+	UnprocessedItems      batch_write_item.Table2Requests
+}
+
+func (e *Emulator) batchWriteItem(body []byte) (interface{}, *opError) {
+	var req batch_write_item.BatchWriteItem
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	n := 0
+	for _, ris := range req.RequestItems {
+		n += len(ris)
+	}
+	if n == 0 || n > batch_write_item.QUERY_LIM {
+		e := fmt.Sprintf("Too many items requested for the BatchWriteItem call: %d", n)
+		return nil, validation(e)
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	// validate every request before writing anything
+	for name, ris := range req.RequestItems {
+		t, t_err := e.lookup(name)
+		if t_err != nil {
+			return nil, t_err
+		}
+		seen := make(map[string]bool)
+		for _, ri := range ris {
+			var key attributevalue.AttributeValueMap
+			switch {
+			case ri.PutRequest != nil && ri.DeleteRequest == nil:
+				it := attributevalue.AttributeValueMap(ri.PutRequest.Item)
+				if it_err := t.checkItem(it); it_err != nil {
+					return nil, validation(it_err.Error())
+				}
+				key = it
+			case ri.DeleteRequest != nil && ri.PutRequest == nil:
+				key = attributevalue.AttributeValueMap(ri.DeleteRequest.Key)
+				if k_err := t.checkKey(key); k_err != nil {
+					return nil, validation(k_err.Error())
+				}
+			default:
+				return nil, validation("Each request must contain exactly one of PutRequest or DeleteRequest")
+			}
+			enc := encodeKey(key, t.keySchema)
+			if seen[enc] {
+				return nil, validation("Provided list of item keys contains duplicates")
+			}
+			seen[enc] = true
+		}
+	}
+	resp := batchWriteItemResponse{
+		ItemCollectionMetrics: itemcollectionmetrics.NewItemCollectionMetricsMap(),
+		UnprocessedItems:      make(batch_write_item.Table2Requests),
+	}
+	for name, ris := range req.RequestItems {
+		t := e.tables[name]
+		for _, ri := range ris {
+			if ri.PutRequest != nil {
+				t.put(attributevalue.AttributeValueMap(ri.PutRequest.Item))
+			} else {
+				t.remove(attributevalue.AttributeValueMap(ri.DeleteRequest.Key))
+			}
+		}
+		if c := consumed(req.ReturnConsumedCapacity, name); c != nil {
+			resp.ConsumedCapacity = append(resp.ConsumedCapacity, c)
+		}
+	}
+	return resp, nil
+}
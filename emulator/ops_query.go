@@ -0,0 +1,315 @@
+package emulator
+
+import (
+	"fmt"
+	query "github.com/smugmug/godynamo/endpoints/query"
+	scan "github.com/smugmug/godynamo/endpoints/scan"
+	"github.com/smugmug/godynamo/expression"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/aws_strings"
+	"github.com/smugmug/godynamo/types/capacity"
+	"github.com/smugmug/godynamo/types/condition"
+	"github.com/smugmug/godynamo/types/item"
+	"github.com/smugmug/godynamo/types/keydefinition"
+	"hash/fnv"
+	"sort"
+)
+
+// MAX_RESPONSE_BYTES is the amount of item data a Query or Scan evaluates before
+// returning a page with a LastEvaluatedKey.
+const MAX_RESPONSE_BYTES = 1048576
+
+// keyConditionOps are the comparison operators permitted on a range key in KeyConditions.
+var keyConditionOps = map[string]bool{
+	aws_strings.OP_EQ:          true,
+	aws_strings.OP_LE:          true,
+	aws_strings.OP_LT:          true,
+	aws_strings.OP_GE:          true,
+	aws_strings.OP_GT:          true,
+	aws_strings.OP_BEGINS_WITH: true,
+	aws_strings.OP_BETWEEN:     true,
+}
+
+type readResponse struct {
+	ConsumedCapacity *capacity.ConsumedCapacity `json:",omitempty"`
+	Count            uint64
+	Items            []attributevalue.AttributeValueMap `json:",omitempty"`
+	LastEvaluatedKey attributevalue.AttributeValueMap   `json:",omitempty"`
+	ScannedCount     uint64
+}
+
+// page holds the parameters shared by Query and Scan that select and shape the items
+// of a response.
+type page struct {
+	t               *table
+	ix              *index
+	limit           uint64
+	filter          condition.Conditions
+	conditional_op  string
+	condition       *expression.Condition
+	selection       string
+	attributesToGet []string
+	projection      *expression.Projection
+}
+
+// specific reports whether the request names the attributes to return.
+func (p *page) specific() bool {
+	return len(p.attributesToGet) != 0 || p.projection != nil
+}
+
+// checkSelect validates Select and AttributesToGet and returns the effective Select.
+func (p *page) checkSelect() *opError {
+	switch p.selection {
+	case "":
+		p.selection = aws_strings.SELECT_ALL
+		if p.specific() {
+			p.selection = aws_strings.SELECT_SPECIFIC
+		} else if p.ix != nil {
+			p.selection = aws_strings.SELECT_PROJECTED
+		}
+	case aws_strings.SELECT_ALL, aws_strings.SELECT_COUNT:
+		if p.specific() {
+			return validation("Cannot specify the AttributesToGet when choosing to get " + p.selection)
+		}
+	case aws_strings.SELECT_PROJECTED:
+		if p.ix == nil {
+			return validation("ALL_PROJECTED_ATTRIBUTES can be used only when Querying using an IndexName")
+		}
+		if p.specific() {
+			return validation("Cannot specify the AttributesToGet when choosing to get " + p.selection)
+		}
+	case aws_strings.SELECT_SPECIFIC:
+		if !p.specific() {
+			return validation("AttributesToGet or ProjectionExpression must be specified when choosing to get SPECIFIC_ATTRIBUTES")
+		}
+	default:
+		return validation("Invalid Select: " + p.selection)
+	}
+	if p.ix != nil && p.ix.global && p.ix.projectionType != aws_strings.ALL &&
+		p.selection == aws_strings.SELECT_ALL {
+		return validation("One or more parameter values were invalid: " +
+			"Select type ALL_ATTRIBUTES is not supported for global secondary index " + p.ix.name +
+			" because its projection type is not ALL")
+	}
+	return nil
+}
+
+// shape returns the item to include in a response.
+func (p *page) shape(it attributevalue.AttributeValueMap) attributevalue.AttributeValueMap {
+	if p.ix != nil && (p.ix.global || p.selection == aws_strings.SELECT_PROJECTED) {
+		it = p.t.project(p.ix, it)
+	}
+	return selectAttributes(it, p.attributesToGet, p.projection)
+}
+
+// read evaluates entries in order, applying Limit, the response size limit and the filter.
+func (p *page) read(entries []entry, ks keydefinition.KeySchema) *readResponse {
+	resp := new(readResponse)
+	var size uint64
+	for i, en := range entries {
+		resp.ScannedCount++
+		size += sizeOf(en.item)
+		if matchConditions(en.item, p.filter, p.conditional_op) && p.condition.Evaluate(item.Item(en.item)) {
+			resp.Count++
+			if p.selection != aws_strings.SELECT_COUNT {
+				resp.Items = append(resp.Items, p.shape(en.item))
+			}
+		}
+		if i+1 < len(entries) && (resp.ScannedCount == p.limit || size >= MAX_RESPONSE_BYTES) {
+			lek := keyOf(en.item, p.t.keySchema)
+			for k, v := range keyOf(en.item, ks) {
+				lek[k] = v
+			}
+			resp.LastEvaluatedKey = lek
+			break
+		}
+	}
+	return resp
+}
+
+// after returns the entries following the ExclusiveStartKey esk in the given order.
+func (t *table) after(entries []entry, esk attributevalue.AttributeValueMap, ks keydefinition.KeySchema,
+	forward bool) ([]entry, *opError) {
+	if len(esk) == 0 {
+		return entries, nil
+	}
+	if k_err := t.checkKeyAttrs(esk, t.keySchema); k_err != nil {
+		return nil, validation("The provided starting key is invalid: " + k_err.Error())
+	}
+	if k_err := t.checkKeyAttrs(esk, ks); k_err != nil {
+		return nil, validation("The provided starting key is invalid: " + k_err.Error())
+	}
+	pk := encodeKey(esk, t.keySchema)
+	i := sort.Search(len(entries), func(i int) bool {
+		if forward {
+			return t.less(ks, esk, pk, entries[i].item, entries[i].pk)
+		}
+		return t.less(ks, entries[i].item, entries[i].pk, esk, pk)
+	})
+	return entries[i:], nil
+}
+
+func (e *Emulator) query(body []byte) (interface{}, *opError) {
+	var req query.Query
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	pd, p_err := parse(expression.Expressions{
+		FilterExpression:          req.FilterExpression,
+		KeyConditionExpression:    req.KeyConditionExpression,
+		ProjectionExpression:      req.ProjectionExpression,
+		ExpressionAttributeNames:  req.ExpressionAttributeNames,
+		ExpressionAttributeValues: req.ExpressionAttributeValues,
+	}, map[string]bool{"AttributesToGet": len(req.AttributesToGet) != 0,
+		"KeyConditions": len(req.KeyConditions) != 0, "QueryFilter": len(req.QueryFilter) != 0,
+		"ConditionalOperator": req.ConditionalOperator != ""})
+	if p_err != nil {
+		return nil, p_err
+	}
+	key_conditions := req.KeyConditions
+	if pd.KeyCondition != nil {
+		key_conditions = pd.KeyCondition.Conditions()
+	}
+	if c_err := checkConditions(key_conditions); c_err != nil {
+		return nil, c_err
+	}
+	if c_err := checkConditions(req.QueryFilter); c_err != nil {
+		return nil, c_err
+	}
+	if co_err := checkConditionalOperator(req.ConditionalOperator); co_err != nil {
+		return nil, co_err
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	t, t_err := e.lookup(req.TableName)
+	if t_err != nil {
+		return nil, t_err
+	}
+	ks, ix, entries, v_err := t.view(req.IndexName)
+	if v_err != nil {
+		return nil, validation(v_err.Error())
+	}
+	hash, rng := hashKey(ks), rangeKey(ks)
+	hc, ok := key_conditions[hash]
+	if !ok || hc.ComparisonOperator != aws_strings.OP_EQ {
+		return nil, validation("Query condition missed key schema element: " + hash)
+	}
+	rc := key_conditions[rng]
+	for name, c := range key_conditions {
+		if name != hash && (name != rng || rng == "") {
+			return nil, validation("Query condition contains a non-key attribute: " + name)
+		}
+		if name == rng && !keyConditionOps[c.ComparisonOperator] {
+			return nil, validation("Unsupported operator on KeyConditions: " + c.ComparisonOperator)
+		}
+		if expression.TypeOf(c.AttributeValueList[0]) != t.attrTypes[name] {
+			return nil, validation("One or more parameter values were invalid: " +
+				"Condition parameter type does not match schema type")
+		}
+	}
+	for name := range req.QueryFilter {
+		if name == hash || name == rng {
+			e := fmt.Sprintf("QueryFilter can only contain non-primary key attributes: "+
+				"Primary key attribute: %s", name)
+			return nil, validation(e)
+		}
+	}
+	p := &page{t: t, ix: ix, limit: req.Limit, filter: req.QueryFilter,
+		conditional_op: req.ConditionalOperator, condition: pd.Filter, selection: req.Select,
+		attributesToGet: req.AttributesToGet, projection: pd.Projection}
+	if s_err := p.checkSelect(); s_err != nil {
+		return nil, s_err
+	}
+	matched := make([]entry, 0)
+	for _, en := range entries {
+		if !expression.Equal(en.item[hash], hc.AttributeValueList[0]) {
+			continue
+		}
+		if rc != nil && !compare(rc.ComparisonOperator, en.item[rng], rc.AttributeValueList) {
+			continue
+		}
+		matched = append(matched, en)
+	}
+	forward := req.ScanIndexForward == nil || *req.ScanIndexForward
+	if !forward {
+		for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+			matched[i], matched[j] = matched[j], matched[i]
+		}
+	}
+	matched, a_err := t.after(matched, req.ExclusiveStartKey, ks, forward)
+	if a_err != nil {
+		return nil, a_err
+	}
+	resp := p.read(matched, ks)
+	resp.ConsumedCapacity = consumed(req.ReturnConsumedCapacity, t.name)
+	return resp, nil
+}
+
+// segmentOf assigns an item to one of total Scan segments by its primary key.
+func segmentOf(pk string, total uint64) uint64 {
+	h := fnv.New32a()
+	h.Write([]byte(pk))
+	return uint64(h.Sum32()) % total
+}
+
+func (e *Emulator) scan(body []byte) (interface{}, *opError) {
+	var req scan.Scan
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	pd, p_err := parse(expression.Expressions{
+		FilterExpression:          req.FilterExpression,
+		ProjectionExpression:      req.ProjectionExpression,
+		ExpressionAttributeNames:  req.ExpressionAttributeNames,
+		ExpressionAttributeValues: req.ExpressionAttributeValues,
+	}, map[string]bool{"AttributesToGet": len(req.AttributesToGet) != 0,
+		"ScanFilter": len(req.ScanFilter) != 0, "ConditionalOperator": req.ConditionalOperator != ""})
+	if p_err != nil {
+		return nil, p_err
+	}
+	if c_err := checkConditions(req.ScanFilter); c_err != nil {
+		return nil, c_err
+	}
+	if co_err := checkConditionalOperator(req.ConditionalOperator); co_err != nil {
+		return nil, co_err
+	}
+	if req.Segment != 0 && req.TotalSegments == 0 {
+		return nil, validation("The TotalSegments parameter is required but was not present in the request when Segment parameter is present")
+	}
+	if req.TotalSegments != 0 && req.Segment >= req.TotalSegments {
+		e := fmt.Sprintf("The Segment parameter is zero-based and must be less than parameter TotalSegments: "+
+			"Segment: %d is not less than TotalSegments: %d", req.Segment, req.TotalSegments)
+		return nil, validation(e)
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	t, t_err := e.lookup(req.TableName)
+	if t_err != nil {
+		return nil, t_err
+	}
+	p := &page{t: t, limit: req.Limit, filter: req.ScanFilter,
+		conditional_op: req.ConditionalOperator, condition: pd.Filter, selection: req.Select,
+		attributesToGet: req.AttributesToGet, projection: pd.Projection}
+	if s_err := p.checkSelect(); s_err != nil {
+		return nil, s_err
+	}
+	// a Scan reads the table in the order of its encoded primary keys
+	entries := make([]entry, 0, len(t.items))
+	for pk, it := range t.items {
+		if req.TotalSegments != 0 && segmentOf(pk, req.TotalSegments) != req.Segment {
+			continue
+		}
+		entries = append(entries, entry{item: it, pk: pk})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].pk < entries[j].pk })
+	if len(req.ExclusiveStartKey) != 0 {
+		if k_err := t.checkKey(req.ExclusiveStartKey); k_err != nil {
+			return nil, validation("The provided starting key is invalid: " + k_err.Error())
+		}
+		pk := encodeKey(req.ExclusiveStartKey, t.keySchema)
+		entries = entries[sort.Search(len(entries), func(i int) bool { return entries[i].pk > pk }):]
+	}
+	resp := p.read(entries, t.keySchema)
+	resp.ConsumedCapacity = consumed(req.ReturnConsumedCapacity, t.name)
+	return resp, nil
+}
@@ -0,0 +1,423 @@
+package emulator
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/dynamoerr"
+	"github.com/smugmug/godynamo/expression"
+	"github.com/smugmug/godynamo/streams"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/item"
+	"github.com/smugmug/godynamo/types/keydefinition"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ITERATOR_TTL is how long a shard iterator may be used.
+	ITERATOR_TTL = 15 * time.Minute
+	// STREAM_LABEL_FORMAT is the layout of stream labels, which are their creation time.
+	STREAM_LABEL_FORMAT = "2006-01-02T15:04:05.000"
+	// the eventVersion of stream records
+	EVENT_VERSION = "1.1"
+)
+
+// shard is a shard of a stream. Records are only appended to the last shard of a stream,
+// until it is closed by a split.
+type shard struct {
+	id      string
+	parent  string
+	start   string
+	end     string
+	records []streams.Record
+}
+
+// stream is the DynamoDB Stream of a table. It outlives the table and is kept after
+// being disabled, as DynamoDB keeps streams readable for a day.
+type stream struct {
+	arn       string
+	label     string
+	tableName string
+	keySchema keydefinition.KeySchema
+	viewType  string
+	status    string
+	created   time.Time
+	shards    []*shard
+	// the sequence number counter of the emulator
+	seq *uint64
+}
+
+// seqString formats a sequence number so that sequence numbers sort as strings.
+func seqString(n uint64) string {
+	return fmt.Sprintf("%021d", n)
+}
+
+// newStream enables a stream on t. The caller must hold the lock.
+func (e *Emulator) newStream(t *table, view_type string) *opError {
+	switch view_type {
+	case streams.KEYS_ONLY, streams.NEW_IMAGE, streams.OLD_IMAGE, streams.NEW_AND_OLD_IMAGES:
+	default:
+		return validation("StreamViewType set to invalid value: " + view_type)
+	}
+	created := time.Now().UTC()
+	s := &stream{tableName: t.name, keySchema: t.keySchema, viewType: view_type,
+		status: streams.ENABLED, seq: &e.seq}
+	for {
+		s.label = created.Format(STREAM_LABEL_FORMAT)
+		s.arn = fmt.Sprintf("arn:aws:dynamodb:%s:000000000000:table/%s/stream/%s", ZONE, t.name, s.label)
+		if _, dup := e.streamsByArn[s.arn]; !dup {
+			break
+		}
+		created = created.Add(time.Millisecond)
+	}
+	s.created = created
+	s.shards = []*shard{e.newShard("")}
+	e.streams = append(e.streams, s)
+	e.streamsByArn[s.arn] = s
+	t.stream = s
+	return nil
+}
+
+// newShard returns an open shard whose records will follow those of parent.
+func (e *Emulator) newShard(parent string) *shard {
+	e.shardSeq++
+	return &shard{
+		id:     fmt.Sprintf("shardId-%020d-%08d", time.Now().UnixNano()/int64(time.Millisecond), e.shardSeq),
+		parent: parent,
+		start:  seqString(e.seq + 1),
+	}
+}
+
+// open returns the shard records are added to, or nil if the stream is disabled.
+func (s *stream) open() *shard {
+	if s == nil || s.status != streams.ENABLED {
+		return nil
+	}
+	return s.shards[len(s.shards)-1]
+}
+
+// closeShard ends the open shard, which will receive no more records.
+func (s *stream) closeShard() {
+	sh := s.open()
+	if sh == nil {
+		return
+	}
+	sh.end = sh.start
+	if n := len(sh.records); n != 0 {
+		sh.end = sh.records[n-1].Dynamodb.SequenceNumber
+	}
+}
+
+// disable closes the stream. The caller must hold the lock.
+func (s *stream) disable() {
+	if s == nil || s.status != streams.ENABLED {
+		return
+	}
+	s.closeShard()
+	s.status = streams.DISABLED
+}
+
+// equalItems reports whether a and b have the same attributes and values.
+func equalItems(a, b attributevalue.AttributeValueMap) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		w, ok := b[k]
+		if !ok || !expression.Equal(v, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// record adds the change of an item from old to new to the stream. Either may be nil,
+// for an insert or a removal. Writes that do not change the item are not recorded.
+func (s *stream) record(old, new attributevalue.AttributeValueMap) {
+	sh := s.open()
+	if sh == nil || (old == nil && new == nil) {
+		return
+	}
+	if old != nil && new != nil && equalItems(old, new) {
+		return
+	}
+	r := streams.Record{AwsRegion: ZONE, EventSource: streams.EVENT_SOURCE, EventVersion: EVENT_VERSION}
+	var keys attributevalue.AttributeValueMap
+	switch {
+	case old == nil:
+		r.EventName = streams.INSERT
+		keys = keyOf(new, s.keySchema)
+	case new == nil:
+		r.EventName = streams.REMOVE
+		keys = keyOf(old, s.keySchema)
+	default:
+		r.EventName = streams.MODIFY
+		keys = keyOf(new, s.keySchema)
+	}
+	*s.seq++
+	r.EventID = fmt.Sprintf("%016x%016x", s.created.UnixNano(), *s.seq)
+	d := &r.Dynamodb
+	d.ApproximateCreationDateTime = float64(time.Now().Unix())
+	d.Keys = item.Item(keys)
+	d.SequenceNumber = seqString(*s.seq)
+	d.StreamViewType = s.viewType
+	size := sizeOf(keys)
+	if new != nil && (s.viewType == streams.NEW_IMAGE || s.viewType == streams.NEW_AND_OLD_IMAGES) {
+		d.NewImage = item.Item(copyItem(new))
+		size += sizeOf(new)
+	}
+	if old != nil && (s.viewType == streams.OLD_IMAGE || s.viewType == streams.NEW_AND_OLD_IMAGES) {
+		d.OldImage = item.Item(copyItem(old))
+		size += sizeOf(old)
+	}
+	d.SizeBytes = int64(size)
+	sh.records = append(sh.records, r)
+}
+
+// SplitShard closes the open shard of the stream of the named table and starts a child
+// shard, as DynamoDB does from time to time, so that consumers of shard lineage can be
+// tested.
+func (e *Emulator) SplitShard(table_name string) error {
+	if e == nil {
+		return errors.New("emulator.SplitShard: receiver is nil")
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	t, ok := e.tables[table_name]
+	if !ok {
+		return errors.New("emulator.SplitShard: no table " + table_name)
+	}
+	parent := t.stream.open()
+	if parent == nil {
+		return errors.New("emulator.SplitShard: table " + table_name + " has no enabled stream")
+	}
+	t.stream.closeShard()
+	t.stream.shards = append(t.stream.shards, e.newShard(parent.id))
+	return nil
+}
+
+// lookupStream returns the stream with arn. The caller must hold the lock.
+func (e *Emulator) lookupStream(arn string) (*stream, *opError) {
+	if arn == "" {
+		return nil, validation("StreamArn must be specified")
+	}
+	s, ok := e.streamsByArn[arn]
+	if !ok {
+		return nil, notFound("Requested resource not found: Stream: " + arn + " not found")
+	}
+	return s, nil
+}
+
+// lookupShard returns the shard of s with id.
+func (s *stream) lookupShard(id string) (*shard, *opError) {
+	for _, sh := range s.shards {
+		if sh.id == id {
+			return sh, nil
+		}
+	}
+	return nil, notFound("Requested resource not found: Shard does not exist")
+}
+
+func (e *Emulator) listStreams(body []byte) (interface{}, *opError) {
+	var req streams.ListStreams
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = streams.LISTSTREAMS_LIMIT
+	}
+	if limit > streams.LISTSTREAMS_LIMIT {
+		return nil, validation(fmt.Sprintf("Limit must be less than or equal to %d", streams.LISTSTREAMS_LIMIT))
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	started := req.ExclusiveStartStreamArn == ""
+	resp := streams.NewListStreamsResponse()
+	for _, s := range e.streams {
+		if !started {
+			started = s.arn == req.ExclusiveStartStreamArn
+			continue
+		}
+		if req.TableName != "" && s.tableName != req.TableName {
+			continue
+		}
+		if len(resp.Streams) == limit {
+			resp.LastEvaluatedStreamArn = resp.Streams[limit-1].StreamArn
+			break
+		}
+		resp.Streams = append(resp.Streams, streams.Stream{StreamArn: s.arn, StreamLabel: s.label, TableName: s.tableName})
+	}
+	return resp, nil
+}
+
+func (e *Emulator) describeStream(body []byte) (interface{}, *opError) {
+	var req streams.DescribeStream
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = streams.DESCRIBESTREAM_LIMIT
+	}
+	if limit > streams.DESCRIBESTREAM_LIMIT {
+		return nil, validation(fmt.Sprintf("Limit must be less than or equal to %d", streams.DESCRIBESTREAM_LIMIT))
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	s, s_err := e.lookupStream(req.StreamArn)
+	if s_err != nil {
+		return nil, s_err
+	}
+	resp := streams.NewDescribeStreamResponse()
+	d := &resp.StreamDescription
+	d.CreationRequestDateTime = float64(s.created.Unix())
+	d.KeySchema = s.keySchema
+	d.StreamArn = s.arn
+	d.StreamLabel = s.label
+	d.StreamStatus = s.status
+	d.StreamViewType = s.viewType
+	d.TableName = s.tableName
+	started := req.ExclusiveStartShardId == ""
+	for _, sh := range s.shards {
+		if !started {
+			started = sh.id == req.ExclusiveStartShardId
+			continue
+		}
+		if len(d.Shards) == limit {
+			d.LastEvaluatedShardId = d.Shards[limit-1].ShardId
+			break
+		}
+		var r streams.SequenceNumberRange
+		r.StartingSequenceNumber = sh.start
+		r.EndingSequenceNumber = sh.end
+		d.Shards = append(d.Shards, streams.Shard{ParentShardId: sh.parent, SequenceNumberRange: r, ShardId: sh.id})
+	}
+	return resp, nil
+}
+
+// iterator is the decoded form of a shard iterator.
+type iterator struct {
+	arn    string
+	shard  string
+	pos    int
+	issued time.Time
+}
+
+func (it *iterator) String() string {
+	s := fmt.Sprintf("%s|%s|%d|%d", it.arn, it.shard, it.pos, it.issued.UnixNano())
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+func parseIterator(s string) (*iterator, *opError) {
+	invalid := validation("Invalid ShardIterator")
+	b, b_err := base64.RawURLEncoding.DecodeString(s)
+	if b_err != nil {
+		return nil, invalid
+	}
+	parts := strings.Split(string(b), "|")
+	if len(parts) != 4 {
+		return nil, invalid
+	}
+	pos, pos_err := strconv.Atoi(parts[2])
+	issued, issued_err := strconv.ParseInt(parts[3], 10, 64)
+	if pos_err != nil || issued_err != nil {
+		return nil, invalid
+	}
+	return &iterator{arn: parts[0], shard: parts[1], pos: pos, issued: time.Unix(0, issued)}, nil
+}
+
+func (e *Emulator) getShardIterator(body []byte) (interface{}, *opError) {
+	var req streams.GetShardIterator
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	if v_err := req.Validate(); v_err != nil {
+		return nil, validation(v_err.Error())
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	s, s_err := e.lookupStream(req.StreamArn)
+	if s_err != nil {
+		return nil, s_err
+	}
+	sh, sh_err := s.lookupShard(req.ShardId)
+	if sh_err != nil {
+		return nil, sh_err
+	}
+	it := &iterator{arn: s.arn, shard: sh.id, issued: time.Now()}
+	switch req.ShardIteratorType {
+	case streams.LATEST:
+		it.pos = len(sh.records)
+	case streams.AT_SEQUENCE_NUMBER, streams.AFTER_SEQUENCE_NUMBER:
+		n, n_err := strconv.ParseUint(req.SequenceNumber, 10, 64)
+		if n_err != nil {
+			return nil, validation("Invalid SequenceNumber " + req.SequenceNumber)
+		}
+		seq := seqString(n)
+		if seq < sh.start || (sh.end != "" && seq > sh.end) {
+			e := fmt.Sprintf("Invalid SequenceNumber %s for shard %s", req.SequenceNumber, sh.id)
+			return nil, validation(e)
+		}
+		it.pos = sort.Search(len(sh.records), func(i int) bool {
+			r := sh.records[i].Dynamodb.SequenceNumber
+			if req.ShardIteratorType == streams.AFTER_SEQUENCE_NUMBER {
+				return r > seq
+			}
+			return r >= seq
+		})
+	}
+	resp := streams.NewGetShardIteratorResponse()
+	resp.ShardIterator = it.String()
+	return resp, nil
+}
+
+func (e *Emulator) getRecords(body []byte) (interface{}, *opError) {
+	var req streams.GetRecords
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	if v_err := req.Validate(); v_err != nil {
+		return nil, validation(v_err.Error())
+	}
+	it, it_err := parseIterator(req.ShardIterator)
+	if it_err != nil {
+		return nil, it_err
+	}
+	if time.Since(it.issued) > ITERATOR_TTL {
+		return nil, &opError{code: dynamoerr.EXPIRED_ITERATOR,
+			message: "Iterator expired", status: http.StatusBadRequest}
+	}
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = streams.GETRECORDS_LIMIT
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	s, s_err := e.lookupStream(it.arn)
+	if s_err != nil {
+		return nil, s_err
+	}
+	sh, sh_err := s.lookupShard(it.shard)
+	if sh_err != nil {
+		return nil, sh_err
+	}
+	if it.pos > len(sh.records) {
+		return nil, validation("Invalid ShardIterator")
+	}
+	end := it.pos + limit
+	if end > len(sh.records) {
+		end = len(sh.records)
+	}
+	resp := streams.NewGetRecordsResponse()
+	resp.Records = append(resp.Records, sh.records[it.pos:end]...)
+	if sh.end == "" || end < len(sh.records) {
+		next := &iterator{arn: it.arn, shard: it.shard, pos: end, issued: time.Now()}
+		resp.NextShardIterator = next.String()
+	}
+	return resp, nil
+}
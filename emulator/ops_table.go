@@ -0,0 +1,357 @@
+package emulator
+
+import (
+	"encoding/json"
+	"fmt"
+	create_table "github.com/smugmug/godynamo/endpoints/create_table"
+	delete_table "github.com/smugmug/godynamo/endpoints/delete_table"
+	describe_table "github.com/smugmug/godynamo/endpoints/describe_table"
+	list_tables "github.com/smugmug/godynamo/endpoints/list_tables"
+	"github.com/smugmug/godynamo/streams"
+	"github.com/smugmug/godynamo/types/attributedefinition"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/aws_strings"
+	"github.com/smugmug/godynamo/types/globalsecondaryindex"
+	"github.com/smugmug/godynamo/types/keydefinition"
+	"github.com/smugmug/godynamo/types/localsecondaryindex"
+	"github.com/smugmug/godynamo/types/provisionedthroughput"
+	"github.com/smugmug/godynamo/types/streamspecification"
+	"sort"
+	"time"
+)
+
+const (
+	STATUS_CREATING = "CREATING"
+	STATUS_UPDATING = "UPDATING"
+	STATUS_DELETING = "DELETING"
+	STATUS_ACTIVE   = describe_table.ACTIVE
+	// the default and maximum Limit of ListTables
+	LIST_TABLES_LIMIT = 100
+)
+
+// tableDesc is the TableDescription of CreateTable, UpdateTable and DeleteTable responses
+// and the Table of DescribeTable responses.
+type tableDesc struct {
+	AttributeDefinitions   attributedefinition.AttributeDefinitions
+	CreationDateTime       float64
+	GlobalSecondaryIndexes []globalsecondaryindex.GlobalSecondaryIndexDesc `json:",omitempty"`
+	ItemCount              uint64
+	KeySchema              keydefinition.KeySchema
+	LatestStreamArn        string                                        `json:",omitempty"`
+	LatestStreamLabel      string                                        `json:",omitempty"`
+	LocalSecondaryIndexes  []localsecondaryindex.LocalSecondaryIndexDesc `json:",omitempty"`
+	ProvisionedThroughput  provisionedthroughput.ProvisionedThroughputDesc
+	StreamSpecification    *streamspecification.StreamSpecification `json:",omitempty"`
+	TableName              string
+	TableSizeBytes         uint64
+	TableStatus            string
+}
+
+// describe builds the description of t. The caller must hold the lock.
+func (t *table) describe(status string) *tableDesc {
+	d := &tableDesc{
+		AttributeDefinitions: t.attrDefs,
+		CreationDateTime:     float64(t.created.UnixNano()) / 1e9,
+		KeySchema:            t.keySchema,
+		TableName:            t.name,
+		TableStatus:          status,
+	}
+	if t.stream != nil {
+		d.LatestStreamArn = t.stream.arn
+		d.LatestStreamLabel = t.stream.label
+		if t.stream.status == streams.ENABLED {
+			d.StreamSpecification = &streamspecification.StreamSpecification{
+				StreamEnabled: true, StreamViewType: t.stream.viewType}
+		}
+	}
+	d.ProvisionedThroughput.ReadCapacityUnits = t.throughput.ReadCapacityUnits
+	d.ProvisionedThroughput.WriteCapacityUnits = t.throughput.WriteCapacityUnits
+	for _, it := range t.items {
+		d.ItemCount++
+		d.TableSizeBytes += sizeOf(it)
+	}
+	for _, name := range t.lsiNames {
+		ix := t.indexes[name]
+		var ld localsecondaryindex.LocalSecondaryIndexDesc
+		ld.IndexName = ix.name
+		ld.KeySchema = ix.keySchema
+		ld.Projection.ProjectionType = ix.projectionType
+		ld.Projection.NonKeyAttributes = ix.nonKeyAttributes
+		ld.ItemCount, ld.IndexSizeBytes = t.indexStats(ix)
+		d.LocalSecondaryIndexes = append(d.LocalSecondaryIndexes, ld)
+	}
+	for _, name := range t.gsiNames {
+		ix := t.indexes[name]
+		var gd globalsecondaryindex.GlobalSecondaryIndexDesc
+		gd.IndexName = ix.name
+		gd.IndexStatus = STATUS_ACTIVE
+		gd.KeySchema = ix.keySchema
+		gd.Projection.ProjectionType = ix.projectionType
+		gd.Projection.NonKeyAttributes = ix.nonKeyAttributes
+		gd.ProvisionedThroughput.ReadCapacityUnits = ix.throughput.ReadCapacityUnits
+		gd.ProvisionedThroughput.WriteCapacityUnits = ix.throughput.WriteCapacityUnits
+		gd.ItemCount, gd.IndexSizeBytes = t.indexStats(ix)
+		d.GlobalSecondaryIndexes = append(d.GlobalSecondaryIndexes, gd)
+	}
+	return d
+}
+
+// indexStats returns the item count and size of an index.
+func (t *table) indexStats(ix *index) (uint64, uint64) {
+	var n, size uint64
+	for _, it := range t.items {
+		if t.checkKeyAttrs(it, ix.keySchema) == nil {
+			n++
+			size += sizeOf(t.project(ix, it))
+		}
+	}
+	return n, size
+}
+
+// newIndex validates an index definition of a CreateTable request.
+func newIndex(name string, ks keydefinition.KeySchema, projection_type string, non_key []string,
+	attrTypes map[string]string) (*index, *opError) {
+	if name == "" {
+		return nil, validation("IndexName must be specified")
+	}
+	if ks_err := checkKeySchema(ks, attrTypes); ks_err != nil {
+		return nil, validation(ks_err.Error())
+	}
+	switch projection_type {
+	case aws_strings.ALL, aws_strings.KEYS_ONLY:
+		if len(non_key) != 0 {
+			return nil, validation("NonKeyAttributes may only be used with the INCLUDE ProjectionType")
+		}
+	case aws_strings.INCLUDE:
+	default:
+		return nil, validation("Invalid ProjectionType: " + projection_type)
+	}
+	ix := &index{name: name, keySchema: ks, projectionType: projection_type}
+	ix.nonKeyAttributes = append(ix.nonKeyAttributes, non_key...)
+	return ix, nil
+}
+
+func (e *Emulator) createTable(body []byte) (interface{}, *opError) {
+	var req create_table.CreateTable
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	if req.TableName == "" {
+		return nil, validation("TableName must be specified")
+	}
+	if req.ProvisionedThroughput.ReadCapacityUnits == 0 || req.ProvisionedThroughput.WriteCapacityUnits == 0 {
+		return nil, validation("ProvisionedThroughput capacity units must be at least 1")
+	}
+	t := &table{
+		name:       req.TableName,
+		attrTypes:  make(map[string]string),
+		attrDefs:   req.AttributeDefinitions,
+		keySchema:  req.KeySchema,
+		indexes:    make(map[string]*index),
+		throughput: req.ProvisionedThroughput,
+		created:    time.Now(),
+		items:      make(map[string]attributevalue.AttributeValueMap),
+	}
+	for _, ad := range req.AttributeDefinitions {
+		if _, dup := t.attrTypes[ad.AttributeName]; dup {
+			return nil, validation("Duplicate AttributeDefinition: " + ad.AttributeName)
+		}
+		t.attrTypes[ad.AttributeName] = ad.AttributeType
+	}
+	if ks_err := checkKeySchema(req.KeySchema, t.attrTypes); ks_err != nil {
+		return nil, validation(ks_err.Error())
+	}
+	used := make(map[string]bool)
+	for _, k := range req.KeySchema {
+		used[k.AttributeName] = true
+	}
+	for _, l := range req.LocalSecondaryIndexes {
+		ix, ix_err := newIndex(l.IndexName, l.KeySchema, l.Projection.ProjectionType,
+			l.Projection.NonKeyAttributes, t.attrTypes)
+		if ix_err != nil {
+			return nil, ix_err
+		}
+		if hashKey(ix.keySchema) != hashKey(t.keySchema) || rangeKey(ix.keySchema) == "" {
+			return nil, validation("Local secondary index " + l.IndexName +
+				" must have the same hash key as the table and a range key")
+		}
+		if _, dup := t.indexes[ix.name]; dup {
+			return nil, validation("Duplicate index name: " + ix.name)
+		}
+		t.indexes[ix.name] = ix
+		t.lsiNames = append(t.lsiNames, ix.name)
+		for _, k := range ix.keySchema {
+			used[k.AttributeName] = true
+		}
+	}
+	for _, g := range req.GlobalSecondaryIndexes {
+		ix, ix_err := newIndex(g.IndexName, g.KeySchema, g.Projection.ProjectionType,
+			g.Projection.NonKeyAttributes, t.attrTypes)
+		if ix_err != nil {
+			return nil, ix_err
+		}
+		if _, dup := t.indexes[ix.name]; dup {
+			return nil, validation("Duplicate index name: " + ix.name)
+		}
+		ix.global = true
+		ix.throughput = g.ProvisionedThroughput
+		t.indexes[ix.name] = ix
+		t.gsiNames = append(t.gsiNames, ix.name)
+		for _, k := range ix.keySchema {
+			used[k.AttributeName] = true
+		}
+	}
+	for name := range t.attrTypes {
+		if !used[name] {
+			e := fmt.Sprintf("One or more parameter values were invalid: "+
+				"AttributeDefinitions contains %s, which is not used in the KeySchema or any index", name)
+			return nil, validation(e)
+		}
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if _, exists := e.tables[t.name]; exists {
+		return nil, inUse("Table already exists: " + t.name)
+	}
+	if ss := req.StreamSpecification; ss != nil && ss.StreamEnabled {
+		if s_err := e.newStream(t, ss.StreamViewType); s_err != nil {
+			return nil, s_err
+		}
+	}
+	e.tables[t.name] = t
+	return map[string]interface{}{"TableDescription": t.describe(STATUS_CREATING)}, nil
+}
+
+func (e *Emulator) describeTable(body []byte) (interface{}, *opError) {
+	var req describe_table.DescribeTable
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	t, t_err := e.lookup(req.TableName)
+	if t_err != nil {
+		return nil, t_err
+	}
+	return map[string]interface{}{"Table": t.describe(STATUS_ACTIVE)}, nil
+}
+
+func (e *Emulator) listTables(body []byte) (interface{}, *opError) {
+	var req list_tables.ListTables
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = LIST_TABLES_LIMIT
+	}
+	if limit > LIST_TABLES_LIMIT {
+		return nil, validation(fmt.Sprintf("Limit must be less than or equal to %d", LIST_TABLES_LIMIT))
+	}
+	e.lock.RLock()
+	names := make([]string, 0, len(e.tables))
+	for name := range e.tables {
+		if name > req.ExclusiveStartTableName {
+			names = append(names, name)
+		}
+	}
+	e.lock.RUnlock()
+	sort.Strings(names)
+	resp := list_tables.NewResponse()
+	if len(names) > limit {
+		names = names[:limit]
+		resp.LastEvaluatedTableName = names[limit-1]
+	}
+	resp.TableNames = names
+	return resp, nil
+}
+
+func (e *Emulator) deleteTable(body []byte) (interface{}, *opError) {
+	var req delete_table.DeleteTable
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	t, t_err := e.lookup(req.TableName)
+	if t_err != nil {
+		return nil, t_err
+	}
+	delete(e.tables, t.name)
+	t.stream.disable()
+	return map[string]interface{}{"TableDescription": t.describe(STATUS_DELETING)}, nil
+}
+
+// updateTableRequest accepts GlobalSecondaryIndexUpdates either as the single object sent
+// by update_table.UpdateTable or as the list of {"Update":{...}} actions of the AWS API.
+type updateTableRequest struct {
+	GlobalSecondaryIndexUpdates json.RawMessage
+	TableName                   string
+	ProvisionedThroughput       *provisionedthroughput.ProvisionedThroughput
+	StreamSpecification         *streamspecification.StreamSpecification
+}
+
+func (e *Emulator) updateTable(body []byte) (interface{}, *opError) {
+	var req updateTableRequest
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	var updates []globalsecondaryindex.GlobalSecondaryIndexUpdates
+	if len(req.GlobalSecondaryIndexUpdates) != 0 && string(req.GlobalSecondaryIndexUpdates) != "null" {
+		var actions []struct {
+			Update *globalsecondaryindex.GlobalSecondaryIndexUpdates
+		}
+		if json.Unmarshal(req.GlobalSecondaryIndexUpdates, &actions) == nil {
+			for _, a := range actions {
+				if a.Update == nil {
+					return nil, validation("only Update actions are supported in GlobalSecondaryIndexUpdates")
+				}
+				updates = append(updates, *a.Update)
+			}
+		} else {
+			var u globalsecondaryindex.GlobalSecondaryIndexUpdates
+			if d_err := decode(req.GlobalSecondaryIndexUpdates, &u); d_err != nil {
+				return nil, d_err
+			}
+			updates = append(updates, u)
+		}
+	}
+	if req.ProvisionedThroughput == nil && len(updates) == 0 && req.StreamSpecification == nil {
+		return nil, validation("At least one of ProvisionedThroughput, GlobalSecondaryIndexUpdates " +
+			"or StreamSpecification is required")
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	t, t_err := e.lookup(req.TableName)
+	if t_err != nil {
+		return nil, t_err
+	}
+	for _, u := range updates {
+		ix, ok := t.indexes[u.IndexName]
+		if !ok || !ix.global {
+			return nil, notFound("Requested resource not found: Index: " + u.IndexName + " not found")
+		}
+	}
+	if ss := req.StreamSpecification; ss != nil {
+		enabled := t.stream.open() != nil
+		switch {
+		case ss.StreamEnabled && enabled:
+			return nil, validation("Table already has an enabled stream: " + t.stream.arn)
+		case ss.StreamEnabled:
+			if s_err := e.newStream(t, ss.StreamViewType); s_err != nil {
+				return nil, s_err
+			}
+		case enabled:
+			t.stream.disable()
+		default:
+			return nil, validation("Table does not have an enabled stream")
+		}
+	}
+	if req.ProvisionedThroughput != nil {
+		t.throughput = *req.ProvisionedThroughput
+	}
+	for _, u := range updates {
+		t.indexes[u.IndexName].throughput = u.ProvisionedThroughput
+	}
+	return map[string]interface{}{"TableDescription": t.describe(STATUS_UPDATING)}, nil
+}
@@ -0,0 +1,303 @@
+package emulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/smugmug/godynamo/dynamoerr"
+	transact_get_items "github.com/smugmug/godynamo/endpoints/transact_get_items"
+	transact_write_items "github.com/smugmug/godynamo/endpoints/transact_write_items"
+	"github.com/smugmug/godynamo/expression"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/aws_strings"
+	"github.com/smugmug/godynamo/types/capacity"
+	"github.com/smugmug/godynamo/types/item"
+	"github.com/smugmug/godynamo/types/itemcollectionmetrics"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TOKEN_TTL is how long a ClientRequestToken makes a TransactWriteItems request idempotent.
+const TOKEN_TTL = 10 * time.Minute
+
+// transaction is a TransactWriteItems request already applied, kept for its token.
+type transaction struct {
+	request  []byte
+	response interface{}
+	at       time.Time
+}
+
+// canceled returns a TransactionCanceledException with the reasons of each action.
+func canceled(reasons []dynamoerr.CancellationReason) *opError {
+	codes := make([]string, len(reasons))
+	for i, r := range reasons {
+		codes[i] = r.Code
+	}
+	return &opError{code: dynamoerr.TRANSACTION_CANCELED,
+		message: "Transaction cancelled, please refer cancellation reasons for specific reasons [" +
+			strings.Join(codes, ", ") + "]",
+		status: http.StatusBadRequest, reasons: reasons}
+}
+
+// writeAction is a validated action of a TransactWriteItems request.
+type writeAction struct {
+	t         *table
+	key       attributevalue.AttributeValueMap
+	put       attributevalue.AttributeValueMap
+	delete    bool
+	parsed    *expression.Parsed
+	return_it string
+}
+
+// prepare validates one action of a transaction and parses its expressions. The caller
+// must hold the lock.
+func (e *Emulator) prepare(ti transact_write_items.TransactWriteItem) (*writeAction, *opError) {
+	var x expression.Expressions
+	var table_name, rv string
+	var key attributevalue.AttributeValueMap
+	a := new(writeAction)
+	n := 0
+	if c := ti.ConditionCheck; c != nil {
+		n++
+		if c.ConditionExpression == "" {
+			return nil, validation("ConditionExpression must be specified for a ConditionCheck")
+		}
+		x = expression.Expressions{ConditionExpression: c.ConditionExpression,
+			ExpressionAttributeNames: c.ExpressionAttributeNames, ExpressionAttributeValues: c.ExpressionAttributeValues}
+		table_name, rv, key = c.TableName, c.ReturnValuesOnConditionCheckFailure, attributevalue.AttributeValueMap(c.Key)
+	}
+	if d := ti.Delete; d != nil {
+		n++
+		x = expression.Expressions{ConditionExpression: d.ConditionExpression,
+			ExpressionAttributeNames: d.ExpressionAttributeNames, ExpressionAttributeValues: d.ExpressionAttributeValues}
+		table_name, rv, key = d.TableName, d.ReturnValuesOnConditionCheckFailure, attributevalue.AttributeValueMap(d.Key)
+		a.delete = true
+	}
+	if p := ti.Put; p != nil {
+		n++
+		x = expression.Expressions{ConditionExpression: p.ConditionExpression,
+			ExpressionAttributeNames: p.ExpressionAttributeNames, ExpressionAttributeValues: p.ExpressionAttributeValues}
+		table_name, rv = p.TableName, p.ReturnValuesOnConditionCheckFailure
+		a.put = attributevalue.AttributeValueMap(p.Item)
+	}
+	if u := ti.Update; u != nil {
+		n++
+		if u.UpdateExpression == "" {
+			return nil, validation("UpdateExpression must be specified for an Update")
+		}
+		x = expression.Expressions{ConditionExpression: u.ConditionExpression, UpdateExpression: u.UpdateExpression,
+			ExpressionAttributeNames: u.ExpressionAttributeNames, ExpressionAttributeValues: u.ExpressionAttributeValues}
+		table_name, rv, key = u.TableName, u.ReturnValuesOnConditionCheckFailure, attributevalue.AttributeValueMap(u.Key)
+	}
+	if n != 1 {
+		return nil, validation("TransactItems can only contain one of Check, Put, Update or Delete")
+	}
+	if rv != "" && rv != aws_strings.RETVAL_NONE && rv != aws_strings.RETVAL_ALL_OLD {
+		return nil, validation("ReturnValuesOnConditionCheckFailure set to invalid value: " + rv)
+	}
+	a.return_it = rv
+	pd, p_err := parse(x, nil)
+	if p_err != nil {
+		return nil, p_err
+	}
+	a.parsed = pd
+	t, t_err := e.lookup(table_name)
+	if t_err != nil {
+		return nil, t_err
+	}
+	a.t = t
+	if a.put != nil {
+		if it_err := t.checkItem(a.put); it_err != nil {
+			return nil, validation(it_err.Error())
+		}
+		key = keyOf(a.put, t.keySchema)
+	} else if k_err := t.checkKey(key); k_err != nil {
+		return nil, validation(k_err.Error())
+	}
+	a.key = key
+	if pd.Update != nil {
+		for _, name := range pd.Update.Attributes() {
+			if _, is_key := key[name]; is_key {
+				e := fmt.Sprintf("One or more parameter values were invalid: "+
+					"Cannot update attribute %s. This attribute is part of the key", name)
+				return nil, validation(e)
+			}
+		}
+	}
+	return a, nil
+}
+
+type transactWriteItemsResponse struct {
+	ConsumedCapacity      []*capacity.ConsumedCapacity `json:",omitempty"`
+	ItemCollectionMetrics itemcollectionmetrics.ItemCollectionMetricsMap
+}
+
+func (e *Emulator) transactWriteItems(body []byte) (interface{}, *opError) {
+	var req transact_write_items.TransactWriteItems
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	n := len(req.TransactItems)
+	if n == 0 || n > transact_write_items.MAX_ACTIONS {
+		e := fmt.Sprintf("1 validation error detected: Value at 'transactItems' failed to satisfy constraint: "+
+			"Member must have length less than or equal to %d and greater than or equal to 1",
+			transact_write_items.MAX_ACTIONS)
+		return nil, validation(e)
+	}
+	if len(req.ClientRequestToken) > transact_write_items.MAX_TOKEN_LEN {
+		return nil, validation("ClientRequestToken must have length less than or equal to 36")
+	}
+	// the token is compared against the rest of the request
+	token := req.ClientRequestToken
+	req.ClientRequestToken = ""
+	canonical_req, _ := json.Marshal(req)
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if token != "" {
+		if tr, ok := e.transactions[token]; ok && time.Since(tr.at) < TOKEN_TTL {
+			if string(tr.request) != string(canonical_req) {
+				return nil, &opError{code: dynamoerr.IDEMPOTENT_PARAMETER_MISMATCH,
+					message: "The request uses the same client token as a previous, but non-identical request",
+					status:  http.StatusBadRequest}
+			}
+			return tr.response, nil
+		}
+	}
+	actions := make([]*writeAction, n)
+	seen := make(map[string]bool)
+	for i, ti := range req.TransactItems {
+		a, a_err := e.prepare(ti)
+		if a_err != nil {
+			return nil, a_err
+		}
+		enc := a.t.name + "\x00" + encodeKey(a.key, a.t.keySchema)
+		if seen[enc] {
+			return nil, validation("Transaction request cannot include multiple operations on one item")
+		}
+		seen[enc] = true
+		actions[i] = a
+	}
+	// evaluate every condition and update before writing anything
+	reasons := make([]dynamoerr.CancellationReason, n)
+	failed := false
+	updated := make([]attributevalue.AttributeValueMap, n)
+	for i, a := range actions {
+		reasons[i].Code = dynamoerr.REASON_NONE
+		cur := a.t.get(a.key)
+		if !a.parsed.Condition.Evaluate(item.Item(cur)) {
+			failed = true
+			reasons[i] = dynamoerr.CancellationReason{Code: dynamoerr.REASON_CONDITIONAL_CHECK_FAILED,
+				Message: "The conditional request failed"}
+			if a.return_it == aws_strings.RETVAL_ALL_OLD && cur != nil {
+				reasons[i].Item = copyItem(cur)
+			}
+			continue
+		}
+		if a.parsed.Update == nil {
+			continue
+		}
+		it := copyItem(a.key)
+		if cur != nil {
+			it = copyItem(cur)
+		}
+		applied, ap_err := a.parsed.Update.Apply(item.Item(it))
+		if ap_err == nil {
+			it = attributevalue.AttributeValueMap(applied)
+			ap_err = a.t.checkItem(it)
+		}
+		if ap_err != nil {
+			failed = true
+			reasons[i] = dynamoerr.CancellationReason{Code: dynamoerr.REASON_VALIDATION, Message: ap_err.Error()}
+			continue
+		}
+		updated[i] = it
+	}
+	if failed {
+		return nil, canceled(reasons)
+	}
+	resp := transactWriteItemsResponse{ItemCollectionMetrics: itemcollectionmetrics.NewItemCollectionMetricsMap()}
+	reported := make(map[string]bool)
+	for i, a := range actions {
+		switch {
+		case a.put != nil:
+			a.t.put(a.put)
+		case a.delete:
+			a.t.remove(a.key)
+		case updated[i] != nil:
+			a.t.put(updated[i])
+		}
+		if reported[a.t.name] {
+			continue
+		}
+		if c := consumed(req.ReturnConsumedCapacity, a.t.name); c != nil {
+			reported[a.t.name] = true
+			resp.ConsumedCapacity = append(resp.ConsumedCapacity, c)
+		}
+	}
+	if token != "" {
+		e.transactions[token] = &transaction{request: canonical_req, response: resp, at: time.Now()}
+	}
+	return resp, nil
+}
+
+type itemResponse struct {
+	Item attributevalue.AttributeValueMap `json:",omitempty"`
+}
+
+type transactGetItemsResponse struct {
+	ConsumedCapacity []*capacity.ConsumedCapacity `json:",omitempty"`
+	Responses        []itemResponse
+}
+
+func (e *Emulator) transactGetItems(body []byte) (interface{}, *opError) {
+	var req transact_get_items.TransactGetItems
+	if d_err := decode(body, &req); d_err != nil {
+		return nil, d_err
+	}
+	n := len(req.TransactItems)
+	if n == 0 || n > transact_get_items.MAX_ACTIONS {
+		e := fmt.Sprintf("1 validation error detected: Value at 'transactItems' failed to satisfy constraint: "+
+			"Member must have length less than or equal to %d and greater than or equal to 1",
+			transact_get_items.MAX_ACTIONS)
+		return nil, validation(e)
+	}
+	projections := make([]*expression.Projection, n)
+	for i, ti := range req.TransactItems {
+		if ti.Get == nil {
+			return nil, validation("Get must be specified for each of TransactItems")
+		}
+		pd, p_err := parse(expression.Expressions{
+			ProjectionExpression:     ti.Get.ProjectionExpression,
+			ExpressionAttributeNames: ti.Get.ExpressionAttributeNames,
+		}, nil)
+		if p_err != nil {
+			return nil, p_err
+		}
+		projections[i] = pd.Projection
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	resp := transactGetItemsResponse{Responses: make([]itemResponse, n)}
+	reported := make(map[string]bool)
+	for i, ti := range req.TransactItems {
+		t, t_err := e.lookup(ti.Get.TableName)
+		if t_err != nil {
+			return nil, t_err
+		}
+		key := attributevalue.AttributeValueMap(ti.Get.Key)
+		if k_err := t.checkKey(key); k_err != nil {
+			return nil, validation(k_err.Error())
+		}
+		if it := t.get(key); it != nil {
+			resp.Responses[i].Item = selectAttributes(it, nil, projections[i])
+		}
+		if reported[t.name] {
+			continue
+		}
+		if c := consumed(req.ReturnConsumedCapacity, t.name); c != nil {
+			reported[t.name] = true
+			resp.ConsumedCapacity = append(resp.ConsumedCapacity, c)
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,245 @@
+package emulator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/expression"
+	"github.com/smugmug/godynamo/types/attributedefinition"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/aws_strings"
+	"github.com/smugmug/godynamo/types/keydefinition"
+	"github.com/smugmug/godynamo/types/provisionedthroughput"
+	"sort"
+	"strings"
+	"time"
+)
+
+// index describes a local or global secondary index. Index entries are derived from the
+// table items on every read, so indexes are always consistent with the table.
+type index struct {
+	name             string
+	global           bool
+	keySchema        keydefinition.KeySchema
+	projectionType   string
+	nonKeyAttributes []string
+	throughput       provisionedthroughput.ProvisionedThroughput
+}
+
+// table is an in-memory DynamoDB table.
+type table struct {
+	name       string
+	attrTypes  map[string]string
+	attrDefs   attributedefinition.AttributeDefinitions
+	keySchema  keydefinition.KeySchema
+	indexes    map[string]*index
+	lsiNames   []string
+	gsiNames   []string
+	throughput provisionedthroughput.ProvisionedThroughput
+	created    time.Time
+	// items are keyed by the encoded primary key
+	items map[string]attributevalue.AttributeValueMap
+	// the latest stream of the table, nil if it never had one
+	stream *stream
+}
+
+// hashKey returns the name of the HASH attribute of ks.
+func hashKey(ks keydefinition.KeySchema) string {
+	for _, k := range ks {
+		if k.KeyType == aws_strings.HASH {
+			return k.AttributeName
+		}
+	}
+	return ""
+}
+
+// rangeKey returns the name of the RANGE attribute of ks, or "".
+func rangeKey(ks keydefinition.KeySchema) string {
+	for _, k := range ks {
+		if k.KeyType == aws_strings.RANGE {
+			return k.AttributeName
+		}
+	}
+	return ""
+}
+
+// checkKeySchema validates a key schema against the defined attribute types.
+func checkKeySchema(ks keydefinition.KeySchema, attrTypes map[string]string) error {
+	if len(ks) == 0 || len(ks) > 2 || ks[0].KeyType != aws_strings.HASH ||
+		(len(ks) == 2 && ks[1].KeyType != aws_strings.RANGE) {
+		return errors.New("Invalid KeySchema: a HASH key, optionally followed by a RANGE key, is required")
+	}
+	for _, k := range ks {
+		t, ok := attrTypes[k.AttributeName]
+		if !ok {
+			e := fmt.Sprintf("One or more parameter values were invalid: "+
+				"Some index key attributes are not defined in AttributeDefinitions: %s", k.AttributeName)
+			return errors.New(e)
+		}
+		if t != aws_strings.S && t != aws_strings.N && t != aws_strings.B {
+			e := fmt.Sprintf("Member must satisfy enum value set: [B, N, S] for %s", k.AttributeName)
+			return errors.New(e)
+		}
+	}
+	return nil
+}
+
+// checkKey validates that key contains exactly the attributes of the table key schema
+// with the defined types.
+func (t *table) checkKey(key attributevalue.AttributeValueMap) error {
+	if len(key) != len(t.keySchema) {
+		return errors.New("The provided key element does not match the schema")
+	}
+	return t.checkKeyAttrs(key, t.keySchema)
+}
+
+// checkKeyAttrs validates that m contains the attributes of ks with the defined types.
+func (t *table) checkKeyAttrs(m attributevalue.AttributeValueMap, ks keydefinition.KeySchema) error {
+	for _, k := range ks {
+		v, ok := m[k.AttributeName]
+		if !ok || expression.TypeOf(v) != t.attrTypes[k.AttributeName] {
+			return errors.New("The provided key element does not match the schema")
+		}
+	}
+	return nil
+}
+
+// checkItem validates an item to be written.
+func (t *table) checkItem(it attributevalue.AttributeValueMap) error {
+	for _, v := range it {
+		if v_err := validValue(v); v_err != nil {
+			return v_err
+		}
+	}
+	if k_err := t.checkKeyAttrs(it, t.keySchema); k_err != nil {
+		return errors.New("One or more parameter values were invalid: " +
+			"Missing the key or type mismatch for a key attribute in the item")
+	}
+	// index key attributes, if present, must have the defined type
+	for _, ix := range t.indexes {
+		for _, k := range ix.keySchema {
+			if v, ok := it[k.AttributeName]; ok && expression.TypeOf(v) != t.attrTypes[k.AttributeName] {
+				e := fmt.Sprintf("One or more parameter values were invalid: "+
+					"Type mismatch for Index Key %s", k.AttributeName)
+				return errors.New(e)
+			}
+		}
+	}
+	return nil
+}
+
+// encodeKey returns the storage key for the attributes of ks in m.
+func encodeKey(m attributevalue.AttributeValueMap, ks keydefinition.KeySchema) string {
+	parts := make([]string, len(ks))
+	for i, k := range ks {
+		parts[i] = canonical(m[k.AttributeName])
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// keyOf extracts the attributes of ks from m.
+func keyOf(m attributevalue.AttributeValueMap, ks keydefinition.KeySchema) attributevalue.AttributeValueMap {
+	k := attributevalue.NewAttributeValueMap()
+	for _, kd := range ks {
+		if v, ok := m[kd.AttributeName]; ok {
+			k[kd.AttributeName] = copyValue(v)
+		}
+	}
+	return k
+}
+
+// get returns the item with the given primary key, or nil.
+func (t *table) get(key attributevalue.AttributeValueMap) attributevalue.AttributeValueMap {
+	return t.items[encodeKey(key, t.keySchema)]
+}
+
+// put stores a copy of it, returning the item it replaced, if any.
+func (t *table) put(it attributevalue.AttributeValueMap) attributevalue.AttributeValueMap {
+	k := encodeKey(it, t.keySchema)
+	old := t.items[k]
+	t.items[k] = copyItem(it)
+	t.stream.record(old, it)
+	return old
+}
+
+// remove deletes the item with the given primary key, returning it if it existed.
+func (t *table) remove(key attributevalue.AttributeValueMap) attributevalue.AttributeValueMap {
+	k := encodeKey(key, t.keySchema)
+	old := t.items[k]
+	delete(t.items, k)
+	t.stream.record(old, nil)
+	return old
+}
+
+// entry is an item as seen by a table or index read, along with its sort position.
+type entry struct {
+	item attributevalue.AttributeValueMap
+	pk   string
+}
+
+// view returns the key schema and the entries of the table, or of the named index.
+// Index entries only exist for items that have all of the index key attributes.
+func (t *table) view(indexName string) (keydefinition.KeySchema, *index, []entry, error) {
+	ks := t.keySchema
+	var ix *index
+	if indexName != "" {
+		var ok bool
+		ix, ok = t.indexes[indexName]
+		if !ok {
+			e := fmt.Sprintf("The table does not have the specified index: %s", indexName)
+			return nil, nil, nil, errors.New(e)
+		}
+		ks = ix.keySchema
+	}
+	entries := make([]entry, 0, len(t.items))
+	for pk, it := range t.items {
+		if ix != nil && t.checkKeyAttrs(it, ix.keySchema) != nil {
+			continue
+		}
+		entries = append(entries, entry{item: it, pk: pk})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return t.less(ks, entries[i].item, entries[i].pk, entries[j].item, entries[j].pk)
+	})
+	return ks, ix, entries, nil
+}
+
+// less orders items by the hash key, then the range key of ks, then the table primary key.
+func (t *table) less(ks keydefinition.KeySchema, a attributevalue.AttributeValueMap, a_pk string,
+	b attributevalue.AttributeValueMap, b_pk string) bool {
+	ha, hb := canonical(a[hashKey(ks)]), canonical(b[hashKey(ks)])
+	if ha != hb {
+		return ha < hb
+	}
+	if r := rangeKey(ks); r != "" {
+		if c, ok := expression.Compare(a[r], b[r]); ok && c != 0 {
+			return c < 0
+		}
+	}
+	return a_pk < b_pk
+}
+
+// project applies the index projection to it.
+func (t *table) project(ix *index, it attributevalue.AttributeValueMap) attributevalue.AttributeValueMap {
+	if ix == nil || ix.projectionType == aws_strings.ALL {
+		return copyItem(it)
+	}
+	p := keyOf(it, t.keySchema)
+	for k, v := range keyOf(it, ix.keySchema) {
+		p[k] = v
+	}
+	if ix.projectionType == aws_strings.INCLUDE {
+		for _, a := range ix.nonKeyAttributes {
+			if v, ok := it[a]; ok {
+				p[a] = copyValue(v)
+			}
+		}
+	}
+	return p
+}
+
+// sizeOf approximates the stored size of an item.
+func sizeOf(it attributevalue.AttributeValueMap) uint64 {
+	b, _ := json.Marshal(it)
+	return uint64(len(b))
+}
@@ -7,7 +7,13 @@
 package endpoint
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/dynamoerr"
 	"github.com/smugmug/godynamo/types/attributedefinition"
 	"github.com/smugmug/godynamo/types/attributesresponse"
 	"github.com/smugmug/godynamo/types/attributestoget"
@@ -133,6 +139,9 @@ type GlobalSecondaryIndexDesc globalsecondaryindex.GlobalSecondaryIndexDesc
 // and an error (or nil). This is the fundamental endpoint interface of
 // GoDynamo.
 type Endpoint interface {
+	// uses a parameterized conf and a context to bound the request and its retries
+	EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error)
+
 	// uses a parameterized conf
 	EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error)
 
@@ -163,3 +172,50 @@ func ServerErr(code int) bool {
 func HttpErr(code int) bool {
 	return ReqErr(code) || ServerErr(code)
 }
+
+// DecodeResponse is used by the Do methods of the endpoint packages to check the
+// result of an EndpointReq and unmarshal a successful body into v.
+// If err is not nil, it is returned unchanged. Error codes are returned as a
+// *dynamoerr.APIError decoded from the body.
+func DecodeResponse(body []byte, code int, err error, v interface{}) error {
+	if err != nil {
+		return err
+	}
+	if HttpErr(code) {
+		return dynamoerr.New(body, code, "")
+	}
+	if code != http.StatusOK {
+		e := fmt.Sprintf("endpoint.DecodeResponse: unexpected code %d: %s", code, string(body))
+		return errors.New(e)
+	}
+	um_err := json.Unmarshal(body, v)
+	if um_err != nil {
+		e := fmt.Sprintf("endpoint.DecodeResponse: cannot unmarshal %s, err: %s",
+			string(body), um_err.Error())
+		return errors.New(e)
+	}
+	return nil
+}
+
+// EncodeCursor returns v, such as the LastEvaluatedKey of a response, as an opaque
+// string that may be stored and later passed to DecodeCursor to resume paginating.
+func EncodeCursor(v interface{}) (string, error) {
+	b, json_err := json.Marshal(v)
+	if json_err != nil {
+		return "", json_err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeCursor decodes a cursor made by EncodeCursor into v.
+func DecodeCursor(cursor string, v interface{}) error {
+	b, b64_err := base64.RawURLEncoding.DecodeString(cursor)
+	if b64_err != nil {
+		return errors.New("endpoint.DecodeCursor: malformed cursor")
+	}
+	um_err := json.Unmarshal(b, v)
+	if um_err != nil {
+		return errors.New("endpoint.DecodeCursor: malformed cursor")
+	}
+	return nil
+}
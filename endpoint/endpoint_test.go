@@ -0,0 +1,45 @@
+package endpoint
+
+import (
+	"errors"
+	"github.com/smugmug/godynamo/dynamoerr"
+	"net/http"
+	"testing"
+)
+
+func TestDecodeResponse(t *testing.T) {
+	var v struct {
+		TableNames []string
+	}
+	err := DecodeResponse([]byte(`{"TableNames":["Forum","Thread"]}`), http.StatusOK, nil, &v)
+	if err != nil || len(v.TableNames) != 2 {
+		t.Errorf("should decode, got %v %v", v, err)
+	}
+	b := `{"__type":"com.amazonaws.dynamodb.v20120810#ResourceNotFoundException","message":"Requested resource not found"}`
+	err = DecodeResponse([]byte(b), http.StatusBadRequest, nil, &v)
+	if !errors.Is(err, dynamoerr.ErrResourceNotFound) {
+		t.Errorf("should be ErrResourceNotFound, got %v", err)
+	}
+	req_err := errors.New("req failed")
+	if DecodeResponse(nil, 0, req_err, &v) != req_err {
+		t.Errorf("err should be returned unchanged")
+	}
+	if DecodeResponse([]byte(`{`), http.StatusOK, nil, &v) == nil {
+		t.Errorf("bad json should be an error")
+	}
+}
+
+func TestCursor(t *testing.T) {
+	k := map[string]map[string]string{"ForumName": {"S": "Amazon DynamoDB"}}
+	cursor, err := EncodeCursor(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back map[string]map[string]string
+	if d_err := DecodeCursor(cursor, &back); d_err != nil || back["ForumName"]["S"] != "Amazon DynamoDB" {
+		t.Errorf("should round trip, got %v %v", back, d_err)
+	}
+	if DecodeCursor("not a cursor!", &back) == nil {
+		t.Errorf("a malformed cursor should be an error")
+	}
+}
@@ -0,0 +1,171 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"github.com/smugmug/godynamo/types/capacity"
+)
+
+// PageInfo describes a page read by a PageFunc.
+type PageInfo struct {
+	// the number of items in the page
+	Count uint64
+	// the number of items evaluated, before any filter was applied
+	ScannedCount uint64
+	// the capacity consumed by the page, if known
+	ConsumedCapacity *capacity.ConsumedCapacity
+	// set if there are pages after this one
+	More bool
+}
+
+// PageFunc requests the next page of a Paginator.
+type PageFunc func(ctx context.Context) (PageInfo, error)
+
+// Paginator is the state shared by the paginators of the endpoint packages, which wrap
+// it with their typed requests and responses. It requests pages with a PageFunc until
+// there are no more or the item limit is reached, and keeps the totals of the pages.
+type Paginator struct {
+	fetch PageFunc
+	// the Limit of the requests sent by fetch, if any
+	limit *uint64
+	// the most items to return in total, 0 for no limit
+	itemLimit uint64
+	started   bool
+	err       error
+	// set once there are no more pages, or the item limit was reached
+	done bool
+	// set while there are pages after the current one
+	more     bool
+	count    uint64
+	scanned  uint64
+	consumed *capacity.ConsumedCapacity
+}
+
+// NewPaginator returns a Paginator requesting pages with fetch. limit points at the Limit
+// of the requests fetch sends, which is lowered as needed so that no more than the item
+// limit are read; it may be nil.
+func NewPaginator(limit *uint64, fetch PageFunc) *Paginator {
+	return &Paginator{fetch: fetch, limit: limit, more: true, consumed: capacity.NewConsumedCapacity()}
+}
+
+// SetItemLimit stops the paginator once n items have been returned, 0 for no limit.
+// The Limit of the requests is lowered as needed so that no more than n items are read.
+func (p *Paginator) SetItemLimit(n uint64) {
+	if p == nil {
+		return
+	}
+	p.itemLimit = n
+}
+
+// Resume decodes a cursor returned by Cursor into start, the start key of the next
+// request. It must be called before the first call to Next. An empty cursor leaves
+// start unchanged and stops the paginator, as there were no more pages.
+func (p *Paginator) Resume(cursor string, start interface{}) error {
+	if p == nil {
+		return errors.New("endpoint.(Paginator)Resume: receiver is nil")
+	}
+	if p.started {
+		return errors.New("endpoint.(Paginator)Resume: paginator has started")
+	}
+	if cursor == "" {
+		p.done = true
+		p.more = false
+		return nil
+	}
+	return DecodeCursor(cursor, start)
+}
+
+// Next is the same as NextWithContext but uses a background context.
+func (p *Paginator) Next() bool {
+	return p.NextWithContext(context.Background())
+}
+
+// NextWithContext requests the next page, returning false when there are no more pages,
+// the item limit has been reached or the request failed. Check Err afterwards.
+func (p *Paginator) NextWithContext(ctx context.Context) bool {
+	if p == nil || p.done {
+		return false
+	}
+	p.started = true
+	if p.itemLimit != 0 && p.limit != nil {
+		remaining := p.itemLimit - p.count
+		if *p.limit == 0 || *p.limit > remaining {
+			*p.limit = remaining
+		}
+	}
+	page, err := p.fetch(ctx)
+	if err != nil {
+		p.err = err
+		p.done = true
+		return false
+	}
+	p.count += page.Count
+	p.scanned += page.ScannedCount
+	p.consumed.Add(page.ConsumedCapacity)
+	p.more = page.More
+	if !p.more || (p.itemLimit != 0 && p.count >= p.itemLimit) {
+		p.done = true
+	}
+	return true
+}
+
+// Err returns the error that stopped the paginator, if any.
+func (p *Paginator) Err() error {
+	if p == nil {
+		return nil
+	}
+	return p.err
+}
+
+// Count returns the number of items returned by all pages so far.
+func (p *Paginator) Count() uint64 {
+	if p == nil {
+		return 0
+	}
+	return p.count
+}
+
+// ScannedCount returns the number of items evaluated by all pages so far, before any
+// filter was applied.
+func (p *Paginator) ScannedCount() uint64 {
+	if p == nil {
+		return 0
+	}
+	return p.scanned
+}
+
+// ConsumedCapacity returns the capacity consumed by all pages so far, if the requests
+// set ReturnConsumedCapacity.
+func (p *Paginator) ConsumedCapacity() *capacity.ConsumedCapacity {
+	if p == nil {
+		return nil
+	}
+	return p.consumed
+}
+
+// Cursor returns an opaque string encoding start, the start key of the next request, from
+// which Resume continues after the last page read, or "" if there are no more pages.
+func (p *Paginator) Cursor(start interface{}) (string, error) {
+	if p == nil {
+		return "", errors.New("endpoint.(Paginator)Cursor: receiver is nil")
+	}
+	if !p.more {
+		return "", nil
+	}
+	return EncodeCursor(start)
+}
+
+// Pages starts a goroutine that requests each page and calls send with it. send returns
+// false if ctx was done before the page could be delivered, which stops the paginator
+// with the error of ctx. closed is called once the goroutine is done.
+func (p *Paginator) Pages(ctx context.Context, send func() bool, closed func()) {
+	go func() {
+		defer closed()
+		for p.NextWithContext(ctx) {
+			if !send() {
+				p.err = ctx.Err()
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,137 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// pagedList serves items in pages of the request limit, defaulting to 3.
+type pagedList struct {
+	items []string
+	start string
+	limit uint64
+	// the limits of the requests
+	limits []uint64
+	page   []string
+}
+
+func (l *pagedList) fetch(ctx context.Context) (PageInfo, error) {
+	if ctx.Err() != nil {
+		return PageInfo{}, ctx.Err()
+	}
+	l.limits = append(l.limits, l.limit)
+	i := 0
+	for i < len(l.items) && l.items[i] <= l.start {
+		i++
+	}
+	n := l.limit
+	if n == 0 {
+		n = 3
+	}
+	j := i + int(n)
+	if j > len(l.items) {
+		j = len(l.items)
+	}
+	l.page = l.items[i:j]
+	more := j < len(l.items)
+	if more {
+		l.start = l.items[j-1]
+	}
+	return PageInfo{Count: uint64(len(l.page)), ScannedCount: 2 * uint64(len(l.page)), More: more}, nil
+}
+
+func TestPaginator(t *testing.T) {
+	l := &pagedList{items: []string{"a", "b", "c", "d", "e", "f", "g"}}
+	p := NewPaginator(&l.limit, l.fetch)
+	pages := 0
+	for p.Next() {
+		pages++
+	}
+	if p.Err() != nil || pages != 3 || p.Count() != 7 || p.ScannedCount() != 14 {
+		t.Errorf("unexpected pagination: %d pages, %d items, %v", pages, p.Count(), p.Err())
+	}
+	if cursor, _ := p.Cursor(l.start); cursor != "" {
+		t.Errorf("a finished paginator should have no cursor, got %s", cursor)
+	}
+
+	// the limit is lowered to stop at 4 items
+	l = &pagedList{items: l.items, limit: 3}
+	p = NewPaginator(&l.limit, l.fetch)
+	p.SetItemLimit(4)
+	for p.Next() {
+	}
+	if p.Count() != 4 || len(l.limits) != 2 || l.limits[0] != 3 || l.limits[1] != 1 {
+		t.Errorf("unexpected limits %v for %d items", l.limits, p.Count())
+	}
+	cursor, err := p.Cursor(l.start)
+	if err != nil || cursor == "" {
+		t.Fatalf("expected a cursor, got %q %v", cursor, err)
+	}
+	r := &pagedList{items: l.items}
+	p = NewPaginator(&r.limit, r.fetch)
+	if r_err := p.Resume(cursor, &r.start); r_err != nil || r.start != "d" {
+		t.Fatalf("unexpected resume from %q: %v", r.start, r_err)
+	}
+	if !p.Next() || r.page[0] != "e" {
+		t.Errorf("resume should continue after the cursor, got %v", r.page)
+	}
+	if p.Resume(cursor, &r.start) == nil {
+		t.Errorf("a started paginator should not resume")
+	}
+	if NewPaginator(nil, r.fetch).Resume("bogus!", &r.start) == nil {
+		t.Errorf("a malformed cursor should not resume")
+	}
+	p = NewPaginator(nil, r.fetch)
+	if p.Resume("", &r.start) != nil || p.Next() {
+		t.Errorf("an empty cursor should resume a finished paginator")
+	}
+
+	failed := errors.New("failed")
+	p = NewPaginator(nil, func(context.Context) (PageInfo, error) {
+		return PageInfo{}, failed
+	})
+	if p.Next() || p.Err() != failed || p.Next() {
+		t.Errorf("a failed request should stop the paginator, got %v", p.Err())
+	}
+}
+
+func TestPaginatorPages(t *testing.T) {
+	l := &pagedList{items: []string{"a", "b", "c", "d", "e", "f", "g"}}
+	p := NewPaginator(&l.limit, l.fetch)
+	ch := make(chan []string)
+	send := func() bool {
+		ch <- l.page
+		return true
+	}
+	p.Pages(context.Background(), send, func() { close(ch) })
+	n := 0
+	for page := range ch {
+		n += len(page)
+	}
+	if n != 7 || p.Err() != nil {
+		t.Errorf("expected 7 items, got %d %v", n, p.Err())
+	}
+
+	// a consumer that stops reading cancels ctx
+	l = &pagedList{items: l.items}
+	p = NewPaginator(&l.limit, l.fetch)
+	ctx, cancel := context.WithCancel(context.Background())
+	ch = make(chan []string)
+	send = func() bool {
+		select {
+		case ch <- l.page:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	done := make(chan struct{})
+	p.Pages(ctx, send, func() { close(done) })
+	<-ch
+	cancel()
+	<-done
+	if p.Err() != context.Canceled {
+		t.Errorf("expected the paginator to stop with ctx, got %v", p.Err())
+	}
+}
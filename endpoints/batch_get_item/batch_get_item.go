@@ -8,19 +8,25 @@
 package batch_get_item
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/smugmug/godynamo/authreq"
 	"github.com/smugmug/godynamo/aws_const"
 	"github.com/smugmug/godynamo/conf"
-	ep "github.com/smugmug/godynamo/endpoint"
+	"github.com/smugmug/godynamo/dynamoerr"
+	"github.com/smugmug/godynamo/expression"
+	"github.com/smugmug/godynamo/ratelimit"
+	"github.com/smugmug/godynamo/retry"
 	"github.com/smugmug/godynamo/types/attributestoget"
 	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/aws_strings"
 	"github.com/smugmug/godynamo/types/capacity"
 	"github.com/smugmug/godynamo/types/expressionattributenames"
 	"github.com/smugmug/godynamo/types/item"
 	"net/http"
+	"sort"
 )
 
 const (
@@ -31,6 +37,9 @@ const (
 	QUERY_LIM_BYTES = 1048576
 	QUERY_LIM       = 100
 	RECURSE_LIM     = 50
+	// the most bytes of keys in one request, and of a key attribute value
+	REQUEST_LIM_BYTES = 16777216
+	KEY_LIM_BYTES     = 2048
 )
 
 // RequestInstance indicates what Keys to retrieve for a Table.
@@ -107,7 +116,7 @@ func (resp *Response) ToResponseItemsJSON() (*ResponseItemsJSON, error) {
 		resp_json.Responses[tn] = make([]interface{}, l)
 		for i, resp_item := range rs {
 			a := attributevalue.AttributeValueMap(resp_item)
-			c, cerr := a.ToInterface()
+			c, cerr := a.ToInterfaceUseNumber()
 			if cerr != nil {
 				return nil, cerr
 			}
@@ -122,7 +131,9 @@ func (resp *Response) ToResponseItemsJSON() (*ResponseItemsJSON, error) {
 // Split supports the ability to have BatchGetItem structs whose size
 // excceds the stated AWS limits. This function splits an arbitrarily-sized
 // BatchGetItems into a list of BatchGetItem structs that are limited
-// to the upper bound stated by AWS.
+// to the upper bound stated by AWS: QUERY_LIM keys and REQUEST_LIM_BYTES
+// of keys each. A key with an attribute value larger than KEY_LIM_BYTES
+// cannot be read, and is reported as an *item.SizeError.
 func Split(b *BatchGetItem) ([]BatchGetItem, error) {
 	if b == nil {
 		return nil, errors.New("batch_get_item.Split: receiver is nil")
@@ -130,13 +141,22 @@ func Split(b *BatchGetItem) ([]BatchGetItem, error) {
 	bs := make([]BatchGetItem, 0)
 	bi := NewBatchGetItem()
 	i := 0
+	bytes := 0
 	for tn := range b.RequestItems {
-		for _, ri := range b.RequestItems[tn].Keys {
-			if i == QUERY_LIM {
+		for j, ri := range b.RequestItems[tn].Keys {
+			for _, v := range ri {
+				if size := v.Size(); size > KEY_LIM_BYTES {
+					return nil, &item.SizeError{TableName: tn, Key: item.Key(ri), Index: j,
+						Size: size, Limit: KEY_LIM_BYTES}
+				}
+			}
+			size := ri.Size()
+			if i == QUERY_LIM || bytes+size > REQUEST_LIM_BYTES {
 				bi.ReturnConsumedCapacity = b.ReturnConsumedCapacity
 				bs = append(bs, *bi)
 				bi = NewBatchGetItem()
 				i = 0
+				bytes = 0
 			}
 			if _, tn_in_bi := bi.RequestItems[tn]; !tn_in_bi {
 				bi.RequestItems[tn] = NewRequestInstance()
@@ -146,9 +166,12 @@ func Split(b *BatchGetItem) ([]BatchGetItem, error) {
 				copy(bi.RequestItems[tn].AttributesToGet,
 					b.RequestItems[tn].AttributesToGet)
 				bi.RequestItems[tn].ConsistentRead = b.RequestItems[tn].ConsistentRead
+				bi.RequestItems[tn].ExpressionAttributeNames = b.RequestItems[tn].ExpressionAttributeNames
+				bi.RequestItems[tn].ProjectionExpression = b.RequestItems[tn].ProjectionExpression
 			}
 			bi.RequestItems[tn].Keys = append(bi.RequestItems[tn].Keys, ri)
 			i++
+			bytes += size
 		}
 	}
 	bi.ReturnConsumedCapacity = b.ReturnConsumedCapacity
@@ -156,57 +179,186 @@ func Split(b *BatchGetItem) ([]BatchGetItem, error) {
 	return bs, nil
 }
 
-// DoBatchGet is an endpoint request handler for BatchGetItem that supports arbitrarily-sized
-// BatchGetItem struct instances.
-// These are split in a list of conforming BatchGetItem instances
-// via `Split` and the concurrently dispatched to DynamoDB,
-// with the resulting responses stitched together. May break your provisioning.
-func (b *BatchGetItem) DoBatchGetWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+// ErrUnprocessed is the error of the keys that DynamoDB still returned as
+// UnprocessedKeys after RECURSE_LIM attempts.
+var ErrUnprocessed = errors.New("batch_get_item: keys still unprocessed after RECURSE_LIM attempts")
+
+// Failure is a key that was not read, and why.
+type Failure struct {
+	TableName string
+	Key       item.Item
+	// the error of the request it was last sent in, or ErrUnprocessed
+	Err error
+}
+
+// Result is the outcome of DoBatchGetResultWithContext.
+type Result struct {
+	// The merged Responses and ConsumedCapacity of every request sent.
+	// UnprocessedKeys are the keys that failed, and may be resubmitted.
+	Response *Response
+	// The keys that failed.
+	Failed []Failure
+}
+
+// Err returns nil if every key was read, or else an error wrapping the cause of the
+// first failure.
+func (r *Result) Err() error {
+	if r == nil {
+		return errors.New("batch_get_item.(Result)Err: receiver is nil")
+	}
+	if len(r.Failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("batch_get_item: %d keys failed, the first on table %s: %w",
+		len(r.Failed), r.Failed[0].TableName, r.Failed[0].Err)
+}
+
+// Options bound how DoBatchGetResultWithOptions sends the requests a batch is split
+// into. The zero value sends all of them at once.
+type Options struct {
+	// The most requests in flight at once; 0 for no limit.
+	MaxInFlight int
+	// The read capacity units per second to consume across all requests, including
+	// resubmissions of UnprocessedKeys; 0 for no limit. Each request is charged a unit
+	// per consistent read and half a unit per eventually consistent read before it is
+	// sent, and corrected by the ConsumedCapacity of its response, for which
+	// ReturnConsumedCapacity is set to TOTAL if not already set.
+	ReadCapacity float64
+}
+
+// DoBatchGetResultWithOptions is an endpoint request handler for BatchGetItem that supports
+// arbitrarily-sized BatchGetItem struct instances. These are split in a list of conforming
+// BatchGetItem instances via `Split` and dispatched to DynamoDB by at most o.MaxInFlight
+// workers at the rate of o.ReadCapacity, each resubmitting its UnprocessedKeys with
+// backoff (see RetryBatchGetWithContext). o may be nil. A failed sub-batch does not stop
+// the others: its keys are reported in the Failed list of the Result. An error is only
+// returned if the request cannot be sent at all.
+func (b *BatchGetItem) DoBatchGetResultWithOptions(ctx context.Context, o *Options, c *conf.AWS_Conf) (*Result, error) {
 	if b == nil {
-		return nil, 0, errors.New("batch_get_item.DoBatchGetWithConf: receiver is nil")
+		return nil, errors.New("batch_get_item.DoBatchGetResultWithOptions: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("batch_get_item.DoBatchGetResultWithOptions: ctx is nil")
 	}
 	if !conf.IsValid(c) {
-		return nil, 0, errors.New("batch_get_item.DoBatchGetWithConf: c is not valid")
+		return nil, errors.New("batch_get_item.DoBatchGetResultWithOptions: c is not valid")
+	}
+	if o == nil {
+		o = new(Options)
+	}
+	req := *b
+	var l *ratelimit.Limiter
+	if o.ReadCapacity > 0 {
+		l = ratelimit.New(o.ReadCapacity, 0)
+		if req.ReturnConsumedCapacity == "" {
+			req.ReturnConsumedCapacity = aws_strings.TOTAL
+		}
 	}
-	bs, split_err := Split(b)
+	bs, split_err := Split(&req)
 	if split_err != nil {
-		e := fmt.Sprintf("batch_get_item.DoBatchGet: split failed: %s", split_err.Error())
-		return nil, 0, errors.New(e)
+		return nil, fmt.Errorf("batch_get_item.DoBatchGetResultWithOptions: split failed: %w", split_err)
+	}
+	workers := len(bs)
+	if o.MaxInFlight > 0 && o.MaxInFlight < workers {
+		workers = o.MaxInFlight
 	}
-	resps := make(chan ep.Endpoint_Response, len(bs))
-	for _, bi := range bs {
-		go func(bi_ BatchGetItem) {
-			body, code, err := bi_.RetryBatchGetWithConf(0, c)
-			resps <- ep.Endpoint_Response{Body: body, Code: code, Err: err}
-		}(bi)
+	type outcome struct {
+		resp      *Response
+		remaining *BatchGetItem
+		err       error
 	}
-	combined_resp := NewResponse()
+	next := make(chan int)
+	outcomes := make(chan outcome, len(bs))
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range next {
+				resp, remaining, err := bs[i].retryUnprocessed(ctx, 0, l, c)
+				outcomes <- outcome{resp: resp, remaining: remaining, err: err}
+			}
+		}()
+	}
+	go func() {
+		for i := range bs {
+			next <- i
+		}
+		close(next)
+	}()
+	result := &Result{Response: NewResponse()}
 	for i := 0; i < len(bs); i++ {
-		resp := <-resps
-		if resp.Err != nil {
-			return nil, 0, resp.Err
-		} else if resp.Code != http.StatusOK {
-			e := fmt.Sprintf("batch_get_item.DoBatchGetWithConf (%d): code %d",
-				i, resp.Code)
-			return nil, resp.Code, errors.New(e)
-		} else {
-			var r Response
-			um_err := json.Unmarshal(resp.Body, &r)
-			if um_err != nil {
-				e := fmt.Sprintf("batch_get_item.DoBatchGetWithConf (%d): %s on \n%s",
-					i, um_err.Error(), string(resp.Body))
-				return nil, 0, errors.New(e)
+		out := <-outcomes
+		_ = combineResponseMetadata(result.Response, out.resp)
+		if cr_err := combineResponses(result.Response, out.resp); cr_err != nil {
+			return nil, cr_err
+		}
+		if out.remaining == nil {
+			continue
+		}
+		for _, tn := range tableNames(out.remaining.RequestItems) {
+			ri := out.remaining.RequestItems[tn]
+			for _, k := range ri.Keys {
+				result.Failed = append(result.Failed, Failure{TableName: tn, Key: k, Err: out.err})
+			}
+			if u, ok := result.Response.UnprocessedKeys[tn]; ok {
+				u.Keys = append(u.Keys, ri.Keys...)
+			} else {
+				u_cp := *ri
+				u_cp.Keys = append([]item.Item(nil), ri.Keys...)
+				result.Response.UnprocessedKeys[tn] = &u_cp
 			}
-			// merge the responses from this call and the recursive one
-			_ = combineResponseMetadata(combined_resp, &r)
-			_ = combineResponses(combined_resp, &r)
 		}
 	}
-	body, marshal_err := json.Marshal(*combined_resp)
+	return result, nil
+}
+
+// DoBatchGetResultWithContext calls DoBatchGetResultWithOptions without limits.
+// May break your provisioning.
+func (b *BatchGetItem) DoBatchGetResultWithContext(ctx context.Context, c *conf.AWS_Conf) (*Result, error) {
+	if b == nil {
+		return nil, errors.New("batch_get_item.DoBatchGetResultWithContext: receiver is nil")
+	}
+	return b.DoBatchGetResultWithOptions(ctx, nil, c)
+}
+
+// DoBatchGetResultWithConf calls DoBatchGetResultWithContext using a background context.
+func (b *BatchGetItem) DoBatchGetResultWithConf(c *conf.AWS_Conf) (*Result, error) {
+	if b == nil {
+		return nil, errors.New("batch_get_item.DoBatchGetResultWithConf: receiver is nil")
+	}
+	return b.DoBatchGetResultWithContext(context.Background(), c)
+}
+
+// DoBatchGetResult calls DoBatchGetResultWithConf using the global conf.
+func (b *BatchGetItem) DoBatchGetResult() (*Result, error) {
+	if b == nil {
+		return nil, errors.New("batch_get_item.DoBatchGetResult: receiver is nil")
+	}
+	return b.DoBatchGetResultWithConf(&conf.Vals)
+}
+
+// DoBatchGetWithContext calls DoBatchGetResultWithContext and returns the merged Response
+// as JSON. If any key failed, the body is returned along with the error of (*Result)Err,
+// and its UnprocessedKeys are the keys that failed.
+func (b *BatchGetItem) DoBatchGetWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if b == nil {
+		return nil, 0, errors.New("batch_get_item.DoBatchGetWithContext: receiver is nil")
+	}
+	result, err := b.DoBatchGetResultWithContext(ctx, c)
+	if err != nil {
+		return nil, 0, err
+	}
+	body, marshal_err := json.Marshal(*result.Response)
 	if marshal_err != nil {
 		return nil, 0, marshal_err
 	}
-	return body, http.StatusOK, nil
+	return body, http.StatusOK, result.Err()
+}
+
+// DoBatchGetWithConf calls DoBatchGetWithContext using a background context.
+func (b *BatchGetItem) DoBatchGetWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if b == nil {
+		return nil, 0, errors.New("batch_get_item.DoBatchGetWithConf: receiver is nil")
+	}
+	return b.DoBatchGetWithContext(context.Background(), c)
 }
 
 // DoBatchGet calls DoBatchGetWithConf using the global conf.
@@ -235,6 +387,11 @@ func unprocessedKeys2BatchGetItems(req *BatchGetItem, resp *Response) (*BatchGet
 				resp.UnprocessedKeys[tn].AttributesToGet)
 			b.RequestItems[tn].ConsistentRead =
 				resp.UnprocessedKeys[tn].ConsistentRead
+			b.RequestItems[tn].ProjectionExpression =
+				resp.UnprocessedKeys[tn].ProjectionExpression
+			for k, v := range resp.UnprocessedKeys[tn].ExpressionAttributeNames {
+				b.RequestItems[tn].ExpressionAttributeNames[k] = v
+			}
 			for _, item_src := range resp.UnprocessedKeys[tn].Keys {
 				item_cp := item.NewItem()
 				for k, v := range item_src {
@@ -257,19 +414,21 @@ func combineResponseMetadata(all, this *Response) error {
 	if all == nil || this == nil {
 		return errors.New("batch_get_item.combineResponseMetadata: all or this is nil")
 	}
-	combinedConsumedCapacity := make([]capacity.ConsumedCapacity, 0)
 	for _, this_cc := range this.ConsumedCapacity {
-		var cc capacity.ConsumedCapacity
-		cc.TableName = this_cc.TableName
-		cc.CapacityUnits = this_cc.CapacityUnits
-		for _, all_cc := range all.ConsumedCapacity {
-			if all_cc.TableName == this_cc.TableName {
-				cc.CapacityUnits += all_cc.CapacityUnits
+		merged := false
+		for i := range all.ConsumedCapacity {
+			if all.ConsumedCapacity[i].TableName == this_cc.TableName {
+				all.ConsumedCapacity[i].CapacityUnits += this_cc.CapacityUnits
+				merged = true
 			}
 		}
-		combinedConsumedCapacity = append(combinedConsumedCapacity, cc)
+		if !merged {
+			var cc capacity.ConsumedCapacity
+			cc.TableName = this_cc.TableName
+			cc.CapacityUnits = this_cc.CapacityUnits
+			all.ConsumedCapacity = append(all.ConsumedCapacity, cc)
+		}
 	}
-	all.ConsumedCapacity = combinedConsumedCapacity
 	return nil
 }
 
@@ -298,61 +457,130 @@ func combineResponses(all, this *Response) error {
 	return nil
 }
 
-// RetryBatchGetWithConf will attempt to fully complete a conforming BatchGetItem request.
-// Callers for this method should be of len QUERY_LIM or less (see DoBatchGets()).
-// This is different than EndpointReq in that it will extract UnprocessedKeys and
-// form new BatchGetItem's based on those, and combine any results.
-func (b *BatchGetItem) RetryBatchGetWithConf(depth int, c *conf.AWS_Conf) ([]byte, int, error) {
-	if b == nil {
-		return nil, 0, errors.New("batch_get_item.RetryBatchGetWithConf: receiver is nil")
+// readUnits estimates the read capacity units of b: a unit per consistent read and half a
+// unit per eventually consistent read.
+func (b *BatchGetItem) readUnits() float64 {
+	units := 0.0
+	for _, ri := range b.RequestItems {
+		if ri == nil {
+			continue
+		}
+		if ri.ConsistentRead {
+			units += float64(len(ri.Keys))
+		} else {
+			units += float64(len(ri.Keys)) / 2
+		}
 	}
-	if depth > RECURSE_LIM {
-		e := fmt.Sprintf("batch_get_item.RetryBatchGetWithConf: recursion depth exceeded")
-		return nil, 0, errors.New(e)
+	return units
+}
+
+// consumedUnits returns the capacity units of all tables in resp.
+func consumedUnits(resp *Response) float64 {
+	units := 0.0
+	for _, cc := range resp.ConsumedCapacity {
+		units += float64(cc.CapacityUnits)
 	}
-	body, code, err := b.EndpointReqWithConf(c)
-	if err != nil || code != http.StatusOK {
-		return body, code, err
+	return units
+}
+
+// tableNames returns the table names of t in order, so failures are reported predictably.
+func tableNames(t Table2Requests) []string {
+	tns := make([]string, 0, len(t))
+	for tn := range t {
+		tns = append(tns, tn)
 	}
-	// we'll need an actual Response object
-	var resp Response
-	um_err := json.Unmarshal([]byte(body), &resp)
-	if um_err != nil {
-		e := fmt.Sprintf("batch_get_item.RetryBatchGetWithConf: %s", um_err.Error())
-		return nil, 0, errors.New(e)
+	sort.Strings(tns)
+	return tns
+}
+
+// retryUnprocessed sends b, then resubmits its UnprocessedKeys after a retry.Unprocessed
+// backoff until none remain or attempt RECURSE_LIM has been made, taking the capacity of
+// each request from l if it is not nil. It returns the merged responses and, if it did not finish, the keys that were not read along with the error
+// that stopped it.
+func (b *BatchGetItem) retryUnprocessed(ctx context.Context, attempt int, l *ratelimit.Limiter, c *conf.AWS_Conf) (*Response, *BatchGetItem, error) {
+	all := NewResponse()
+	if attempt > RECURSE_LIM {
+		return all, b, ErrUnprocessed
 	}
-	// if there are unprocessed items remaining from this call...
-	if len(resp.UnprocessedKeys) > 0 {
-		// make a new BatchGetItem object based on the unprocessed items
-		n_req, n_req_err := unprocessedKeys2BatchGetItems(b, &resp)
-		if n_req_err != nil {
-			e := fmt.Sprintf("batch_get_item.RetryBatchGet: %s", n_req_err.Error())
-			return nil, 0, errors.New(e)
+	req := b
+	for n := attempt; ; n++ {
+		if n > attempt {
+			d := retry.Unprocessed.NextDelay(&retry.Attempt{Number: n - attempt})
+			if sleep_err := retry.Sleep(ctx, d); sleep_err != nil {
+				return all, req, sleep_err
+			}
+		}
+		estimate := req.readUnits()
+		if take_err := l.Take(ctx, estimate); take_err != nil {
+			return all, req, take_err
+		}
+		resp, err := req.DoWithContext(ctx, c)
+		if err != nil {
+			return all, req, err
+		}
+		if len(resp.ConsumedCapacity) != 0 {
+			l.Charge(consumedUnits(resp) - estimate)
+		}
+		_ = combineResponseMetadata(all, resp)
+		if cr_err := combineResponses(all, resp); cr_err != nil {
+			return all, req, cr_err
 		}
-		// call this function on the new object
-		n_body, n_code, n_err := n_req.RetryBatchGetWithConf(depth+1, c)
-		if n_err != nil || n_code != http.StatusOK {
-			return nil, n_code, n_err
+		if len(resp.UnprocessedKeys) == 0 {
+			return all, nil, nil
 		}
-		// get the response as an object
-		var n_resp Response
-		um_err := json.Unmarshal(n_body, &n_resp)
-		if um_err != nil {
-			e := fmt.Sprintf("batch_get_item.RetryBatchGet: %s", um_err.Error())
-			return nil, 0, errors.New(e)
+		n_req, n_req_err := unprocessedKeys2BatchGetItems(req, resp)
+		if n_req_err != nil {
+			return all, req, n_req_err
+		}
+		req = n_req
+		if n >= RECURSE_LIM {
+			return all, req, ErrUnprocessed
 		}
-		// merge the responses from this call and the recursive one
-		_ = combineResponseMetadata(&resp, &n_resp)
-		_ = combineResponses(&resp, &n_resp)
-		// make a response string again out of the merged responses
-		resp_json, resp_json_err := json.Marshal(resp)
-		if resp_json_err != nil {
-			e := fmt.Sprintf("batch_get_item.RetryBatchGet: %s", resp_json_err.Error())
-			return nil, 0, errors.New(e)
+	}
+}
+
+// RetryBatchGetWithContext will attempt to fully complete a conforming BatchGetItem request.
+// Callers for this method should be of len QUERY_LIM or less (see DoBatchGets()).
+// This is different than EndpointReq in that it will extract UnprocessedKeys and
+// resubmit them after an exponential backoff, and combine any results. depth is the
+// number of attempts already made; at most RECURSE_LIM+1 are made in all. If keys remain
+// unprocessed, the combined response is returned with them as its UnprocessedKeys and an
+// error wrapping ErrUnprocessed.
+func (b *BatchGetItem) RetryBatchGetWithContext(ctx context.Context, depth int, c *conf.AWS_Conf) ([]byte, int, error) {
+	if b == nil {
+		return nil, 0, errors.New("batch_get_item.RetryBatchGetWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, 0, errors.New("batch_get_item.RetryBatchGetWithContext: ctx is nil")
+	}
+	resp, remaining, err := b.retryUnprocessed(ctx, depth, nil, c)
+	if err != nil && !errors.Is(err, ErrUnprocessed) {
+		var api_err *dynamoerr.APIError
+		if errors.As(err, &api_err) {
+			return nil, api_err.StatusCode, err
 		}
-		body = resp_json
+		return nil, 0, err
+	}
+	if remaining != nil {
+		resp.UnprocessedKeys = remaining.RequestItems
+	}
+	body, marshal_err := json.Marshal(*resp)
+	if marshal_err != nil {
+		e := fmt.Sprintf("batch_get_item.RetryBatchGetWithContext: %s", marshal_err.Error())
+		return nil, 0, errors.New(e)
+	}
+	if err != nil {
+		return body, http.StatusOK, fmt.Errorf("batch_get_item.RetryBatchGetWithContext: %w", err)
+	}
+	return body, http.StatusOK, nil
+}
+
+// RetryBatchGetWithConf calls RetryBatchGetWithContext using a background context.
+func (b *BatchGetItem) RetryBatchGetWithConf(depth int, c *conf.AWS_Conf) ([]byte, int, error) {
+	if b == nil {
+		return nil, 0, errors.New("batch_get_item.RetryBatchGetWithConf: receiver is nil")
 	}
-	return body, code, err
+	return b.RetryBatchGetWithContext(context.Background(), depth, c)
 }
 
 // RetryBatchGet is just a wrapper for RetryBatchGetWithConf using the global conf.
@@ -363,29 +591,66 @@ func (b *BatchGetItem) RetryBatchGet(depth int) ([]byte, int, error) {
 	return b.RetryBatchGetWithConf(depth, &conf.Vals)
 }
 
-// These implementations of EndpointReq use a parameterized conf.
+// ValidateExpressions parses the projection expression of each table of the request
+// and checks its expression attribute names as DynamoDB would, without making the request.
+func (batch_get_item *BatchGetItem) ValidateExpressions() error {
+	if batch_get_item == nil {
+		return errors.New("batch_get_item.(BatchGetItem)ValidateExpressions: receiver is nil")
+	}
+	for tn, r := range batch_get_item.RequestItems {
+		if r == nil {
+			continue
+		}
+		e := expression.Expressions{
+			ProjectionExpression:     r.ProjectionExpression,
+			ExpressionAttributeNames: r.ExpressionAttributeNames,
+		}
+		if v_err := e.Validate(); v_err != nil {
+			return errors.New(fmt.Sprintf("batch_get_item.(BatchGetItem)ValidateExpressions: table %s: %s", tn, v_err.Error()))
+		}
+	}
+	return nil
+}
 
-func (batch_get_item *BatchGetItem) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+// These implementations of EndpointReq use a parameterized conf and context.
+
+func (batch_get_item *BatchGetItem) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
 	if batch_get_item == nil {
-		return nil, 0, errors.New("batch_get_item.(BatchGetItem)EndpointReqWithConf: receiver is nil")
+		return nil, 0, errors.New("batch_get_item.(BatchGetItem)EndpointReqWithContext: receiver is nil")
 	}
 	if !conf.IsValid(c) {
-		return nil, 0, errors.New("batch_get_item.EndpointReqWithConf: c is not valid")
+		return nil, 0, errors.New("batch_get_item.EndpointReqWithContext: c is not valid")
 	}
 	// returns resp_body,code,err
 	reqJSON, json_err := json.Marshal(batch_get_item)
 	if json_err != nil {
 		return nil, 0, json_err
 	}
-	return authreq.RetryReqJSON_V4WithConf(reqJSON, BATCHGET_ENDPOINT, c)
+	return authreq.RetryReqJSON_V4WithContext(ctx, reqJSON, BATCHGET_ENDPOINT, c)
+}
+
+func (req *Request) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("batch_get_item.(Request)EndpointReqWithContext: receiver is nil")
+	}
+	batch_get_item := BatchGetItem(*req)
+	return batch_get_item.EndpointReqWithContext(ctx, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (batch_get_item *BatchGetItem) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if batch_get_item == nil {
+		return nil, 0, errors.New("batch_get_item.(BatchGetItem)EndpointReqWithConf: receiver is nil")
+	}
+	return batch_get_item.EndpointReqWithContext(context.Background(), c)
 }
 
 func (req *Request) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if req == nil {
 		return nil, 0, errors.New("batch_get_item.(Request)EndpointReqWithConf: receiver is nil")
 	}
-	batch_get_item := BatchGetItem(*req)
-	return batch_get_item.EndpointReqWithConf(c)
+	return req.EndpointReqWithContext(context.Background(), c)
 }
 
 // These implementations of EndpointReq use the global conf.
@@ -404,3 +669,59 @@ func (req *Request) EndpointReq() ([]byte, int, error) {
 	batch_get_item := BatchGetItem(*req)
 	return batch_get_item.EndpointReqWithConf(&conf.Vals)
 }
+
+// These implementations of Do perform the request and decode the result into a Response.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded Response. Error responses are returned as a *dynamoerr.APIError.
+func (batch_get_item *BatchGetItem) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if batch_get_item == nil {
+		return nil, errors.New("batch_get_item.(BatchGetItem)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("batch_get_item.(BatchGetItem)DoWithContext: ctx is nil")
+	}
+	resp := NewResponse()
+	if err := authreq.DoWithContext(ctx, batch_get_item, BATCHGET_ENDPOINT, c, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (req *Request) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("batch_get_item.(Request)DoWithContext: receiver is nil")
+	}
+	batch_get_item := BatchGetItem(*req)
+	return batch_get_item.DoWithContext(ctx, c)
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (batch_get_item *BatchGetItem) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if batch_get_item == nil {
+		return nil, errors.New("batch_get_item.(BatchGetItem)DoWithConf: receiver is nil")
+	}
+	return batch_get_item.DoWithContext(context.Background(), c)
+}
+
+func (req *Request) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("batch_get_item.(Request)DoWithConf: receiver is nil")
+	}
+	return req.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (batch_get_item *BatchGetItem) Do() (*Response, error) {
+	if batch_get_item == nil {
+		return nil, errors.New("batch_get_item.(BatchGetItem)Do: receiver is nil")
+	}
+	return batch_get_item.DoWithConf(&conf.Vals)
+}
+
+func (req *Request) Do() (*Response, error) {
+	if req == nil {
+		return nil, errors.New("batch_get_item.(Request)Do: receiver is nil")
+	}
+	return req.DoWithConf(&conf.Vals)
+}
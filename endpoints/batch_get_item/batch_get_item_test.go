@@ -2,11 +2,22 @@
 package batch_get_item
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/retry"
 	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/capacity"
 	"github.com/smugmug/godynamo/types/item"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestNil(t *testing.T) {
@@ -103,3 +114,177 @@ func TestSplit1(t *testing.T) {
 		i++
 	}
 }
+
+func TestSplitKeySize(t *testing.T) {
+	b := NewBatchGetItem()
+	b.RequestItems["foo"] = NewRequestInstance()
+	b.RequestItems["foo"].ProjectionExpression = "KeyName"
+	for i := 0; i < 3; i++ {
+		key := make(item.Item)
+		key["KeyName"] = &attributevalue.AttributeValue{S: fmt.Sprintf("TheKey%d", i)}
+		b.RequestItems["foo"].Keys = append(b.RequestItems["foo"].Keys, key)
+	}
+	bs, err := Split(b)
+	if err != nil || len(bs) != 1 || bs[0].RequestItems["foo"].ProjectionExpression != "KeyName" {
+		t.Errorf("unexpected split %v %v", bs, err)
+	}
+	key := make(item.Item)
+	key["KeyName"] = &attributevalue.AttributeValue{S: strings.Repeat("k", KEY_LIM_BYTES+1)}
+	b.RequestItems["foo"].Keys = append(b.RequestItems["foo"].Keys, key)
+	_, err = Split(b)
+	var size_err *item.SizeError
+	if !errors.As(err, &size_err) || size_err.Index != 3 || size_err.TableName != "foo" {
+		t.Errorf("expected a SizeError, got %v", err)
+	}
+	c := new(conf.AWS_Conf)
+	c.Initialized = true
+	_, err = b.DoBatchGetResultWithConf(c)
+	if !errors.As(err, &size_err) || size_err.Index != 3 {
+		t.Errorf("DoBatchGetResultWithConf should wrap the SizeError, got %v", err)
+	}
+}
+
+func TestUnprocessedFailures(t *testing.T) {
+	defer func(u *retry.FullJitter) { retry.Unprocessed = u }(retry.Unprocessed)
+	retry.Unprocessed = &retry.FullJitter{Base: time.Microsecond, Multiplier: 2, Cap: time.Millisecond}
+	// keys are read on their second attempt, except "stuck" which never is
+	var lock sync.Mutex
+	seen := make(map[string]bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b BatchGetItem
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			t.Error(err)
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		w.Header().Set("X-Amzn-Requestid", "TESTREQID")
+		resp := NewResponse()
+		for tn, ri := range b.RequestItems {
+			for _, k := range ri.Keys {
+				if k["Key"].S == "stuck" || !seen[k["Key"].S] {
+					if _, ok := resp.UnprocessedKeys[tn]; !ok {
+						u := *ri
+						u.Keys = nil
+						resp.UnprocessedKeys[tn] = &u
+					}
+					resp.UnprocessedKeys[tn].Keys = append(resp.UnprocessedKeys[tn].Keys, k)
+				} else {
+					resp.Responses[tn] = append(resp.Responses[tn], k)
+				}
+				seen[k["Key"].S] = true
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	c := new(conf.AWS_Conf)
+	c.Auth.AccessKey = "myAccessKey"
+	c.Auth.Secret = "mySecret"
+	c.Network.DynamoDB.URL = srv.URL
+	c.Network.DynamoDB.Host = u.Hostname()
+	c.Network.DynamoDB.Port = u.Port()
+	c.Network.DynamoDB.Zone = "us-east-1"
+	c.RetryPolicy = retry.NoRetry{}
+	c.Initialized = true
+
+	b := NewBatchGetItem()
+	b.RequestItems["foo"] = NewRequestInstance()
+	b.RequestItems["foo"].ProjectionExpression = "#k"
+	b.RequestItems["foo"].ExpressionAttributeNames["#k"] = "Key"
+	for i := 0; i < 150; i++ {
+		k := fmt.Sprintf("k%d", i)
+		if i == 120 {
+			k = "stuck"
+		}
+		b.RequestItems["foo"].Keys = append(b.RequestItems["foo"].Keys,
+			item.Item{"Key": &attributevalue.AttributeValue{S: k}})
+	}
+	result, err := b.DoBatchGetResultWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Response.Responses["foo"]) != 149 {
+		t.Errorf("expected 149 items, got %d", len(result.Response.Responses["foo"]))
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Key["Key"].S != "stuck" || !errors.Is(result.Failed[0].Err, ErrUnprocessed) {
+		t.Fatalf("expected stuck to fail, got %v", result.Failed)
+	}
+	unprocessed := result.Response.UnprocessedKeys["foo"]
+	if unprocessed == nil || len(unprocessed.Keys) != 1 || unprocessed.ProjectionExpression != "#k" ||
+		unprocessed.ExpressionAttributeNames["#k"] != "Key" {
+		t.Errorf("the unprocessed keys should keep their projection: %+v", unprocessed)
+	}
+	if !errors.Is(result.Err(), ErrUnprocessed) {
+		t.Errorf("expected the result to fail with ErrUnprocessed, got %v", result.Err())
+	}
+}
+
+func TestOptions(t *testing.T) {
+	var lock sync.Mutex
+	var in_flight, most int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b BatchGetItem
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			t.Error(err)
+		}
+		lock.Lock()
+		in_flight++
+		if in_flight > most {
+			most = in_flight
+		}
+		lock.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		lock.Lock()
+		in_flight--
+		lock.Unlock()
+		w.Header().Set("X-Amzn-Requestid", "TESTREQID")
+		resp := NewResponse()
+		for tn, ri := range b.RequestItems {
+			resp.Responses[tn] = ri.Keys
+			resp.ConsumedCapacity = append(resp.ConsumedCapacity,
+				capacity.ConsumedCapacity{TableName: tn, CapacityUnits: capacity.ConsumedCapacityUnit(len(ri.Keys) / 2)})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	c := new(conf.AWS_Conf)
+	c.Auth.AccessKey = "myAccessKey"
+	c.Auth.Secret = "mySecret"
+	c.Network.DynamoDB.URL = srv.URL
+	c.Network.DynamoDB.Host = u.Hostname()
+	c.Network.DynamoDB.Port = u.Port()
+	c.Network.DynamoDB.Zone = "us-east-1"
+	c.Initialized = true
+
+	b := NewBatchGetItem()
+	b.RequestItems["foo"] = NewRequestInstance()
+	for i := 0; i < 400; i++ {
+		b.RequestItems["foo"].Keys = append(b.RequestItems["foo"].Keys,
+			item.Item{"Key": &attributevalue.AttributeValue{S: fmt.Sprintf("k%d", i)}})
+	}
+	result, err := b.DoBatchGetResultWithOptions(context.Background(), &Options{MaxInFlight: 2}, c)
+	if err != nil || result.Err() != nil {
+		t.Fatal(err, result.Err())
+	}
+	if most != 2 {
+		t.Errorf("expected at most 2 requests in flight, saw %d", most)
+	}
+	if len(result.Response.Responses["foo"]) != 400 {
+		t.Errorf("expected 400 items, got %d", len(result.Response.Responses["foo"]))
+	}
+
+	// four requests of 50 units at 200 units a second
+	start := time.Now()
+	result, err = b.DoBatchGetResultWithOptions(context.Background(), &Options{ReadCapacity: 200}, c)
+	if err != nil || result.Err() != nil {
+		t.Fatal(err, result.Err())
+	}
+	if elapsed := time.Since(start); elapsed < 700*time.Millisecond {
+		t.Errorf("expected the requests to take about 750ms, took %v", elapsed)
+	}
+	if len(result.Response.ConsumedCapacity) != 1 || result.Response.ConsumedCapacity[0].CapacityUnits != 200 {
+		t.Errorf("unexpected consumed capacity %v", result.Response.ConsumedCapacity)
+	}
+}
@@ -8,18 +8,28 @@
 package batch_write_item
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/smugmug/godynamo/authreq"
 	"github.com/smugmug/godynamo/aws_const"
 	"github.com/smugmug/godynamo/conf"
-	ep "github.com/smugmug/godynamo/endpoint"
+	"github.com/smugmug/godynamo/dynamoerr"
+	"github.com/smugmug/godynamo/endpoints/describe_table"
+	"github.com/smugmug/godynamo/ratelimit"
+	"github.com/smugmug/godynamo/retry"
 	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/aws_strings"
 	"github.com/smugmug/godynamo/types/capacity"
 	"github.com/smugmug/godynamo/types/item"
 	"github.com/smugmug/godynamo/types/itemcollectionmetrics"
+	"github.com/smugmug/godynamo/types/keydefinition"
+	"math"
 	"net/http"
+	"sort"
 )
 
 const (
@@ -30,6 +40,9 @@ const (
 	QUERY_LIM_BYTES = 1048576
 	QUERY_LIM       = 25
 	RECURSE_LIM     = 50
+	// the most bytes of items in one request, and in one item
+	REQUEST_LIM_BYTES = 16777216
+	ITEM_LIM_BYTES    = attributevalue.ITEM_LIM_BYTES
 )
 
 type DeleteRequest struct {
@@ -137,25 +150,40 @@ func NewResponse() *Response {
 // Split supports the ability to have BatchWriteItem structs whose size
 // excceds the stated AWS limits. This function splits an arbitrarily-sized
 // BatchWriteItems into a list of BatchWriteItem structs that are limited
-// to the upper bound stated by AWS.
+// to the upper bound stated by AWS: QUERY_LIM requests and REQUEST_LIM_BYTES
+// of items each. An item larger than ITEM_LIM_BYTES cannot be written, and is
+// reported as an *item.SizeError naming the digest of the item. Use
+// SplitWithKeySchema to name its key instead.
 func Split(b *BatchWriteItem) ([]BatchWriteItem, error) {
+	return SplitWithKeySchema(b, nil)
+}
+
+// SplitWithKeySchema is the same as Split, but an *item.SizeError for a put
+// request of a table in schemas names the key of the item.
+func SplitWithKeySchema(b *BatchWriteItem, schemas map[string]keydefinition.KeySchema) ([]BatchWriteItem, error) {
 	if b == nil {
 		return nil, errors.New("batch_write_item.Split: receiver is nil")
 	}
 	bs := make([]BatchWriteItem, 0)
 	bi := NewBatchWriteItem()
 	i := 0
+	bytes := 0
 	// for each table name (tn) in b.RequestItems
 	for tn := range b.RequestItems {
 		// for each request in that table's list
-		for _, ri := range b.RequestItems[tn] {
-			if i == QUERY_LIM {
+		for j, ri := range b.RequestItems[tn] {
+			size, size_err := requestSize(tn, j, ri, schemas[tn])
+			if size_err != nil {
+				return nil, size_err
+			}
+			if i == QUERY_LIM || bytes+size > REQUEST_LIM_BYTES {
 				// append value of existing bi, make a new one
 				bi.ReturnConsumedCapacity = b.ReturnConsumedCapacity
 				bi.ReturnItemCollectionMetrics = b.ReturnItemCollectionMetrics
 				bs = append(bs, *bi)
 				bi = NewBatchWriteItem()
 				i = 0
+				bytes = 0
 			}
 			// if creating a request in bi for tn for the first time, initialize
 			if _, tn_in_bi := bi.RequestItems[tn]; !tn_in_bi {
@@ -164,6 +192,7 @@ func Split(b *BatchWriteItem) ([]BatchWriteItem, error) {
 			// append request to list in bi for this tn
 			bi.RequestItems[tn] = append(bi.RequestItems[tn], ri)
 			i++
+			bytes += size
 		}
 	}
 	bi.ReturnConsumedCapacity = b.ReturnConsumedCapacity
@@ -172,55 +201,233 @@ func Split(b *BatchWriteItem) ([]BatchWriteItem, error) {
 	return bs, nil
 }
 
-// DoBatchWriteWithConf is an endpoint request handler for BatchWriteItem that supports arbitrarily-sized
-// BatchWriteItem struct instances. These are split in a list of conforming BatchWriteItem instances
-// via `Split` and the concurrently dispatched to DynamoDB, with the resulting responses stitched
-// together. May break your provisioning.
-func (b *BatchWriteItem) DoBatchWriteWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+// requestSize returns the size of the item put or the key deleted by ri, the request
+// at index j of table tn, or an *item.SizeError if it is over ITEM_LIM_BYTES. The error
+// names the key of a put if ks is known, and the digest of the item otherwise.
+func requestSize(tn string, j int, ri RequestInstance, ks keydefinition.KeySchema) (int, error) {
+	var size int
+	var key item.Key
+	var digest string
+	switch {
+	case ri.PutRequest != nil:
+		size = ri.PutRequest.Item.Size()
+		if size > ITEM_LIM_BYTES && len(ks) != 0 {
+			key = item.NewKey()
+			for _, kd := range ks {
+				key[kd.AttributeName] = ri.PutRequest.Item[kd.AttributeName]
+			}
+		} else if size > ITEM_LIM_BYTES {
+			// json.Marshal sorts the attributes, so the digest is stable
+			if b, json_err := json.Marshal(ri.PutRequest.Item); json_err == nil {
+				h := sha256.Sum256(b)
+				digest = hex.EncodeToString(h[:])
+			}
+		}
+	case ri.DeleteRequest != nil:
+		size = ri.DeleteRequest.Key.Size()
+		key = item.Key(ri.DeleteRequest.Key)
+	}
+	if size > ITEM_LIM_BYTES {
+		return 0, &item.SizeError{TableName: tn, Key: key, Digest: digest, Index: j, Size: size, Limit: ITEM_LIM_BYTES}
+	}
+	return size, nil
+}
+
+// splitDescribing splits b as SplitWithKeySchema does. If a put is too large and the key
+// schema of its table is not in schemas, it is read with DescribeTable so that the
+// *item.SizeError names the key of the item.
+func splitDescribing(ctx context.Context, b *BatchWriteItem, schemas map[string]keydefinition.KeySchema, c *conf.AWS_Conf) ([]BatchWriteItem, error) {
+	bs, split_err := SplitWithKeySchema(b, schemas)
+	var size_err *item.SizeError
+	if !errors.As(split_err, &size_err) || size_err.Key != nil {
+		return bs, split_err
+	}
+	d := describe_table.NewDescribeTable()
+	d.TableName = size_err.TableName
+	resp, d_err := d.DoWithContext(ctx, c)
+	if d_err != nil {
+		// the digest still identifies the item
+		return nil, split_err
+	}
+	ri := b.RequestItems[size_err.TableName][size_err.Index]
+	_, key_err := requestSize(size_err.TableName, size_err.Index, ri, resp.Table.KeySchema)
+	return nil, key_err
+}
+
+// ErrUnprocessed is the error of the requests that DynamoDB still returned as
+// UnprocessedItems after RECURSE_LIM attempts.
+var ErrUnprocessed = errors.New("batch_write_item: requests still unprocessed after RECURSE_LIM attempts")
+
+// Failure is a put or delete request that was not written, and why.
+type Failure struct {
+	TableName string
+	Request   RequestInstance
+	// the error of the request it was last sent in, or ErrUnprocessed
+	Err error
+}
+
+// Result is the outcome of DoBatchWriteResultWithContext.
+type Result struct {
+	// The merged ConsumedCapacity and ItemCollectionMetrics of every request sent.
+	// UnprocessedItems are the requests that failed, and may be resubmitted.
+	Response *Response
+	// The requests that failed, so they may be dead-lettered.
+	Failed []Failure
+}
+
+// Err returns nil if every request was written, or else an error wrapping the cause of
+// the first failure.
+func (r *Result) Err() error {
+	if r == nil {
+		return errors.New("batch_write_item.(Result)Err: receiver is nil")
+	}
+	if len(r.Failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("batch_write_item: %d requests failed, the first on table %s: %w",
+		len(r.Failed), r.Failed[0].TableName, r.Failed[0].Err)
+}
+
+// Options bound how DoBatchWriteResultWithOptions sends the requests a batch is split
+// into. The zero value sends all of them at once.
+type Options struct {
+	// The most requests in flight at once; 0 for no limit.
+	MaxInFlight int
+	// The write capacity units per second to consume across all requests, including
+	// resubmissions of UnprocessedItems; 0 for no limit. Each request is charged a unit
+	// per KB of its puts and a unit per delete before it is sent, and corrected by the
+	// ConsumedCapacity of its response, for which ReturnConsumedCapacity is set to TOTAL
+	// if not already set.
+	WriteCapacity float64
+	// The key schemas of the tables written, which name the key of an item too large to
+	// write. The key schema of any other table is read with DescribeTable if one of its
+	// items is too large.
+	KeySchemas map[string]keydefinition.KeySchema
+}
+
+// DoBatchWriteResultWithOptions is an endpoint request handler for BatchWriteItem that supports
+// arbitrarily-sized BatchWriteItem struct instances. These are split in a list of conforming
+// BatchWriteItem instances via `Split` and dispatched to DynamoDB by at most o.MaxInFlight
+// workers at the rate of o.WriteCapacity, each resubmitting its UnprocessedItems with
+// backoff (see RetryBatchWriteWithContext). o may be nil. A failed sub-batch does not stop
+// the others: its requests are reported in the Failed list of the Result. An error is only
+// returned if the request cannot be sent at all.
+func (b *BatchWriteItem) DoBatchWriteResultWithOptions(ctx context.Context, o *Options, c *conf.AWS_Conf) (*Result, error) {
 	if b == nil {
-		return nil, 0, errors.New("batch_write_item.DoBatchWriteWithConf: receiver is nil")
+		return nil, errors.New("batch_write_item.DoBatchWriteResultWithOptions: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("batch_write_item.DoBatchWriteResultWithOptions: ctx is nil")
 	}
 	if !conf.IsValid(c) {
-		return nil, 0, errors.New("batch_write_item.DoBatchWriteWithConf: c is not valid")
+		return nil, errors.New("batch_write_item.DoBatchWriteResultWithOptions: c is not valid")
 	}
-	bs, split_err := Split(b)
+	if o == nil {
+		o = new(Options)
+	}
+	req := *b
+	var l *ratelimit.Limiter
+	if o.WriteCapacity > 0 {
+		l = ratelimit.New(o.WriteCapacity, 0)
+		if req.ReturnConsumedCapacity == "" {
+			req.ReturnConsumedCapacity = aws_strings.TOTAL
+		}
+	}
+	bs, split_err := splitDescribing(ctx, &req, o.KeySchemas, c)
 	if split_err != nil {
-		e := fmt.Sprintf("batch_write_item.DoBatchWriteWithConf: split failed: %s", split_err.Error())
-		return nil, 0, errors.New(e)
+		return nil, fmt.Errorf("batch_write_item.DoBatchWriteResultWithOptions: split failed: %w", split_err)
+	}
+	workers := len(bs)
+	if o.MaxInFlight > 0 && o.MaxInFlight < workers {
+		workers = o.MaxInFlight
 	}
-	resps := make(chan ep.Endpoint_Response, len(bs))
-	for _, bi := range bs {
-		go func(bi_ BatchWriteItem) {
-			body, code, err := bi_.RetryBatchWriteWithConf(0, c)
-			resps <- ep.Endpoint_Response{Body: body, Code: code, Err: err}
-		}(bi)
+	type outcome struct {
+		resp      *Response
+		remaining *BatchWriteItem
+		err       error
 	}
-	combined_resp := NewResponse()
+	next := make(chan int)
+	outcomes := make(chan outcome, len(bs))
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range next {
+				resp, remaining, err := bs[i].retryUnprocessed(ctx, 0, l, c)
+				outcomes <- outcome{resp: resp, remaining: remaining, err: err}
+			}
+		}()
+	}
+	go func() {
+		for i := range bs {
+			next <- i
+		}
+		close(next)
+	}()
+	result := &Result{Response: NewResponse()}
 	for i := 0; i < len(bs); i++ {
-		resp := <-resps
-		if resp.Err != nil {
-			return nil, 0, resp.Err
-		} else if resp.Code != http.StatusOK {
-			e := fmt.Sprintf("batch_write_item.DoBatchWrite (%d): code %d",
-				i, resp.Code)
-			return nil, resp.Code, errors.New(e)
-		} else {
-			var r Response
-			um_err := json.Unmarshal(resp.Body, &r)
-			if um_err != nil {
-				e := fmt.Sprintf("batch_write_item.DoBatchWrite (%d):%s on \n%s",
-					i, um_err.Error(), string(resp.Body))
-				return nil, 0, errors.New(e)
+		out := <-outcomes
+		_ = combineResponseMetadata(result.Response, out.resp)
+		if out.remaining == nil {
+			continue
+		}
+		for _, tn := range tableNames(out.remaining.RequestItems) {
+			for _, ri := range out.remaining.RequestItems[tn] {
+				result.Failed = append(result.Failed, Failure{TableName: tn, Request: ri, Err: out.err})
+				result.Response.UnprocessedItems[tn] = append(result.Response.UnprocessedItems[tn], ri)
 			}
-			// merge the responses from this call and the recursive one
-			_ = combineResponseMetadata(combined_resp, &r)
 		}
 	}
-	body, marshal_err := json.Marshal(*combined_resp)
+	return result, nil
+}
+
+// DoBatchWriteResultWithContext calls DoBatchWriteResultWithOptions without limits.
+// May break your provisioning.
+func (b *BatchWriteItem) DoBatchWriteResultWithContext(ctx context.Context, c *conf.AWS_Conf) (*Result, error) {
+	if b == nil {
+		return nil, errors.New("batch_write_item.DoBatchWriteResultWithContext: receiver is nil")
+	}
+	return b.DoBatchWriteResultWithOptions(ctx, nil, c)
+}
+
+// DoBatchWriteResultWithConf calls DoBatchWriteResultWithContext using a background context.
+func (b *BatchWriteItem) DoBatchWriteResultWithConf(c *conf.AWS_Conf) (*Result, error) {
+	if b == nil {
+		return nil, errors.New("batch_write_item.DoBatchWriteResultWithConf: receiver is nil")
+	}
+	return b.DoBatchWriteResultWithContext(context.Background(), c)
+}
+
+// DoBatchWriteResult calls DoBatchWriteResultWithConf using the global conf.
+func (b *BatchWriteItem) DoBatchWriteResult() (*Result, error) {
+	if b == nil {
+		return nil, errors.New("batch_write_item.DoBatchWriteResult: receiver is nil")
+	}
+	return b.DoBatchWriteResultWithConf(&conf.Vals)
+}
+
+// DoBatchWriteWithContext calls DoBatchWriteResultWithContext and returns the merged
+// Response as JSON. If any request failed, the body is returned along with the error of
+// (*Result)Err, and its UnprocessedItems are the requests that failed.
+func (b *BatchWriteItem) DoBatchWriteWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if b == nil {
+		return nil, 0, errors.New("batch_write_item.DoBatchWriteWithContext: receiver is nil")
+	}
+	result, err := b.DoBatchWriteResultWithContext(ctx, c)
+	if err != nil {
+		return nil, 0, err
+	}
+	body, marshal_err := json.Marshal(*result.Response)
 	if marshal_err != nil {
 		return nil, 0, marshal_err
 	}
-	return body, http.StatusOK, nil
+	return body, http.StatusOK, result.Err()
+}
+
+// DoBatchWriteWithConf calls DoBatchWriteWithContext using a background context.
+func (b *BatchWriteItem) DoBatchWriteWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if b == nil {
+		return nil, 0, errors.New("batch_write_item.DoBatchWriteWithConf: receiver is nil")
+	}
+	return b.DoBatchWriteWithContext(context.Background(), c)
 }
 
 // DoBatchWrite calls DoBatchWriteWithConf using the global conf.
@@ -278,19 +485,21 @@ func combineResponseMetadata(all, this *Response) error {
 	if all == nil || this == nil {
 		return errors.New("batch_write_item.combineResponseMetadata: all or this is nil")
 	}
-	combinedConsumedCapacity := make([]capacity.ConsumedCapacity, 0)
 	for _, this_cc := range this.ConsumedCapacity {
-		var cc capacity.ConsumedCapacity
-		cc.TableName = this_cc.TableName
-		cc.CapacityUnits = this_cc.CapacityUnits
-		for _, all_cc := range all.ConsumedCapacity {
-			if all_cc.TableName == this_cc.TableName {
-				cc.CapacityUnits += all_cc.CapacityUnits
+		merged := false
+		for i := range all.ConsumedCapacity {
+			if all.ConsumedCapacity[i].TableName == this_cc.TableName {
+				all.ConsumedCapacity[i].CapacityUnits += this_cc.CapacityUnits
+				merged = true
 			}
 		}
-		combinedConsumedCapacity = append(combinedConsumedCapacity, cc)
+		if !merged {
+			var cc capacity.ConsumedCapacity
+			cc.TableName = this_cc.TableName
+			cc.CapacityUnits = this_cc.CapacityUnits
+			all.ConsumedCapacity = append(all.ConsumedCapacity, cc)
+		}
 	}
-	all.ConsumedCapacity = combinedConsumedCapacity
 	for tn := range this.ItemCollectionMetrics {
 		for _, icm := range this.ItemCollectionMetrics[tn] {
 			if _, tn_is_all := all.ItemCollectionMetrics[tn]; !tn_is_all {
@@ -303,60 +512,126 @@ func combineResponseMetadata(all, this *Response) error {
 	return nil
 }
 
-// RetryBatchWriteWithConf will attempt to fully complete a conforming BatchWriteItem request.
-// Callers for this method should be of len QUERY_LIM or less (see DoBatchWrites()).
-// This is different than EndpointReq in that it will extract UnprocessedKeys and
-// form new BatchWriteItem's based on those, and combine any results.
-func (b *BatchWriteItem) RetryBatchWriteWithConf(depth int, c *conf.AWS_Conf) ([]byte, int, error) {
-	if b == nil {
-		return nil, 0, errors.New("batch_write_item.RetryBatchWriteWithConf: receiver is nil")
+// writeUnits estimates the write capacity units of b: a unit per KB of each put, and a
+// unit per delete.
+func (b *BatchWriteItem) writeUnits() float64 {
+	units := 0.0
+	for _, ris := range b.RequestItems {
+		for _, ri := range ris {
+			if ri.PutRequest != nil {
+				units += math.Max(1, math.Ceil(float64(ri.PutRequest.Item.Size())/1024))
+			} else {
+				units++
+			}
+		}
 	}
-	if depth > RECURSE_LIM {
-		e := fmt.Sprintf("batch_write_item.RetryBatchWriteWithConf: recursion depth exceeded")
-		return nil, 0, errors.New(e)
+	return units
+}
+
+// consumedUnits returns the capacity units of all tables in resp.
+func consumedUnits(resp *Response) float64 {
+	units := 0.0
+	for _, cc := range resp.ConsumedCapacity {
+		units += float64(cc.CapacityUnits)
 	}
-	body, code, err := b.EndpointReqWithConf(c)
-	if err != nil || code != http.StatusOK {
-		return body, code, err
+	return units
+}
+
+// tableNames returns the table names of t in order, so failures are reported predictably.
+func tableNames(t Table2Requests) []string {
+	tns := make([]string, 0, len(t))
+	for tn := range t {
+		tns = append(tns, tn)
 	}
-	// we'll need an actual Response object
-	var resp Response
-	um_err := json.Unmarshal([]byte(body), &resp)
-	if um_err != nil {
-		e := fmt.Sprintf("batch_write_item.RetryBatchWriteWithConf: %s", um_err.Error())
-		return nil, 0, errors.New(e)
+	sort.Strings(tns)
+	return tns
+}
+
+// retryUnprocessed sends b, then resubmits its UnprocessedItems after a retry.Unprocessed
+// backoff until none remain or attempt RECURSE_LIM has been made, taking the capacity of
+// each request from l if it is not nil. It returns the merged metadata of the responses and, if it did not finish, the requests that were not written
+// along with the error that stopped it.
+func (b *BatchWriteItem) retryUnprocessed(ctx context.Context, attempt int, l *ratelimit.Limiter, c *conf.AWS_Conf) (*Response, *BatchWriteItem, error) {
+	all := NewResponse()
+	if attempt > RECURSE_LIM {
+		return all, b, ErrUnprocessed
 	}
-	// if there are unprocessed items remaining from this call...
-	if len(resp.UnprocessedItems) > 0 {
-		// make a new BatchWriteItem object based on the unprocessed items
-		n_req, n_req_err := unprocessedItems2BatchWriteItems(b, &resp)
-		if n_req_err != nil {
-			e := fmt.Sprintf("batch_write_item.RetryBatchWriteWithConf: %s", n_req_err.Error())
-			return nil, 0, errors.New(e)
+	req := b
+	for n := attempt; ; n++ {
+		if n > attempt {
+			d := retry.Unprocessed.NextDelay(&retry.Attempt{Number: n - attempt})
+			if sleep_err := retry.Sleep(ctx, d); sleep_err != nil {
+				return all, req, sleep_err
+			}
 		}
-		// call this function on the new object
-		n_body, n_code, n_err := n_req.RetryBatchWriteWithConf(depth+1, c)
-		if n_err != nil || n_code != http.StatusOK {
-			return nil, n_code, n_err
+		estimate := req.writeUnits()
+		if take_err := l.Take(ctx, estimate); take_err != nil {
+			return all, req, take_err
 		}
-		// get the response as an object
-		var n_resp Response
-		um_err := json.Unmarshal([]byte(n_body), &n_resp)
-		if um_err != nil {
-			e := fmt.Sprintf("batch_write_item.RetryBatchWriteWithConf: %s", um_err.Error())
-			return nil, 0, errors.New(e)
+		resp, err := req.DoWithContext(ctx, c)
+		if err != nil {
+			return all, req, err
 		}
-		// merge the responses from this call and the recursive one
-		_ = combineResponseMetadata(&resp, &n_resp)
-		// make a response string again out of the merged responses
-		resp_json, resp_json_err := json.Marshal(resp)
-		if resp_json_err != nil {
-			e := fmt.Sprintf("batch_write_item.RetryBatchWriteWithConf: %s", resp_json_err.Error())
-			return nil, 0, errors.New(e)
+		if len(resp.ConsumedCapacity) != 0 {
+			l.Charge(consumedUnits(resp) - estimate)
+		}
+		_ = combineResponseMetadata(all, resp)
+		if len(resp.UnprocessedItems) == 0 {
+			return all, nil, nil
+		}
+		n_req, n_req_err := unprocessedItems2BatchWriteItems(req, resp)
+		if n_req_err != nil {
+			return all, req, n_req_err
+		}
+		req = n_req
+		if n >= RECURSE_LIM {
+			return all, req, ErrUnprocessed
 		}
-		body = resp_json
 	}
-	return body, code, err
+}
+
+// RetryBatchWriteWithContext will attempt to fully complete a conforming BatchWriteItem request.
+// Callers for this method should be of len QUERY_LIM or less (see DoBatchWrites()).
+// This is different than EndpointReq in that it will extract UnprocessedItems and
+// resubmit them after an exponential backoff, and combine any results. depth is the
+// number of attempts already made; at most RECURSE_LIM+1 are made in all. If requests
+// remain unprocessed, the combined response is returned with them as its UnprocessedItems
+// and an error wrapping ErrUnprocessed.
+func (b *BatchWriteItem) RetryBatchWriteWithContext(ctx context.Context, depth int, c *conf.AWS_Conf) ([]byte, int, error) {
+	if b == nil {
+		return nil, 0, errors.New("batch_write_item.RetryBatchWriteWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, 0, errors.New("batch_write_item.RetryBatchWriteWithContext: ctx is nil")
+	}
+	resp, remaining, err := b.retryUnprocessed(ctx, depth, nil, c)
+	if err != nil && !errors.Is(err, ErrUnprocessed) {
+		var api_err *dynamoerr.APIError
+		if errors.As(err, &api_err) {
+			return nil, api_err.StatusCode, err
+		}
+		return nil, 0, err
+	}
+	if remaining != nil {
+		resp.UnprocessedItems = remaining.RequestItems
+	}
+	body, marshal_err := json.Marshal(*resp)
+	if marshal_err != nil {
+		e := fmt.Sprintf("batch_write_item.RetryBatchWriteWithContext: %s", marshal_err.Error())
+		return nil, 0, errors.New(e)
+	}
+	if err != nil {
+		return body, http.StatusOK, fmt.Errorf("batch_write_item.RetryBatchWriteWithContext: %w", err)
+	}
+	return body, http.StatusOK, nil
+}
+
+// RetryBatchWriteWithConf calls RetryBatchWriteWithContext using a background context.
+func (b *BatchWriteItem) RetryBatchWriteWithConf(depth int, c *conf.AWS_Conf) ([]byte, int, error) {
+	if b == nil {
+		return nil, 0, errors.New("batch_write_item.RetryBatchWriteWithConf: receiver is nil")
+	}
+	return b.RetryBatchWriteWithContext(context.Background(), depth, c)
 }
 
 // RetryBatchWrite is just a wrapper for RetryBatchWriteWithConf using the global conf.
@@ -367,29 +642,45 @@ func (b *BatchWriteItem) RetryBatchWrite(depth int) ([]byte, int, error) {
 	return b.RetryBatchWriteWithConf(depth, &conf.Vals)
 }
 
-// These implementations of EndpointReq use a parameterized conf.
+// These implementations of EndpointReq use a parameterized conf and context.
 
-func (batch_write_item *BatchWriteItem) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+func (batch_write_item *BatchWriteItem) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
 	if batch_write_item == nil {
-		return nil, 0, errors.New("batch_write_item.(BatchWriteItem)EndpointReqWithConf: receiver is nil")
+		return nil, 0, errors.New("batch_write_item.(BatchWriteItem)EndpointReqWithContext: receiver is nil")
 	}
 	if !conf.IsValid(c) {
-		return nil, 0, errors.New("batch_write_item.EndpointReqWithConf: c is not valid")
+		return nil, 0, errors.New("batch_write_item.EndpointReqWithContext: c is not valid")
 	}
 	// returns resp_body,code,err
 	reqJSON, json_err := json.Marshal(batch_write_item)
 	if json_err != nil {
 		return nil, 0, json_err
 	}
-	return authreq.RetryReqJSON_V4WithConf(reqJSON, BATCHWRITE_ENDPOINT, c)
+	return authreq.RetryReqJSON_V4WithContext(ctx, reqJSON, BATCHWRITE_ENDPOINT, c)
+}
+
+func (req *Request) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("batch_write_item.(Request)EndpointReqWithContext: receiver is nil")
+	}
+	batch_write_item := BatchWriteItem(*req)
+	return batch_write_item.EndpointReqWithContext(ctx, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (batch_write_item *BatchWriteItem) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if batch_write_item == nil {
+		return nil, 0, errors.New("batch_write_item.(BatchWriteItem)EndpointReqWithConf: receiver is nil")
+	}
+	return batch_write_item.EndpointReqWithContext(context.Background(), c)
 }
 
 func (req *Request) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if req == nil {
 		return nil, 0, errors.New("batch_write_item.(Request)EndpointReqWithConf: receiver is nil")
 	}
-	batch_write_item := BatchWriteItem(*req)
-	return batch_write_item.EndpointReqWithConf(c)
+	return req.EndpointReqWithContext(context.Background(), c)
 }
 
 // These implementations of EndpointReq use the global conf.
@@ -408,3 +699,59 @@ func (req *Request) EndpointReq() ([]byte, int, error) {
 	batch_write_item := BatchWriteItem(*req)
 	return batch_write_item.EndpointReqWithConf(&conf.Vals)
 }
+
+// These implementations of Do perform the request and decode the result into a Response.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded Response. Error responses are returned as a *dynamoerr.APIError.
+func (batch_write_item *BatchWriteItem) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if batch_write_item == nil {
+		return nil, errors.New("batch_write_item.(BatchWriteItem)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("batch_write_item.(BatchWriteItem)DoWithContext: ctx is nil")
+	}
+	resp := NewResponse()
+	if err := authreq.DoWithContext(ctx, batch_write_item, BATCHWRITE_ENDPOINT, c, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (req *Request) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("batch_write_item.(Request)DoWithContext: receiver is nil")
+	}
+	batch_write_item := BatchWriteItem(*req)
+	return batch_write_item.DoWithContext(ctx, c)
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (batch_write_item *BatchWriteItem) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if batch_write_item == nil {
+		return nil, errors.New("batch_write_item.(BatchWriteItem)DoWithConf: receiver is nil")
+	}
+	return batch_write_item.DoWithContext(context.Background(), c)
+}
+
+func (req *Request) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("batch_write_item.(Request)DoWithConf: receiver is nil")
+	}
+	return req.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (batch_write_item *BatchWriteItem) Do() (*Response, error) {
+	if batch_write_item == nil {
+		return nil, errors.New("batch_write_item.(BatchWriteItem)Do: receiver is nil")
+	}
+	return batch_write_item.DoWithConf(&conf.Vals)
+}
+
+func (req *Request) Do() (*Response, error) {
+	if req == nil {
+		return nil, errors.New("batch_write_item.(Request)Do: receiver is nil")
+	}
+	return req.DoWithConf(&conf.Vals)
+}
@@ -1,11 +1,24 @@
 package batch_write_item
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/dynamoerr"
+	"github.com/smugmug/godynamo/retry"
 	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/capacity"
 	"github.com/smugmug/godynamo/types/item"
+	"github.com/smugmug/godynamo/types/keydefinition"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestNil(t *testing.T) {
@@ -219,3 +232,241 @@ func TestSplit2(t *testing.T) {
 		i++
 	}
 }
+
+func TestSplitBytes(t *testing.T) {
+	b := NewBatchWriteItem()
+	big := strings.Repeat("x", 300000)
+	for i := 0; i < 100; i++ {
+		var p PutRequest
+		p.Item = make(item.Item)
+		p.Item["Key"] = &attributevalue.AttributeValue{S: fmt.Sprintf("TheKey%d", i)}
+		p.Item["Val"] = &attributevalue.AttributeValue{S: big}
+		b.RequestItems["foo"] = append(b.RequestItems["foo"], RequestInstance{PutRequest: &p})
+	}
+	bs, err := Split(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// items below the item limit always fit 25 to a request
+	if len(bs) != 4 {
+		t.Errorf("expected 4 requests, got %d", len(bs))
+	}
+
+	var p PutRequest
+	p.Item = make(item.Item)
+	p.Item["Key"] = &attributevalue.AttributeValue{S: "huge"}
+	p.Item["Val"] = &attributevalue.AttributeValue{S: strings.Repeat("x", ITEM_LIM_BYTES)}
+	b.RequestItems["foo"] = append(b.RequestItems["foo"], RequestInstance{PutRequest: &p})
+	ks := keydefinition.KeySchema{keydefinition.KeyDefinition{AttributeName: "Key", KeyType: "HASH"}}
+	_, err = SplitWithKeySchema(b, map[string]keydefinition.KeySchema{"foo": ks})
+	var size_err *item.SizeError
+	if !errors.As(err, &size_err) || size_err.Index != 100 || size_err.Size != ITEM_LIM_BYTES+10 {
+		t.Fatalf("expected a SizeError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `key {"Key":{"S":"huge"}}`) {
+		t.Errorf("the error should name the key: %s", err.Error())
+	}
+}
+
+func TestSplitErrorKey(t *testing.T) {
+	b := NewBatchWriteItem()
+	p := &PutRequest{Item: item.Item{"Key": &attributevalue.AttributeValue{S: "huge"},
+		"Val": &attributevalue.AttributeValue{S: strings.Repeat("x", ITEM_LIM_BYTES)}}}
+	b.RequestItems["foo"] = []RequestInstance{{PutRequest: p}}
+	_, err := Split(b)
+	var size_err *item.SizeError
+	if !errors.As(err, &size_err) || len(size_err.Digest) != 64 || !strings.Contains(err.Error(), size_err.Digest) {
+		t.Fatalf("without a key schema the error should name the digest of the item, got %v", err)
+	}
+
+	// DoBatchWrite* read the key schema to name the key
+	var describes int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amzn-Requestid", "TESTREQID")
+		if r.Header.Get("X-Amz-Target") != "DynamoDB_20120810.DescribeTable" {
+			t.Errorf("unexpected request %s", r.Header.Get("X-Amz-Target"))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		describes++
+		w.Write([]byte(`{"Table":{"TableName":"foo","KeySchema":[{"AttributeName":"Key","KeyType":"HASH"}]}}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	c := new(conf.AWS_Conf)
+	c.Auth.AccessKey = "myAccessKey"
+	c.Auth.Secret = "mySecret"
+	c.Network.DynamoDB.URL = srv.URL
+	c.Network.DynamoDB.Host = u.Hostname()
+	c.Network.DynamoDB.Port = u.Port()
+	c.Network.DynamoDB.Zone = "us-east-1"
+	c.RetryPolicy = retry.NoRetry{}
+	c.Initialized = true
+	_, _, err = b.DoBatchWriteWithConf(c)
+	if !errors.As(err, &size_err) || size_err.TableName != "foo" || size_err.Size != ITEM_LIM_BYTES+10 ||
+		!strings.Contains(err.Error(), `key {"Key":{"S":"huge"}}`) || describes != 1 {
+		t.Errorf("the error should be a SizeError naming the key, got %v", err)
+	}
+	ks := keydefinition.KeySchema{keydefinition.KeyDefinition{AttributeName: "Key", KeyType: "HASH"}}
+	o := &Options{KeySchemas: map[string]keydefinition.KeySchema{"foo": ks}}
+	_, err = b.DoBatchWriteResultWithOptions(context.Background(), o, c)
+	if !errors.As(err, &size_err) || size_err.Key == nil || describes != 1 {
+		t.Errorf("the key schema of Options should be used, got %v", err)
+	}
+}
+
+// unprocessedServer answers BatchWriteItem requests: a request with a put of key "bad" fails
+// with ResourceNotFound, puts of "stuck" are never processed and the other puts are
+// processed on their second attempt.
+func unprocessedServer(t *testing.T) (*conf.AWS_Conf, func()) {
+	var lock sync.Mutex
+	seen := make(map[string]bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b BatchWriteItem
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			t.Error(err)
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		w.Header().Set("X-Amzn-Requestid", "TESTREQID")
+		resp := NewResponse()
+		for tn, ris := range b.RequestItems {
+			for _, ri := range ris {
+				k := ri.PutRequest.Item["Key"].S
+				if k == "bad" {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ResourceNotFoundException","message":"gone"}`))
+					return
+				}
+				if k == "stuck" || !seen[k] {
+					resp.UnprocessedItems[tn] = append(resp.UnprocessedItems[tn], ri)
+				}
+				seen[k] = true
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	u, _ := url.Parse(srv.URL)
+	c := new(conf.AWS_Conf)
+	c.Auth.AccessKey = "myAccessKey"
+	c.Auth.Secret = "mySecret"
+	c.Network.DynamoDB.URL = srv.URL
+	c.Network.DynamoDB.Host = u.Hostname()
+	c.Network.DynamoDB.Port = u.Port()
+	c.Network.DynamoDB.Zone = "us-east-1"
+	c.RetryPolicy = retry.NoRetry{}
+	c.Initialized = true
+	return c, srv.Close
+}
+
+func TestUnprocessedFailures(t *testing.T) {
+	defer func(u *retry.FullJitter) { retry.Unprocessed = u }(retry.Unprocessed)
+	retry.Unprocessed = &retry.FullJitter{Base: time.Microsecond, Multiplier: 2, Cap: time.Millisecond}
+	c, done := unprocessedServer(t)
+	defer done()
+
+	b := NewBatchWriteItem()
+	for i := 0; i < 30; i++ {
+		k := fmt.Sprintf("k%d", i)
+		switch i {
+		case 3:
+			k = "stuck"
+		case 27:
+			k = "bad"
+		}
+		p := &PutRequest{Item: item.Item{"Key": &attributevalue.AttributeValue{S: k}}}
+		b.RequestItems["foo"] = append(b.RequestItems["foo"], RequestInstance{PutRequest: p})
+	}
+	result, err := b.DoBatchWriteResultWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the stuck put and the five puts of the failed second request
+	if len(result.Failed) != 6 || len(result.Response.UnprocessedItems["foo"]) != 6 {
+		t.Fatalf("expected 6 failures, got %d", len(result.Failed))
+	}
+	for _, f := range result.Failed {
+		k := f.Request.PutRequest.Item["Key"].S
+		if k == "stuck" && !errors.Is(f.Err, ErrUnprocessed) {
+			t.Errorf("stuck should be unprocessed, got %v", f.Err)
+		} else if k != "stuck" && !errors.Is(f.Err, dynamoerr.ErrResourceNotFound) {
+			t.Errorf("%s should have failed with its request, got %v", k, f.Err)
+		}
+	}
+
+	// a request retried from depth RECURSE_LIM-2 gives up after three attempts
+	stuck := NewBatchWriteItem()
+	stuck.RequestItems["foo"] = b.RequestItems["foo"][3:4]
+	body, code, retry_err := stuck.RetryBatchWriteWithConf(RECURSE_LIM-2, c)
+	if !errors.Is(retry_err, ErrUnprocessed) || code != http.StatusOK {
+		t.Fatalf("expected ErrUnprocessed, got %d %v", code, retry_err)
+	}
+	var resp Response
+	if um_err := json.Unmarshal(body, &resp); um_err != nil || len(resp.UnprocessedItems["foo"]) != 1 {
+		t.Errorf("the response should hold the unprocessed put: %s", string(body))
+	}
+}
+
+func TestOptions(t *testing.T) {
+	var lock sync.Mutex
+	var in_flight, most int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b BatchWriteItem
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			t.Error(err)
+		}
+		lock.Lock()
+		in_flight++
+		if in_flight > most {
+			most = in_flight
+		}
+		lock.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		lock.Lock()
+		in_flight--
+		lock.Unlock()
+		w.Header().Set("X-Amzn-Requestid", "TESTREQID")
+		resp := NewResponse()
+		for tn, ris := range b.RequestItems {
+			resp.ConsumedCapacity = append(resp.ConsumedCapacity,
+				capacity.ConsumedCapacity{TableName: tn, CapacityUnits: capacity.ConsumedCapacityUnit(len(ris))})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	c := new(conf.AWS_Conf)
+	c.Auth.AccessKey = "myAccessKey"
+	c.Auth.Secret = "mySecret"
+	c.Network.DynamoDB.URL = srv.URL
+	c.Network.DynamoDB.Host = u.Hostname()
+	c.Network.DynamoDB.Port = u.Port()
+	c.Network.DynamoDB.Zone = "us-east-1"
+	c.Initialized = true
+
+	b := NewBatchWriteItem()
+	for i := 0; i < 100; i++ {
+		p := &PutRequest{Item: item.Item{"Key": &attributevalue.AttributeValue{S: fmt.Sprintf("k%d", i)}}}
+		b.RequestItems["foo"] = append(b.RequestItems["foo"], RequestInstance{PutRequest: p})
+	}
+	result, err := b.DoBatchWriteResultWithOptions(context.Background(), &Options{MaxInFlight: 2}, c)
+	if err != nil || result.Err() != nil {
+		t.Fatal(err, result.Err())
+	}
+	if most != 2 {
+		t.Errorf("expected at most 2 requests in flight, saw %d", most)
+	}
+
+	// four requests of 25 units at 100 units a second
+	start := time.Now()
+	result, err = b.DoBatchWriteResultWithOptions(context.Background(), &Options{WriteCapacity: 100}, c)
+	if err != nil || result.Err() != nil {
+		t.Fatal(err, result.Err())
+	}
+	if elapsed := time.Since(start); elapsed < 700*time.Millisecond {
+		t.Errorf("expected the requests to take about 750ms, took %v", elapsed)
+	}
+	if len(result.Response.ConsumedCapacity) != 1 || result.Response.ConsumedCapacity[0].CapacityUnits != 100 {
+		t.Errorf("unexpected consumed capacity %v", result.Response.ConsumedCapacity)
+	}
+}
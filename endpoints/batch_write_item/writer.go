@@ -0,0 +1,381 @@
+package batch_write_item
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/endpoints/describe_table"
+	"github.com/smugmug/godynamo/ratelimit"
+	"github.com/smugmug/godynamo/types/item"
+	"github.com/smugmug/godynamo/types/keydefinition"
+	"sync"
+	"time"
+)
+
+const (
+	// how long a request waits for others to share its batch unless set
+	WRITER_FLUSH_INTERVAL = 100 * time.Millisecond
+	// how many requests a Writer buffers unless set
+	WRITER_BUFFER = 1000
+)
+
+// ErrWriterClosed is returned by the methods of a Writer once it is closed.
+var ErrWriterClosed = errors.New("batch_write_item: writer is closed")
+
+// WriterConfig configures a Writer. The zero value is usable.
+type WriterConfig struct {
+	// How long a request may wait for others to fill its batch before the batch is sent;
+	// WRITER_FLUSH_INTERVAL if 0.
+	FlushInterval time.Duration
+	// How many requests may wait to be batched before Write blocks; WRITER_BUFFER if 0.
+	BufferSize int
+	// The most batches in flight at once; 1 if 0.
+	MaxInFlight int
+	// The write capacity units per second to consume, as in Options; 0 for no limit.
+	WriteCapacity float64
+	// The key schemas of the tables written. The key schema of any other table is read
+	// with DescribeTable the first time it is written.
+	KeySchemas map[string]keydefinition.KeySchema
+	// If set, OnFailure is called for every request that was not written. Calls are
+	// not concurrent.
+	OnFailure func(Failure)
+}
+
+// WriteRequest is a put or delete of a table.
+type WriteRequest struct {
+	TableName string
+	Request   RequestInstance
+}
+
+// Writer groups puts and deletes into batches of QUERY_LIM requests and up to
+// REQUEST_LIM_BYTES, sending a batch once it is full or FlushInterval after its first
+// request. A request on the same key as one already in the batch replaces it, as
+// DynamoDB rejects batches that touch a key twice. UnprocessedItems are resubmitted with
+// backoff while later batches are built, and the requests that still fail are passed to
+// OnFailure.
+//
+// example use:
+//
+//	w, _ := batch_write_item.NewWriter(cfg, c)
+//	for _, it := range items {
+//		if err := w.Put(ctx, "Thread", it); err != nil {
+//			...
+//		}
+//	}
+//	err := w.Close(ctx)
+type Writer struct {
+	cfg WriterConfig
+	c   *conf.AWS_Conf
+	l   *ratelimit.Limiter
+	// the context of the requests, cancelled if Close gives up
+	ctx    context.Context
+	cancel context.CancelFunc
+	in     chan WriteRequest
+	flush  chan chan struct{}
+	// closed once the batching goroutine has returned
+	stopped chan struct{}
+	// held to send on in, and to close it
+	lock   sync.RWMutex
+	closed bool
+	// held to call OnFailure
+	fail_lock sync.Mutex
+}
+
+// NewWriter returns a running Writer that sends its requests with c.
+func NewWriter(cfg WriterConfig, c *conf.AWS_Conf) (*Writer, error) {
+	if !conf.IsValid(c) {
+		return nil, errors.New("batch_write_item.NewWriter: c is not valid")
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = WRITER_FLUSH_INTERVAL
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = WRITER_BUFFER
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = 1
+	}
+	w := &Writer{cfg: cfg, c: c, in: make(chan WriteRequest, cfg.BufferSize),
+		flush: make(chan chan struct{}), stopped: make(chan struct{})}
+	if cfg.WriteCapacity > 0 {
+		w.l = ratelimit.New(cfg.WriteCapacity, 0)
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	go w.run()
+	return w, nil
+}
+
+// Put queues a put of it to table tn.
+func (w *Writer) Put(ctx context.Context, tn string, it item.Item) error {
+	return w.Write(ctx, WriteRequest{TableName: tn, Request: RequestInstance{PutRequest: &PutRequest{Item: it}}})
+}
+
+// Delete queues a delete of the item with key k from table tn.
+func (w *Writer) Delete(ctx context.Context, tn string, k item.Item) error {
+	return w.Write(ctx, WriteRequest{TableName: tn, Request: RequestInstance{DeleteRequest: &DeleteRequest{Key: k}}})
+}
+
+// Write queues r, blocking while the buffer is full. It returns an error if ctx is done
+// first or the Writer is closed. A nil error means r was accepted, not that it was written;
+// requests that fail are passed to OnFailure.
+func (w *Writer) Write(ctx context.Context, r WriteRequest) error {
+	if w == nil {
+		return errors.New("batch_write_item.(Writer)Write: receiver is nil")
+	}
+	if (r.Request.PutRequest == nil) == (r.Request.DeleteRequest == nil) {
+		return errors.New("batch_write_item.(Writer)Write: exactly one of PutRequest and DeleteRequest must be set")
+	}
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	if w.closed {
+		return ErrWriterClosed
+	}
+	select {
+	case w.in <- r:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WriteAll queues every request received from ch until ch is closed.
+func (w *Writer) WriteAll(ctx context.Context, ch <-chan WriteRequest) error {
+	if w == nil {
+		return errors.New("batch_write_item.(Writer)WriteAll: receiver is nil")
+	}
+	for {
+		select {
+		case r, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := w.Write(ctx, r); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Flush sends the requests queued so far and waits until they have been written or
+// passed to OnFailure.
+func (w *Writer) Flush(ctx context.Context) error {
+	if w == nil {
+		return errors.New("batch_write_item.(Writer)Flush: receiver is nil")
+	}
+	done := make(chan struct{})
+	select {
+	case w.flush <- done:
+	case <-w.stopped:
+		return ErrWriterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close flushes the Writer and stops it. If ctx is done first, the requests in flight are
+// cancelled and passed to OnFailure, and ctx.Err() is returned.
+func (w *Writer) Close(ctx context.Context) error {
+	if w == nil {
+		return errors.New("batch_write_item.(Writer)Close: receiver is nil")
+	}
+	w.lock.Lock()
+	if w.closed {
+		w.lock.Unlock()
+		return ErrWriterClosed
+	}
+	w.closed = true
+	close(w.in)
+	w.lock.Unlock()
+	select {
+	case <-w.stopped:
+		w.cancel()
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		<-w.stopped
+		return ctx.Err()
+	}
+}
+
+// failed passes the requests of b to OnFailure.
+func (w *Writer) failed(b *BatchWriteItem, err error) {
+	if w.cfg.OnFailure == nil {
+		return
+	}
+	w.fail_lock.Lock()
+	defer w.fail_lock.Unlock()
+	for _, tn := range tableNames(b.RequestItems) {
+		for _, ri := range b.RequestItems[tn] {
+			w.cfg.OnFailure(Failure{TableName: tn, Request: ri, Err: err})
+		}
+	}
+}
+
+// batch is the batch being built by run.
+type batch struct {
+	b     *BatchWriteItem
+	count int
+	bytes int
+	// the index in b.RequestItems of the request on each key
+	keys map[string]int
+}
+
+// reject passes r to OnFailure.
+func (w *Writer) reject(r WriteRequest, err error) {
+	b := NewBatchWriteItem()
+	b.RequestItems[r.TableName] = []RequestInstance{r.Request}
+	w.failed(b, err)
+}
+
+// run builds batches from w.in and hands them to the senders until w.in is closed.
+func (w *Writer) run() {
+	defer close(w.stopped)
+	batches := make(chan *BatchWriteItem)
+	// in_flight counts the batches handed to the senders that are not done, so that a
+	// flush can wait for them
+	var in_flight sync.WaitGroup
+	for i := 0; i < w.cfg.MaxInFlight; i++ {
+		go func() {
+			for b := range batches {
+				_, remaining, err := b.retryUnprocessed(w.ctx, 0, w.l, w.c)
+				if remaining != nil {
+					w.failed(remaining, err)
+				}
+				in_flight.Done()
+			}
+		}()
+	}
+	schemas := make(map[string]keydefinition.KeySchema)
+	for tn, ks := range w.cfg.KeySchemas {
+		schemas[tn] = ks
+	}
+	var cur *batch
+	timer := time.NewTimer(w.cfg.FlushInterval)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	send := func() {
+		if cur == nil {
+			return
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		in_flight.Add(1)
+		batches <- cur.b
+		cur = nil
+	}
+	seq := 0
+	add := func(r WriteRequest) {
+		seq++
+		ks, have := schemas[r.TableName]
+		if !have {
+			d := describe_table.NewDescribeTable()
+			d.TableName = r.TableName
+			resp, d_err := d.DoWithContext(w.ctx, w.c)
+			if d_err != nil {
+				w.reject(r, fmt.Errorf("batch_write_item: cannot describe %s: %w", r.TableName, d_err))
+				return
+			}
+			ks = resp.Table.KeySchema
+			schemas[r.TableName] = ks
+		}
+		size, size_err := requestSize(r.TableName, seq-1, r.Request, ks)
+		if size_err != nil {
+			w.reject(r, size_err)
+			return
+		}
+		k, k_err := requestKey(r, ks)
+		if k_err != nil {
+			w.reject(r, k_err)
+			return
+		}
+		if cur != nil {
+			if j, dup := cur.keys[k]; dup {
+				// the later request on a key replaces the earlier one
+				prev, _ := requestSize(r.TableName, j, cur.b.RequestItems[r.TableName][j], ks)
+				cur.b.RequestItems[r.TableName][j] = r.Request
+				cur.bytes += size - prev
+				return
+			}
+			if cur.bytes+size > REQUEST_LIM_BYTES {
+				send()
+			}
+		}
+		if cur == nil {
+			cur = &batch{b: NewBatchWriteItem(), keys: make(map[string]int)}
+			timer.Reset(w.cfg.FlushInterval)
+		}
+		cur.keys[k] = len(cur.b.RequestItems[r.TableName])
+		cur.b.RequestItems[r.TableName] = append(cur.b.RequestItems[r.TableName], r.Request)
+		cur.count++
+		cur.bytes += size
+		if cur.count == QUERY_LIM {
+			send()
+		}
+	}
+	for {
+		select {
+		case r, ok := <-w.in:
+			if !ok {
+				send()
+				close(batches)
+				in_flight.Wait()
+				return
+			}
+			add(r)
+		case <-timer.C:
+			if cur != nil {
+				in_flight.Add(1)
+				batches <- cur.b
+				cur = nil
+			}
+		case done := <-w.flush:
+			// the requests queued before the flush are buffered in w.in
+			for queued := len(w.in); queued > 0; queued-- {
+				if r, ok := <-w.in; ok {
+					add(r)
+				}
+			}
+			send()
+			in_flight.Wait()
+			close(done)
+		}
+	}
+}
+
+// requestKey returns a string identifying the table and key r writes.
+func requestKey(r WriteRequest, ks keydefinition.KeySchema) (string, error) {
+	k := item.NewItem()
+	if r.Request.DeleteRequest != nil {
+		k = r.Request.DeleteRequest.Key
+	} else {
+		for _, kd := range ks {
+			v, have := r.Request.PutRequest.Item[kd.AttributeName]
+			if !have {
+				e := fmt.Sprintf("batch_write_item.requestKey: item of %s has no key attribute %s",
+					r.TableName, kd.AttributeName)
+				return "", errors.New(e)
+			}
+			k[kd.AttributeName] = v
+		}
+	}
+	b, json_err := json.Marshal(k)
+	if json_err != nil {
+		return "", json_err
+	}
+	return r.TableName + "\x00" + string(b), nil
+}
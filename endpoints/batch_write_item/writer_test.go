@@ -0,0 +1,161 @@
+package batch_write_item_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/dynamoerr"
+	"github.com/smugmug/godynamo/emulator"
+	batch_write_item "github.com/smugmug/godynamo/endpoints/batch_write_item"
+	create_table "github.com/smugmug/godynamo/endpoints/create_table"
+	scan "github.com/smugmug/godynamo/endpoints/scan"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/item"
+	"sync"
+	"testing"
+	"time"
+)
+
+// setup starts an emulator with an empty Thread table.
+func setup(t *testing.T) (*emulator.Emulator, *conf.AWS_Conf) {
+	e := emulator.New()
+	c := e.Conf()
+	var ct create_table.CreateTable
+	s := `{"TableName":"Thread",
+	 "AttributeDefinitions":[{"AttributeName":"ForumName","AttributeType":"S"},{"AttributeName":"Subject","AttributeType":"S"}],
+	 "KeySchema":[{"AttributeName":"ForumName","KeyType":"HASH"},{"AttributeName":"Subject","KeyType":"RANGE"}],
+	 "ProvisionedThroughput":{"ReadCapacityUnits":5,"WriteCapacityUnits":5}}`
+	if um_err := json.Unmarshal([]byte(s), &ct); um_err != nil {
+		t.Fatal(um_err)
+	}
+	if _, err := ct.DoWithConf(c); err != nil {
+		t.Fatal(err)
+	}
+	return e, c
+}
+
+func thread(subject string, views int) item.Item {
+	return item.Item{
+		"ForumName": &attributevalue.AttributeValue{S: "F"},
+		"Subject":   &attributevalue.AttributeValue{S: subject},
+		"Views":     &attributevalue.AttributeValue{N: fmt.Sprint(views)},
+	}
+}
+
+// count scans the Thread table.
+func count(t *testing.T, c *conf.AWS_Conf) *scan.Response {
+	var s scan.Scan
+	s.TableName = "Thread"
+	resp, s_err := s.DoWithConf(c)
+	if s_err != nil {
+		t.Fatal(s_err)
+	}
+	return resp
+}
+
+func TestWriter(t *testing.T) {
+	e, c := setup(t)
+	defer e.Close()
+	var lock sync.Mutex
+	var failed []batch_write_item.Failure
+	w, err := batch_write_item.NewWriter(batch_write_item.WriterConfig{
+		FlushInterval: 10 * time.Millisecond,
+		MaxInFlight:   2,
+		OnFailure: func(f batch_write_item.Failure) {
+			lock.Lock()
+			failed = append(failed, f)
+			lock.Unlock()
+		},
+	}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	// the same keys are written twice in a batch, and the last write wins
+	for i := 0; i < 60; i++ {
+		if err := w.Put(ctx, "Thread", thread(fmt.Sprintf("s%02d", i%20), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ch := make(chan batch_write_item.WriteRequest)
+	go func() {
+		k := thread("s00", 0)
+		delete(k, "Views")
+		ch <- batch_write_item.WriteRequest{TableName: "Thread",
+			Request: batch_write_item.RequestInstance{DeleteRequest: &batch_write_item.DeleteRequest{Key: k}}}
+		ch <- batch_write_item.WriteRequest{TableName: "Missing",
+			Request: batch_write_item.RequestInstance{PutRequest: &batch_write_item.PutRequest{Item: thread("x", 0)}}}
+		close(ch)
+	}()
+	if err := w.WriteAll(ctx, ch); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	lock.Lock()
+	if len(failed) != 1 || failed[0].TableName != "Missing" || !errors.Is(failed[0].Err, dynamoerr.ErrResourceNotFound) {
+		t.Errorf("expected the put to Missing to fail, got %v", failed)
+	}
+	lock.Unlock()
+
+	// timed flushes write without Flush
+	if err := w.Put(ctx, "Thread", thread("late", 1)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := w.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Put(ctx, "Thread", thread("closed", 1)); err != batch_write_item.ErrWriterClosed {
+		t.Errorf("expected ErrWriterClosed, got %v", err)
+	}
+	resp := count(t, c)
+	if resp.Count != 20 {
+		t.Errorf("expected 20 items, got %d", resp.Count)
+	}
+	for _, it := range resp.Items {
+		if it["Subject"].S == "s05" && it["Views"].N != "45" {
+			t.Errorf("the last put of s05 should win, got %s", it["Views"].N)
+		}
+	}
+}
+
+func TestWriterCanceled(t *testing.T) {
+	e, c := setup(t)
+	defer e.Close()
+	failed := 0
+	// the first batch uses the capacity of the next 25 seconds, so later batches wait
+	w, err := batch_write_item.NewWriter(batch_write_item.WriterConfig{
+		WriteCapacity: 1,
+		OnFailure: func(f batch_write_item.Failure) {
+			if !errors.Is(f.Err, context.Canceled) {
+				t.Errorf("expected the request to be canceled, got %v", f.Err)
+			}
+			failed++
+		},
+	}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := w.Put(context.Background(), "Thread", thread(fmt.Sprintf("s%02d", i), i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := w.Put(ctx, "Thread", thread("canceled", 0)); err != nil && err != context.Canceled {
+		t.Errorf("a put with a canceled ctx should be queued or canceled, got %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := w.Close(ctx); err != context.Canceled {
+		t.Errorf("Close with a canceled ctx should give up, got %v", err)
+	}
+	written := count(t, c).Count
+	if written == 0 || failed == 0 || written+uint64(failed) < 100 {
+		t.Errorf("every request should be written or failed, got %d written and %d failed", written, failed)
+	}
+}
@@ -7,6 +7,7 @@
 package create_table
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/smugmug/godynamo/authreq"
@@ -17,6 +18,7 @@ import (
 	"github.com/smugmug/godynamo/types/keydefinition"
 	"github.com/smugmug/godynamo/types/localsecondaryindex"
 	"github.com/smugmug/godynamo/types/provisionedthroughput"
+	"github.com/smugmug/godynamo/types/streamspecification"
 )
 
 const (
@@ -30,6 +32,7 @@ type CreateTable struct {
 	KeySchema              keydefinition.KeySchema
 	LocalSecondaryIndexes  []localsecondaryindex.LocalSecondaryIndex `json:",omitempty"`
 	ProvisionedThroughput  provisionedthroughput.ProvisionedThroughput
+	StreamSpecification    *streamspecification.StreamSpecification `json:",omitempty"`
 	TableName              string
 }
 
@@ -60,8 +63,11 @@ type Response struct {
 		GlobalSecondaryIndexes []globalsecondaryindex.GlobalSecondaryIndexDesc `json:",omitempty"`
 		ItemCount              uint64                                          `json:",omitempty"`
 		KeySchema              keydefinition.KeySchema                         `json:",omitempty"`
+		LatestStreamArn        string                                          `json:",omitempty"`
+		LatestStreamLabel      string                                          `json:",omitempty"`
 		LocalSecondaryIndexes  []localsecondaryindex.LocalSecondaryIndexDesc   `json:",omitempty"`
 		ProvisionedThroughput  provisionedthroughput.ProvisionedThroughputDesc `json:",omitempty"`
+		StreamSpecification    *streamspecification.StreamSpecification        `json:",omitempty"`
 		TableName              string
 		TableSizeBytes         uint64 `json:",omitempty"`
 		TableStatus            string
@@ -78,37 +84,60 @@ func NewResponse() *Response {
 	return r
 }
 
-// These implementations of EndpointReq use a parameterized conf.
+// These implementations of EndpointReq use a parameterized conf and context.
 
-func (create_table *CreateTable) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+func (create_table *CreateTable) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
 	if create_table == nil {
-		return nil, 0, errors.New("create_table.(CreateTable)EndpointReqWithConf: receiver is nil")
+		return nil, 0, errors.New("create_table.(CreateTable)EndpointReqWithContext: receiver is nil")
 	}
 	if !conf.IsValid(c) {
-		return nil, 0, errors.New("create_table.EndpointReqWithConf: c is not valid")
+		return nil, 0, errors.New("create_table.EndpointReqWithContext: c is not valid")
 	}
 	// returns resp_body,code,err
 	reqJSON, json_err := json.Marshal(create_table)
 	if json_err != nil {
 		return nil, 0, json_err
 	}
-	return authreq.RetryReqJSON_V4WithConf(reqJSON, CREATETABLE_ENDPOINT, c)
+	return authreq.RetryReqJSON_V4WithContext(ctx, reqJSON, CREATETABLE_ENDPOINT, c)
+}
+
+func (create *Create) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if create == nil {
+		return nil, 0, errors.New("create_table.(Create)EndpointReqWithContext: receiver is nil")
+	}
+	create_table := CreateTable(*create)
+	return create_table.EndpointReqWithContext(ctx, c)
+}
+
+func (req *Request) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("create_table.(Request)EndpointReqWithContext: receiver is nil")
+	}
+	create_table := CreateTable(*req)
+	return create_table.EndpointReqWithContext(ctx, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (create_table *CreateTable) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if create_table == nil {
+		return nil, 0, errors.New("create_table.(CreateTable)EndpointReqWithConf: receiver is nil")
+	}
+	return create_table.EndpointReqWithContext(context.Background(), c)
 }
 
 func (create *Create) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if create == nil {
 		return nil, 0, errors.New("create_table.(Create)EndpointReqWithConf: receiver is nil")
 	}
-	create_table := CreateTable(*create)
-	return create_table.EndpointReqWithConf(c)
+	return create.EndpointReqWithContext(context.Background(), c)
 }
 
 func (req *Request) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if req == nil {
 		return nil, 0, errors.New("create_table.(Request)EndpointReqWithConf: receiver is nil")
 	}
-	create_table := CreateTable(*req)
-	return create_table.EndpointReqWithConf(c)
+	return req.EndpointReqWithContext(context.Background(), c)
 }
 
 // These implementations of EndpointReq use the global conf.
@@ -136,6 +165,84 @@ func (req *Request) EndpointReq() ([]byte, int, error) {
 	return create_table.EndpointReqWithConf(&conf.Vals)
 }
 
+// These implementations of Do perform the request and decode the result into a Response.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded Response. Error responses are returned as a *dynamoerr.APIError.
+func (create_table *CreateTable) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if create_table == nil {
+		return nil, errors.New("create_table.(CreateTable)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("create_table.(CreateTable)DoWithContext: ctx is nil")
+	}
+	resp := NewResponse()
+	if err := authreq.DoWithContext(ctx, create_table, CREATETABLE_ENDPOINT, c, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (create *Create) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if create == nil {
+		return nil, errors.New("create_table.(Create)DoWithContext: receiver is nil")
+	}
+	create_table := CreateTable(*create)
+	return create_table.DoWithContext(ctx, c)
+}
+
+func (req *Request) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("create_table.(Request)DoWithContext: receiver is nil")
+	}
+	create_table := CreateTable(*req)
+	return create_table.DoWithContext(ctx, c)
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (create_table *CreateTable) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if create_table == nil {
+		return nil, errors.New("create_table.(CreateTable)DoWithConf: receiver is nil")
+	}
+	return create_table.DoWithContext(context.Background(), c)
+}
+
+func (create *Create) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if create == nil {
+		return nil, errors.New("create_table.(Create)DoWithConf: receiver is nil")
+	}
+	return create.DoWithContext(context.Background(), c)
+}
+
+func (req *Request) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("create_table.(Request)DoWithConf: receiver is nil")
+	}
+	return req.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (create_table *CreateTable) Do() (*Response, error) {
+	if create_table == nil {
+		return nil, errors.New("create_table.(CreateTable)Do: receiver is nil")
+	}
+	return create_table.DoWithConf(&conf.Vals)
+}
+
+func (create *Create) Do() (*Response, error) {
+	if create == nil {
+		return nil, errors.New("create_table.(Create)Do: receiver is nil")
+	}
+	return create.DoWithConf(&conf.Vals)
+}
+
+func (req *Request) Do() (*Response, error) {
+	if req == nil {
+		return nil, errors.New("create_table.(Request)Do: receiver is nil")
+	}
+	return req.DoWithConf(&conf.Vals)
+}
+
 // ValidTable is a local validator that helps callers determine if a table name is too long.
 func ValidTableName(t string) bool {
 	l := len([]byte(t))
@@ -7,11 +7,13 @@
 package delete_item
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/smugmug/godynamo/authreq"
 	"github.com/smugmug/godynamo/aws_const"
 	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/expression"
 	"github.com/smugmug/godynamo/types/attributesresponse"
 	"github.com/smugmug/godynamo/types/attributevalue"
 	"github.com/smugmug/godynamo/types/aws_strings"
@@ -69,37 +71,74 @@ func NewResponse() *Response {
 	return &r
 }
 
-// These implementations of EndpointReq use a parameterized conf.
+// ValidateExpressions parses the expressions of the request and checks its expression
+// attribute names and values as DynamoDB would, without making the request.
+func (delete_item *DeleteItem) ValidateExpressions() error {
+	if delete_item == nil {
+		return errors.New("delete_item.(DeleteItem)ValidateExpressions: receiver is nil")
+	}
+	e := expression.Expressions{
+		ConditionExpression:       delete_item.ConditionExpression,
+		ExpressionAttributeNames:  delete_item.ExpressionAttributeNames,
+		ExpressionAttributeValues: delete_item.ExpressionAttributeValues,
+	}
+	return e.Validate()
+}
 
-func (delete_item *DeleteItem) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+// These implementations of EndpointReq use a parameterized conf and context.
+
+func (delete_item *DeleteItem) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
 	if delete_item == nil {
-		return nil, 0, errors.New("delete_item.(DeleteItem)EndpointReqWithConf: receiver is nil")
+		return nil, 0, errors.New("delete_item.(DeleteItem)EndpointReqWithContext: receiver is nil")
 	}
 	if !conf.IsValid(c) {
-		return nil, 0, errors.New("delete_item.EndpointReqWithConf: c is not valid")
+		return nil, 0, errors.New("delete_item.EndpointReqWithContext: c is not valid")
 	}
 	// returns resp_body,code,err
 	reqJSON, json_err := json.Marshal(delete_item)
 	if json_err != nil {
 		return nil, 0, json_err
 	}
-	return authreq.RetryReqJSON_V4WithConf(reqJSON, DELETEITEM_ENDPOINT, c)
+	return authreq.RetryReqJSON_V4WithContext(ctx, reqJSON, DELETEITEM_ENDPOINT, c)
+}
+
+func (delete *Delete) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if delete == nil {
+		return nil, 0, errors.New("delete_item.(Delete)EndpointReqWithContext: receiver is nil")
+	}
+	delete_item := DeleteItem(*delete)
+	return delete_item.EndpointReqWithContext(ctx, c)
+}
+
+func (req *Request) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("delete_item.(Request)EndpointReqWithContext: receiver is nil")
+	}
+	delete_item := DeleteItem(*req)
+	return delete_item.EndpointReqWithContext(ctx, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (delete_item *DeleteItem) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if delete_item == nil {
+		return nil, 0, errors.New("delete_item.(DeleteItem)EndpointReqWithConf: receiver is nil")
+	}
+	return delete_item.EndpointReqWithContext(context.Background(), c)
 }
 
 func (delete *Delete) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if delete == nil {
 		return nil, 0, errors.New("delete_item.(Delete)EndpointReqWithConf: receiver is nil")
 	}
-	delete_item := DeleteItem(*delete)
-	return delete_item.EndpointReqWithConf(c)
+	return delete.EndpointReqWithContext(context.Background(), c)
 }
 
 func (req *Request) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if req == nil {
 		return nil, 0, errors.New("delete_item.(Request)EndpointReqWithConf: receiver is nil")
 	}
-	delete_item := DeleteItem(*req)
-	return delete_item.EndpointReqWithConf(c)
+	return req.EndpointReqWithContext(context.Background(), c)
 }
 
 // These implementations of EndpointReq use the global conf.
@@ -126,3 +165,81 @@ func (req *Request) EndpointReq() ([]byte, int, error) {
 	delete_item := DeleteItem(*req)
 	return delete_item.EndpointReqWithConf(&conf.Vals)
 }
+
+// These implementations of Do perform the request and decode the result into a Response.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded Response. Error responses are returned as a *dynamoerr.APIError.
+func (delete_item *DeleteItem) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if delete_item == nil {
+		return nil, errors.New("delete_item.(DeleteItem)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("delete_item.(DeleteItem)DoWithContext: ctx is nil")
+	}
+	resp := NewResponse()
+	if err := authreq.DoWithContext(ctx, delete_item, DELETEITEM_ENDPOINT, c, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (delete *Delete) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if delete == nil {
+		return nil, errors.New("delete_item.(Delete)DoWithContext: receiver is nil")
+	}
+	delete_item := DeleteItem(*delete)
+	return delete_item.DoWithContext(ctx, c)
+}
+
+func (req *Request) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("delete_item.(Request)DoWithContext: receiver is nil")
+	}
+	delete_item := DeleteItem(*req)
+	return delete_item.DoWithContext(ctx, c)
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (delete_item *DeleteItem) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if delete_item == nil {
+		return nil, errors.New("delete_item.(DeleteItem)DoWithConf: receiver is nil")
+	}
+	return delete_item.DoWithContext(context.Background(), c)
+}
+
+func (delete *Delete) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if delete == nil {
+		return nil, errors.New("delete_item.(Delete)DoWithConf: receiver is nil")
+	}
+	return delete.DoWithContext(context.Background(), c)
+}
+
+func (req *Request) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("delete_item.(Request)DoWithConf: receiver is nil")
+	}
+	return req.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (delete_item *DeleteItem) Do() (*Response, error) {
+	if delete_item == nil {
+		return nil, errors.New("delete_item.(DeleteItem)Do: receiver is nil")
+	}
+	return delete_item.DoWithConf(&conf.Vals)
+}
+
+func (delete *Delete) Do() (*Response, error) {
+	if delete == nil {
+		return nil, errors.New("delete_item.(Delete)Do: receiver is nil")
+	}
+	return delete.DoWithConf(&conf.Vals)
+}
+
+func (req *Request) Do() (*Response, error) {
+	if req == nil {
+		return nil, errors.New("delete_item.(Request)Do: receiver is nil")
+	}
+	return req.DoWithConf(&conf.Vals)
+}
@@ -7,6 +7,7 @@
 package delete_table
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/smugmug/godynamo/authreq"
@@ -43,37 +44,60 @@ func NewResponse() *Response {
 	return &r
 }
 
-// These implementations of EndpointReq use a parameterized conf.
+// These implementations of EndpointReq use a parameterized conf and context.
 
-func (delete_table *DeleteTable) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+func (delete_table *DeleteTable) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
     	if delete_table == nil {
-		return nil, 0, errors.New("delete_table.(DeleteTable)EndpointReqWithConf: receiver is nil")
+		return nil, 0, errors.New("delete_table.(DeleteTable)EndpointReqWithContext: receiver is nil")
 	}
 	if !conf.IsValid(c) {
-		return nil, 0, errors.New("delete_table.EndpointReqWithConf: c is not valid")
+		return nil, 0, errors.New("delete_table.EndpointReqWithContext: c is not valid")
 	}
 	// returns resp_body,code,err
 	reqJSON, json_err := json.Marshal(delete_table)
 	if json_err != nil {
 		return nil, 0, json_err
 	}
-	return authreq.RetryReqJSON_V4WithConf(reqJSON, DELETETABLE_ENDPOINT, c)
+	return authreq.RetryReqJSON_V4WithContext(ctx, reqJSON, DELETETABLE_ENDPOINT, c)
+}
+
+func (delete *Delete) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if delete == nil {
+		return nil, 0, errors.New("delete_table.(Delete)EndpointReqWithContext: receiver is nil")
+	}
+	delete_table := DeleteTable(*delete)
+	return delete_table.EndpointReqWithContext(ctx, c)
+}
+
+func (req *Request) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("delete_table.(Request)EndpointReqWithContext: receiver is nil")
+	}
+	delete_table := DeleteTable(*req)
+	return delete_table.EndpointReqWithContext(ctx, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (delete_table *DeleteTable) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+    	if delete_table == nil {
+		return nil, 0, errors.New("delete_table.(DeleteTable)EndpointReqWithConf: receiver is nil")
+	}
+	return delete_table.EndpointReqWithContext(context.Background(), c)
 }
 
 func (delete *Delete) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if delete == nil {
 		return nil, 0, errors.New("delete_table.(Delete)EndpointReqWithConf: receiver is nil")
 	}
-	delete_table := DeleteTable(*delete)
-	return delete_table.EndpointReqWithConf(c)
+	return delete.EndpointReqWithContext(context.Background(), c)
 }
 
 func (req *Request) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if req == nil {
 		return nil, 0, errors.New("delete_table.(Request)EndpointReqWithConf: receiver is nil")
 	}
-	delete_table := DeleteTable(*req)
-	return delete_table.EndpointReqWithConf(c)
+	return req.EndpointReqWithContext(context.Background(), c)
 }
 
 // These implementations of EndpointReq use the global conf.
@@ -100,3 +124,81 @@ func (req *Request) EndpointReq() ([]byte, int, error) {
 	delete_table := DeleteTable(*req)
 	return delete_table.EndpointReqWithConf(&conf.Vals)
 }
+
+// These implementations of Do perform the request and decode the result into a Response.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded Response. Error responses are returned as a *dynamoerr.APIError.
+func (delete_table *DeleteTable) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if delete_table == nil {
+		return nil, errors.New("delete_table.(DeleteTable)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("delete_table.(DeleteTable)DoWithContext: ctx is nil")
+	}
+	resp := NewResponse()
+	if err := authreq.DoWithContext(ctx, delete_table, DELETETABLE_ENDPOINT, c, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (delete *Delete) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if delete == nil {
+		return nil, errors.New("delete_table.(Delete)DoWithContext: receiver is nil")
+	}
+	delete_table := DeleteTable(*delete)
+	return delete_table.DoWithContext(ctx, c)
+}
+
+func (req *Request) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("delete_table.(Request)DoWithContext: receiver is nil")
+	}
+	delete_table := DeleteTable(*req)
+	return delete_table.DoWithContext(ctx, c)
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (delete_table *DeleteTable) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if delete_table == nil {
+		return nil, errors.New("delete_table.(DeleteTable)DoWithConf: receiver is nil")
+	}
+	return delete_table.DoWithContext(context.Background(), c)
+}
+
+func (delete *Delete) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if delete == nil {
+		return nil, errors.New("delete_table.(Delete)DoWithConf: receiver is nil")
+	}
+	return delete.DoWithContext(context.Background(), c)
+}
+
+func (req *Request) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("delete_table.(Request)DoWithConf: receiver is nil")
+	}
+	return req.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (delete_table *DeleteTable) Do() (*Response, error) {
+	if delete_table == nil {
+		return nil, errors.New("delete_table.(DeleteTable)Do: receiver is nil")
+	}
+	return delete_table.DoWithConf(&conf.Vals)
+}
+
+func (delete *Delete) Do() (*Response, error) {
+	if delete == nil {
+		return nil, errors.New("delete_table.(Delete)Do: receiver is nil")
+	}
+	return delete.DoWithConf(&conf.Vals)
+}
+
+func (req *Request) Do() (*Response, error) {
+	if req == nil {
+		return nil, errors.New("delete_table.(Request)Do: receiver is nil")
+	}
+	return req.DoWithConf(&conf.Vals)
+}
@@ -7,18 +7,21 @@
 package describe_table
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/smugmug/godynamo/authreq"
 	"github.com/smugmug/godynamo/aws_const"
 	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/dynamoerr"
 	ep "github.com/smugmug/godynamo/endpoint"
 	"github.com/smugmug/godynamo/types/attributedefinition"
 	"github.com/smugmug/godynamo/types/globalsecondaryindex"
 	"github.com/smugmug/godynamo/types/keydefinition"
 	"github.com/smugmug/godynamo/types/localsecondaryindex"
 	"github.com/smugmug/godynamo/types/provisionedthroughput"
+	"github.com/smugmug/godynamo/types/streamspecification"
 	"net/http"
 	"time"
 )
@@ -50,8 +53,11 @@ type Response struct {
 		GlobalSecondaryIndexes []globalsecondaryindex.GlobalSecondaryIndexDesc
 		ItemCount              uint64
 		KeySchema              keydefinition.KeySchema
+		LatestStreamArn        string `json:",omitempty"`
+		LatestStreamLabel      string `json:",omitempty"`
 		LocalSecondaryIndexes  []localsecondaryindex.LocalSecondaryIndexDesc
 		ProvisionedThroughput  provisionedthroughput.ProvisionedThroughputDesc
+		StreamSpecification    *streamspecification.StreamSpecification `json:",omitempty"`
 		TableName              string
 		TableSizeBytes         uint64
 		TableStatus            string
@@ -71,37 +77,60 @@ type StatusResult struct {
 	StatusResult bool
 }
 
-// These implementations of EndpointReq use a parameterized conf.
+// These implementations of EndpointReq use a parameterized conf and context.
 
-func (describe_table *DescribeTable) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+func (describe_table *DescribeTable) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
 	if describe_table == nil {
-		return nil, 0, errors.New("describe_table.(DescribeTable)EndpointReqWithConf: receiver is nil")
+		return nil, 0, errors.New("describe_table.(DescribeTable)EndpointReqWithContext: receiver is nil")
 	}
 	if !conf.IsValid(c) {
-		return nil, 0, errors.New("describe_table.EndpointReqWithConf: c is not valid")
+		return nil, 0, errors.New("describe_table.EndpointReqWithContext: c is not valid")
 	}
 	// returns resp_body,code,err
 	reqJSON, json_err := json.Marshal(describe_table)
 	if json_err != nil {
 		return nil, 0, json_err
 	}
-	return authreq.RetryReqJSON_V4WithConf(reqJSON, DESCTABLE_ENDPOINT, c)
+	return authreq.RetryReqJSON_V4WithContext(ctx, reqJSON, DESCTABLE_ENDPOINT, c)
+}
+
+func (describe *Describe) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if describe == nil {
+		return nil, 0, errors.New("describe_table.(Describe)EndpointReqWithContext: receiver is nil")
+	}
+	describe_table := DescribeTable(*describe)
+	return describe_table.EndpointReqWithContext(ctx, c)
+}
+
+func (req *Request) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("describe_table.(Request)EndpointReqWithContext: receiver is nil")
+	}
+	describe_table := DescribeTable(*req)
+	return describe_table.EndpointReqWithContext(ctx, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (describe_table *DescribeTable) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if describe_table == nil {
+		return nil, 0, errors.New("describe_table.(DescribeTable)EndpointReqWithConf: receiver is nil")
+	}
+	return describe_table.EndpointReqWithContext(context.Background(), c)
 }
 
 func (describe *Describe) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if describe == nil {
 		return nil, 0, errors.New("describe_table.(Describe)EndpointReqWithConf: receiver is nil")
 	}
-	describe_table := DescribeTable(*describe)
-	return describe_table.EndpointReqWithConf(c)
+	return describe.EndpointReqWithContext(context.Background(), c)
 }
 
 func (req *Request) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if req == nil {
 		return nil, 0, errors.New("describe_table.(Request)EndpointReqWithConf: receiver is nil")
 	}
-	describe_table := DescribeTable(*req)
-	return describe_table.EndpointReqWithConf(c)
+	return req.EndpointReqWithContext(context.Background(), c)
 }
 
 // These implementations of EndpointReq use the global conf.
@@ -129,17 +158,96 @@ func (req *Request) EndpointReq() ([]byte, int, error) {
 	return describe_table.EndpointReqWithConf(&conf.Vals)
 }
 
-// PollTableStatusWithConf allows the caller to poll a table for a specific status.
-func PollTableStatusWithConf(tablename string, status string, tries int, c *conf.AWS_Conf) (bool, error) {
+// These implementations of Do perform the request and decode the result into a Response.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded Response. Error responses are returned as a *dynamoerr.APIError.
+func (describe_table *DescribeTable) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if describe_table == nil {
+		return nil, errors.New("describe_table.(DescribeTable)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("describe_table.(DescribeTable)DoWithContext: ctx is nil")
+	}
+	resp := NewResponse()
+	if err := authreq.DoWithContext(ctx, describe_table, DESCTABLE_ENDPOINT, c, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (describe *Describe) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if describe == nil {
+		return nil, errors.New("describe_table.(Describe)DoWithContext: receiver is nil")
+	}
+	describe_table := DescribeTable(*describe)
+	return describe_table.DoWithContext(ctx, c)
+}
+
+func (req *Request) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("describe_table.(Request)DoWithContext: receiver is nil")
+	}
+	describe_table := DescribeTable(*req)
+	return describe_table.DoWithContext(ctx, c)
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (describe_table *DescribeTable) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if describe_table == nil {
+		return nil, errors.New("describe_table.(DescribeTable)DoWithConf: receiver is nil")
+	}
+	return describe_table.DoWithContext(context.Background(), c)
+}
+
+func (describe *Describe) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if describe == nil {
+		return nil, errors.New("describe_table.(Describe)DoWithConf: receiver is nil")
+	}
+	return describe.DoWithContext(context.Background(), c)
+}
+
+func (req *Request) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("describe_table.(Request)DoWithConf: receiver is nil")
+	}
+	return req.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (describe_table *DescribeTable) Do() (*Response, error) {
+	if describe_table == nil {
+		return nil, errors.New("describe_table.(DescribeTable)Do: receiver is nil")
+	}
+	return describe_table.DoWithConf(&conf.Vals)
+}
+
+func (describe *Describe) Do() (*Response, error) {
+	if describe == nil {
+		return nil, errors.New("describe_table.(Describe)Do: receiver is nil")
+	}
+	return describe.DoWithConf(&conf.Vals)
+}
+
+func (req *Request) Do() (*Response, error) {
+	if req == nil {
+		return nil, errors.New("describe_table.(Request)Do: receiver is nil")
+	}
+	return req.DoWithConf(&conf.Vals)
+}
+
+// PollTableStatusWithContext allows the caller to poll a table for a specific status.
+// Polling stops early if ctx is done.
+func PollTableStatusWithContext(ctx context.Context, tablename string, status string, tries int, c *conf.AWS_Conf) (bool, error) {
 	if !conf.IsValid(c) {
-		return false, errors.New("describe_table.PollTableStatusWithConf: c is not valid")
+		return false, errors.New("describe_table.PollTableStatusWithContext: c is not valid")
 	}
 	// aws docs informs us to poll the describe endpoint until the table
 	// "status" is status for this tablename
 	wait := time.Duration(2 * time.Second)
 
 	for i := 0; i < tries; i++ {
-		active, err := IsTableStatusWithConf(tablename, status, c)
+		active, err := IsTableStatusWithContext(ctx, tablename, status, c)
 		if err != nil {
 			e := fmt.Sprintf("describe_table.PollStatus:%s",
 				err.Error())
@@ -148,23 +256,33 @@ func PollTableStatusWithConf(tablename string, status string, tries int, c *conf
 		if active {
 			return active, nil
 		}
-		time.Sleep(wait) // wait for table to become ACTIVE
+		// wait for table to become ACTIVE
+		select {
+		case <-ctx.Done():
+			return false, fmt.Errorf("describe_table.PollStatus: %w", ctx.Err())
+		case <-time.After(wait):
+		}
 	}
 	return false, nil
 }
 
+// PollTableStatusWithConf is the same as PollTableStatusWithContext but uses a background context.
+func PollTableStatusWithConf(tablename string, status string, tries int, c *conf.AWS_Conf) (bool, error) {
+	return PollTableStatusWithContext(context.Background(), tablename, status, tries, c)
+}
+
 // PollTableStatus is the same as PollTableStatusWithConf but uses the global conf.Vals.
 func PollTableStatus(tablename string, status string, tries int) (bool, error) {
 	return PollTableStatusWithConf(tablename, status, tries, &conf.Vals)
 }
 
-// IsTableStatusWithConf will test the equality status of a table.
-func IsTableStatusWithConf(tablename string, status string, c *conf.AWS_Conf) (bool, error) {
+// IsTableStatusWithContext will test the equality status of a table.
+func IsTableStatusWithContext(ctx context.Context, tablename string, status string, c *conf.AWS_Conf) (bool, error) {
 	if !conf.IsValid(c) {
-		return false, errors.New("describe_table.IsTableStatusWithConf: c is not valid")
+		return false, errors.New("describe_table.IsTableStatusWithContext: c is not valid")
 	}
 	d := ep.Endpoint(&DescribeTable{TableName: tablename})
-	s_resp, s_code, s_err := authreq.RetryReq_V4WithConf(d, DESCTABLE_ENDPOINT, c)
+	s_resp, s_code, s_err := authreq.RetryReq_V4WithContext(ctx, d, DESCTABLE_ENDPOINT, c)
 	if s_err != nil {
 		e := fmt.Sprintf("describe_table.IsTableStatus: "+
 			"check on %s err %s",
@@ -190,25 +308,39 @@ func IsTableStatusWithConf(tablename string, status string, c *conf.AWS_Conf) (b
 	return false, errors.New(e)
 }
 
+// IsTableStatusWithConf is the same as IsTableStatusWithContext but uses a background context.
+func IsTableStatusWithConf(tablename string, status string, c *conf.AWS_Conf) (bool, error) {
+	return IsTableStatusWithContext(context.Background(), tablename, status, c)
+}
+
 // IsTableStatus is the same as IsTableStatusWithConf but uses the global conf.Vals.
 func IsTableStatus(tablename string, status string) (bool, error) {
 	return IsTableStatusWithConf(tablename, status, &conf.Vals)
 }
 
-// TableExistsWithconf test for table exists: exploit the fact that aws reports 4xx for tables that don't exist.
-func (desc DescribeTable) TableExistsWithConf(c *conf.AWS_Conf) (bool, error) {
+// TableExistsWithContext test for table exists: exploit the fact that aws reports 4xx for tables that don't exist.
+func (desc DescribeTable) TableExistsWithContext(ctx context.Context, c *conf.AWS_Conf) (bool, error) {
 	if !conf.IsValid(c) {
-		return false, errors.New("describe_table.TableExistsWithConf: c is not valid")
+		return false, errors.New("describe_table.TableExistsWithContext: c is not valid")
+	}
+	_, code, err := desc.EndpointReqWithContext(ctx, c)
+	if errors.Is(err, dynamoerr.ErrResourceNotFound) {
+		// typed errors were requested, the table does not exist
+		return false, nil
 	}
-	_, code, err := desc.EndpointReqWithConf(c)
 	if err != nil {
-		e := fmt.Sprintf("describe_table.TableExistsWithConf "+
+		e := fmt.Sprintf("describe_table.TableExistsWithContext "+
 			"%s", err.Error())
 		return false, errors.New(e)
 	}
 	return (code == http.StatusOK), nil
 }
 
+// TableExistsWithConf is the same as TableExistsWithContext but uses a background context.
+func (desc DescribeTable) TableExistsWithConf(c *conf.AWS_Conf) (bool, error) {
+	return desc.TableExistsWithContext(context.Background(), c)
+}
+
 // TableExists is the same as TableExistsWithConf but uses the global conf.Vals.
 func (desc DescribeTable) TableExists() (bool, error) {
 	return desc.TableExistsWithConf(&conf.Vals)
@@ -7,11 +7,13 @@
 package get_item
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/smugmug/godynamo/authreq"
 	"github.com/smugmug/godynamo/aws_const"
 	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/expression"
 	"github.com/smugmug/godynamo/types/attributestoget"
 	"github.com/smugmug/godynamo/types/attributevalue"
 	"github.com/smugmug/godynamo/types/capacity"
@@ -119,7 +121,7 @@ func (resp *Response) ToResponseItemJSON() (*ResponseItemJSON, error) {
 		return nil, errors.New("receiver is nil")
 	}
 	a := attributevalue.AttributeValueMap(resp.Item)
-	c, cerr := a.ToInterface()
+	c, cerr := a.ToInterfaceUseNumber()
 	if cerr != nil {
 		return nil, cerr
 	}
@@ -129,37 +131,73 @@ func (resp *Response) ToResponseItemJSON() (*ResponseItemJSON, error) {
 	return resp_json, nil
 }
 
-// These implementations of EndpointReq use a parameterized conf.
+// ValidateExpressions parses the expressions of the request and checks its expression
+// attribute names and values as DynamoDB would, without making the request.
+func (get_item *GetItem) ValidateExpressions() error {
+	if get_item == nil {
+		return errors.New("get_item.(GetItem)ValidateExpressions: receiver is nil")
+	}
+	e := expression.Expressions{
+		ProjectionExpression:     get_item.ProjectionExpression,
+		ExpressionAttributeNames: get_item.ExpressionAttributeNames,
+	}
+	return e.Validate()
+}
 
-func (get_item *GetItem) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+// These implementations of EndpointReq use a parameterized conf and context.
+
+func (get_item *GetItem) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
 	if get_item == nil {
-		return nil, 0, errors.New("get_item.(GetItem)EndpointReqWithConf: receiver is nil")
+		return nil, 0, errors.New("get_item.(GetItem)EndpointReqWithContext: receiver is nil")
 	}
 	if !conf.IsValid(c) {
-		return nil, 0, errors.New("get_item.EndpointReqWithConf: c is not valid")
+		return nil, 0, errors.New("get_item.EndpointReqWithContext: c is not valid")
 	}
 	// returns resp_body,code,err
 	reqJSON, json_err := json.Marshal(get_item)
 	if json_err != nil {
 		return nil, 0, json_err
 	}
-	return authreq.RetryReqJSON_V4WithConf(reqJSON, GETITEM_ENDPOINT, c)
+	return authreq.RetryReqJSON_V4WithContext(ctx, reqJSON, GETITEM_ENDPOINT, c)
+}
+
+func (get *Get) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if get == nil {
+		return nil, 0, errors.New("get_item.(Get)EndpointReqWithContext: receiver is nil")
+	}
+	get_item := GetItem(*get)
+	return get_item.EndpointReqWithContext(ctx, c)
+}
+
+func (req *Request) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("get_item.(Request)EndpointReqWithContext: receiver is nil")
+	}
+	get_item := GetItem(*req)
+	return get_item.EndpointReqWithContext(ctx, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (get_item *GetItem) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if get_item == nil {
+		return nil, 0, errors.New("get_item.(GetItem)EndpointReqWithConf: receiver is nil")
+	}
+	return get_item.EndpointReqWithContext(context.Background(), c)
 }
 
 func (get *Get) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if get == nil {
 		return nil, 0, errors.New("get_item.(Get)EndpointReqWithConf: receiver is nil")
 	}
-	get_item := GetItem(*get)
-	return get_item.EndpointReqWithConf(c)
+	return get.EndpointReqWithContext(context.Background(), c)
 }
 
 func (req *Request) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if req == nil {
 		return nil, 0, errors.New("get_item.(Request)EndpointReqWithConf: receiver is nil")
 	}
-	get_item := GetItem(*req)
-	return get_item.EndpointReqWithConf(c)
+	return req.EndpointReqWithContext(context.Background(), c)
 }
 
 // These implementations of EndpointReq use the global conf.
@@ -186,3 +224,81 @@ func (req *Request) EndpointReq() ([]byte, int, error) {
 	get_item := GetItem(*req)
 	return get_item.EndpointReqWithConf(&conf.Vals)
 }
+
+// These implementations of Do perform the request and decode the result into a Response.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded Response. Error responses are returned as a *dynamoerr.APIError.
+func (get_item *GetItem) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if get_item == nil {
+		return nil, errors.New("get_item.(GetItem)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("get_item.(GetItem)DoWithContext: ctx is nil")
+	}
+	resp := NewResponse()
+	if err := authreq.DoWithContext(ctx, get_item, GETITEM_ENDPOINT, c, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (get *Get) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if get == nil {
+		return nil, errors.New("get_item.(Get)DoWithContext: receiver is nil")
+	}
+	get_item := GetItem(*get)
+	return get_item.DoWithContext(ctx, c)
+}
+
+func (req *Request) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("get_item.(Request)DoWithContext: receiver is nil")
+	}
+	get_item := GetItem(*req)
+	return get_item.DoWithContext(ctx, c)
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (get_item *GetItem) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if get_item == nil {
+		return nil, errors.New("get_item.(GetItem)DoWithConf: receiver is nil")
+	}
+	return get_item.DoWithContext(context.Background(), c)
+}
+
+func (get *Get) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if get == nil {
+		return nil, errors.New("get_item.(Get)DoWithConf: receiver is nil")
+	}
+	return get.DoWithContext(context.Background(), c)
+}
+
+func (req *Request) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("get_item.(Request)DoWithConf: receiver is nil")
+	}
+	return req.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (get_item *GetItem) Do() (*Response, error) {
+	if get_item == nil {
+		return nil, errors.New("get_item.(GetItem)Do: receiver is nil")
+	}
+	return get_item.DoWithConf(&conf.Vals)
+}
+
+func (get *Get) Do() (*Response, error) {
+	if get == nil {
+		return nil, errors.New("get_item.(Get)Do: receiver is nil")
+	}
+	return get.DoWithConf(&conf.Vals)
+}
+
+func (req *Request) Do() (*Response, error) {
+	if req == nil {
+		return nil, errors.New("get_item.(Request)Do: receiver is nil")
+	}
+	return req.DoWithConf(&conf.Vals)
+}
@@ -12,6 +12,10 @@ func TestNil(t *testing.T) {
 	if err == nil {
 		t.Errorf("nil conf should result in error")
 	}
+	_, do_err := g.DoWithConf(nil)
+	if do_err == nil {
+		t.Errorf("nil conf should result in error")
+	}
 }
 
 func TestRequestMarshal(t *testing.T) {
@@ -7,6 +7,7 @@
 package list_tables
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/smugmug/godynamo/authreq"
@@ -44,37 +45,60 @@ func NewResponse() *Response {
 	return r
 }
 
-// These implementations of EndpointReq use a parameterized conf.
+// These implementations of EndpointReq use a parameterized conf and context.
 
-func (list_tables *ListTables) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+func (list_tables *ListTables) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
 	if list_tables == nil {
-		return nil, 0, errors.New("list_tables.(ListTables)EndpointReqWithConf: receiver is nil")
+		return nil, 0, errors.New("list_tables.(ListTables)EndpointReqWithContext: receiver is nil")
 	}
 	if !conf.IsValid(c) {
-		return nil, 0, errors.New("list_tables.EndpointReqWithConf: c is not valid")
+		return nil, 0, errors.New("list_tables.EndpointReqWithContext: c is not valid")
 	}
 	// returns resp_body,code,err
 	reqJSON, json_err := json.Marshal(list_tables)
 	if json_err != nil {
 		return nil, 0, json_err
 	}
-	return authreq.RetryReqJSON_V4WithConf(reqJSON, LISTTABLE_ENDPOINT, c)
+	return authreq.RetryReqJSON_V4WithContext(ctx, reqJSON, LISTTABLE_ENDPOINT, c)
+}
+
+func (list *List) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if list == nil {
+		return nil, 0, errors.New("list_tables.(List)EndpointReqWithContext: receiver is nil")
+	}
+	list_tables := ListTables(*list)
+	return list_tables.EndpointReqWithContext(ctx, c)
+}
+
+func (req *Request) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("list_tables.(Request)EndpointReqWithContext: receiver is nil")
+	}
+	list_tables := ListTables(*req)
+	return list_tables.EndpointReqWithContext(ctx, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (list_tables *ListTables) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if list_tables == nil {
+		return nil, 0, errors.New("list_tables.(ListTables)EndpointReqWithConf: receiver is nil")
+	}
+	return list_tables.EndpointReqWithContext(context.Background(), c)
 }
 
 func (list *List) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if list == nil {
 		return nil, 0, errors.New("list_tables.(List)EndpointReqWithConf: receiver is nil")
 	}
-	list_tables := ListTables(*list)
-	return list_tables.EndpointReqWithConf(c)
+	return list.EndpointReqWithContext(context.Background(), c)
 }
 
 func (req *Request) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if req == nil {
 		return nil, 0, errors.New("list_tables.(Request)EndpointReqWithConf: receiver is nil")
 	}
-	list_tables := ListTables(*req)
-	return list_tables.EndpointReqWithConf(c)
+	return req.EndpointReqWithContext(context.Background(), c)
 }
 
 // These implementations of EndpointReq use the global conf.
@@ -101,3 +125,81 @@ func (req *Request) EndpointReq() ([]byte, int, error) {
 	list_table := ListTables(*req)
 	return list_table.EndpointReqWithConf(&conf.Vals)
 }
+
+// These implementations of Do perform the request and decode the result into a Response.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded Response. Error responses are returned as a *dynamoerr.APIError.
+func (list_tables *ListTables) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if list_tables == nil {
+		return nil, errors.New("list_tables.(ListTables)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("list_tables.(ListTables)DoWithContext: ctx is nil")
+	}
+	resp := NewResponse()
+	if err := authreq.DoWithContext(ctx, list_tables, LISTTABLE_ENDPOINT, c, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (list *List) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if list == nil {
+		return nil, errors.New("list_tables.(List)DoWithContext: receiver is nil")
+	}
+	list_tables := ListTables(*list)
+	return list_tables.DoWithContext(ctx, c)
+}
+
+func (req *Request) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("list_tables.(Request)DoWithContext: receiver is nil")
+	}
+	list_tables := ListTables(*req)
+	return list_tables.DoWithContext(ctx, c)
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (list_tables *ListTables) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if list_tables == nil {
+		return nil, errors.New("list_tables.(ListTables)DoWithConf: receiver is nil")
+	}
+	return list_tables.DoWithContext(context.Background(), c)
+}
+
+func (list *List) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if list == nil {
+		return nil, errors.New("list_tables.(List)DoWithConf: receiver is nil")
+	}
+	return list.DoWithContext(context.Background(), c)
+}
+
+func (req *Request) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("list_tables.(Request)DoWithConf: receiver is nil")
+	}
+	return req.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (list_tables *ListTables) Do() (*Response, error) {
+	if list_tables == nil {
+		return nil, errors.New("list_tables.(ListTables)Do: receiver is nil")
+	}
+	return list_tables.DoWithConf(&conf.Vals)
+}
+
+func (list *List) Do() (*Response, error) {
+	if list == nil {
+		return nil, errors.New("list_tables.(List)Do: receiver is nil")
+	}
+	return list.DoWithConf(&conf.Vals)
+}
+
+func (req *Request) Do() (*Response, error) {
+	if req == nil {
+		return nil, errors.New("list_tables.(Request)Do: receiver is nil")
+	}
+	return req.DoWithConf(&conf.Vals)
+}
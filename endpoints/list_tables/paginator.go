@@ -0,0 +1,101 @@
+package list_tables
+
+import (
+	"context"
+	"errors"
+	"github.com/smugmug/godynamo/conf"
+	ep "github.com/smugmug/godynamo/endpoint"
+)
+
+// ListTablesPaginator follows the pages of a ListTables by passing the
+// LastEvaluatedTableName of each page as the ExclusiveStartTableName of the next.
+// Next, NextWithContext, SetItemLimit, Err and Count are those of ep.Paginator, and
+// count table names.
+type ListTablesPaginator struct {
+	*ep.Paginator
+	list_tables ListTables
+	c           *conf.AWS_Conf
+	page        *Response
+}
+
+// NewListTablesPaginator returns a paginator for l that sends its requests with c.
+// l is copied, so later changes to it are not seen by the paginator.
+func NewListTablesPaginator(l *ListTables, c *conf.AWS_Conf) *ListTablesPaginator {
+	p := &ListTablesPaginator{c: c}
+	if l == nil {
+		p.Paginator = ep.NewPaginator(nil, func(context.Context) (ep.PageInfo, error) {
+			return ep.PageInfo{}, errors.New("list_tables.NewListTablesPaginator: l is nil")
+		})
+		// fail at once, so that Err reports the nil request before Next is called
+		p.Paginator.Next()
+		return p
+	}
+	p.list_tables = *l
+	p.Paginator = ep.NewPaginator(&p.list_tables.Limit, func(ctx context.Context) (ep.PageInfo, error) {
+		resp, err := p.list_tables.DoWithContext(ctx, p.c)
+		if err != nil {
+			return ep.PageInfo{}, err
+		}
+		p.page = resp
+		p.list_tables.ExclusiveStartTableName = resp.LastEvaluatedTableName
+		n := uint64(len(resp.TableNames))
+		return ep.PageInfo{Count: n, ScannedCount: n, More: resp.LastEvaluatedTableName != ""}, nil
+	})
+	return p
+}
+
+// Resume starts the paginator from a cursor returned by Cursor. It must be called
+// before the first call to Next.
+func (p *ListTablesPaginator) Resume(cursor string) error {
+	if p == nil {
+		return errors.New("list_tables.(ListTablesPaginator)Resume: receiver is nil")
+	}
+	var start string
+	if r_err := p.Paginator.Resume(cursor, &start); r_err != nil {
+		return r_err
+	}
+	if start != "" {
+		p.list_tables.ExclusiveStartTableName = start
+	}
+	return nil
+}
+
+// Cursor returns an opaque string from which Resume continues after the last page read,
+// or "" if there are no more pages.
+func (p *ListTablesPaginator) Cursor() (string, error) {
+	if p == nil {
+		return "", errors.New("list_tables.(ListTablesPaginator)Cursor: receiver is nil")
+	}
+	return p.Paginator.Cursor(p.list_tables.ExclusiveStartTableName)
+}
+
+// Page returns the last page read by Next.
+func (p *ListTablesPaginator) Page() *Response {
+	if p == nil {
+		return nil
+	}
+	return p.page
+}
+
+// Items returns the table names of the last page read by Next.
+func (p *ListTablesPaginator) Items() []string {
+	if p == nil || p.page == nil {
+		return nil
+	}
+	return p.page.TableNames
+}
+
+// Pages sends each page to the returned channel, which is closed when there are no
+// more pages or ctx is done. Check Err once the channel is closed.
+func (p *ListTablesPaginator) Pages(ctx context.Context) <-chan *Response {
+	ch := make(chan *Response)
+	p.Paginator.Pages(ctx, func() bool {
+		select {
+		case ch <- p.page:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}, func() { close(ch) })
+	return ch
+}
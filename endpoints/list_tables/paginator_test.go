@@ -0,0 +1,55 @@
+package list_tables_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/smugmug/godynamo/emulator"
+	create_table "github.com/smugmug/godynamo/endpoints/create_table"
+	list_tables "github.com/smugmug/godynamo/endpoints/list_tables"
+	"testing"
+)
+
+func TestListTablesPaginator(t *testing.T) {
+	e := emulator.New()
+	defer e.Close()
+	c := e.Conf()
+	for _, name := range []string{"Thread", "Thread2", "Thread3"} {
+		var ct create_table.CreateTable
+		s := `{"TableName":"` + name + `",
+		 "AttributeDefinitions":[{"AttributeName":"ForumName","AttributeType":"S"}],
+		 "KeySchema":[{"AttributeName":"ForumName","KeyType":"HASH"}],
+		 "ProvisionedThroughput":{"ReadCapacityUnits":5,"WriteCapacityUnits":5}}`
+		if um_err := json.Unmarshal([]byte(s), &ct); um_err != nil {
+			t.Fatal(um_err)
+		}
+		if _, err := ct.DoWithConf(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l := list_tables.ListTables{Limit: 1}
+	lp := list_tables.NewListTablesPaginator(&l, c)
+	names := make([]string, 0)
+	for lp.Next() {
+		names = append(names, lp.Items()...)
+	}
+	if lp.Err() != nil || fmt.Sprint(names) != "[Thread Thread2 Thread3]" || lp.Count() != 3 {
+		t.Errorf("unexpected tables %v %v", names, lp.Err())
+	}
+
+	// stop after two tables and resume from the cursor
+	lp = list_tables.NewListTablesPaginator(&l, c)
+	lp.SetItemLimit(2)
+	for lp.Next() {
+	}
+	cursor, err := lp.Cursor()
+	if err != nil || cursor == "" {
+		t.Fatalf("expected a cursor, got %q %v", cursor, err)
+	}
+	lp = list_tables.NewListTablesPaginator(&l, c)
+	if r_err := lp.Resume(cursor); r_err != nil {
+		t.Fatal(r_err)
+	}
+	if !lp.Next() || fmt.Sprint(lp.Items()) != "[Thread3]" {
+		t.Errorf("resume should continue after the cursor, got %v %v", lp.Items(), lp.Err())
+	}
+}
@@ -7,11 +7,13 @@
 package put_item
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/smugmug/godynamo/authreq"
 	"github.com/smugmug/godynamo/aws_const"
 	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/expression"
 	"github.com/smugmug/godynamo/types/attributesresponse"
 	"github.com/smugmug/godynamo/types/attributevalue"
 	"github.com/smugmug/godynamo/types/aws_strings"
@@ -118,37 +120,74 @@ func NewResponse() *Response {
 	return &r
 }
 
-// These implementations of EndpointReq use a parameterized conf.
+// ValidateExpressions parses the expressions of the request and checks its expression
+// attribute names and values as DynamoDB would, without making the request.
+func (put_item *PutItem) ValidateExpressions() error {
+	if put_item == nil {
+		return errors.New("put_item.(PutItem)ValidateExpressions: receiver is nil")
+	}
+	e := expression.Expressions{
+		ConditionExpression:       put_item.ConditionExpression,
+		ExpressionAttributeNames:  put_item.ExpressionAttributeNames,
+		ExpressionAttributeValues: put_item.ExpressionAttributeValues,
+	}
+	return e.Validate()
+}
 
-func (put_item *PutItem) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+// These implementations of EndpointReq use a parameterized conf and context.
+
+func (put_item *PutItem) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
 	if put_item == nil {
-		return nil, 0, errors.New("put_item.(PutItem)EndpointReqWithConf: receiver is nil")
+		return nil, 0, errors.New("put_item.(PutItem)EndpointReqWithContext: receiver is nil")
 	}
 	if !conf.IsValid(c) {
-		return nil, 0, errors.New("put_item.EndpointReqWithConf: c is not valid")
+		return nil, 0, errors.New("put_item.EndpointReqWithContext: c is not valid")
 	}
 	// returns resp_body,code,err
 	reqJSON, json_err := json.Marshal(put_item)
 	if json_err != nil {
 		return nil, 0, json_err
 	}
-	return authreq.RetryReqJSON_V4WithConf(reqJSON, PUTITEM_ENDPOINT, c)
+	return authreq.RetryReqJSON_V4WithContext(ctx, reqJSON, PUTITEM_ENDPOINT, c)
+}
+
+func (put *Put) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if put == nil {
+		return nil, 0, errors.New("put_item.(Put)EndpointReqWithContext: receiver is nil")
+	}
+	put_item := PutItem(*put)
+	return put_item.EndpointReqWithContext(ctx, c)
+}
+
+func (req *Request) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("put_item.(Request)EndpointReqWithContext: receiver is nil")
+	}
+	put_item := PutItem(*req)
+	return put_item.EndpointReqWithContext(ctx, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (put_item *PutItem) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if put_item == nil {
+		return nil, 0, errors.New("put_item.(PutItem)EndpointReqWithConf: receiver is nil")
+	}
+	return put_item.EndpointReqWithContext(context.Background(), c)
 }
 
 func (put *Put) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if put == nil {
 		return nil, 0, errors.New("put_item.(Put)EndpointReqWithConf: receiver is nil")
 	}
-	put_item := PutItem(*put)
-	return put_item.EndpointReqWithConf(c)
+	return put.EndpointReqWithContext(context.Background(), c)
 }
 
 func (req *Request) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if req == nil {
 		return nil, 0, errors.New("put_item.(Request)EndpointReqWithConf: receiver is nil")
 	}
-	put_item := PutItem(*req)
-	return put_item.EndpointReqWithConf(c)
+	return req.EndpointReqWithContext(context.Background(), c)
 }
 
 // These implementations of EndpointReq use the global conf.
@@ -176,6 +215,84 @@ func (req *Request) EndpointReq() ([]byte, int, error) {
 	return put_item.EndpointReqWithConf(&conf.Vals)
 }
 
+// These implementations of Do perform the request and decode the result into a Response.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded Response. Error responses are returned as a *dynamoerr.APIError.
+func (put_item *PutItem) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if put_item == nil {
+		return nil, errors.New("put_item.(PutItem)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("put_item.(PutItem)DoWithContext: ctx is nil")
+	}
+	resp := NewResponse()
+	if err := authreq.DoWithContext(ctx, put_item, PUTITEM_ENDPOINT, c, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (put *Put) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if put == nil {
+		return nil, errors.New("put_item.(Put)DoWithContext: receiver is nil")
+	}
+	put_item := PutItem(*put)
+	return put_item.DoWithContext(ctx, c)
+}
+
+func (req *Request) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("put_item.(Request)DoWithContext: receiver is nil")
+	}
+	put_item := PutItem(*req)
+	return put_item.DoWithContext(ctx, c)
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (put_item *PutItem) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if put_item == nil {
+		return nil, errors.New("put_item.(PutItem)DoWithConf: receiver is nil")
+	}
+	return put_item.DoWithContext(context.Background(), c)
+}
+
+func (put *Put) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if put == nil {
+		return nil, errors.New("put_item.(Put)DoWithConf: receiver is nil")
+	}
+	return put.DoWithContext(context.Background(), c)
+}
+
+func (req *Request) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("put_item.(Request)DoWithConf: receiver is nil")
+	}
+	return req.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (put_item *PutItem) Do() (*Response, error) {
+	if put_item == nil {
+		return nil, errors.New("put_item.(PutItem)Do: receiver is nil")
+	}
+	return put_item.DoWithConf(&conf.Vals)
+}
+
+func (put *Put) Do() (*Response, error) {
+	if put == nil {
+		return nil, errors.New("put_item.(Put)Do: receiver is nil")
+	}
+	return put.DoWithConf(&conf.Vals)
+}
+
+func (req *Request) Do() (*Response, error) {
+	if req == nil {
+		return nil, errors.New("put_item.(Request)Do: receiver is nil")
+	}
+	return req.DoWithConf(&conf.Vals)
+}
+
 // ValidItem validates the size of a json serialization of an Item.
 // AWS says items can only be 400k bytes binary
 func ValidItem(i string) bool {
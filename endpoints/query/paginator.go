@@ -0,0 +1,114 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"github.com/smugmug/godynamo/conf"
+	ep "github.com/smugmug/godynamo/endpoint"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/item"
+)
+
+// QueryPaginator follows the pages of a Query by passing the LastEvaluatedKey of each
+// page as the ExclusiveStartKey of the next. Next, NextWithContext, SetItemLimit, Err,
+// Count, ScannedCount and ConsumedCapacity are those of ep.Paginator.
+//
+// example use:
+//
+//	p := query.NewQueryPaginator(q, c)
+//	for p.Next() {
+//		for _, it := range p.Items() {
+//			...
+//		}
+//	}
+//	if p.Err() != nil {
+//		...
+//	}
+type QueryPaginator struct {
+	*ep.Paginator
+	query Query
+	c     *conf.AWS_Conf
+	page  *Response
+}
+
+// NewQueryPaginator returns a paginator for q that sends its requests with c. q is
+// copied, so later changes to it are not seen by the paginator.
+func NewQueryPaginator(q *Query, c *conf.AWS_Conf) *QueryPaginator {
+	p := &QueryPaginator{c: c}
+	if q == nil {
+		p.Paginator = ep.NewPaginator(nil, func(context.Context) (ep.PageInfo, error) {
+			return ep.PageInfo{}, errors.New("query.NewQueryPaginator: q is nil")
+		})
+		// fail at once, so that Err reports the nil request before Next is called
+		p.Paginator.Next()
+		return p
+	}
+	p.query = *q
+	p.Paginator = ep.NewPaginator(&p.query.Limit, func(ctx context.Context) (ep.PageInfo, error) {
+		resp, err := p.query.DoWithContext(ctx, p.c)
+		if err != nil {
+			return ep.PageInfo{}, err
+		}
+		p.page = resp
+		p.query.ExclusiveStartKey = resp.LastEvaluatedKey
+		return ep.PageInfo{Count: resp.Count, ScannedCount: resp.ScannedCount,
+			ConsumedCapacity: resp.ConsumedCapacity, More: len(resp.LastEvaluatedKey) != 0}, nil
+	})
+	return p
+}
+
+// Resume starts the paginator from a cursor returned by Cursor. It must be called
+// before the first call to Next.
+func (p *QueryPaginator) Resume(cursor string) error {
+	if p == nil {
+		return errors.New("query.(QueryPaginator)Resume: receiver is nil")
+	}
+	k := attributevalue.NewAttributeValueMap()
+	if r_err := p.Paginator.Resume(cursor, &k); r_err != nil {
+		return r_err
+	}
+	if len(k) != 0 {
+		p.query.ExclusiveStartKey = k
+	}
+	return nil
+}
+
+// Cursor returns an opaque string from which Resume continues after the last page read,
+// or "" if there are no more pages.
+func (p *QueryPaginator) Cursor() (string, error) {
+	if p == nil {
+		return "", errors.New("query.(QueryPaginator)Cursor: receiver is nil")
+	}
+	return p.Paginator.Cursor(p.query.ExclusiveStartKey)
+}
+
+// Page returns the last page read by Next.
+func (p *QueryPaginator) Page() *Response {
+	if p == nil {
+		return nil
+	}
+	return p.page
+}
+
+// Items returns the items of the last page read by Next.
+func (p *QueryPaginator) Items() []item.Item {
+	if p == nil || p.page == nil {
+		return nil
+	}
+	return p.page.Items
+}
+
+// Pages sends each page to the returned channel, which is closed when there are no
+// more pages or ctx is done. Check Err once the channel is closed.
+func (p *QueryPaginator) Pages(ctx context.Context) <-chan *Response {
+	ch := make(chan *Response)
+	p.Paginator.Pages(ctx, func() bool {
+		select {
+		case ch <- p.page:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}, func() { close(ch) })
+	return ch
+}
@@ -0,0 +1,92 @@
+package query_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/emulator"
+	create_table "github.com/smugmug/godynamo/endpoints/create_table"
+	put_item "github.com/smugmug/godynamo/endpoints/put_item"
+	query "github.com/smugmug/godynamo/endpoints/query"
+	"testing"
+)
+
+// setup starts an emulator with a Thread table holding n items in the forum "F".
+func setup(t *testing.T, n int) (*emulator.Emulator, *conf.AWS_Conf) {
+	e := emulator.New()
+	c := e.Conf()
+	var ct create_table.CreateTable
+	s := `{"TableName":"Thread",
+	 "AttributeDefinitions":[{"AttributeName":"ForumName","AttributeType":"S"},{"AttributeName":"Subject","AttributeType":"S"}],
+	 "KeySchema":[{"AttributeName":"ForumName","KeyType":"HASH"},{"AttributeName":"Subject","KeyType":"RANGE"}],
+	 "ProvisionedThroughput":{"ReadCapacityUnits":5,"WriteCapacityUnits":5}}`
+	if um_err := json.Unmarshal([]byte(s), &ct); um_err != nil {
+		t.Fatal(um_err)
+	}
+	if _, err := ct.DoWithConf(c); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		var p put_item.PutItem
+		s := fmt.Sprintf(`{"TableName":"Thread","Item":{"ForumName":{"S":"F"},"Subject":{"S":"s%02d"},"Views":{"N":"%d"}}}`, i, i)
+		if um_err := json.Unmarshal([]byte(s), &p); um_err != nil {
+			t.Fatal(um_err)
+		}
+		if _, err := p.DoWithConf(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return e, c
+}
+
+func TestQueryPaginator(t *testing.T) {
+	e, c := setup(t, 10)
+	defer e.Close()
+	var q query.Query
+	json.Unmarshal([]byte(`{"TableName":"Thread","Limit":3,"ReturnConsumedCapacity":"TOTAL",
+	 "KeyConditionExpression":"ForumName = :f","ExpressionAttributeValues":{":f":{"S":"F"}}}`), &q)
+	qp := query.NewQueryPaginator(&q, c)
+	pages := 0
+	for qp.Next() {
+		pages++
+	}
+	if qp.Err() != nil || pages != 4 || qp.Count() != 10 || qp.ConsumedCapacity().CapacityUnits == 0 {
+		t.Errorf("unexpected pagination: %d pages, %d items, %v", pages, qp.Count(), qp.Err())
+	}
+	if cursor, _ := qp.Cursor(); cursor != "" {
+		t.Errorf("a finished paginator should have no cursor, got %s", cursor)
+	}
+
+	// stop after 5 items and resume from the cursor
+	qp = query.NewQueryPaginator(&q, c)
+	qp.SetItemLimit(5)
+	subjects := make([]string, 0)
+	for qp.Next() {
+		for _, it := range qp.Items() {
+			subjects = append(subjects, it["Subject"].S)
+		}
+	}
+	cursor, err := qp.Cursor()
+	if err != nil || len(subjects) != 5 || cursor == "" {
+		t.Fatalf("expected 5 items and a cursor, got %v %q %v", subjects, cursor, err)
+	}
+	qp = query.NewQueryPaginator(&q, c)
+	if r_err := qp.Resume(cursor); r_err != nil {
+		t.Fatal(r_err)
+	}
+	for qp.Next() {
+		for _, it := range qp.Items() {
+			subjects = append(subjects, it["Subject"].S)
+		}
+	}
+	if len(subjects) != 10 || subjects[5] != "s05" || subjects[9] != "s09" {
+		t.Errorf("resume should continue after the cursor, got %v", subjects)
+	}
+	if query.NewQueryPaginator(&q, c).Resume("bogus!") == nil {
+		t.Errorf("a malformed cursor should not resume")
+	}
+	qp = query.NewQueryPaginator(nil, c)
+	if qp.Err() == nil || qp.Next() {
+		t.Errorf("a nil query should fail")
+	}
+}
@@ -7,11 +7,13 @@
 package query
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/smugmug/godynamo/authreq"
 	"github.com/smugmug/godynamo/aws_const"
 	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/expression"
 	"github.com/smugmug/godynamo/types/attributestoget"
 	"github.com/smugmug/godynamo/types/attributevalue"
 	"github.com/smugmug/godynamo/types/aws_strings"
@@ -49,13 +51,14 @@ type Query struct {
 	ExpressionAttributeValues attributevalue.AttributeValueMap                  `json:",omitempty"`
 	FilterExpression          string                                            `json:",omitempty"`
 	IndexName                 string                                            `json:",omitempty"`
-	KeyConditions             condition.Conditions
-	Limit                     uint64               `json:",omitempty"`
-	ProjectionExpression      string               `json:",omitempty"`
-	QueryFilter               condition.Conditions `json:",omitempty"`
-	ReturnConsumedCapacity    string               `json:",omitempty"`
-	ScanIndexForward          *bool                `json:",omitempty"`
-	Select                    string               `json:",omitempty"`
+	KeyConditionExpression    string                                            `json:",omitempty"`
+	KeyConditions             condition.Conditions                              `json:",omitempty"`
+	Limit                     uint64                                            `json:",omitempty"`
+	ProjectionExpression      string                                            `json:",omitempty"`
+	QueryFilter               condition.Conditions                              `json:",omitempty"`
+	ReturnConsumedCapacity    string                                            `json:",omitempty"`
+	ScanIndexForward          *bool                                             `json:",omitempty"`
+	Select                    string                                            `json:",omitempty"`
 	TableName                 string
 }
 
@@ -89,29 +92,61 @@ func NewResponse() *Response {
 	return r
 }
 
-// These implementations of EndpointReq use a parameterized conf.
+// ValidateExpressions parses the expressions of the request and checks its expression
+// attribute names and values as DynamoDB would, without making the request.
+func (query *Query) ValidateExpressions() error {
+	if query == nil {
+		return errors.New("query.(Query)ValidateExpressions: receiver is nil")
+	}
+	e := expression.Expressions{
+		FilterExpression:          query.FilterExpression,
+		KeyConditionExpression:    query.KeyConditionExpression,
+		ProjectionExpression:      query.ProjectionExpression,
+		ExpressionAttributeNames:  query.ExpressionAttributeNames,
+		ExpressionAttributeValues: query.ExpressionAttributeValues,
+	}
+	return e.Validate()
+}
 
-func (query *Query) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+// These implementations of EndpointReq use a parameterized conf and context.
+
+func (query *Query) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
 	if query == nil {
-		return nil, 0, errors.New("query.(Query)EndpointReqWithConf: receiver is nil")
+		return nil, 0, errors.New("query.(Query)EndpointReqWithContext: receiver is nil")
 	}
 	if !conf.IsValid(c) {
-		return nil, 0, errors.New("query.EndpointReqWithConf: c is not valid")
+		return nil, 0, errors.New("query.EndpointReqWithContext: c is not valid")
 	}
 	// returns resp_body,code,err
 	reqJSON, json_err := json.Marshal(query)
 	if json_err != nil {
 		return nil, 0, json_err
 	}
-	return authreq.RetryReqJSON_V4WithConf(reqJSON, QUERY_ENDPOINT, c)
+	return authreq.RetryReqJSON_V4WithContext(ctx, reqJSON, QUERY_ENDPOINT, c)
+}
+
+func (req *Request) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("query.(Request)EndpointReqWithContext: receiver is nil")
+	}
+	query := Query(*req)
+	return query.EndpointReqWithContext(ctx, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (query *Query) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if query == nil {
+		return nil, 0, errors.New("query.(Query)EndpointReqWithConf: receiver is nil")
+	}
+	return query.EndpointReqWithContext(context.Background(), c)
 }
 
 func (req *Request) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if req == nil {
 		return nil, 0, errors.New("query.(Request)EndpointReqWithConf: receiver is nil")
 	}
-	query := Query(*req)
-	return query.EndpointReqWithConf(c)
+	return req.EndpointReqWithContext(context.Background(), c)
 }
 
 // These implementations of EndpointReq use the global conf.
@@ -131,6 +166,62 @@ func (req *Request) EndpointReq() ([]byte, int, error) {
 	return query.EndpointReqWithConf(&conf.Vals)
 }
 
+// These implementations of Do perform the request and decode the result into a Response.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded Response. Error responses are returned as a *dynamoerr.APIError.
+func (query *Query) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if query == nil {
+		return nil, errors.New("query.(Query)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("query.(Query)DoWithContext: ctx is nil")
+	}
+	resp := NewResponse()
+	if err := authreq.DoWithContext(ctx, query, QUERY_ENDPOINT, c, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (req *Request) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("query.(Request)DoWithContext: receiver is nil")
+	}
+	query := Query(*req)
+	return query.DoWithContext(ctx, c)
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (query *Query) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if query == nil {
+		return nil, errors.New("query.(Query)DoWithConf: receiver is nil")
+	}
+	return query.DoWithContext(context.Background(), c)
+}
+
+func (req *Request) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("query.(Request)DoWithConf: receiver is nil")
+	}
+	return req.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (query *Query) Do() (*Response, error) {
+	if query == nil {
+		return nil, errors.New("query.(Query)Do: receiver is nil")
+	}
+	return query.DoWithConf(&conf.Vals)
+}
+
+func (req *Request) Do() (*Response, error) {
+	if req == nil {
+		return nil, errors.New("query.(Request)Do: receiver is nil")
+	}
+	return req.DoWithConf(&conf.Vals)
+}
+
 // ValidOp determines if an operation is in the approved list.
 func ValidOp(op string) bool {
 	return (op == OP_EQ ||
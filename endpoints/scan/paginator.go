@@ -0,0 +1,114 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"github.com/smugmug/godynamo/conf"
+	ep "github.com/smugmug/godynamo/endpoint"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/item"
+)
+
+// ScanPaginator follows the pages of a Scan by passing the LastEvaluatedKey of each
+// page as the ExclusiveStartKey of the next. Next, NextWithContext, SetItemLimit, Err,
+// Count, ScannedCount and ConsumedCapacity are those of ep.Paginator.
+//
+// example use:
+//
+//	p := scan.NewScanPaginator(s, c)
+//	for p.Next() {
+//		for _, it := range p.Items() {
+//			...
+//		}
+//	}
+//	if p.Err() != nil {
+//		...
+//	}
+type ScanPaginator struct {
+	*ep.Paginator
+	scan Scan
+	c    *conf.AWS_Conf
+	page *Response
+}
+
+// NewScanPaginator returns a paginator for s that sends its requests with c. s is
+// copied, so later changes to it are not seen by the paginator.
+func NewScanPaginator(s *Scan, c *conf.AWS_Conf) *ScanPaginator {
+	p := &ScanPaginator{c: c}
+	if s == nil {
+		p.Paginator = ep.NewPaginator(nil, func(context.Context) (ep.PageInfo, error) {
+			return ep.PageInfo{}, errors.New("scan.NewScanPaginator: s is nil")
+		})
+		// fail at once, so that Err reports the nil request before Next is called
+		p.Paginator.Next()
+		return p
+	}
+	p.scan = *s
+	p.Paginator = ep.NewPaginator(&p.scan.Limit, func(ctx context.Context) (ep.PageInfo, error) {
+		resp, err := p.scan.DoWithContext(ctx, p.c)
+		if err != nil {
+			return ep.PageInfo{}, err
+		}
+		p.page = resp
+		p.scan.ExclusiveStartKey = resp.LastEvaluatedKey
+		return ep.PageInfo{Count: resp.Count, ScannedCount: resp.ScannedCount,
+			ConsumedCapacity: resp.ConsumedCapacity, More: len(resp.LastEvaluatedKey) != 0}, nil
+	})
+	return p
+}
+
+// Resume starts the paginator from a cursor returned by Cursor. It must be called
+// before the first call to Next.
+func (p *ScanPaginator) Resume(cursor string) error {
+	if p == nil {
+		return errors.New("scan.(ScanPaginator)Resume: receiver is nil")
+	}
+	k := attributevalue.NewAttributeValueMap()
+	if r_err := p.Paginator.Resume(cursor, &k); r_err != nil {
+		return r_err
+	}
+	if len(k) != 0 {
+		p.scan.ExclusiveStartKey = k
+	}
+	return nil
+}
+
+// Cursor returns an opaque string from which Resume continues after the last page read,
+// or "" if there are no more pages.
+func (p *ScanPaginator) Cursor() (string, error) {
+	if p == nil {
+		return "", errors.New("scan.(ScanPaginator)Cursor: receiver is nil")
+	}
+	return p.Paginator.Cursor(p.scan.ExclusiveStartKey)
+}
+
+// Page returns the last page read by Next.
+func (p *ScanPaginator) Page() *Response {
+	if p == nil {
+		return nil
+	}
+	return p.page
+}
+
+// Items returns the items of the last page read by Next.
+func (p *ScanPaginator) Items() []item.Item {
+	if p == nil || p.page == nil {
+		return nil
+	}
+	return p.page.Items
+}
+
+// Pages sends each page to the returned channel, which is closed when there are no
+// more pages or ctx is done. Check Err once the channel is closed.
+func (p *ScanPaginator) Pages(ctx context.Context) <-chan *Response {
+	ch := make(chan *Response)
+	p.Paginator.Pages(ctx, func() bool {
+		select {
+		case ch <- p.page:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}, func() { close(ch) })
+	return ch
+}
@@ -0,0 +1,90 @@
+package scan_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/emulator"
+	create_table "github.com/smugmug/godynamo/endpoints/create_table"
+	put_item "github.com/smugmug/godynamo/endpoints/put_item"
+	scan "github.com/smugmug/godynamo/endpoints/scan"
+	"testing"
+)
+
+// setup starts an emulator with a Thread table holding n items in the forum "F".
+func setup(t *testing.T, n int) (*emulator.Emulator, *conf.AWS_Conf) {
+	e := emulator.New()
+	c := e.Conf()
+	var ct create_table.CreateTable
+	s := `{"TableName":"Thread",
+	 "AttributeDefinitions":[{"AttributeName":"ForumName","AttributeType":"S"},{"AttributeName":"Subject","AttributeType":"S"}],
+	 "KeySchema":[{"AttributeName":"ForumName","KeyType":"HASH"},{"AttributeName":"Subject","KeyType":"RANGE"}],
+	 "ProvisionedThroughput":{"ReadCapacityUnits":5,"WriteCapacityUnits":5}}`
+	if um_err := json.Unmarshal([]byte(s), &ct); um_err != nil {
+		t.Fatal(um_err)
+	}
+	if _, err := ct.DoWithConf(c); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		var p put_item.PutItem
+		s := fmt.Sprintf(`{"TableName":"Thread","Item":{"ForumName":{"S":"F"},"Subject":{"S":"s%02d"},"Views":{"N":"%d"}}}`, i, i)
+		if um_err := json.Unmarshal([]byte(s), &p); um_err != nil {
+			t.Fatal(um_err)
+		}
+		if _, err := p.DoWithConf(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return e, c
+}
+
+func TestScanPaginator(t *testing.T) {
+	e, c := setup(t, 10)
+	defer e.Close()
+	var s scan.Scan
+	json.Unmarshal([]byte(`{"TableName":"Thread","Limit":4,"FilterExpression":"#v BETWEEN :lo AND :hi",
+	 "ExpressionAttributeNames":{"#v":"Views"},"ExpressionAttributeValues":{":lo":{"N":"2"},":hi":{"N":"7"}}}`), &s)
+	sp := scan.NewScanPaginator(&s, c)
+	items := 0
+	for page := range sp.Pages(context.Background()) {
+		items += len(page.Items)
+	}
+	if sp.Err() != nil || items != 6 || sp.Count() != 6 || sp.ScannedCount() != 10 {
+		t.Errorf("unexpected scan: %d items, %d scanned, %v", items, sp.ScannedCount(), sp.Err())
+	}
+
+	// stop after the first page and resume from the cursor
+	sp = scan.NewScanPaginator(&s, c)
+	if !sp.Next() {
+		t.Fatal(sp.Err())
+	}
+	cursor, err := sp.Cursor()
+	if err != nil || cursor == "" {
+		t.Fatalf("expected a cursor, got %q %v", cursor, err)
+	}
+	sp = scan.NewScanPaginator(&s, c)
+	if r_err := sp.Resume(cursor); r_err != nil {
+		t.Fatal(r_err)
+	}
+	for sp.Next() {
+	}
+	if sp.Err() != nil || sp.ScannedCount() != 6 || sp.Count() != 4 {
+		t.Errorf("resume should scan the 6 items after the first page, got %d scanned, %d items, %v",
+			sp.ScannedCount(), sp.Count(), sp.Err())
+	}
+
+	// a consumer that stops reading cancels the paginator
+	sp = scan.NewScanPaginator(&s, c)
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := sp.Pages(ctx)
+	<-ch
+	cancel()
+	for range ch {
+	}
+	if !errors.Is(sp.Err(), context.Canceled) {
+		t.Errorf("expected the paginator to stop with ctx, got %v", sp.Err())
+	}
+}
@@ -0,0 +1,304 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/ratelimit"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/aws_strings"
+	"github.com/smugmug/godynamo/types/capacity"
+	"github.com/smugmug/godynamo/types/item"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// the most segments DynamoDB allows in a parallel scan
+	MAX_TOTAL_SEGMENTS = 1000000
+	// how often a checkpoint file is written unless set
+	CHECKPOINT_INTERVAL = 10 * time.Second
+)
+
+// SegmentProgress describes how far the scan of one segment has gone.
+type SegmentProgress struct {
+	Segment      uint64
+	Pages        uint64
+	Count        uint64
+	ScannedCount uint64
+	// the capacity units consumed, if known
+	ConsumedCapacity capacity.ConsumedCapacityUnit
+	// where the scan of the segment continues, empty once Done
+	LastEvaluatedKey attributevalue.AttributeValueMap
+	Done             bool
+}
+
+// ParallelScanner runs a Scan as several segments at once. The zero value scans with
+// conf.Vals and no rate limit.
+type ParallelScanner struct {
+	// The conf requests are sent with. If nil, conf.Vals is used.
+	Conf *conf.AWS_Conf
+	// The read capacity units per second to consume across all segments; 0 for no limit.
+	// Requests are delayed as needed after the capacity of each page is known, so the
+	// budget may be exceeded by a page per segment.
+	ReadCapacity float64
+	// If set, Progress is called after each page with the progress of its segment.
+	// Calls are not concurrent.
+	Progress func(SegmentProgress)
+	// If set, the progress of every segment is saved to this JSON file each
+	// CheckpointInterval and when the scan stops. If the file exists when the scan
+	// starts, the scan resumes from it: finished segments are skipped and the others
+	// continue after their saved LastEvaluatedKey. Items of the pages read after the
+	// last checkpoint are passed to fn again.
+	CheckpointFile string
+	// How often the checkpoint file is written; CHECKPOINT_INTERVAL if 0.
+	CheckpointInterval time.Duration
+}
+
+// Checkpoint is the content of a checkpoint file.
+type Checkpoint struct {
+	TableName     string
+	TotalSegments uint64
+	Segments      []SegmentProgress
+	Updated       time.Time
+}
+
+// LoadCheckpoint reads a checkpoint file written by a ParallelScanner.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	b, read_err := os.ReadFile(path)
+	if read_err != nil {
+		return nil, read_err
+	}
+	cp := new(Checkpoint)
+	if um_err := json.Unmarshal(b, cp); um_err != nil {
+		e := fmt.Sprintf("scan.LoadCheckpoint: cannot unmarshal %s: %s", path, um_err.Error())
+		return nil, errors.New(e)
+	}
+	if cp.TotalSegments == 0 || uint64(len(cp.Segments)) != cp.TotalSegments {
+		return nil, errors.New("scan.LoadCheckpoint: " + path + " does not describe every segment")
+	}
+	return cp, nil
+}
+
+// save writes cp to path, replacing the file only once it is completely written.
+func (cp *Checkpoint) save(path string) error {
+	b, json_err := json.Marshal(cp)
+	if json_err != nil {
+		return json_err
+	}
+	tmp := path + ".tmp"
+	f, f_err := os.Create(tmp)
+	if f_err != nil {
+		return f_err
+	}
+	_, w_err := f.Write(b)
+	if w_err == nil {
+		w_err = f.Sync()
+	}
+	if c_err := f.Close(); w_err == nil {
+		w_err = c_err
+	}
+	if w_err != nil {
+		os.Remove(tmp)
+		return w_err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ParallelScan is the same as (*ParallelScanner)Scan with the zero ParallelScanner.
+func ParallelScan(ctx context.Context, s *Scan, segments, workers int, fn func(item.Item) error) ([]SegmentProgress, error) {
+	var ps ParallelScanner
+	return ps.Scan(ctx, s, segments, workers, fn)
+}
+
+// Scan scans s as segments segments, at most workers at a time (all at once if workers
+// is 0), and calls fn for every item. fn is called concurrently from the workers. The
+// first error returned by fn or by a request stops all segments and is returned, along
+// with the progress of each segment. If ctx is done first, its error is returned. The
+// Segment, TotalSegments and ExclusiveStartKey of s are ignored.
+func (ps *ParallelScanner) Scan(ctx context.Context, s *Scan, segments, workers int, fn func(item.Item) error) ([]SegmentProgress, error) {
+	if ps == nil {
+		return nil, errors.New("scan.(ParallelScanner)Scan: receiver is nil")
+	}
+	if ctx == nil || s == nil || fn == nil {
+		return nil, errors.New("scan.(ParallelScanner)Scan: ctx, s and fn must be set")
+	}
+	if segments < 1 || segments > MAX_TOTAL_SEGMENTS {
+		e := fmt.Sprintf("scan.(ParallelScanner)Scan: segments must be between 1 and %d", MAX_TOTAL_SEGMENTS)
+		return nil, errors.New(e)
+	}
+	progress := make([]SegmentProgress, segments)
+	for i := range progress {
+		progress[i].Segment = uint64(i)
+	}
+	if ps.CheckpointFile != "" {
+		cp, load_err := LoadCheckpoint(ps.CheckpointFile)
+		switch {
+		case load_err == nil:
+			if cp.TableName != s.TableName || cp.TotalSegments != uint64(segments) {
+				e := fmt.Sprintf("scan.(ParallelScanner)Scan: %s is for %d segments of %s",
+					ps.CheckpointFile, cp.TotalSegments, cp.TableName)
+				return nil, errors.New(e)
+			}
+			progress = cp.Segments
+		case !errors.Is(load_err, os.ErrNotExist):
+			return nil, load_err
+		}
+	}
+	return ps.run(ctx, s, progress, workers, fn)
+}
+
+// run scans the segments of progress that are not done, starting each after its
+// LastEvaluatedKey.
+func (ps *ParallelScanner) run(ctx context.Context, s *Scan, progress []SegmentProgress, workers int,
+	fn func(item.Item) error) ([]SegmentProgress, error) {
+	c := ps.Conf
+	if c == nil {
+		c = &conf.Vals
+	}
+	if workers <= 0 || workers > len(progress) {
+		workers = len(progress)
+	}
+	var l *ratelimit.Limiter
+	req := *s
+	req.TotalSegments = uint64(len(progress))
+	if ps.ReadCapacity > 0 {
+		l = ratelimit.New(ps.ReadCapacity, 0)
+		if req.ReturnConsumedCapacity == "" {
+			req.ReturnConsumedCapacity = aws_strings.TOTAL
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var lock sync.Mutex
+	var first error
+	fail := func(err error) {
+		lock.Lock()
+		if first == nil {
+			first = err
+		}
+		lock.Unlock()
+		cancel()
+	}
+	// report copies the progress of a segment after a page, holding lock
+	report := func(sp *SegmentProgress, page *Response) {
+		lock.Lock()
+		defer lock.Unlock()
+		sp.Pages++
+		sp.Count += page.Count
+		sp.ScannedCount += page.ScannedCount
+		if page.ConsumedCapacity != nil {
+			sp.ConsumedCapacity += page.ConsumedCapacity.CapacityUnits
+		}
+		sp.LastEvaluatedKey = page.LastEvaluatedKey
+		sp.Done = len(sp.LastEvaluatedKey) == 0
+		if ps.Progress != nil {
+			ps.Progress(*sp)
+		}
+	}
+	// checkpoint saves the progress of all segments
+	checkpoint := func() {
+		lock.Lock()
+		cp := &Checkpoint{TableName: req.TableName, TotalSegments: req.TotalSegments,
+			Segments: append([]SegmentProgress(nil), progress...), Updated: time.Now()}
+		lock.Unlock()
+		if save_err := cp.save(ps.CheckpointFile); save_err != nil {
+			fail(save_err)
+		}
+	}
+	saved := make(chan struct{})
+	stop_saving := make(chan struct{})
+	if ps.CheckpointFile != "" {
+		interval := ps.CheckpointInterval
+		if interval <= 0 {
+			interval = CHECKPOINT_INTERVAL
+		}
+		go func() {
+			defer close(saved)
+			tick := time.NewTicker(interval)
+			defer tick.Stop()
+			for {
+				select {
+				case <-stop_saving:
+					return
+				case <-tick.C:
+					checkpoint()
+				}
+			}
+		}()
+	} else {
+		close(saved)
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				sp := &progress[i]
+				seg := req
+				seg.Segment = sp.Segment
+				seg.ExclusiveStartKey = sp.LastEvaluatedKey
+				p := NewScanPaginator(&seg, c)
+				for {
+					if l != nil {
+						if w_err := l.Wait(ctx); w_err != nil {
+							fail(w_err)
+							break
+						}
+					}
+					if !p.NextWithContext(ctx) {
+						break
+					}
+					if l != nil && p.Page().ConsumedCapacity != nil {
+						l.Charge(float64(p.Page().ConsumedCapacity.CapacityUnits))
+					}
+					fn_failed := false
+					for _, it := range p.Items() {
+						if fn_err := fn(it); fn_err != nil {
+							fail(fn_err)
+							fn_failed = true
+							break
+						}
+					}
+					if fn_failed {
+						break
+					}
+					report(sp, p.Page())
+				}
+				if p.Err() != nil {
+					fail(p.Err())
+				}
+			}
+		}()
+	}
+	for i := range progress {
+		if progress[i].Done {
+			continue
+		}
+		// select picks at random when a worker is also ready, so check ctx first
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case next <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(next)
+	wg.Wait()
+	// segments skipped because ctx was done before a worker took them fail nothing
+	if first == nil && ctx.Err() != nil {
+		first = ctx.Err()
+	}
+	close(stop_saving)
+	<-saved
+	if ps.CheckpointFile != "" {
+		checkpoint()
+	}
+	return progress, first
+}
@@ -0,0 +1,182 @@
+package scan_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	scan "github.com/smugmug/godynamo/endpoints/scan"
+	"github.com/smugmug/godynamo/types/item"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParallelScan(t *testing.T) {
+	e, c := setup(t, 10)
+	defer e.Close()
+	var s scan.Scan
+	json.Unmarshal([]byte(`{"TableName":"Thread","Limit":2}`), &s)
+	var lock sync.Mutex
+	seen := make(map[string]bool)
+	pages := 0
+	ps := scan.ParallelScanner{Conf: c, ReadCapacity: 50, Progress: func(sp scan.SegmentProgress) {
+		pages++
+	}}
+	start := time.Now()
+	progress, err := ps.Scan(context.Background(), &s, 3, 2, func(it item.Item) error {
+		lock.Lock()
+		seen[it["Subject"].S] = true
+		lock.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := uint64(0)
+	for _, sp := range progress {
+		if !sp.Done || sp.ConsumedCapacity == 0 {
+			t.Errorf("unexpected progress %+v", sp)
+		}
+		count += sp.Count
+	}
+	if len(seen) != 10 || count != 10 {
+		t.Errorf("expected 10 items, saw %d and counted %d", len(seen), count)
+	}
+	// a page consumes a unit in the emulator, so the later pages wait 20ms each
+	if elapsed := time.Since(start); elapsed < time.Duration(pages-3)*20*time.Millisecond {
+		t.Errorf("%d pages took %v, expected the read capacity to be limited", pages, elapsed)
+	}
+
+	stop := errors.New("stop")
+	_, err = scan.ParallelScan(context.Background(), &s, 2, 0, func(it item.Item) error {
+		return stop
+	})
+	if err == nil {
+		t.Errorf("ParallelScan with conf.Vals should fail")
+	}
+	ps = scan.ParallelScanner{Conf: c}
+	progress, err = ps.Scan(context.Background(), &s, 2, 0, func(it item.Item) error {
+		return stop
+	})
+	if err != stop || progress[0].Done || progress[1].Done {
+		t.Errorf("the first error should stop the scan, got %v", err)
+	}
+}
+
+func TestParallelScanCanceled(t *testing.T) {
+	e, c := setup(t, 10)
+	defer e.Close()
+	var s scan.Scan
+	json.Unmarshal([]byte(`{"TableName":"Thread","Limit":2}`), &s)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ps := scan.ParallelScanner{Conf: c}
+	n := 0
+	progress, err := ps.Scan(ctx, &s, 8, 1, func(it item.Item) error {
+		n++
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("a canceled scan should return the ctx error, got %v", err)
+	}
+	for _, sp := range progress {
+		if sp.Done {
+			t.Errorf("a canceled scan should finish no segment, got %+v", sp)
+		}
+	}
+	if n != 0 {
+		t.Errorf("a canceled scan should read no items, got %d", n)
+	}
+}
+
+func TestResumableScan(t *testing.T) {
+	e, c := setup(t, 10)
+	defer e.Close()
+	var s scan.Scan
+	json.Unmarshal([]byte(`{"TableName":"Thread","Limit":2}`), &s)
+	file := filepath.Join(t.TempDir(), "scan.json")
+	ps := scan.ParallelScanner{Conf: c, CheckpointFile: file, CheckpointInterval: time.Millisecond}
+	// crash on the fifth item, after two pages have been processed
+	stop := errors.New("stop")
+	n := 0
+	_, err := ps.Scan(context.Background(), &s, 2, 1, func(it item.Item) error {
+		if n++; n == 5 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Fatalf("expected the scan to stop, got %v", err)
+	}
+	cp, err := scan.LoadCheckpoint(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.TableName != "Thread" || cp.TotalSegments != 2 || cp.Segments[0].Count != 4 || cp.Segments[0].Done {
+		t.Errorf("unexpected checkpoint %+v", cp)
+	}
+	if _, err = ps.Scan(context.Background(), &s, 3, 1, func(item.Item) error { return nil }); err == nil {
+		t.Errorf("a checkpoint for 2 segments should not resume 3")
+	}
+
+	n = 0
+	progress, err := ps.Scan(context.Background(), &s, 2, 1, func(it item.Item) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 6 || progress[0].Count+progress[1].Count != 10 || !progress[0].Done || !progress[1].Done {
+		t.Errorf("expected to resume with the 6 remaining items, got %d: %+v", n, progress)
+	}
+}
+
+func TestResumableScanCanceled(t *testing.T) {
+	e, c := setup(t, 10)
+	defer e.Close()
+	var s scan.Scan
+	json.Unmarshal([]byte(`{"TableName":"Thread","Limit":2}`), &s)
+	file := filepath.Join(t.TempDir(), "scan.json")
+	ps := scan.ParallelScanner{Conf: c, CheckpointFile: file}
+	// cancel on the fifth item; the rest of its page is still processed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	seen := make(map[string]int)
+	_, err := ps.Scan(ctx, &s, 2, 1, func(it item.Item) error {
+		if seen[it["Subject"].S]++; len(seen) == 5 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("a canceled scan should return the ctx error, got %v", err)
+	}
+	cp, err := scan.LoadCheckpoint(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Segments[0].Done && cp.Segments[1].Done {
+		t.Errorf("a canceled scan should leave segments to resume, got %+v", cp)
+	}
+	if cp.Segments[0].Count+cp.Segments[1].Count != uint64(len(seen)) {
+		t.Errorf("the checkpoint should count the %d items processed, got %+v", len(seen), cp)
+	}
+
+	progress, err := ps.Scan(context.Background(), &s, 2, 1, func(it item.Item) error {
+		seen[it["Subject"].S]++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 10 || !progress[0].Done || !progress[1].Done {
+		t.Errorf("resume should finish the scan, saw %d items: %+v", len(seen), progress)
+	}
+	for subject, n := range seen {
+		if n != 1 {
+			t.Errorf("%s was processed %d times", subject, n)
+		}
+	}
+}
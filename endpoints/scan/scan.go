@@ -7,11 +7,13 @@
 package scan
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/smugmug/godynamo/authreq"
 	"github.com/smugmug/godynamo/aws_const"
 	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/expression"
 	"github.com/smugmug/godynamo/types/attributestoget"
 	"github.com/smugmug/godynamo/types/attributevalue"
 	"github.com/smugmug/godynamo/types/aws_strings"
@@ -52,9 +54,9 @@ type Scan struct {
 	Limit                     uint64                                            `json:",omitempty"`
 	ProjectionExpression      string                                            `json:",omitempty"`
 	ReturnConsumedCapacity    string                                            `json:",omitempty"`
-	ScanFilter                condition.Conditions
-	Segment                   uint64 `json:",omitempty"`
-	Select                    string `json:",omitempty"`
+	ScanFilter                condition.Conditions                              `json:",omitempty"`
+	Segment                   uint64                                            `json:",omitempty"`
+	Select                    string                                            `json:",omitempty"`
 	TableName                 string
 	TotalSegments             uint64 `json:",omitempty"`
 }
@@ -87,29 +89,65 @@ func NewResponse() *Response {
 	return r
 }
 
-// These implementations of EndpointReq use a parameterized conf.
+// ValidateExpressions parses the expressions of the request and checks its expression
+// attribute names and values as DynamoDB would, without making the request.
+func (scan *Scan) ValidateExpressions() error {
+	if scan == nil {
+		return errors.New("scan.(Scan)ValidateExpressions: receiver is nil")
+	}
+	e := expression.Expressions{
+		FilterExpression:          scan.FilterExpression,
+		ProjectionExpression:      scan.ProjectionExpression,
+		ExpressionAttributeNames:  scan.ExpressionAttributeNames,
+		ExpressionAttributeValues: scan.ExpressionAttributeValues,
+	}
+	return e.Validate()
+}
 
-func (scan *Scan) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+// These implementations of EndpointReq use a parameterized conf and context.
+
+func (scan *Scan) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
 	if scan == nil {
-		return nil, 0, errors.New("scan.(Scan)EndpointReqWithConf: receiver is nil")
+		return nil, 0, errors.New("scan.(Scan)EndpointReqWithContext: receiver is nil")
 	}
 	if !conf.IsValid(c) {
-		return nil, 0, errors.New("scan.EndpointReqWithConf: c is not valid")
+		return nil, 0, errors.New("scan.EndpointReqWithContext: c is not valid")
 	}
 	// returns resp_body,code,err
 	reqJSON, json_err := json.Marshal(scan)
 	if json_err != nil {
 		return nil, 0, json_err
 	}
-	return authreq.RetryReqJSON_V4WithConf(reqJSON, SCAN_ENDPOINT, c)
+	return authreq.RetryReqJSON_V4WithContext(ctx, reqJSON, SCAN_ENDPOINT, c)
 }
 
-func (req *Request) EndpointWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+func (req *Request) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
 	if req == nil {
-		return nil, 0, errors.New("scan.(Request)EndpointReqWithConf: receiver is nil")
+		return nil, 0, errors.New("scan.(Request)EndpointReqWithContext: receiver is nil")
 	}
 	scan := Scan(*req)
-	return scan.EndpointReqWithConf(c)
+	return scan.EndpointReqWithContext(ctx, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (scan *Scan) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if scan == nil {
+		return nil, 0, errors.New("scan.(Scan)EndpointReqWithConf: receiver is nil")
+	}
+	return scan.EndpointReqWithContext(context.Background(), c)
+}
+
+func (req *Request) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("scan.(Request)EndpointReqWithConf: receiver is nil")
+	}
+	return req.EndpointReqWithContext(context.Background(), c)
+}
+
+// EndpointWithConf is a misnamed alias of EndpointReqWithConf, kept for backwards compatibility.
+func (req *Request) EndpointWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	return req.EndpointReqWithConf(c)
 }
 
 // These implementations of EndpointReq use the global conf.
@@ -129,6 +167,62 @@ func (req *Request) EndpointReq() ([]byte, int, error) {
 	return scan.EndpointReqWithConf(&conf.Vals)
 }
 
+// These implementations of Do perform the request and decode the result into a Response.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded Response. Error responses are returned as a *dynamoerr.APIError.
+func (scan *Scan) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if scan == nil {
+		return nil, errors.New("scan.(Scan)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("scan.(Scan)DoWithContext: ctx is nil")
+	}
+	resp := NewResponse()
+	if err := authreq.DoWithContext(ctx, scan, SCAN_ENDPOINT, c, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (req *Request) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("scan.(Request)DoWithContext: receiver is nil")
+	}
+	scan := Scan(*req)
+	return scan.DoWithContext(ctx, c)
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (scan *Scan) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if scan == nil {
+		return nil, errors.New("scan.(Scan)DoWithConf: receiver is nil")
+	}
+	return scan.DoWithContext(context.Background(), c)
+}
+
+func (req *Request) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("scan.(Request)DoWithConf: receiver is nil")
+	}
+	return req.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (scan *Scan) Do() (*Response, error) {
+	if scan == nil {
+		return nil, errors.New("scan.(Scan)Do: receiver is nil")
+	}
+	return scan.DoWithConf(&conf.Vals)
+}
+
+func (req *Request) Do() (*Response, error) {
+	if req == nil {
+		return nil, errors.New("scan.(Request)Do: receiver is nil")
+	}
+	return req.DoWithConf(&conf.Vals)
+}
+
 // ValidOp determines if an operation is in the approved list.
 func ValidOp(op string) bool {
 	return (op == OP_EQ ||
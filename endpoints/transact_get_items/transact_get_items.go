@@ -0,0 +1,243 @@
+// Support for the DynamoDB TransactGetItems endpoint.
+//
+// A TransactGetItems request reads up to MAX_ACTIONS items as one consistent snapshot.
+// The Responses are in the order of the TransactItems; the Item of a Response is empty
+// if the item does not exist. If the transaction is canceled, for example because one
+// of the items is being written by a concurrent transaction, Do returns a
+// *dynamoerr.APIError whose CancellationReasons give the outcome of each read in order.
+//
+// example use:
+//
+//	req := transact_get_items.NewTransactGetItems()
+//	get := transact_get_items.NewGet()
+//	get.TableName = "Thread"
+//	get.Key["ForumName"] = ...
+//	req.TransactItems = append(req.TransactItems, transact_get_items.TransactGetItem{Get: get})
+//	resp, err := req.DoWithConf(c)
+//
+package transact_get_items
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/authreq"
+	"github.com/smugmug/godynamo/aws_const"
+	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/expression"
+	"github.com/smugmug/godynamo/types/capacity"
+	"github.com/smugmug/godynamo/types/expressionattributenames"
+	"github.com/smugmug/godynamo/types/item"
+)
+
+const (
+	ENDPOINT_NAME             = "TransactGetItems"
+	TRANSACTGETITEMS_ENDPOINT = aws_const.ENDPOINT_PREFIX + ENDPOINT_NAME
+	// the most reads a transaction may contain
+	MAX_ACTIONS = 100
+)
+
+// Get reads the item with Key from TableName.
+type Get struct {
+	ExpressionAttributeNames expressionattributenames.ExpressionAttributeNames `json:",omitempty"`
+	Key                      item.Key
+	ProjectionExpression     string `json:",omitempty"`
+	TableName                string
+}
+
+func NewGet() *Get {
+	g := new(Get)
+	g.ExpressionAttributeNames = expressionattributenames.NewExpressionAttributeNames()
+	g.Key = item.NewKey()
+	return g
+}
+
+// TransactGetItem is one read of a transaction.
+type TransactGetItem struct {
+	Get *Get
+}
+
+type TransactGetItems struct {
+	ReturnConsumedCapacity string `json:",omitempty"`
+	TransactItems          []TransactGetItem
+}
+
+func NewTransactGetItems() *TransactGetItems {
+	t := new(TransactGetItems)
+	t.TransactItems = make([]TransactGetItem, 0)
+	return t
+}
+
+type Request TransactGetItems
+
+// ItemResponse holds the item read by one Get.
+type ItemResponse struct {
+	Item item.Item
+}
+
+type Response struct {
+	ConsumedCapacity []capacity.ConsumedCapacity
+	Responses        []ItemResponse
+}
+
+func NewResponse() *Response {
+	r := new(Response)
+	r.ConsumedCapacity = make([]capacity.ConsumedCapacity, 0)
+	r.Responses = make([]ItemResponse, 0)
+	return r
+}
+
+// Validate checks the number of reads and that each has a Get with a TableName.
+func (transact_get_items *TransactGetItems) Validate() error {
+	if transact_get_items == nil {
+		return errors.New("transact_get_items.(TransactGetItems)Validate: receiver is nil")
+	}
+	l := len(transact_get_items.TransactItems)
+	if l == 0 || l > MAX_ACTIONS {
+		e := fmt.Sprintf("transact_get_items.(TransactGetItems)Validate: "+
+			"a transaction must have between 1 and %d reads, not %d", MAX_ACTIONS, l)
+		return errors.New(e)
+	}
+	for i, t := range transact_get_items.TransactItems {
+		if t.Get == nil || t.Get.TableName == "" {
+			e := fmt.Sprintf("transact_get_items.(TransactGetItems)Validate: "+
+				"TransactItems[%d]: a Get with a TableName must be set", i)
+			return errors.New(e)
+		}
+	}
+	return nil
+}
+
+// ValidateExpressions parses the projection of every read of the request and checks
+// their expression attribute names as DynamoDB would, without making the request.
+func (transact_get_items *TransactGetItems) ValidateExpressions() error {
+	if v_err := transact_get_items.Validate(); v_err != nil {
+		return v_err
+	}
+	for i, t := range transact_get_items.TransactItems {
+		e := expression.Expressions{
+			ProjectionExpression:     t.Get.ProjectionExpression,
+			ExpressionAttributeNames: t.Get.ExpressionAttributeNames,
+		}
+		if e_err := e.Validate(); e_err != nil {
+			return errors.New(fmt.Sprintf("TransactItems[%d]: %s", i, e_err.Error()))
+		}
+	}
+	return nil
+}
+
+// These implementations of EndpointReq use a parameterized conf and context.
+
+func (transact_get_items *TransactGetItems) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if transact_get_items == nil {
+		return nil, 0, errors.New("transact_get_items.(TransactGetItems)EndpointReqWithContext: receiver is nil")
+	}
+	if !conf.IsValid(c) {
+		return nil, 0, errors.New("transact_get_items.EndpointReqWithContext: c is not valid")
+	}
+	// returns resp_body,code,err
+	reqJSON, json_err := json.Marshal(transact_get_items)
+	if json_err != nil {
+		return nil, 0, json_err
+	}
+	return authreq.RetryReqJSON_V4WithContext(ctx, reqJSON, TRANSACTGETITEMS_ENDPOINT, c)
+}
+
+func (req *Request) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("transact_get_items.(Request)EndpointReqWithContext: receiver is nil")
+	}
+	transact_get_items := TransactGetItems(*req)
+	return transact_get_items.EndpointReqWithContext(ctx, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (transact_get_items *TransactGetItems) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if transact_get_items == nil {
+		return nil, 0, errors.New("transact_get_items.(TransactGetItems)EndpointReqWithConf: receiver is nil")
+	}
+	return transact_get_items.EndpointReqWithContext(context.Background(), c)
+}
+
+func (req *Request) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("transact_get_items.(Request)EndpointReqWithConf: receiver is nil")
+	}
+	return req.EndpointReqWithContext(context.Background(), c)
+}
+
+// These implementations of EndpointReq use the global conf.
+
+func (transact_get_items *TransactGetItems) EndpointReq() ([]byte, int, error) {
+	if transact_get_items == nil {
+		return nil, 0, errors.New("transact_get_items.(TransactGetItems)EndpointReq: receiver is nil")
+	}
+	return transact_get_items.EndpointReqWithConf(&conf.Vals)
+}
+
+func (req *Request) EndpointReq() ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("transact_get_items.(Request)EndpointReq: receiver is nil")
+	}
+	transact_get_items := TransactGetItems(*req)
+	return transact_get_items.EndpointReqWithConf(&conf.Vals)
+}
+
+// These implementations of Do perform the request and decode the result into a Response.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded Response. Error responses are returned as a *dynamoerr.APIError, with
+// CancellationReasons set if the transaction was canceled.
+func (transact_get_items *TransactGetItems) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if transact_get_items == nil {
+		return nil, errors.New("transact_get_items.(TransactGetItems)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("transact_get_items.(TransactGetItems)DoWithContext: ctx is nil")
+	}
+	resp := NewResponse()
+	if err := authreq.DoWithContext(ctx, transact_get_items, TRANSACTGETITEMS_ENDPOINT, c, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (req *Request) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("transact_get_items.(Request)DoWithContext: receiver is nil")
+	}
+	transact_get_items := TransactGetItems(*req)
+	return transact_get_items.DoWithContext(ctx, c)
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (transact_get_items *TransactGetItems) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if transact_get_items == nil {
+		return nil, errors.New("transact_get_items.(TransactGetItems)DoWithConf: receiver is nil")
+	}
+	return transact_get_items.DoWithContext(context.Background(), c)
+}
+
+func (req *Request) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("transact_get_items.(Request)DoWithConf: receiver is nil")
+	}
+	return req.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (transact_get_items *TransactGetItems) Do() (*Response, error) {
+	if transact_get_items == nil {
+		return nil, errors.New("transact_get_items.(TransactGetItems)Do: receiver is nil")
+	}
+	return transact_get_items.DoWithConf(&conf.Vals)
+}
+
+func (req *Request) Do() (*Response, error) {
+	if req == nil {
+		return nil, errors.New("transact_get_items.(Request)Do: receiver is nil")
+	}
+	return req.DoWithConf(&conf.Vals)
+}
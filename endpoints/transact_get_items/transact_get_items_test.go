@@ -0,0 +1,56 @@
+package transact_get_items
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestNil(t *testing.T) {
+	tg := NewTransactGetItems()
+	_, _, err := tg.EndpointReqWithConf(nil)
+	if err == nil {
+		t.Errorf("nil conf should result in error")
+	}
+}
+
+func TestRequestUnmarshal(t *testing.T) {
+	s := []string{
+		`{"TransactItems":[{"Get":{"TableName":"Orders","Key":{"OrderId":{"S":"1ac8b1b0"}},"ProjectionExpression":"#s","ExpressionAttributeNames":{"#s":"OrderStatus"}}},{"Get":{"TableName":"Customers","Key":{"CustomerId":{"S":"09e8e9c8"}}}}],"ReturnConsumedCapacity":"TOTAL"}`,
+	}
+	for _, v := range s {
+		tg := NewTransactGetItems()
+		um_err := json.Unmarshal([]byte(v), tg)
+		if um_err != nil {
+			e := fmt.Sprintf("unmarshal TransactGetItems: %v", um_err)
+			t.Error(e)
+		}
+		if v_err := tg.ValidateExpressions(); v_err != nil {
+			t.Errorf("valid request rejected: %v", v_err)
+		}
+		_, jerr := json.Marshal(*tg)
+		if jerr != nil {
+			t.Errorf("cannot marshal\n")
+		}
+	}
+	if NewTransactGetItems().Validate() == nil {
+		t.Errorf("empty transaction should be rejected")
+	}
+}
+
+func TestResponseUnmarshal(t *testing.T) {
+	s := []string{
+		`{"ConsumedCapacity":[{"TableName":"Orders","CapacityUnits":2}],"Responses":[{"Item":{"OrderStatus":{"S":"PENDING"}}},{}]}`,
+	}
+	for _, v := range s {
+		r := NewResponse()
+		um_err := json.Unmarshal([]byte(v), r)
+		if um_err != nil {
+			e := fmt.Sprintf("unmarshal TransactGetItems Response: %v", um_err)
+			t.Error(e)
+		}
+		if len(r.Responses) != 2 || r.Responses[0].Item["OrderStatus"].S != "PENDING" || len(r.Responses[1].Item) != 0 {
+			t.Errorf("unexpected response %+v", r)
+		}
+	}
+}
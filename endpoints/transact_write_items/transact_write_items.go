@@ -0,0 +1,395 @@
+// Support for the DynamoDB TransactWriteItems endpoint.
+//
+// A TransactWriteItems request applies up to MAX_ACTIONS Put, Update, Delete and
+// ConditionCheck actions, on distinct items, atomically: either every action succeeds or
+// none is applied. If the transaction is canceled, Do returns a *dynamoerr.APIError
+// whose CancellationReasons give the outcome of each action in order:
+//
+//	_, err := req.DoWithConf(c)
+//	var api_err *dynamoerr.APIError
+//	if errors.As(err, &api_err) && errors.Is(err, dynamoerr.ErrTransactionCanceled) {
+//		for _, i := range api_err.CanceledBy() { ... req.TransactItems[i] ... }
+//	}
+//
+// The ClientRequestToken makes the request idempotent for ten minutes. If it is empty,
+// one is generated for each call to EndpointReq, so that the retries of a call are
+// idempotent; set it yourself to extend that across calls.
+//
+// example use:
+//
+//	req := transact_write_items.NewTransactWriteItems()
+//	put := transact_write_items.NewPut()
+//	put.TableName = "Thread"
+//	put.Item["ForumName"] = ...
+//	req.TransactItems = append(req.TransactItems, transact_write_items.TransactWriteItem{Put: put})
+//	resp, err := req.DoWithConf(c)
+//
+package transact_write_items
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/authreq"
+	"github.com/smugmug/godynamo/aws_const"
+	"github.com/smugmug/godynamo/conf"
+	ep "github.com/smugmug/godynamo/endpoint"
+	"github.com/smugmug/godynamo/expression"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/aws_strings"
+	"github.com/smugmug/godynamo/types/capacity"
+	"github.com/smugmug/godynamo/types/expressionattributenames"
+	"github.com/smugmug/godynamo/types/item"
+	"github.com/smugmug/godynamo/types/itemcollectionmetrics"
+)
+
+const (
+	ENDPOINT_NAME               = "TransactWriteItems"
+	TRANSACTWRITEITEMS_ENDPOINT = aws_const.ENDPOINT_PREFIX + ENDPOINT_NAME
+	// the most actions a transaction may contain
+	MAX_ACTIONS = 100
+	// the longest permitted ClientRequestToken
+	MAX_TOKEN_LEN = 36
+	// the permitted ReturnValuesOnConditionCheckFailure flags
+	RETVAL_ALL_OLD = aws_strings.RETVAL_ALL_OLD
+	RETVAL_NONE    = aws_strings.RETVAL_NONE
+)
+
+// Put writes Item to TableName if ConditionExpression, if any, holds.
+type Put struct {
+	ConditionExpression                 string                                            `json:",omitempty"`
+	ExpressionAttributeNames            expressionattributenames.ExpressionAttributeNames `json:",omitempty"`
+	ExpressionAttributeValues           attributevalue.AttributeValueMap                  `json:",omitempty"`
+	Item                                item.Item
+	ReturnValuesOnConditionCheckFailure string `json:",omitempty"`
+	TableName                           string
+}
+
+func NewPut() *Put {
+	p := new(Put)
+	p.ExpressionAttributeNames = expressionattributenames.NewExpressionAttributeNames()
+	p.ExpressionAttributeValues = attributevalue.NewAttributeValueMap()
+	p.Item = item.NewItem()
+	return p
+}
+
+// Update applies UpdateExpression to the item with Key in TableName if
+// ConditionExpression, if any, holds.
+type Update struct {
+	ConditionExpression                 string                                            `json:",omitempty"`
+	ExpressionAttributeNames            expressionattributenames.ExpressionAttributeNames `json:",omitempty"`
+	ExpressionAttributeValues           attributevalue.AttributeValueMap                  `json:",omitempty"`
+	Key                                 item.Key
+	ReturnValuesOnConditionCheckFailure string `json:",omitempty"`
+	TableName                           string
+	UpdateExpression                    string
+}
+
+func NewUpdate() *Update {
+	u := new(Update)
+	u.ExpressionAttributeNames = expressionattributenames.NewExpressionAttributeNames()
+	u.ExpressionAttributeValues = attributevalue.NewAttributeValueMap()
+	u.Key = item.NewKey()
+	return u
+}
+
+// Delete deletes the item with Key from TableName if ConditionExpression, if any, holds.
+type Delete struct {
+	ConditionExpression                 string                                            `json:",omitempty"`
+	ExpressionAttributeNames            expressionattributenames.ExpressionAttributeNames `json:",omitempty"`
+	ExpressionAttributeValues           attributevalue.AttributeValueMap                  `json:",omitempty"`
+	Key                                 item.Key
+	ReturnValuesOnConditionCheckFailure string `json:",omitempty"`
+	TableName                           string
+}
+
+func NewDelete() *Delete {
+	d := new(Delete)
+	d.ExpressionAttributeNames = expressionattributenames.NewExpressionAttributeNames()
+	d.ExpressionAttributeValues = attributevalue.NewAttributeValueMap()
+	d.Key = item.NewKey()
+	return d
+}
+
+// ConditionCheck cancels the transaction unless ConditionExpression holds for the item
+// with Key in TableName. It does not change the item.
+type ConditionCheck struct {
+	ConditionExpression                 string
+	ExpressionAttributeNames            expressionattributenames.ExpressionAttributeNames `json:",omitempty"`
+	ExpressionAttributeValues           attributevalue.AttributeValueMap                  `json:",omitempty"`
+	Key                                 item.Key
+	ReturnValuesOnConditionCheckFailure string `json:",omitempty"`
+	TableName                           string
+}
+
+func NewConditionCheck() *ConditionCheck {
+	c := new(ConditionCheck)
+	c.ExpressionAttributeNames = expressionattributenames.NewExpressionAttributeNames()
+	c.ExpressionAttributeValues = attributevalue.NewAttributeValueMap()
+	c.Key = item.NewKey()
+	return c
+}
+
+// TransactWriteItem is one action of a transaction.
+// Exactly one member of this struct must be non-nil.
+type TransactWriteItem struct {
+	ConditionCheck *ConditionCheck `json:",omitempty"`
+	Delete         *Delete         `json:",omitempty"`
+	Put            *Put            `json:",omitempty"`
+	Update         *Update         `json:",omitempty"`
+}
+
+type TransactWriteItems struct {
+	ClientRequestToken          string `json:",omitempty"`
+	ReturnConsumedCapacity      string `json:",omitempty"`
+	ReturnItemCollectionMetrics string `json:",omitempty"`
+	TransactItems               []TransactWriteItem
+}
+
+func NewTransactWriteItems() *TransactWriteItems {
+	t := new(TransactWriteItems)
+	t.TransactItems = make([]TransactWriteItem, 0)
+	return t
+}
+
+type Request TransactWriteItems
+
+type Response struct {
+	ConsumedCapacity      []capacity.ConsumedCapacity
+	ItemCollectionMetrics itemcollectionmetrics.ItemCollectionMetricsMap
+}
+
+func NewResponse() *Response {
+	r := new(Response)
+	r.ConsumedCapacity = make([]capacity.ConsumedCapacity, 0)
+	r.ItemCollectionMetrics = itemcollectionmetrics.NewItemCollectionMetricsMap()
+	return r
+}
+
+// NewClientRequestToken returns a random token suitable for the ClientRequestToken.
+func NewClientRequestToken() (string, error) {
+	b := make([]byte, 16)
+	if _, rand_err := rand.Read(b); rand_err != nil {
+		return "", rand_err
+	}
+	// format as a version 4 UUID
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// expressions returns the expressions of the action and the name of its table.
+func (t TransactWriteItem) expressions() (expression.Expressions, string, error) {
+	n := 0
+	var e expression.Expressions
+	var table string
+	if t.ConditionCheck != nil {
+		n++
+		c := t.ConditionCheck
+		if c.ConditionExpression == "" {
+			return e, "", errors.New("ConditionCheck requires a ConditionExpression")
+		}
+		e = expression.Expressions{ConditionExpression: c.ConditionExpression,
+			ExpressionAttributeNames: c.ExpressionAttributeNames, ExpressionAttributeValues: c.ExpressionAttributeValues}
+		table = c.TableName
+	}
+	if t.Delete != nil {
+		n++
+		d := t.Delete
+		e = expression.Expressions{ConditionExpression: d.ConditionExpression,
+			ExpressionAttributeNames: d.ExpressionAttributeNames, ExpressionAttributeValues: d.ExpressionAttributeValues}
+		table = d.TableName
+	}
+	if t.Put != nil {
+		n++
+		p := t.Put
+		e = expression.Expressions{ConditionExpression: p.ConditionExpression,
+			ExpressionAttributeNames: p.ExpressionAttributeNames, ExpressionAttributeValues: p.ExpressionAttributeValues}
+		table = p.TableName
+	}
+	if t.Update != nil {
+		n++
+		u := t.Update
+		if u.UpdateExpression == "" {
+			return e, "", errors.New("Update requires an UpdateExpression")
+		}
+		e = expression.Expressions{ConditionExpression: u.ConditionExpression, UpdateExpression: u.UpdateExpression,
+			ExpressionAttributeNames: u.ExpressionAttributeNames, ExpressionAttributeValues: u.ExpressionAttributeValues}
+		table = u.TableName
+	}
+	if n != 1 {
+		return e, "", errors.New("exactly one of ConditionCheck, Delete, Put or Update must be set")
+	}
+	if table == "" {
+		return e, "", errors.New("TableName must be set")
+	}
+	return e, table, nil
+}
+
+// Validate checks the number of actions, that each has exactly one of its members set
+// with a TableName, and the length of the ClientRequestToken.
+func (transact_write_items *TransactWriteItems) Validate() error {
+	if transact_write_items == nil {
+		return errors.New("transact_write_items.(TransactWriteItems)Validate: receiver is nil")
+	}
+	l := len(transact_write_items.TransactItems)
+	if l == 0 || l > MAX_ACTIONS {
+		e := fmt.Sprintf("transact_write_items.(TransactWriteItems)Validate: "+
+			"a transaction must have between 1 and %d actions, not %d", MAX_ACTIONS, l)
+		return errors.New(e)
+	}
+	if len(transact_write_items.ClientRequestToken) > MAX_TOKEN_LEN {
+		e := fmt.Sprintf("transact_write_items.(TransactWriteItems)Validate: "+
+			"ClientRequestToken is longer than %d", MAX_TOKEN_LEN)
+		return errors.New(e)
+	}
+	for i, t := range transact_write_items.TransactItems {
+		if _, _, t_err := t.expressions(); t_err != nil {
+			e := fmt.Sprintf("transact_write_items.(TransactWriteItems)Validate: TransactItems[%d]: %s",
+				i, t_err.Error())
+			return errors.New(e)
+		}
+	}
+	return nil
+}
+
+// ValidateExpressions parses the expressions of every action of the request and checks
+// their expression attribute names and values as DynamoDB would, without making the request.
+func (transact_write_items *TransactWriteItems) ValidateExpressions() error {
+	if v_err := transact_write_items.Validate(); v_err != nil {
+		return v_err
+	}
+	for i, t := range transact_write_items.TransactItems {
+		e, _, _ := t.expressions()
+		if e_err := e.Validate(); e_err != nil {
+			return errors.New(fmt.Sprintf("TransactItems[%d]: %s", i, e_err.Error()))
+		}
+	}
+	return nil
+}
+
+// These implementations of EndpointReq use a parameterized conf and context.
+
+func (transact_write_items *TransactWriteItems) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if transact_write_items == nil {
+		return nil, 0, errors.New("transact_write_items.(TransactWriteItems)EndpointReqWithContext: receiver is nil")
+	}
+	if !conf.IsValid(c) {
+		return nil, 0, errors.New("transact_write_items.EndpointReqWithContext: c is not valid")
+	}
+	req := *transact_write_items
+	if req.ClientRequestToken == "" {
+		token, token_err := NewClientRequestToken()
+		if token_err != nil {
+			return nil, 0, token_err
+		}
+		req.ClientRequestToken = token
+	}
+	// returns resp_body,code,err
+	reqJSON, json_err := json.Marshal(req)
+	if json_err != nil {
+		return nil, 0, json_err
+	}
+	return authreq.RetryReqJSON_V4WithContext(ctx, reqJSON, TRANSACTWRITEITEMS_ENDPOINT, c)
+}
+
+func (req *Request) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("transact_write_items.(Request)EndpointReqWithContext: receiver is nil")
+	}
+	transact_write_items := TransactWriteItems(*req)
+	return transact_write_items.EndpointReqWithContext(ctx, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (transact_write_items *TransactWriteItems) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if transact_write_items == nil {
+		return nil, 0, errors.New("transact_write_items.(TransactWriteItems)EndpointReqWithConf: receiver is nil")
+	}
+	return transact_write_items.EndpointReqWithContext(context.Background(), c)
+}
+
+func (req *Request) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("transact_write_items.(Request)EndpointReqWithConf: receiver is nil")
+	}
+	return req.EndpointReqWithContext(context.Background(), c)
+}
+
+// These implementations of EndpointReq use the global conf.
+
+func (transact_write_items *TransactWriteItems) EndpointReq() ([]byte, int, error) {
+	if transact_write_items == nil {
+		return nil, 0, errors.New("transact_write_items.(TransactWriteItems)EndpointReq: receiver is nil")
+	}
+	return transact_write_items.EndpointReqWithConf(&conf.Vals)
+}
+
+func (req *Request) EndpointReq() ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("transact_write_items.(Request)EndpointReq: receiver is nil")
+	}
+	transact_write_items := TransactWriteItems(*req)
+	return transact_write_items.EndpointReqWithConf(&conf.Vals)
+}
+
+// These implementations of Do perform the request and decode the result into a Response.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded Response. Error responses are returned as a *dynamoerr.APIError, with
+// CancellationReasons set if the transaction was canceled.
+func (transact_write_items *TransactWriteItems) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if transact_write_items == nil {
+		return nil, errors.New("transact_write_items.(TransactWriteItems)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("transact_write_items.(TransactWriteItems)DoWithContext: ctx is nil")
+	}
+	body, code, err := transact_write_items.EndpointReqWithContext(authreq.WithAPIErrors(ctx), c)
+	resp := NewResponse()
+	if decode_err := ep.DecodeResponse(body, code, err, resp); decode_err != nil {
+		return nil, decode_err
+	}
+	return resp, nil
+}
+
+func (req *Request) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("transact_write_items.(Request)DoWithContext: receiver is nil")
+	}
+	transact_write_items := TransactWriteItems(*req)
+	return transact_write_items.DoWithContext(ctx, c)
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (transact_write_items *TransactWriteItems) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if transact_write_items == nil {
+		return nil, errors.New("transact_write_items.(TransactWriteItems)DoWithConf: receiver is nil")
+	}
+	return transact_write_items.DoWithContext(context.Background(), c)
+}
+
+func (req *Request) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("transact_write_items.(Request)DoWithConf: receiver is nil")
+	}
+	return req.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (transact_write_items *TransactWriteItems) Do() (*Response, error) {
+	if transact_write_items == nil {
+		return nil, errors.New("transact_write_items.(TransactWriteItems)Do: receiver is nil")
+	}
+	return transact_write_items.DoWithConf(&conf.Vals)
+}
+
+func (req *Request) Do() (*Response, error) {
+	if req == nil {
+		return nil, errors.New("transact_write_items.(Request)Do: receiver is nil")
+	}
+	return req.DoWithConf(&conf.Vals)
+}
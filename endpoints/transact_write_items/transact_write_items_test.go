@@ -0,0 +1,89 @@
+package transact_write_items
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestNil(t *testing.T) {
+	tw := NewTransactWriteItems()
+	_, _, err := tw.EndpointReqWithConf(nil)
+	if err == nil {
+		t.Errorf("nil conf should result in error")
+	}
+}
+
+func TestRequestUnmarshal(t *testing.T) {
+	s := []string{
+		`{"TransactItems":[{"ConditionCheck":{"TableName":"Customers","Key":{"CustomerId":{"S":"09e8e9c8-ec48-4b42-9ccf-5a2b7fc5f1a1"}},"ConditionExpression":"attribute_exists(CustomerId)"}},{"Put":{"TableName":"Orders","Item":{"OrderId":{"S":"1ac8b1b0"},"OrderStatus":{"S":"PENDING"}},"ConditionExpression":"attribute_not_exists(OrderId)"}},{"Update":{"TableName":"Inventory","Key":{"ProductId":{"S":"p1"}},"UpdateExpression":"SET ProductStatus = :new","ConditionExpression":"ProductStatus = :expected","ExpressionAttributeValues":{":new":{"S":"SOLD"},":expected":{"S":"IN_STOCK"}},"ReturnValuesOnConditionCheckFailure":"ALL_OLD"}},{"Delete":{"TableName":"Carts","Key":{"CartId":{"S":"c1"}}}}],"ClientRequestToken":"TRANSACTION1","ReturnConsumedCapacity":"TOTAL"}`,
+	}
+	for _, v := range s {
+		tw := NewTransactWriteItems()
+		um_err := json.Unmarshal([]byte(v), tw)
+		if um_err != nil {
+			e := fmt.Sprintf("unmarshal TransactWriteItems: %v", um_err)
+			t.Error(e)
+		}
+		if v_err := tw.ValidateExpressions(); v_err != nil {
+			t.Errorf("valid request rejected: %v", v_err)
+		}
+		_, jerr := json.Marshal(*tw)
+		if jerr != nil {
+			t.Errorf("cannot marshal\n")
+		}
+	}
+}
+
+func TestResponseUnmarshal(t *testing.T) {
+	s := []string{
+		`{"ConsumedCapacity":[{"TableName":"Orders","CapacityUnits":4}],"ItemCollectionMetrics":{}}`,
+	}
+	for _, v := range s {
+		r := NewResponse()
+		um_err := json.Unmarshal([]byte(v), r)
+		if um_err != nil {
+			e := fmt.Sprintf("unmarshal TransactWriteItems Response: %v", um_err)
+			t.Error(e)
+		}
+		if len(r.ConsumedCapacity) != 1 || r.ConsumedCapacity[0].CapacityUnits != 4 {
+			t.Errorf("unexpected response %+v", r)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	bad := []TransactWriteItem{
+		{},
+		{Put: NewPut(), Delete: NewDelete()},
+		{Put: NewPut()},
+		{Update: &Update{TableName: "t"}},
+		{ConditionCheck: &ConditionCheck{TableName: "t"}},
+	}
+	for i, b := range bad {
+		tw := NewTransactWriteItems()
+		tw.TransactItems = append(tw.TransactItems, b)
+		if tw.Validate() == nil {
+			t.Errorf("action %d should be rejected", i)
+		}
+	}
+	tw := NewTransactWriteItems()
+	if tw.Validate() == nil {
+		t.Errorf("empty transaction should be rejected")
+	}
+	d := NewDelete()
+	d.TableName = "t"
+	d.ConditionExpression = "attribute_exists(#a)"
+	tw.TransactItems = append(tw.TransactItems, TransactWriteItem{Delete: d})
+	if tw.Validate() != nil || tw.ValidateExpressions() == nil {
+		t.Errorf("undefined expression attribute name should only be caught by ValidateExpressions")
+	}
+}
+
+func TestClientRequestToken(t *testing.T) {
+	a, a_err := NewClientRequestToken()
+	b, _ := NewClientRequestToken()
+	if a_err != nil || len(a) != MAX_TOKEN_LEN || a == b {
+		t.Errorf("unexpected tokens %q %q %v", a, b, a_err)
+	}
+}
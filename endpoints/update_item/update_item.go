@@ -7,11 +7,13 @@
 package update_item
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/smugmug/godynamo/authreq"
 	"github.com/smugmug/godynamo/aws_const"
 	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/expression"
 	"github.com/smugmug/godynamo/types/attributesresponse"
 	"github.com/smugmug/godynamo/types/attributevalue"
 	"github.com/smugmug/godynamo/types/aws_strings"
@@ -82,37 +84,75 @@ func NewResponse() *Response {
 	return &r
 }
 
-// These implementations of EndpointReq use a parameterized conf.
+// ValidateExpressions parses the expressions of the request and checks its expression
+// attribute names and values as DynamoDB would, without making the request.
+func (update_item *UpdateItem) ValidateExpressions() error {
+	if update_item == nil {
+		return errors.New("update_item.(UpdateItem)ValidateExpressions: receiver is nil")
+	}
+	e := expression.Expressions{
+		ConditionExpression:       update_item.ConditionExpression,
+		UpdateExpression:          update_item.UpdateExpression,
+		ExpressionAttributeNames:  update_item.ExpressionAttributeNames,
+		ExpressionAttributeValues: update_item.ExpressionAttributeValues,
+	}
+	return e.Validate()
+}
 
-func (update_item *UpdateItem) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+// These implementations of EndpointReq use a parameterized conf and context.
+
+func (update_item *UpdateItem) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
 	if update_item == nil {
-		return nil, 0, errors.New("update_item.(UpdateItem)EndpointReqWithConf: receiver is nil")
+		return nil, 0, errors.New("update_item.(UpdateItem)EndpointReqWithContext: receiver is nil")
 	}
 	if !conf.IsValid(c) {
-		return nil, 0, errors.New("update_item.EndpointReqWithConf: c is not valid")
+		return nil, 0, errors.New("update_item.EndpointReqWithContext: c is not valid")
 	}
 	// returns resp_body,code,err
 	reqJSON, json_err := json.Marshal(update_item)
 	if json_err != nil {
 		return nil, 0, json_err
 	}
-	return authreq.RetryReqJSON_V4WithConf(reqJSON, UPDATEITEM_ENDPOINT, c)
+	return authreq.RetryReqJSON_V4WithContext(ctx, reqJSON, UPDATEITEM_ENDPOINT, c)
+}
+
+func (update *Update) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if update == nil {
+		return nil, 0, errors.New("update_item.(Update)EndpointReqWithContext: receiver is nil")
+	}
+	update_item := UpdateItem(*update)
+	return update_item.EndpointReqWithContext(ctx, c)
+}
+
+func (req *Request) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("update_item.(Request)EndpointReqWithContext: receiver is nil")
+	}
+	update_item := UpdateItem(*req)
+	return update_item.EndpointReqWithContext(ctx, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (update_item *UpdateItem) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if update_item == nil {
+		return nil, 0, errors.New("update_item.(UpdateItem)EndpointReqWithConf: receiver is nil")
+	}
+	return update_item.EndpointReqWithContext(context.Background(), c)
 }
 
 func (update *Update) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if update == nil {
 		return nil, 0, errors.New("update_item.(Update)EndpointReqWithConf: receiver is nil")
 	}
-	update_item := UpdateItem(*update)
-	return update_item.EndpointReqWithConf(c)
+	return update.EndpointReqWithContext(context.Background(), c)
 }
 
 func (req *Request) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if req == nil {
 		return nil, 0, errors.New("update_item.(Request)EndpointReqWithConf: receiver is nil")
 	}
-	update_item := UpdateItem(*req)
-	return update_item.EndpointReqWithConf(c)
+	return req.EndpointReqWithContext(context.Background(), c)
 }
 
 // These implementations of EndpointReq use the global conf.
@@ -139,3 +179,81 @@ func (req *Request) EndpointReq() ([]byte, int, error) {
 	update_item := UpdateItem(*req)
 	return update_item.EndpointReqWithConf(&conf.Vals)
 }
+
+// These implementations of Do perform the request and decode the result into a Response.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded Response. Error responses are returned as a *dynamoerr.APIError.
+func (update_item *UpdateItem) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if update_item == nil {
+		return nil, errors.New("update_item.(UpdateItem)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("update_item.(UpdateItem)DoWithContext: ctx is nil")
+	}
+	resp := NewResponse()
+	if err := authreq.DoWithContext(ctx, update_item, UPDATEITEM_ENDPOINT, c, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (update *Update) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if update == nil {
+		return nil, errors.New("update_item.(Update)DoWithContext: receiver is nil")
+	}
+	update_item := UpdateItem(*update)
+	return update_item.DoWithContext(ctx, c)
+}
+
+func (req *Request) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("update_item.(Request)DoWithContext: receiver is nil")
+	}
+	update_item := UpdateItem(*req)
+	return update_item.DoWithContext(ctx, c)
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (update_item *UpdateItem) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if update_item == nil {
+		return nil, errors.New("update_item.(UpdateItem)DoWithConf: receiver is nil")
+	}
+	return update_item.DoWithContext(context.Background(), c)
+}
+
+func (update *Update) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if update == nil {
+		return nil, errors.New("update_item.(Update)DoWithConf: receiver is nil")
+	}
+	return update.DoWithContext(context.Background(), c)
+}
+
+func (req *Request) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("update_item.(Request)DoWithConf: receiver is nil")
+	}
+	return req.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (update_item *UpdateItem) Do() (*Response, error) {
+	if update_item == nil {
+		return nil, errors.New("update_item.(UpdateItem)Do: receiver is nil")
+	}
+	return update_item.DoWithConf(&conf.Vals)
+}
+
+func (update *Update) Do() (*Response, error) {
+	if update == nil {
+		return nil, errors.New("update_item.(Update)Do: receiver is nil")
+	}
+	return update.DoWithConf(&conf.Vals)
+}
+
+func (req *Request) Do() (*Response, error) {
+	if req == nil {
+		return nil, errors.New("update_item.(Request)Do: receiver is nil")
+	}
+	return req.DoWithConf(&conf.Vals)
+}
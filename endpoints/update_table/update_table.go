@@ -7,6 +7,7 @@
 package update_table
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/smugmug/godynamo/authreq"
@@ -15,6 +16,7 @@ import (
 	create_table "github.com/smugmug/godynamo/endpoints/create_table"
 	"github.com/smugmug/godynamo/types/globalsecondaryindex"
 	"github.com/smugmug/godynamo/types/provisionedthroughput"
+	"github.com/smugmug/godynamo/types/streamspecification"
 )
 
 const (
@@ -26,6 +28,7 @@ type UpdateTable struct {
 	GlobalSecondaryIndexUpdates *globalsecondaryindex.GlobalSecondaryIndexUpdates `json:",omitempty"`
 	TableName                   string
 	ProvisionedThroughput       *provisionedthroughput.ProvisionedThroughput `json:",omitempty"`
+	StreamSpecification         *streamspecification.StreamSpecification     `json:",omitempty"`
 }
 
 func NewUpdateTable() *UpdateTable {
@@ -56,37 +59,60 @@ func NewResponse() *Response {
 	return &r
 }
 
-// These implementations of EndpointReq use a parameterized conf.
+// These implementations of EndpointReq use a parameterized conf and context.
 
-func (update_table *UpdateTable) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+func (update_table *UpdateTable) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
 	if update_table == nil {
-		return nil, 0, errors.New("update_table.(UpdateTable)EndpointReqWithConf: receiver is nil")
+		return nil, 0, errors.New("update_table.(UpdateTable)EndpointReqWithContext: receiver is nil")
 	}
 	if !conf.IsValid(c) {
-		return nil, 0, errors.New("update_table.EndpointReqWithConf: c is not valid")
+		return nil, 0, errors.New("update_table.EndpointReqWithContext: c is not valid")
 	}
 	// returns resp_body,code,err
 	reqJSON, json_err := json.Marshal(update_table)
 	if json_err != nil {
 		return nil, 0, json_err
 	}
-	return authreq.RetryReqJSON_V4WithConf(reqJSON, UPDATETABLE_ENDPOINT, c)
+	return authreq.RetryReqJSON_V4WithContext(ctx, reqJSON, UPDATETABLE_ENDPOINT, c)
+}
+
+func (update *Update) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if update == nil {
+		return nil, 0, errors.New("update_table.(Update)EndpointReqWithContext: receiver is nil")
+	}
+	update_table := UpdateTable(*update)
+	return update_table.EndpointReqWithContext(ctx, c)
+}
+
+func (req *Request) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if req == nil {
+		return nil, 0, errors.New("update_table.(Request)EndpointReqWithContext: receiver is nil")
+	}
+	update_table := UpdateTable(*req)
+	return update_table.EndpointReqWithContext(ctx, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (update_table *UpdateTable) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if update_table == nil {
+		return nil, 0, errors.New("update_table.(UpdateTable)EndpointReqWithConf: receiver is nil")
+	}
+	return update_table.EndpointReqWithContext(context.Background(), c)
 }
 
 func (update *Update) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if update == nil {
 		return nil, 0, errors.New("update_table.(Update)EndpointReqWithConf: receiver is nil")
 	}
-	update_table := UpdateTable(*update)
-	return update_table.EndpointReqWithConf(c)
+	return update.EndpointReqWithContext(context.Background(), c)
 }
 
 func (req *Request) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
 	if req == nil {
 		return nil, 0, errors.New("update_table.(Request)EndpointReqWithConf: receiver is nil")
 	}
-	update_table := UpdateTable(*req)
-	return update_table.EndpointReqWithConf(c)
+	return req.EndpointReqWithContext(context.Background(), c)
 }
 
 // These implementations of EndpointReq use the global conf.
@@ -113,3 +139,81 @@ func (req *Request) EndpointReq() ([]byte, int, error) {
 	update_table := UpdateTable(*req)
 	return update_table.EndpointReqWithConf(&conf.Vals)
 }
+
+// These implementations of Do perform the request and decode the result into a Response.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded Response. Error responses are returned as a *dynamoerr.APIError.
+func (update_table *UpdateTable) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if update_table == nil {
+		return nil, errors.New("update_table.(UpdateTable)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("update_table.(UpdateTable)DoWithContext: ctx is nil")
+	}
+	resp := NewResponse()
+	if err := authreq.DoWithContext(ctx, update_table, UPDATETABLE_ENDPOINT, c, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (update *Update) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if update == nil {
+		return nil, errors.New("update_table.(Update)DoWithContext: receiver is nil")
+	}
+	update_table := UpdateTable(*update)
+	return update_table.DoWithContext(ctx, c)
+}
+
+func (req *Request) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("update_table.(Request)DoWithContext: receiver is nil")
+	}
+	update_table := UpdateTable(*req)
+	return update_table.DoWithContext(ctx, c)
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (update_table *UpdateTable) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if update_table == nil {
+		return nil, errors.New("update_table.(UpdateTable)DoWithConf: receiver is nil")
+	}
+	return update_table.DoWithContext(context.Background(), c)
+}
+
+func (update *Update) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if update == nil {
+		return nil, errors.New("update_table.(Update)DoWithConf: receiver is nil")
+	}
+	return update.DoWithContext(context.Background(), c)
+}
+
+func (req *Request) DoWithConf(c *conf.AWS_Conf) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("update_table.(Request)DoWithConf: receiver is nil")
+	}
+	return req.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (update_table *UpdateTable) Do() (*Response, error) {
+	if update_table == nil {
+		return nil, errors.New("update_table.(UpdateTable)Do: receiver is nil")
+	}
+	return update_table.DoWithConf(&conf.Vals)
+}
+
+func (update *Update) Do() (*Response, error) {
+	if update == nil {
+		return nil, errors.New("update_table.(Update)Do: receiver is nil")
+	}
+	return update.DoWithConf(&conf.Vals)
+}
+
+func (req *Request) Do() (*Response, error) {
+	if req == nil {
+		return nil, errors.New("update_table.(Request)Do: receiver is nil")
+	}
+	return req.DoWithConf(&conf.Vals)
+}
@@ -0,0 +1,183 @@
+// Implements a builder for DynamoDB expressions that allocates the
+// ExpressionAttributeNames and ExpressionAttributeValues placeholders itself.
+//
+// Every attribute name is replaced with a #name placeholder, so reserved words and names
+// that are not valid identifiers need no special handling, and every value with a :value
+// placeholder. Conditions, key conditions, projections and updates are combined on one
+// Builder so that their placeholders are allocated from the same maps:
+//
+//	cond := builder.Name("Views").LessThan(100).And(builder.Name("Tags").Contains("new"))
+//	update := builder.Set(builder.Name("Views"), builder.Name("Views").Plus(1)).
+//		Remove(builder.Name("Pictures.SideView"))
+//	e, err := builder.NewBuilder().WithCondition(cond).WithUpdate(update).Build()
+//	...
+//	u := update_item.NewUpdateItem()
+//	u.ConditionExpression = e.ConditionExpression
+//	u.UpdateExpression = e.UpdateExpression
+//	u.ExpressionAttributeNames = e.ExpressionAttributeNames
+//	u.ExpressionAttributeValues = e.ExpressionAttributeValues
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/expression"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/expressionattributenames"
+	"strconv"
+	"strings"
+)
+
+// alloc allocates placeholders for one request.
+type alloc struct {
+	names  expressionattributenames.ExpressionAttributeNames
+	byName map[string]string
+	values attributevalue.AttributeValueMap
+}
+
+func newAlloc() *alloc {
+	return &alloc{
+		names:  expressionattributenames.NewExpressionAttributeNames(),
+		byName: make(map[string]string),
+		values: attributevalue.NewAttributeValueMap(),
+	}
+}
+
+// name returns the placeholder for an attribute name, reusing it if the name was seen.
+func (a *alloc) name(n string) string {
+	if p, ok := a.byName[n]; ok {
+		return p
+	}
+	p := "#n" + strconv.Itoa(len(a.byName))
+	a.byName[n] = p
+	a.names[p] = n
+	return p
+}
+
+// value returns a new placeholder for v.
+func (a *alloc) value(v *attributevalue.AttributeValue) string {
+	p := ":v" + strconv.Itoa(len(a.values))
+	a.values[p] = v
+	return p
+}
+
+// ProjectionBuilder builds a ProjectionExpression.
+type ProjectionBuilder struct {
+	names []NameBuilder
+}
+
+// Projection returns a ProjectionBuilder for the given document paths.
+func Projection(name NameBuilder, names ...NameBuilder) ProjectionBuilder {
+	return ProjectionBuilder{names: append([]NameBuilder{name}, names...)}
+}
+
+// AddNames returns a ProjectionBuilder with names added to the projected paths.
+func (p ProjectionBuilder) AddNames(names ...NameBuilder) ProjectionBuilder {
+	return ProjectionBuilder{names: append(append([]NameBuilder{}, p.names...), names...)}
+}
+
+func (p ProjectionBuilder) build(a *alloc) (string, error) {
+	if len(p.names) == 0 {
+		return "", errors.New("builder.ProjectionBuilder: no names to project")
+	}
+	paths := make([]string, len(p.names))
+	for i, n := range p.names {
+		s, s_err := n.build(a)
+		if s_err != nil {
+			return "", s_err
+		}
+		paths[i] = s
+	}
+	return strings.Join(paths, ", "), nil
+}
+
+// Builder combines the expressions of one request.
+type Builder struct {
+	condition    *ConditionBuilder
+	filter       *ConditionBuilder
+	keyCondition *KeyConditionBuilder
+	projection   *ProjectionBuilder
+	update       *UpdateBuilder
+}
+
+// NewBuilder returns an empty Builder.
+func NewBuilder() Builder {
+	return Builder{}
+}
+
+// WithCondition returns a Builder with c as its ConditionExpression.
+func (b Builder) WithCondition(c ConditionBuilder) Builder {
+	b.condition = &c
+	return b
+}
+
+// WithFilter returns a Builder with c as its FilterExpression.
+func (b Builder) WithFilter(c ConditionBuilder) Builder {
+	b.filter = &c
+	return b
+}
+
+// WithKeyCondition returns a Builder with k as its KeyConditionExpression.
+func (b Builder) WithKeyCondition(k KeyConditionBuilder) Builder {
+	b.keyCondition = &k
+	return b
+}
+
+// WithProjection returns a Builder with p as its ProjectionExpression.
+func (b Builder) WithProjection(p ProjectionBuilder) Builder {
+	b.projection = &p
+	return b
+}
+
+// WithUpdate returns a Builder with u as its UpdateExpression.
+func (b Builder) WithUpdate(u UpdateBuilder) Builder {
+	b.update = &u
+	return b
+}
+
+// Build returns the expression strings and the placeholder maps they share. The result
+// is checked with expression.Expressions.Validate, so errors are reported as DynamoDB
+// would report them.
+func (b Builder) Build() (expression.Expressions, error) {
+	var e expression.Expressions
+	if b.condition == nil && b.filter == nil && b.keyCondition == nil && b.projection == nil && b.update == nil {
+		return e, errors.New("builder.Build: no expressions to build")
+	}
+	a := newAlloc()
+	var b_err error
+	if b.condition != nil {
+		if e.ConditionExpression, b_err = b.condition.build(a); b_err != nil {
+			return e, b_err
+		}
+	}
+	if b.filter != nil {
+		if e.FilterExpression, b_err = b.filter.build(a); b_err != nil {
+			return e, b_err
+		}
+	}
+	if b.keyCondition != nil {
+		if e.KeyConditionExpression, b_err = b.keyCondition.build(a); b_err != nil {
+			return e, b_err
+		}
+	}
+	if b.projection != nil {
+		if e.ProjectionExpression, b_err = b.projection.build(a); b_err != nil {
+			return e, b_err
+		}
+	}
+	if b.update != nil {
+		if e.UpdateExpression, b_err = b.update.build(a); b_err != nil {
+			return e, b_err
+		}
+	}
+	if len(a.names) != 0 {
+		e.ExpressionAttributeNames = a.names
+	}
+	if len(a.values) != 0 {
+		e.ExpressionAttributeValues = a.values
+	}
+	if v_err := e.Validate(); v_err != nil {
+		return e, errors.New(fmt.Sprintf("builder.Build: %s", v_err.Error()))
+	}
+	return e, nil
+}
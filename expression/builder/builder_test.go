@@ -0,0 +1,112 @@
+package builder
+
+import (
+	"encoding/json"
+	"github.com/smugmug/godynamo/types/aws_strings"
+	"github.com/smugmug/godynamo/types/item"
+	"strings"
+	"testing"
+)
+
+func TestCondition(t *testing.T) {
+	c := Name("Views").LessThan(100).And(Name("Tags").Contains("new"), Name("Pictures.SideView").AttributeExists()).
+		Or(Not(Name("Replies[1]").Size().GreaterThan(Name("Views"))))
+	e, err := NewBuilder().WithCondition(c).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "((#n0 < :v0) AND (contains(#n1, :v1)) AND (attribute_exists(#n2.#n3))) OR (NOT (size(#n4[1]) > #n0))"
+	if e.ConditionExpression != want {
+		t.Errorf("unexpected condition %s", e.ConditionExpression)
+	}
+	if len(e.ExpressionAttributeNames) != 5 || e.ExpressionAttributeNames["#n0"] != "Views" ||
+		e.ExpressionAttributeValues[":v0"].N != "100" || e.ExpressionAttributeValues[":v1"].S != "new" {
+		t.Errorf("unexpected placeholders %v %v", e.ExpressionAttributeNames, e.ExpressionAttributeValues)
+	}
+	parsed, p_err := e.Parse()
+	if p_err != nil {
+		t.Fatal(p_err)
+	}
+	it := item.NewItem()
+	json.Unmarshal([]byte(`{"Views":{"N":"3"},"Tags":{"SS":["new"]},"Pictures":{"M":{"SideView":{"S":"x"}}}}`), &it)
+	if !parsed.Condition.Evaluate(it) {
+		t.Errorf("condition should match %v", it)
+	}
+}
+
+func TestCombined(t *testing.T) {
+	update := Set(Name("Views"), Name("Views").Plus(1)).
+		Set(Name("Replies"), Name("Replies").ListAppend([]interface{}{"r", 1})).
+		Set(Name("First"), IfNotExists(Name("First"), "now")).
+		Remove(Name("Pictures.SideView")).
+		Add(Name("Tags"), []string{"a", "b"}).
+		Delete(Name("Old"), []float64{1, 2})
+	e, err := NewBuilder().
+		WithCondition(Name("Views").AttributeExists()).
+		WithProjection(Projection(Name("Views"), Name("Replies[0]"))).
+		WithUpdate(update).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.ConditionExpression != "attribute_exists(#n0)" || e.ProjectionExpression != "#n0, #n1[0]" {
+		t.Errorf("unexpected expressions %+v", e)
+	}
+	want := "SET #n0 = #n0 + :v0, #n1 = list_append(#n1, :v1), #n2 = if_not_exists(#n2, :v2) " +
+		"REMOVE #n3.#n4 ADD #n5 :v3 DELETE #n6 :v4"
+	if e.UpdateExpression != want {
+		t.Errorf("unexpected update %s", e.UpdateExpression)
+	}
+	if len(e.ExpressionAttributeValues[":v1"].L) != 2 || len(e.ExpressionAttributeValues[":v3"].SS) != 2 ||
+		len(e.ExpressionAttributeValues[":v4"].NS) != 2 {
+		t.Errorf("unexpected values %v", e.ExpressionAttributeValues)
+	}
+}
+
+func TestKeyCondition(t *testing.T) {
+	k := Key("ForumName").Equal("F").And(Key("Subject").BeginsWith("How"))
+	e, err := NewBuilder().WithKeyCondition(k).WithFilter(Name("Views").Between(1, 10)).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.KeyConditionExpression != "#n1 = :v2 AND begins_with(#n2, :v3)" ||
+		e.FilterExpression != "#n0 BETWEEN :v0 AND :v1" {
+		t.Errorf("unexpected expressions %+v", e)
+	}
+	parsed, _ := e.Parse()
+	if cs := parsed.KeyCondition.Conditions(); cs["Subject"].ComparisonOperator != aws_strings.OP_BEGINS_WITH {
+		t.Errorf("unexpected key conditions %v", cs)
+	}
+	bad := []KeyConditionBuilder{
+		Key("a").Equal(1).And(Key("b").Equal(2)).And(Key("c").Equal(3)),
+		Key("a").Equal(1).And(Key("a").LessThan(2)),
+		Key("a.b").Equal(1),
+		{},
+	}
+	for _, k := range bad {
+		if _, err := NewBuilder().WithKeyCondition(k).Build(); err == nil {
+			t.Errorf("key condition %+v should be rejected", k)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	bad := []Builder{
+		NewBuilder(),
+		NewBuilder().WithCondition(ConditionBuilder{}),
+		NewBuilder().WithCondition(Name("a").In()),
+		NewBuilder().WithCondition(Name("a..b").AttributeExists()),
+		NewBuilder().WithCondition(Name("a[x]").AttributeExists()),
+		NewBuilder().WithCondition(Name("a").Equal(make(chan int))),
+		NewBuilder().WithUpdate(UpdateBuilder{}),
+		NewBuilder().WithUpdate(Set(Name("a"), 1).Remove(Name("a"))),
+		NewBuilder().WithUpdate(Delete(Name("a"), 1)),
+	}
+	for i, b := range bad {
+		if _, err := b.Build(); err == nil {
+			t.Errorf("builder %d should fail", i)
+		} else if !strings.HasPrefix(err.Error(), "builder.") {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ConditionBuilder builds a ConditionExpression or FilterExpression.
+type ConditionBuilder struct {
+	format   string
+	operands []OperandBuilder
+	// the operands of AND, OR and NOT
+	conditions []ConditionBuilder
+	join       string
+}
+
+func (c ConditionBuilder) build(a *alloc) (string, error) {
+	if c.join != "" {
+		parts := make([]string, len(c.conditions))
+		for i, sub := range c.conditions {
+			s, s_err := sub.build(a)
+			if s_err != nil {
+				return "", s_err
+			}
+			parts[i] = "(" + s + ")"
+		}
+		if c.join == "NOT" {
+			return "NOT " + parts[0], nil
+		}
+		return strings.Join(parts, " "+c.join+" "), nil
+	}
+	if c.format == "" {
+		return "", errors.New("builder.ConditionBuilder: empty condition")
+	}
+	args := make([]interface{}, len(c.operands))
+	for i, o := range c.operands {
+		s, s_err := o.build(a)
+		if s_err != nil {
+			return "", s_err
+		}
+		args[i] = s
+	}
+	return fmt.Sprintf(c.format, args...), nil
+}
+
+func compare(format string, l OperandBuilder, r interface{}) ConditionBuilder {
+	return ConditionBuilder{format: format, operands: []OperandBuilder{l, operand(r)}}
+}
+
+// Equal returns the condition l = r. l and r are OperandBuilders or values.
+func Equal(l, r interface{}) ConditionBuilder {
+	return compare("%s = %s", operand(l), r)
+}
+
+// NotEqual returns the condition l <> r.
+func NotEqual(l, r interface{}) ConditionBuilder {
+	return compare("%s <> %s", operand(l), r)
+}
+
+// LessThan returns the condition l < r.
+func LessThan(l, r interface{}) ConditionBuilder {
+	return compare("%s < %s", operand(l), r)
+}
+
+// LessThanEqual returns the condition l <= r.
+func LessThanEqual(l, r interface{}) ConditionBuilder {
+	return compare("%s <= %s", operand(l), r)
+}
+
+// GreaterThan returns the condition l > r.
+func GreaterThan(l, r interface{}) ConditionBuilder {
+	return compare("%s > %s", operand(l), r)
+}
+
+// GreaterThanEqual returns the condition l >= r.
+func GreaterThanEqual(l, r interface{}) ConditionBuilder {
+	return compare("%s >= %s", operand(l), r)
+}
+
+// Between returns the condition v BETWEEN lo AND hi.
+func Between(v, lo, hi interface{}) ConditionBuilder {
+	return ConditionBuilder{format: "%s BETWEEN %s AND %s",
+		operands: []OperandBuilder{operand(v), operand(lo), operand(hi)}}
+}
+
+// In returns the condition v IN (list...). At least one value must be given.
+func In(v interface{}, list ...interface{}) ConditionBuilder {
+	if len(list) == 0 {
+		return ConditionBuilder{}
+	}
+	c := ConditionBuilder{operands: []OperandBuilder{operand(v)}}
+	for _, o := range list {
+		c.operands = append(c.operands, operand(o))
+	}
+	c.format = "%s IN (%s" + strings.Repeat(", %s", len(list)-1) + ")"
+	return c
+}
+
+// AttributeExists returns the condition attribute_exists(n).
+func AttributeExists(n NameBuilder) ConditionBuilder {
+	return ConditionBuilder{format: "attribute_exists(%s)", operands: []OperandBuilder{n}}
+}
+
+// AttributeNotExists returns the condition attribute_not_exists(n).
+func AttributeNotExists(n NameBuilder) ConditionBuilder {
+	return ConditionBuilder{format: "attribute_not_exists(%s)", operands: []OperandBuilder{n}}
+}
+
+// AttributeType returns the condition attribute_type(n, t), where t is a type
+// designation such as aws_strings.SS.
+func AttributeType(n NameBuilder, t string) ConditionBuilder {
+	return ConditionBuilder{format: "attribute_type(%s, %s)", operands: []OperandBuilder{n, Value(t)}}
+}
+
+// BeginsWith returns the condition begins_with(n, prefix).
+func BeginsWith(n NameBuilder, prefix string) ConditionBuilder {
+	return ConditionBuilder{format: "begins_with(%s, %s)", operands: []OperandBuilder{n, Value(prefix)}}
+}
+
+// Contains returns the condition contains(n, v).
+func Contains(n NameBuilder, v interface{}) ConditionBuilder {
+	return compare("contains(%s, %s)", n, v)
+}
+
+func join(op string, c ConditionBuilder, others []ConditionBuilder) ConditionBuilder {
+	return ConditionBuilder{join: op, conditions: append([]ConditionBuilder{c}, others...)}
+}
+
+// And returns the conjunction of the conditions.
+func And(c, other ConditionBuilder, others ...ConditionBuilder) ConditionBuilder {
+	return join("AND", c, append([]ConditionBuilder{other}, others...))
+}
+
+// Or returns the disjunction of the conditions.
+func Or(c, other ConditionBuilder, others ...ConditionBuilder) ConditionBuilder {
+	return join("OR", c, append([]ConditionBuilder{other}, others...))
+}
+
+// Not returns the negation of c.
+func Not(c ConditionBuilder) ConditionBuilder {
+	return join("NOT", c, nil)
+}
+
+// And returns the conjunction of c and the other conditions.
+func (c ConditionBuilder) And(other ConditionBuilder, others ...ConditionBuilder) ConditionBuilder {
+	return And(c, other, others...)
+}
+
+// Or returns the disjunction of c and the other conditions.
+func (c ConditionBuilder) Or(other ConditionBuilder, others ...ConditionBuilder) ConditionBuilder {
+	return Or(c, other, others...)
+}
+
+// Not returns the negation of c.
+func (c ConditionBuilder) Not() ConditionBuilder {
+	return Not(c)
+}
+
+// Equal returns the condition n = v.
+func (n NameBuilder) Equal(v interface{}) ConditionBuilder {
+	return Equal(n, v)
+}
+
+// NotEqual returns the condition n <> v.
+func (n NameBuilder) NotEqual(v interface{}) ConditionBuilder {
+	return NotEqual(n, v)
+}
+
+// LessThan returns the condition n < v.
+func (n NameBuilder) LessThan(v interface{}) ConditionBuilder {
+	return LessThan(n, v)
+}
+
+// LessThanEqual returns the condition n <= v.
+func (n NameBuilder) LessThanEqual(v interface{}) ConditionBuilder {
+	return LessThanEqual(n, v)
+}
+
+// GreaterThan returns the condition n > v.
+func (n NameBuilder) GreaterThan(v interface{}) ConditionBuilder {
+	return GreaterThan(n, v)
+}
+
+// GreaterThanEqual returns the condition n >= v.
+func (n NameBuilder) GreaterThanEqual(v interface{}) ConditionBuilder {
+	return GreaterThanEqual(n, v)
+}
+
+// Between returns the condition n BETWEEN lo AND hi.
+func (n NameBuilder) Between(lo, hi interface{}) ConditionBuilder {
+	return Between(n, lo, hi)
+}
+
+// In returns the condition n IN (list...).
+func (n NameBuilder) In(list ...interface{}) ConditionBuilder {
+	return In(n, list...)
+}
+
+// AttributeExists returns the condition attribute_exists(n).
+func (n NameBuilder) AttributeExists() ConditionBuilder {
+	return AttributeExists(n)
+}
+
+// AttributeNotExists returns the condition attribute_not_exists(n).
+func (n NameBuilder) AttributeNotExists() ConditionBuilder {
+	return AttributeNotExists(n)
+}
+
+// AttributeType returns the condition attribute_type(n, t).
+func (n NameBuilder) AttributeType(t string) ConditionBuilder {
+	return AttributeType(n, t)
+}
+
+// BeginsWith returns the condition begins_with(n, prefix).
+func (n NameBuilder) BeginsWith(prefix string) ConditionBuilder {
+	return BeginsWith(n, prefix)
+}
+
+// Contains returns the condition contains(n, v).
+func (n NameBuilder) Contains(v interface{}) ConditionBuilder {
+	return Contains(n, v)
+}
+
+// Equal returns the condition size(n) = v.
+func (s SizeBuilder) Equal(v interface{}) ConditionBuilder {
+	return Equal(s, v)
+}
+
+// NotEqual returns the condition size(n) <> v.
+func (s SizeBuilder) NotEqual(v interface{}) ConditionBuilder {
+	return NotEqual(s, v)
+}
+
+// LessThan returns the condition size(n) < v.
+func (s SizeBuilder) LessThan(v interface{}) ConditionBuilder {
+	return LessThan(s, v)
+}
+
+// LessThanEqual returns the condition size(n) <= v.
+func (s SizeBuilder) LessThanEqual(v interface{}) ConditionBuilder {
+	return LessThanEqual(s, v)
+}
+
+// GreaterThan returns the condition size(n) > v.
+func (s SizeBuilder) GreaterThan(v interface{}) ConditionBuilder {
+	return GreaterThan(s, v)
+}
+
+// GreaterThanEqual returns the condition size(n) >= v.
+func (s SizeBuilder) GreaterThanEqual(v interface{}) ConditionBuilder {
+	return GreaterThanEqual(s, v)
+}
+
+// Between returns the condition size(n) BETWEEN lo AND hi.
+func (s SizeBuilder) Between(lo, hi interface{}) ConditionBuilder {
+	return Between(s, lo, hi)
+}
+
+// KeyBuilder is a top level key attribute in a key condition.
+type KeyBuilder struct {
+	name string
+}
+
+// Key returns a KeyBuilder for the key attribute name.
+func Key(name string) KeyBuilder {
+	return KeyBuilder{name: name}
+}
+
+// KeyConditionBuilder builds a KeyConditionExpression: a condition on the hash key,
+// optionally joined with a condition on the range key by And.
+type KeyConditionBuilder struct {
+	terms []ConditionBuilder
+	keys  []string
+}
+
+func (k KeyBuilder) condition(c ConditionBuilder) KeyConditionBuilder {
+	return KeyConditionBuilder{terms: []ConditionBuilder{c}, keys: []string{k.name}}
+}
+
+// Equal returns the key condition k = v.
+func (k KeyBuilder) Equal(v interface{}) KeyConditionBuilder {
+	return k.condition(compare("%s = %s", Name(k.name), v))
+}
+
+// LessThan returns the key condition k < v.
+func (k KeyBuilder) LessThan(v interface{}) KeyConditionBuilder {
+	return k.condition(compare("%s < %s", Name(k.name), v))
+}
+
+// LessThanEqual returns the key condition k <= v.
+func (k KeyBuilder) LessThanEqual(v interface{}) KeyConditionBuilder {
+	return k.condition(compare("%s <= %s", Name(k.name), v))
+}
+
+// GreaterThan returns the key condition k > v.
+func (k KeyBuilder) GreaterThan(v interface{}) KeyConditionBuilder {
+	return k.condition(compare("%s > %s", Name(k.name), v))
+}
+
+// GreaterThanEqual returns the key condition k >= v.
+func (k KeyBuilder) GreaterThanEqual(v interface{}) KeyConditionBuilder {
+	return k.condition(compare("%s >= %s", Name(k.name), v))
+}
+
+// Between returns the key condition k BETWEEN lo AND hi.
+func (k KeyBuilder) Between(lo, hi interface{}) KeyConditionBuilder {
+	return k.condition(ConditionBuilder{format: "%s BETWEEN %s AND %s",
+		operands: []OperandBuilder{Name(k.name), operand(lo), operand(hi)}})
+}
+
+// BeginsWith returns the key condition begins_with(k, prefix).
+func (k KeyBuilder) BeginsWith(prefix string) KeyConditionBuilder {
+	return k.condition(BeginsWith(Name(k.name), prefix))
+}
+
+// And returns the key condition joining k and other, which must each be on one key.
+func (k KeyConditionBuilder) And(other KeyConditionBuilder) KeyConditionBuilder {
+	return KeyConditionBuilder{
+		terms: append(append([]ConditionBuilder{}, k.terms...), other.terms...),
+		keys:  append(append([]string{}, k.keys...), other.keys...),
+	}
+}
+
+func (k KeyConditionBuilder) build(a *alloc) (string, error) {
+	switch {
+	case len(k.terms) == 0:
+		return "", errors.New("builder.KeyConditionBuilder: empty key condition")
+	case len(k.terms) > 2:
+		return "", errors.New("builder.KeyConditionBuilder: a key condition may only have two terms")
+	case len(k.keys) == 2 && k.keys[0] == k.keys[1]:
+		return "", errors.New("builder.KeyConditionBuilder: a key condition may only have one term per key")
+	}
+	for _, key := range k.keys {
+		if !Name(key).topLevel() {
+			e := fmt.Sprintf("builder.Key: %q is not a top level attribute", key)
+			return "", errors.New(e)
+		}
+	}
+	parts := make([]string, len(k.terms))
+	for i, t := range k.terms {
+		s, s_err := t.build(a)
+		if s_err != nil {
+			return "", s_err
+		}
+		parts[i] = s
+	}
+	return strings.Join(parts, " AND "), nil
+}
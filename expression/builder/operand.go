@@ -0,0 +1,211 @@
+package builder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// OperandBuilder is an operand of a condition or the value of a SET action: a
+// NameBuilder, a ValueBuilder, a SizeBuilder or a SetValueBuilder.
+type OperandBuilder interface {
+	build(a *alloc) (string, error)
+}
+
+// operand returns v if it is an OperandBuilder, or else Value(v).
+func operand(v interface{}) OperandBuilder {
+	if o, ok := v.(OperandBuilder); ok {
+		return o
+	}
+	return Value(v)
+}
+
+// NameBuilder is a document path such as Pictures.SideView or Reviews[1].Stars.
+type NameBuilder struct {
+	path string
+}
+
+// Name returns a NameBuilder for path. Elements are separated by "." and list
+// elements are selected with [n]; each attribute name is given a placeholder.
+func Name(path string) NameBuilder {
+	return NameBuilder{path: path}
+}
+
+func (n NameBuilder) build(a *alloc) (string, error) {
+	var b strings.Builder
+	for i, elem := range strings.Split(n.path, ".") {
+		name := elem
+		if j := strings.IndexByte(elem, '['); j >= 0 {
+			name = elem[:j]
+		}
+		if name == "" {
+			e := fmt.Sprintf("builder.Name: invalid document path %q", n.path)
+			return "", errors.New(e)
+		}
+		if i > 0 {
+			b.WriteString(".")
+		}
+		b.WriteString(a.name(name))
+		for rest := elem[len(name):]; rest != ""; {
+			k := strings.IndexByte(rest, ']')
+			if rest[0] != '[' || k < 0 {
+				e := fmt.Sprintf("builder.Name: invalid document path %q", n.path)
+				return "", errors.New(e)
+			}
+			if _, a_err := strconv.ParseUint(rest[1:k], 10, 31); a_err != nil {
+				e := fmt.Sprintf("builder.Name: invalid list index in document path %q", n.path)
+				return "", errors.New(e)
+			}
+			b.WriteString(rest[:k+1])
+			rest = rest[k+1:]
+		}
+	}
+	return b.String(), nil
+}
+
+// topLevel reports whether the path names a top level attribute.
+func (n NameBuilder) topLevel() bool {
+	return n.path != "" && !strings.ContainsAny(n.path, ".[")
+}
+
+// ValueBuilder is a value, given a :value placeholder.
+type ValueBuilder struct {
+	value interface{}
+}
+
+// Value returns a ValueBuilder for v. v may be an *attributevalue.AttributeValue,
+// a string, []byte, bool, nil or number, or anything that marshals to json, which is
+// converted with attributevalue.CoerceToAttributeValue (so a list of strings or
+// numbers becomes a set).
+func Value(v interface{}) ValueBuilder {
+	return ValueBuilder{value: v}
+}
+
+func (v ValueBuilder) build(a *alloc) (string, error) {
+	av, av_err := toAttributeValue(v.value)
+	if av_err != nil {
+		return "", av_err
+	}
+	return a.value(av), nil
+}
+
+// toAttributeValue converts v for a ValueBuilder.
+func toAttributeValue(v interface{}) (*attributevalue.AttributeValue, error) {
+	a := attributevalue.NewAttributeValue()
+	switch x := v.(type) {
+	case *attributevalue.AttributeValue:
+		if !x.Valid() {
+			return nil, errors.New("builder.Value: invalid AttributeValue")
+		}
+		return x, nil
+	case attributevalue.AttributeValue:
+		return toAttributeValue(&x)
+	case nil:
+		return a, a.InsertNULL(true)
+	case string:
+		return a, a.InsertS(x)
+	case []byte:
+		return a, a.InsertB_unencoded(string(x))
+	case bool:
+		return a, a.InsertBOOL(x)
+	}
+	r := reflect.ValueOf(v)
+	switch r.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a, a.InsertN(strconv.FormatInt(r.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return a, a.InsertN(strconv.FormatUint(r.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		return a, a.InsertN(strconv.FormatFloat(r.Float(), 'f', -1, 64))
+	}
+	b, json_err := json.Marshal(v)
+	if json_err == nil {
+		var c *attributevalue.AttributeValue
+		if c, json_err = attributevalue.BasicJSONToAttributeValue(b); json_err == nil {
+			return c, nil
+		}
+	}
+	e := fmt.Sprintf("builder.Value: cannot convert %v: %s", v, json_err.Error())
+	return nil, errors.New(e)
+}
+
+// SizeBuilder is the size function applied to a document path.
+type SizeBuilder struct {
+	name NameBuilder
+}
+
+// Size returns a SizeBuilder for the size of the attribute at n.
+func (n NameBuilder) Size() SizeBuilder {
+	return SizeBuilder{name: n}
+}
+
+func (s SizeBuilder) build(a *alloc) (string, error) {
+	p, p_err := s.name.build(a)
+	if p_err != nil {
+		return "", p_err
+	}
+	return "size(" + p + ")", nil
+}
+
+// SetValueBuilder is a value computed by a SET action: arithmetic, list_append or
+// if_not_exists.
+type SetValueBuilder struct {
+	format string
+	l, r   OperandBuilder
+}
+
+func (s SetValueBuilder) build(a *alloc) (string, error) {
+	l, l_err := s.l.build(a)
+	if l_err != nil {
+		return "", l_err
+	}
+	r, r_err := s.r.build(a)
+	if r_err != nil {
+		return "", r_err
+	}
+	return fmt.Sprintf(s.format, l, r), nil
+}
+
+// Plus returns the sum of l and r, each an OperandBuilder or a value.
+func Plus(l, r interface{}) SetValueBuilder {
+	return SetValueBuilder{format: "%s + %s", l: operand(l), r: operand(r)}
+}
+
+// Minus returns l less r, each an OperandBuilder or a value.
+func Minus(l, r interface{}) SetValueBuilder {
+	return SetValueBuilder{format: "%s - %s", l: operand(l), r: operand(r)}
+}
+
+// ListAppend returns the list l with the elements of the list r appended.
+func ListAppend(l, r interface{}) SetValueBuilder {
+	return SetValueBuilder{format: "list_append(%s, %s)", l: operand(l), r: operand(r)}
+}
+
+// IfNotExists returns the attribute at n if it exists, or else def.
+func IfNotExists(n NameBuilder, def interface{}) SetValueBuilder {
+	return SetValueBuilder{format: "if_not_exists(%s, %s)", l: n, r: operand(def)}
+}
+
+// Plus returns the value of n plus v.
+func (n NameBuilder) Plus(v interface{}) SetValueBuilder {
+	return Plus(n, v)
+}
+
+// Minus returns the value of n less v.
+func (n NameBuilder) Minus(v interface{}) SetValueBuilder {
+	return Minus(n, v)
+}
+
+// ListAppend returns the list at n with the elements of v appended.
+func (n NameBuilder) ListAppend(v interface{}) SetValueBuilder {
+	return ListAppend(n, v)
+}
+
+// IfNotExists returns the attribute at n if it exists, or else def.
+func (n NameBuilder) IfNotExists(def interface{}) SetValueBuilder {
+	return IfNotExists(n, def)
+}
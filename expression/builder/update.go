@@ -0,0 +1,102 @@
+package builder
+
+import (
+	"errors"
+	"github.com/smugmug/godynamo/expression"
+	"strings"
+)
+
+// the order in which clauses are written
+var clauses = []string{expression.CLAUSE_SET, expression.CLAUSE_REMOVE, expression.CLAUSE_ADD, expression.CLAUSE_DELETE}
+
+type action struct {
+	clause string
+	name   NameBuilder
+	value  OperandBuilder
+}
+
+// UpdateBuilder builds an UpdateExpression from SET, REMOVE, ADD and DELETE actions.
+type UpdateBuilder struct {
+	actions []action
+}
+
+func (u UpdateBuilder) with(a action) UpdateBuilder {
+	return UpdateBuilder{actions: append(append([]action{}, u.actions...), a)}
+}
+
+// Set returns an UpdateBuilder that sets n to v, which is an OperandBuilder (such as
+// Name("Count").Plus(1) or IfNotExists(...)) or a value.
+func Set(n NameBuilder, v interface{}) UpdateBuilder {
+	return UpdateBuilder{}.Set(n, v)
+}
+
+// Remove returns an UpdateBuilder that removes n.
+func Remove(n NameBuilder) UpdateBuilder {
+	return UpdateBuilder{}.Remove(n)
+}
+
+// Add returns an UpdateBuilder that adds the number or set v to n.
+func Add(n NameBuilder, v interface{}) UpdateBuilder {
+	return UpdateBuilder{}.Add(n, v)
+}
+
+// Delete returns an UpdateBuilder that deletes the members of the set v from n.
+func Delete(n NameBuilder, v interface{}) UpdateBuilder {
+	return UpdateBuilder{}.Delete(n, v)
+}
+
+// Set adds an action setting n to v.
+func (u UpdateBuilder) Set(n NameBuilder, v interface{}) UpdateBuilder {
+	return u.with(action{clause: expression.CLAUSE_SET, name: n, value: operand(v)})
+}
+
+// Remove adds an action removing n.
+func (u UpdateBuilder) Remove(n NameBuilder) UpdateBuilder {
+	return u.with(action{clause: expression.CLAUSE_REMOVE, name: n})
+}
+
+// Add adds an action adding the number or set v to n.
+func (u UpdateBuilder) Add(n NameBuilder, v interface{}) UpdateBuilder {
+	return u.with(action{clause: expression.CLAUSE_ADD, name: n, value: Value(v)})
+}
+
+// Delete adds an action deleting the members of the set v from n.
+func (u UpdateBuilder) Delete(n NameBuilder, v interface{}) UpdateBuilder {
+	return u.with(action{clause: expression.CLAUSE_DELETE, name: n, value: Value(v)})
+}
+
+func (u UpdateBuilder) build(a *alloc) (string, error) {
+	if len(u.actions) == 0 {
+		return "", errors.New("builder.UpdateBuilder: no actions")
+	}
+	sections := make([]string, 0, len(clauses))
+	for _, clause := range clauses {
+		parts := make([]string, 0)
+		for _, act := range u.actions {
+			if act.clause != clause {
+				continue
+			}
+			n, n_err := act.name.build(a)
+			if n_err != nil {
+				return "", n_err
+			}
+			if act.value == nil {
+				parts = append(parts, n)
+				continue
+			}
+			v, v_err := act.value.build(a)
+			if v_err != nil {
+				return "", v_err
+			}
+			if clause == expression.CLAUSE_SET {
+				parts = append(parts, n+" = "+v)
+			} else {
+				parts = append(parts, n+" "+v)
+			}
+		}
+		if len(parts) != 0 {
+			sections = append(sections, clause+" "+strings.Join(parts, ", "))
+		}
+	}
+	return strings.Join(sections, " "), nil
+}
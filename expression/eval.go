@@ -0,0 +1,472 @@
+package expression
+
+import (
+	"errors"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/aws_strings"
+	"sort"
+	"strconv"
+)
+
+// resolve returns the value at path in it, or nil if there is none.
+func resolve(it attributevalue.AttributeValueMap, path Path) *attributevalue.AttributeValue {
+	if len(path) == 0 || path[0].Name == "" {
+		return nil
+	}
+	v := it[path[0].Name]
+	for _, e := range path[1:] {
+		if v == nil {
+			return nil
+		}
+		switch {
+		case e.Name != "" && TypeOf(v) == aws_strings.M:
+			v = v.M[e.Name]
+		case e.Name == "" && TypeOf(v) == aws_strings.L && e.Index < len(v.L):
+			v = v.L[e.Index]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+type operand interface {
+	eval(it attributevalue.AttributeValueMap) (*attributevalue.AttributeValue, error)
+}
+
+type pathOp struct {
+	path Path
+}
+
+func (o pathOp) eval(it attributevalue.AttributeValueMap) (*attributevalue.AttributeValue, error) {
+	return resolve(it, o.path), nil
+}
+
+type valueOp struct {
+	v *attributevalue.AttributeValue
+}
+
+func (o valueOp) eval(it attributevalue.AttributeValueMap) (*attributevalue.AttributeValue, error) {
+	return o.v, nil
+}
+
+type sizeOp struct {
+	path Path
+}
+
+func (o sizeOp) eval(it attributevalue.AttributeValueMap) (*attributevalue.AttributeValue, error) {
+	n, ok := Size(resolve(it, o.path))
+	if !ok {
+		return nil, nil
+	}
+	return &attributevalue.AttributeValue{N: strconv.Itoa(n)}, nil
+}
+
+type ifNotExistsOp struct {
+	path Path
+	def  operand
+}
+
+func (o ifNotExistsOp) eval(it attributevalue.AttributeValueMap) (*attributevalue.AttributeValue, error) {
+	if v := resolve(it, o.path); v != nil {
+		return v, nil
+	}
+	return o.def.eval(it)
+}
+
+type listAppendOp struct {
+	a, b operand
+}
+
+func (o listAppendOp) eval(it attributevalue.AttributeValueMap) (*attributevalue.AttributeValue, error) {
+	a, a_err := o.a.eval(it)
+	if a_err != nil {
+		return nil, a_err
+	}
+	b, b_err := o.b.eval(it)
+	if b_err != nil {
+		return nil, b_err
+	}
+	if a == nil || b == nil {
+		return nil, errors.New("The provided expression refers to an attribute that does not exist in the item")
+	}
+	if TypeOf(a) != aws_strings.L || TypeOf(b) != aws_strings.L {
+		return nil, errors.New("An operand in the update expression has an incorrect data type")
+	}
+	l := attributevalue.NewAttributeValue()
+	for _, v := range append(append([]*attributevalue.AttributeValue{}, a.L...), b.L...) {
+		l.L = append(l.L, copyValue(v))
+	}
+	return l, nil
+}
+
+type arithOp struct {
+	op   string
+	a, b operand
+}
+
+func (o arithOp) eval(it attributevalue.AttributeValueMap) (*attributevalue.AttributeValue, error) {
+	a, a_err := o.a.eval(it)
+	if a_err != nil {
+		return nil, a_err
+	}
+	b, b_err := o.b.eval(it)
+	if b_err != nil {
+		return nil, b_err
+	}
+	if a == nil || b == nil {
+		return nil, errors.New("The provided expression refers to an attribute that does not exist in the item")
+	}
+	return arith(o.op, a, b)
+}
+
+// condNode is a node of a parsed condition. Operands of conditions never fail to evaluate;
+// a missing attribute or an unsupported size makes the comparison false.
+type condNode interface {
+	eval(it attributevalue.AttributeValueMap) bool
+}
+
+type andNode struct {
+	l, r condNode
+}
+
+func (n andNode) eval(it attributevalue.AttributeValueMap) bool {
+	return n.l.eval(it) && n.r.eval(it)
+}
+
+type orNode struct {
+	l, r condNode
+}
+
+func (n orNode) eval(it attributevalue.AttributeValueMap) bool {
+	return n.l.eval(it) || n.r.eval(it)
+}
+
+type notNode struct {
+	c condNode
+}
+
+func (n notNode) eval(it attributevalue.AttributeValueMap) bool {
+	return !n.c.eval(it)
+}
+
+type cmpNode struct {
+	op   string
+	l, r operand
+}
+
+func (n cmpNode) eval(it attributevalue.AttributeValueMap) bool {
+	l, _ := n.l.eval(it)
+	r, _ := n.r.eval(it)
+	if l == nil || r == nil {
+		return n.op == "<>" && (l != nil || r != nil)
+	}
+	switch n.op {
+	case "=":
+		return Equal(l, r)
+	case "<>":
+		return !Equal(l, r)
+	}
+	c, ok := Compare(l, r)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+type betweenNode struct {
+	v, lo, hi operand
+}
+
+func (n betweenNode) eval(it attributevalue.AttributeValueMap) bool {
+	v, _ := n.v.eval(it)
+	lo, _ := n.lo.eval(it)
+	hi, _ := n.hi.eval(it)
+	if v == nil || lo == nil || hi == nil {
+		return false
+	}
+	c_lo, lo_ok := Compare(v, lo)
+	c_hi, hi_ok := Compare(v, hi)
+	return lo_ok && hi_ok && c_lo >= 0 && c_hi <= 0
+}
+
+type inNode struct {
+	v    operand
+	list []operand
+}
+
+func (n inNode) eval(it attributevalue.AttributeValueMap) bool {
+	v, _ := n.v.eval(it)
+	if v == nil {
+		return false
+	}
+	for _, o := range n.list {
+		if a, _ := o.eval(it); a != nil && Equal(v, a) {
+			return true
+		}
+	}
+	return false
+}
+
+type funcNode struct {
+	name string
+	path Path
+	arg  operand
+}
+
+func (n funcNode) eval(it attributevalue.AttributeValueMap) bool {
+	v := resolve(it, n.path)
+	switch n.name {
+	case "attribute_exists":
+		return v != nil
+	case "attribute_not_exists":
+		return v == nil
+	}
+	arg, _ := n.arg.eval(it)
+	if v == nil || arg == nil {
+		return false
+	}
+	switch n.name {
+	case "attribute_type":
+		return TypeOf(v) == arg.S
+	case "begins_with":
+		return BeginsWith(v, arg)
+	}
+	return Contains(v, arg)
+}
+
+type setAction struct {
+	path  Path
+	value operand
+}
+
+type valueAction struct {
+	path  Path
+	value *attributevalue.AttributeValue
+}
+
+// paths returns the paths written by an update.
+func (u *Update) paths() []Path {
+	paths := make([]Path, 0)
+	for _, s := range u.sets {
+		paths = append(paths, s.path)
+	}
+	paths = append(paths, u.removes...)
+	for _, a := range u.adds {
+		paths = append(paths, a.path)
+	}
+	for _, d := range u.deletes {
+		paths = append(paths, d.path)
+	}
+	return paths
+}
+
+var errInvalidPath = errors.New("The document path provided in the update expression is invalid for update")
+
+// parentOf returns the value containing the last element of path, or nil with
+// errInvalidPath if it does not exist.
+func parentOf(it attributevalue.AttributeValueMap, path Path) (*attributevalue.AttributeValue, error) {
+	if len(path) == 1 {
+		return nil, nil
+	}
+	parent := resolve(it, path[:len(path)-1])
+	last := path[len(path)-1]
+	if parent == nil || (last.Name != "" && TypeOf(parent) != aws_strings.M) ||
+		(last.Name == "" && TypeOf(parent) != aws_strings.L) {
+		return nil, errInvalidPath
+	}
+	return parent, nil
+}
+
+// put stores v at path. Setting a list index past the end of the list appends to it.
+func put(it attributevalue.AttributeValueMap, path Path, v *attributevalue.AttributeValue) error {
+	parent, p_err := parentOf(it, path)
+	if p_err != nil {
+		return p_err
+	}
+	last := path[len(path)-1]
+	switch {
+	case parent == nil:
+		it[last.Name] = v
+	case last.Name != "":
+		parent.M[last.Name] = v
+	case last.Index < len(parent.L):
+		parent.L[last.Index] = v
+	default:
+		parent.L = append(parent.L, v)
+	}
+	return nil
+}
+
+// remove deletes the value at path, shifting later list elements down. Since an
+// AttributeValue cannot hold an empty list or map, a container left empty is removed too.
+func remove(it attributevalue.AttributeValueMap, path Path) {
+	if resolve(it, path) == nil {
+		return
+	}
+	last := path[len(path)-1]
+	if len(path) == 1 {
+		delete(it, last.Name)
+		return
+	}
+	parent := resolve(it, path[:len(path)-1])
+	if last.Name != "" {
+		delete(parent.M, last.Name)
+	} else {
+		parent.L = append(parent.L[:last.Index], parent.L[last.Index+1:]...)
+	}
+	if TypeOf(parent) == "" {
+		remove(it, path[:len(path)-1])
+	}
+}
+
+// apply performs the actions of the update on it in place. All SET values are computed
+// from the item as it was before the update.
+func (u *Update) apply(it attributevalue.AttributeValueMap) error {
+	orig := copyItem(it)
+	values := make([]*attributevalue.AttributeValue, len(u.sets))
+	for i, s := range u.sets {
+		v, v_err := s.value.eval(orig)
+		if v_err != nil {
+			return v_err
+		}
+		if v == nil {
+			return errors.New("The provided expression refers to an attribute that does not exist in the item")
+		}
+		values[i] = copyValue(v)
+	}
+	for i, s := range u.sets {
+		if p_err := put(it, s.path, values[i]); p_err != nil {
+			return p_err
+		}
+	}
+	// remove list elements from the highest index down so earlier removals do not
+	// shift the elements named by later ones
+	removes := append([]Path{}, u.removes...)
+	sort.SliceStable(removes, func(i, j int) bool {
+		a, b := removes[i], removes[j]
+		pa, pb := a[:len(a)-1].String(), b[:len(b)-1].String()
+		if pa != pb {
+			return pa < pb
+		}
+		la, lb := a[len(a)-1], b[len(b)-1]
+		if la.Name != lb.Name {
+			return la.Name < lb.Name
+		}
+		return la.Index > lb.Index
+	})
+	for _, path := range removes {
+		remove(it, path)
+	}
+	for _, a := range u.adds {
+		if _, p_err := parentOf(it, a.path); p_err != nil {
+			return p_err
+		}
+		v, v_err := AddValue(resolve(it, a.path), a.value)
+		if v_err != nil {
+			return v_err
+		}
+		if p_err := put(it, a.path, v); p_err != nil {
+			return p_err
+		}
+	}
+	for _, d := range u.deletes {
+		v, v_err := DeleteValue(resolve(it, d.path), d.value)
+		if v_err != nil {
+			return v_err
+		}
+		if v == nil {
+			remove(it, d.path)
+		} else if p_err := put(it, d.path, v); p_err != nil {
+			return p_err
+		}
+	}
+	return nil
+}
+
+// selection is a tree of projected paths.
+type selection struct {
+	all     bool
+	names   map[string]*selection
+	indexes map[int]*selection
+}
+
+func (s *selection) add(path Path) {
+	for _, e := range path {
+		if s.all {
+			return
+		}
+		var next *selection
+		if e.Name != "" {
+			if s.names == nil {
+				s.names = make(map[string]*selection)
+			}
+			if next = s.names[e.Name]; next == nil {
+				next = new(selection)
+				s.names[e.Name] = next
+			}
+		} else {
+			if s.indexes == nil {
+				s.indexes = make(map[int]*selection)
+			}
+			if next = s.indexes[e.Index]; next == nil {
+				next = new(selection)
+				s.indexes[e.Index] = next
+			}
+		}
+		s = next
+	}
+	s.all = true
+}
+
+// extract copies the selected parts of v. Selected list elements are returned in a
+// list of their own, in index order.
+func (s *selection) extract(v *attributevalue.AttributeValue) *attributevalue.AttributeValue {
+	if s.all {
+		return copyValue(v)
+	}
+	out := attributevalue.NewAttributeValue()
+	switch TypeOf(v) {
+	case aws_strings.M:
+		for name, sub := range s.names {
+			if m, ok := v.M[name]; ok {
+				if x := sub.extract(m); x != nil {
+					out.M[name] = x
+				}
+			}
+		}
+	case aws_strings.L:
+		idx := make([]int, 0, len(s.indexes))
+		for i := range s.indexes {
+			idx = append(idx, i)
+		}
+		sort.Ints(idx)
+		for _, i := range idx {
+			if i < len(v.L) {
+				if x := s.indexes[i].extract(v.L[i]); x != nil {
+					out.L = append(out.L, x)
+				}
+			}
+		}
+	}
+	if TypeOf(out) == "" {
+		return nil
+	}
+	return out
+}
+
+func copyItem(it attributevalue.AttributeValueMap) attributevalue.AttributeValueMap {
+	c := attributevalue.NewAttributeValueMap()
+	for k, v := range it {
+		c[k] = copyValue(v)
+	}
+	return c
+}
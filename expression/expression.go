@@ -0,0 +1,533 @@
+// Implements a parser and evaluator for DynamoDB expressions. See
+// http://docs.aws.amazon.com/amazondynamodb/latest/developerguide/Expressions.html
+//
+// Expressions are otherwise passed to DynamoDB as opaque strings, so mistakes are only
+// reported by the service. This package parses ConditionExpression, FilterExpression,
+// KeyConditionExpression, ProjectionExpression and UpdateExpression strings, resolving
+// their ExpressionAttributeNames and ExpressionAttributeValues, so that requests may be
+// validated offline and expressions may be evaluated against items client side:
+//
+//	e := expression.Expressions{
+//		UpdateExpression:          "SET #c = #c + :one REMOVE Pending",
+//		ExpressionAttributeNames:  map[string]string{"#c": "Count"},
+//		ExpressionAttributeValues: values,
+//	}
+//	parsed, err := e.Parse()
+//	...
+//	updated, err := parsed.Update.Apply(current_item)
+//
+// Error messages follow the text of the ValidationException DynamoDB would return.
+package expression
+
+import (
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/aws_strings"
+	"github.com/smugmug/godynamo/types/condition"
+	"github.com/smugmug/godynamo/types/expressionattributenames"
+	"github.com/smugmug/godynamo/types/item"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// the clauses of an update expression
+	CLAUSE_SET    = "SET"
+	CLAUSE_REMOVE = "REMOVE"
+	CLAUSE_ADD    = "ADD"
+	CLAUSE_DELETE = "DELETE"
+	// the names of the expression fields, as used in error messages
+	CONDITION_EXPRESSION     = "ConditionExpression"
+	FILTER_EXPRESSION        = "FilterExpression"
+	KEY_CONDITION_EXPRESSION = "KeyConditionExpression"
+	PROJECTION_EXPRESSION    = "ProjectionExpression"
+	UPDATE_EXPRESSION        = "UpdateExpression"
+)
+
+// PathElement is one element of a document path: an attribute or map key Name,
+// or a list Index when Name is "".
+type PathElement struct {
+	Name  string
+	Index int
+}
+
+// Path is a document path such as Pictures.SideView or Reviews[1].Stars, with
+// expression attribute names already resolved.
+type Path []PathElement
+
+// String renders the path in expression syntax.
+func (p Path) String() string {
+	var b strings.Builder
+	for i, e := range p {
+		switch {
+		case e.Name == "":
+			b.WriteString("[" + strconv.Itoa(e.Index) + "]")
+		case i > 0:
+			b.WriteString("." + e.Name)
+		default:
+			b.WriteString(e.Name)
+		}
+	}
+	return b.String()
+}
+
+// overlaps reports whether p and q are equal or one is a prefix of the other.
+func (p Path) overlaps(q Path) bool {
+	n := len(p)
+	if len(q) < n {
+		n = len(q)
+	}
+	for i := 0; i < n; i++ {
+		if p[i] != q[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Condition is a parsed ConditionExpression or FilterExpression.
+type Condition struct {
+	root condNode
+}
+
+// Evaluate reports whether it satisfies the condition. Pass a nil or empty item to
+// evaluate a ConditionExpression for an item that does not exist.
+func (c *Condition) Evaluate(it item.Item) bool {
+	if c == nil {
+		return true
+	}
+	return c.root.eval(attributevalue.AttributeValueMap(it))
+}
+
+// Filter returns the items satisfying the condition.
+func (c *Condition) Filter(items []item.Item) []item.Item {
+	matched := make([]item.Item, 0, len(items))
+	for _, it := range items {
+		if c.Evaluate(it) {
+			matched = append(matched, it)
+		}
+	}
+	return matched
+}
+
+// KeyTerm is one term of a KeyConditionExpression, using the ComparisonOperator
+// names of the legacy KeyConditions parameter.
+type KeyTerm struct {
+	AttributeName      string
+	ComparisonOperator string
+	AttributeValueList []*attributevalue.AttributeValue
+}
+
+// KeyCondition is a parsed KeyConditionExpression: a condition on a hash key,
+// optionally ANDed with a condition on a range key.
+type KeyCondition struct {
+	Terms []KeyTerm
+}
+
+// Conditions returns the key condition as the equivalent KeyConditions parameter.
+func (k *KeyCondition) Conditions() condition.Conditions {
+	cs := condition.NewConditions()
+	if k == nil {
+		return cs
+	}
+	for _, t := range k.Terms {
+		c := condition.NewCondition()
+		c.ComparisonOperator = t.ComparisonOperator
+		c.AttributeValueList = t.AttributeValueList
+		cs[t.AttributeName] = c
+	}
+	return cs
+}
+
+// Evaluate reports whether it satisfies every term of the key condition.
+func (k *KeyCondition) Evaluate(it item.Item) bool {
+	if k == nil {
+		return true
+	}
+	for _, t := range k.Terms {
+		v := it[t.AttributeName]
+		if v == nil {
+			return false
+		}
+		var ok bool
+		switch t.ComparisonOperator {
+		case aws_strings.OP_BEGINS_WITH:
+			ok = BeginsWith(v, t.AttributeValueList[0])
+		case aws_strings.OP_BETWEEN:
+			lo, lo_ok := Compare(v, t.AttributeValueList[0])
+			hi, hi_ok := Compare(v, t.AttributeValueList[1])
+			ok = lo_ok && hi_ok && lo >= 0 && hi <= 0
+		default:
+			c, c_ok := Compare(v, t.AttributeValueList[0])
+			ok = c_ok && keyOps[t.ComparisonOperator](c)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+var keyOps = map[string]func(int) bool{
+	aws_strings.OP_EQ: func(c int) bool { return c == 0 },
+	aws_strings.OP_LT: func(c int) bool { return c < 0 },
+	aws_strings.OP_LE: func(c int) bool { return c <= 0 },
+	aws_strings.OP_GT: func(c int) bool { return c > 0 },
+	aws_strings.OP_GE: func(c int) bool { return c >= 0 },
+}
+
+var keyComparators = map[string]string{
+	"=": aws_strings.OP_EQ, "<": aws_strings.OP_LT, "<=": aws_strings.OP_LE,
+	">": aws_strings.OP_GT, ">=": aws_strings.OP_GE,
+}
+
+// keyTerm converts one node of a parsed key condition.
+func (p *parser) keyTerm(n condNode) (KeyTerm, error) {
+	var t KeyTerm
+	var path Path
+	var args []operand
+	switch n := n.(type) {
+	case cmpNode:
+		op, ok := keyComparators[n.op]
+		if !ok {
+			return t, p.fail("Unsupported operator in KeyConditionExpression: " + n.op)
+		}
+		t.ComparisonOperator = op
+		l, l_ok := n.l.(pathOp)
+		if !l_ok {
+			return t, p.fail("Key conditions must name a key attribute on the left of the operator")
+		}
+		path, args = l.path, []operand{n.r}
+	case betweenNode:
+		l, l_ok := n.v.(pathOp)
+		if !l_ok {
+			return t, p.fail("Key conditions must name a key attribute on the left of the operator")
+		}
+		t.ComparisonOperator = aws_strings.OP_BETWEEN
+		path, args = l.path, []operand{n.lo, n.hi}
+	case funcNode:
+		if n.name != "begins_with" {
+			return t, p.fail("Invalid operator used in KeyConditionExpression: " + n.name)
+		}
+		t.ComparisonOperator = aws_strings.OP_BEGINS_WITH
+		path, args = n.path, []operand{n.arg}
+	case orNode:
+		return t, p.fail("Invalid operator used in KeyConditionExpression: OR")
+	case notNode:
+		return t, p.fail("Invalid operator used in KeyConditionExpression: NOT")
+	case inNode:
+		return t, p.fail("Invalid operator used in KeyConditionExpression: IN")
+	default:
+		return t, p.fail("Invalid KeyConditionExpression")
+	}
+	if len(path) != 1 {
+		return t, p.fail("KeyConditionExpressions cannot contain nested attribute paths: " + path.String())
+	}
+	t.AttributeName = path[0].Name
+	for _, a := range args {
+		v, ok := a.(valueOp)
+		if !ok {
+			return t, p.fail("Key conditions must compare a key attribute with expression attribute values")
+		}
+		t.AttributeValueList = append(t.AttributeValueList, v.v)
+	}
+	return t, nil
+}
+
+// Update is a parsed UpdateExpression.
+type Update struct {
+	sets    []setAction
+	removes []Path
+	adds    []valueAction
+	deletes []valueAction
+}
+
+// Apply returns a copy of it with the update applied. it may be nil if the item does
+// not exist. The error has the text of the ValidationException DynamoDB would return,
+// for example when adding to an attribute that is not a number.
+func (u *Update) Apply(it item.Item) (item.Item, error) {
+	updated := copyItem(attributevalue.AttributeValueMap(it))
+	if u == nil {
+		return item.Item(updated), nil
+	}
+	if a_err := u.apply(updated); a_err != nil {
+		return nil, a_err
+	}
+	return item.Item(updated), nil
+}
+
+// Attributes returns the sorted names of the top level attributes the update writes.
+func (u *Update) Attributes() []string {
+	if u == nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for _, p := range u.paths() {
+		if !seen[p[0].Name] {
+			seen[p[0].Name] = true
+			names = append(names, p[0].Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Projection is a parsed ProjectionExpression.
+type Projection struct {
+	paths []Path
+}
+
+// Paths returns the projected paths.
+func (p *Projection) Paths() []Path {
+	if p == nil {
+		return nil
+	}
+	return append([]Path{}, p.paths...)
+}
+
+// Apply returns the projected attributes of it. Projected list elements are returned
+// in a list of their own, in index order, as DynamoDB does.
+func (p *Projection) Apply(it item.Item) item.Item {
+	if p == nil {
+		return item.Item(copyItem(attributevalue.AttributeValueMap(it)))
+	}
+	root := new(selection)
+	for _, path := range p.paths {
+		root.add(path)
+	}
+	projected := item.NewItem()
+	for name, sub := range root.names {
+		if v, ok := it[name]; ok {
+			if x := sub.extract(v); x != nil {
+				projected[name] = x
+			}
+		}
+	}
+	return projected
+}
+
+// ParseCondition parses a ConditionExpression.
+func ParseCondition(expr string, names expressionattributenames.ExpressionAttributeNames,
+	values attributevalue.AttributeValueMap) (*Condition, error) {
+	c, _, c_err := parseCondition(CONDITION_EXPRESSION, expr, names, values)
+	return c, c_err
+}
+
+// ParseFilter parses a FilterExpression, which has the same grammar as a ConditionExpression.
+func ParseFilter(expr string, names expressionattributenames.ExpressionAttributeNames,
+	values attributevalue.AttributeValueMap) (*Condition, error) {
+	c, _, c_err := parseCondition(FILTER_EXPRESSION, expr, names, values)
+	return c, c_err
+}
+
+func parseCondition(kind, expr string, names expressionattributenames.ExpressionAttributeNames,
+	values attributevalue.AttributeValueMap) (*Condition, *parser, error) {
+	p, p_err := newParser(kind, expr, names, values)
+	if p_err != nil {
+		return nil, nil, p_err
+	}
+	root, c_err := p.condition()
+	if c_err != nil {
+		return nil, nil, c_err
+	}
+	if e_err := p.end(); e_err != nil {
+		return nil, nil, e_err
+	}
+	return &Condition{root: root}, p, nil
+}
+
+// ParseKeyCondition parses a KeyConditionExpression.
+func ParseKeyCondition(expr string, names expressionattributenames.ExpressionAttributeNames,
+	values attributevalue.AttributeValueMap) (*KeyCondition, error) {
+	k, _, k_err := parseKeyCondition(expr, names, values)
+	return k, k_err
+}
+
+func parseKeyCondition(expr string, names expressionattributenames.ExpressionAttributeNames,
+	values attributevalue.AttributeValueMap) (*KeyCondition, *parser, error) {
+	c, p, c_err := parseCondition(KEY_CONDITION_EXPRESSION, expr, names, values)
+	if c_err != nil {
+		return nil, nil, c_err
+	}
+	nodes := []condNode{c.root}
+	if and, ok := c.root.(andNode); ok {
+		if _, nested := and.l.(andNode); nested {
+			return nil, nil, p.fail("KeyConditionExpressions must only contain one condition per key")
+		}
+		nodes = []condNode{and.l, and.r}
+	}
+	k := new(KeyCondition)
+	for _, n := range nodes {
+		t, t_err := p.keyTerm(n)
+		if t_err != nil {
+			return nil, nil, t_err
+		}
+		if len(k.Terms) == 1 && k.Terms[0].AttributeName == t.AttributeName {
+			return nil, nil, p.fail("KeyConditionExpressions must only contain one condition per key")
+		}
+		k.Terms = append(k.Terms, t)
+	}
+	return k, p, nil
+}
+
+// ParseUpdate parses an UpdateExpression.
+func ParseUpdate(expr string, names expressionattributenames.ExpressionAttributeNames,
+	values attributevalue.AttributeValueMap) (*Update, error) {
+	u, _, u_err := parseUpdate(expr, names, values)
+	return u, u_err
+}
+
+func parseUpdate(expr string, names expressionattributenames.ExpressionAttributeNames,
+	values attributevalue.AttributeValueMap) (*Update, *parser, error) {
+	p, p_err := newParser(UPDATE_EXPRESSION, expr, names, values)
+	if p_err != nil {
+		return nil, nil, p_err
+	}
+	u, u_err := p.update()
+	if u_err != nil {
+		return nil, nil, u_err
+	}
+	return u, p, nil
+}
+
+// ParseProjection parses a ProjectionExpression.
+func ParseProjection(expr string, names expressionattributenames.ExpressionAttributeNames) (*Projection, error) {
+	pr, _, pr_err := parseProjection(expr, names)
+	return pr, pr_err
+}
+
+func parseProjection(expr string, names expressionattributenames.ExpressionAttributeNames) (*Projection, *parser, error) {
+	p, p_err := newParser(PROJECTION_EXPRESSION, expr, names, nil)
+	if p_err != nil {
+		return nil, nil, p_err
+	}
+	pr, pr_err := p.projection()
+	if pr_err != nil {
+		return nil, nil, pr_err
+	}
+	return pr, p, nil
+}
+
+// Expressions holds the expression fields of a request. The field names match the
+// request types of the endpoint packages.
+type Expressions struct {
+	ConditionExpression       string
+	FilterExpression          string
+	KeyConditionExpression    string
+	ProjectionExpression      string
+	UpdateExpression          string
+	ExpressionAttributeNames  expressionattributenames.ExpressionAttributeNames
+	ExpressionAttributeValues attributevalue.AttributeValueMap
+}
+
+// Parsed holds the parsed expressions of a request. Fields are nil for expressions
+// that were not set.
+type Parsed struct {
+	Condition    *Condition
+	Filter       *Condition
+	KeyCondition *KeyCondition
+	Projection   *Projection
+	Update       *Update
+}
+
+// Parse parses every expression that is set and checks the attribute names and values
+// as DynamoDB does: every value must be valid, and every name and value must be used.
+func (e *Expressions) Parse() (*Parsed, error) {
+	if e == nil {
+		return nil, errors.New("expression.(Expressions)Parse: receiver is nil")
+	}
+	for k, v := range e.ExpressionAttributeValues {
+		if v == nil || !v.Valid() || TypeOf(v) == "" {
+			return nil, errors.New("ExpressionAttributeValues contains invalid value: " +
+				"One or more parameter values were invalid: An AttributeValue may not contain an empty string for key " + k)
+		}
+	}
+	pd := new(Parsed)
+	parsers := make([]*parser, 0)
+	var p *parser
+	var p_err error
+	if e.ConditionExpression != "" {
+		if pd.Condition, p, p_err = parseCondition(CONDITION_EXPRESSION, e.ConditionExpression,
+			e.ExpressionAttributeNames, e.ExpressionAttributeValues); p_err != nil {
+			return nil, p_err
+		}
+		parsers = append(parsers, p)
+	}
+	if e.FilterExpression != "" {
+		if pd.Filter, p, p_err = parseCondition(FILTER_EXPRESSION, e.FilterExpression,
+			e.ExpressionAttributeNames, e.ExpressionAttributeValues); p_err != nil {
+			return nil, p_err
+		}
+		parsers = append(parsers, p)
+	}
+	if e.KeyConditionExpression != "" {
+		if pd.KeyCondition, p, p_err = parseKeyCondition(e.KeyConditionExpression,
+			e.ExpressionAttributeNames, e.ExpressionAttributeValues); p_err != nil {
+			return nil, p_err
+		}
+		parsers = append(parsers, p)
+	}
+	if e.ProjectionExpression != "" {
+		if pd.Projection, p, p_err = parseProjection(e.ProjectionExpression,
+			e.ExpressionAttributeNames); p_err != nil {
+			return nil, p_err
+		}
+		parsers = append(parsers, p)
+	}
+	if e.UpdateExpression != "" {
+		if pd.Update, p, p_err = parseUpdate(e.UpdateExpression,
+			e.ExpressionAttributeNames, e.ExpressionAttributeValues); p_err != nil {
+			return nil, p_err
+		}
+		parsers = append(parsers, p)
+	}
+	if len(parsers) == 0 {
+		if len(e.ExpressionAttributeNames) != 0 {
+			return nil, errors.New("ExpressionAttributeNames can only be specified when using expressions")
+		}
+		if len(e.ExpressionAttributeValues) != 0 {
+			return nil, errors.New("ExpressionAttributeValues can only be specified when using expressions")
+		}
+	}
+	usedNames := make(map[string]bool)
+	usedValues := make(map[string]bool)
+	for _, p := range parsers {
+		for k := range p.usedNames {
+			usedNames[k] = true
+		}
+		for k := range p.usedValues {
+			usedValues[k] = true
+		}
+	}
+	if unused := unusedKeys(e.ExpressionAttributeNames, usedNames); unused != "" {
+		return nil, errors.New(fmt.Sprintf("Value provided in ExpressionAttributeNames unused in expressions: keys: {%s}", unused))
+	}
+	values := make(map[string]string, len(e.ExpressionAttributeValues))
+	for k := range e.ExpressionAttributeValues {
+		values[k] = ""
+	}
+	if unused := unusedKeys(values, usedValues); unused != "" {
+		return nil, errors.New(fmt.Sprintf("Value provided in ExpressionAttributeValues unused in expressions: keys: {%s}", unused))
+	}
+	return pd, nil
+}
+
+// Validate is the same as Parse, but only reports the error.
+func (e *Expressions) Validate() error {
+	_, p_err := e.Parse()
+	return p_err
+}
+
+func unusedKeys(m map[string]string, used map[string]bool) string {
+	unused := make([]string, 0)
+	for k := range m {
+		if !used[k] {
+			unused = append(unused, k)
+		}
+	}
+	sort.Strings(unused)
+	return strings.Join(unused, ", ")
+}
@@ -0,0 +1,290 @@
+package expression
+
+import (
+	"encoding/json"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/aws_strings"
+	"github.com/smugmug/godynamo/types/expressionattributenames"
+	"github.com/smugmug/godynamo/types/item"
+	"strings"
+	"testing"
+)
+
+const threadItem = `{"ForumName":{"S":"Amazon DynamoDB"},"Subject":{"S":"How do I update multiple items?"},
+ "Hits":{"N":"10"},"Tags":{"SS":["Update","Multiple Items"]},
+ "Replies":{"L":[{"S":"r0"},{"S":"r1"},{"S":"r2"}]},
+ "Pictures":{"M":{"SideView":{"S":"side"},"FrontView":{"S":"front"}}}}`
+
+func decodeItem(t *testing.T, s string) item.Item {
+	it := item.NewItem()
+	if um_err := json.Unmarshal([]byte(s), &it); um_err != nil {
+		t.Fatal(um_err)
+	}
+	return it
+}
+
+func decodeValues(t *testing.T, s string) attributevalue.AttributeValueMap {
+	v := attributevalue.NewAttributeValueMap()
+	if um_err := json.Unmarshal([]byte(s), &v); um_err != nil {
+		t.Fatal(um_err)
+	}
+	return v
+}
+
+func TestParseErrors(t *testing.T) {
+	names := expressionattributenames.ExpressionAttributeNames{"#v": "Hits"}
+	values := decodeValues(t, `{":n":{"N":"1"},":s":{"S":"x"}}`)
+	bad := map[string]string{
+		"":                         "can not be empty",
+		"Hits >":                   "Syntax error",
+		"Hits = :n AND":            "Syntax error",
+		"Size = :n":                "reserved keyword",
+		"#x = :n":                  "attribute name: #x",
+		"Hits = :missing":          "attribute value: :missing",
+		"frobnicate(Hits)":         "Invalid function name",
+		"if_not_exists(Hits, :n)":  "not allowed",
+		"attribute_type(Hits, :s)": "Invalid attribute type",
+		"Hits = :n $":              "Syntax error",
+	}
+	for expr, msg := range bad {
+		if _, err := ParseCondition(expr, names, values); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%q: expected error containing %q, got %v", expr, msg, err)
+		}
+	}
+	if _, err := ParseCondition("(#v > :n OR NOT contains(Tags, :s)) AND size(Replies) BETWEEN :n AND :n", names, values); err != nil {
+		t.Error(err)
+	}
+	if _, err := ParseUpdate("SET a = :n SET b = :n", nil, values); err == nil {
+		t.Errorf("a repeated clause should be rejected")
+	}
+	if _, err := ParseUpdate("SET a.b = :n REMOVE a", nil, values); err == nil ||
+		!strings.Contains(err.Error(), "overlap") {
+		t.Errorf("overlapping paths should be rejected, got %v", err)
+	}
+	if _, err := ParseUpdate("DELETE a :n", nil, values); err == nil {
+		t.Errorf("DELETE of a number should be rejected")
+	}
+	if _, err := ParseProjection("a, a.b", nil); err == nil {
+		t.Errorf("overlapping projection paths should be rejected")
+	}
+}
+
+func TestUnused(t *testing.T) {
+	e := Expressions{
+		ConditionExpression:       "attribute_exists(#a)",
+		ExpressionAttributeNames:  expressionattributenames.ExpressionAttributeNames{"#a": "a", "#b": "b"},
+		ExpressionAttributeValues: decodeValues(t, `{":x":{"S":"x"}}`),
+	}
+	err := e.Validate()
+	if err == nil || err.Error() != "Value provided in ExpressionAttributeNames unused in expressions: keys: {#b}" {
+		t.Errorf("unexpected error %v", err)
+	}
+	delete(e.ExpressionAttributeNames, "#b")
+	err = e.Validate()
+	if err == nil || err.Error() != "Value provided in ExpressionAttributeValues unused in expressions: keys: {:x}" {
+		t.Errorf("unexpected error %v", err)
+	}
+	e.ProjectionExpression = "#a"
+	e.FilterExpression = "#a = :x"
+	parsed, p_err := e.Parse()
+	if p_err != nil {
+		t.Fatal(p_err)
+	}
+	if parsed.Condition == nil || parsed.Filter == nil || parsed.Projection == nil || parsed.Update != nil {
+		t.Errorf("unexpected parse %+v", parsed)
+	}
+	e = Expressions{ExpressionAttributeNames: expressionattributenames.ExpressionAttributeNames{"#a": "a"}}
+	if err := e.Validate(); err == nil {
+		t.Errorf("names without expressions should be rejected")
+	}
+}
+
+func TestCondition(t *testing.T) {
+	it := decodeItem(t, threadItem)
+	names := expressionattributenames.ExpressionAttributeNames{"#p": "Pictures"}
+	values := decodeValues(t, `{":ten":{"N":"10.0"},":five":{"N":"5"},":u":{"S":"Update"},
+	 ":pre":{"S":"How"},":side":{"S":"side"},":t":{"S":"SS"},":r1":{"S":"r1"},":l":{"L":[{"S":"r0"},{"S":"r1"},{"S":"r2"}]}}`)
+	cases := map[string]bool{
+		"Hits = :ten":                                true,
+		"Hits <> :ten":                               false,
+		"Hits > :five AND Hits <= :ten":              true,
+		"Hits BETWEEN :five AND :ten":                true,
+		"Hits IN (:five, :ten)":                      true,
+		"NOT Hits IN (:five)":                        true,
+		"contains(Tags, :u)":                         true,
+		"contains(Replies, :r1)":                     true,
+		"begins_with(Subject, :pre)":                 true,
+		"#p.SideView = :side":                        true,
+		"Replies[1] = :r1":                           true,
+		"Replies = :l":                               true,
+		"attribute_type(Tags, :t)":                   true,
+		"attribute_exists(#p.FrontView)":             true,
+		"attribute_not_exists(Absent)":               true,
+		"Absent = :ten OR size(Replies) > :five":     false,
+		"size(Subject) > :ten AND (Absent <> :five)": true,
+		"Absent < :ten":                              false,
+	}
+	for expr, want := range cases {
+		c, err := ParseCondition(expr, names, values)
+		if err != nil {
+			t.Errorf("%q: %v", expr, err)
+			continue
+		}
+		if got := c.Evaluate(it); got != want {
+			t.Errorf("%q: expected %v, got %v", expr, want, got)
+		}
+	}
+	c, _ := ParseFilter("Hits > :five", names, values)
+	low := decodeItem(t, `{"Hits":{"N":"1"}}`)
+	if matched := c.Filter([]item.Item{it, low}); len(matched) != 1 {
+		t.Errorf("expected one match, got %d", len(matched))
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	it := decodeItem(t, threadItem)
+	names := expressionattributenames.ExpressionAttributeNames{"#v": "Hits", "#p": "Pictures"}
+	values := decodeValues(t, `{":one":{"N":"1"},":tags":{"SS":["Update","New"]},":more":{"L":[{"S":"r3"}]},
+	 ":zero":{"N":"0"},":rear":{"S":"rear"}}`)
+	u, err := ParseUpdate("SET #v = #v + :one, Answered = if_not_exists(Answered, :zero), "+
+		"Replies = list_append(Replies, :more), #p.RearView = :rear "+
+		"REMOVE #p.SideView, Tags ADD Tally :one", names, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs := strings.Join(u.Attributes(), ","); attrs != "Answered,Hits,Pictures,Replies,Tags,Tally" {
+		t.Errorf("unexpected attributes %s", attrs)
+	}
+	updated, err := u.Apply(it)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it["Hits"].N != "10" {
+		t.Errorf("Apply should not modify its argument")
+	}
+	if updated["Hits"].N != "11" || updated["Answered"].N != "0" || updated["Tally"].N != "1" ||
+		len(updated["Replies"].L) != 4 || updated["Pictures"].M["RearView"].S != "rear" {
+		t.Errorf("unexpected update %+v", updated)
+	}
+	if _, ok := updated["Pictures"].M["SideView"]; ok {
+		t.Errorf("SideView should have been removed")
+	}
+	if _, ok := updated["Tags"]; ok {
+		t.Errorf("Tags should have been removed")
+	}
+
+	u, _ = ParseUpdate("REMOVE Replies[0], Replies[2] ADD Tags :tags", nil, values)
+	updated, err = u.Apply(it)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated["Replies"].L) != 1 || updated["Replies"].L[0].S != "r1" || len(updated["Tags"].SS) != 3 {
+		t.Errorf("unexpected update %+v", updated)
+	}
+	u, _ = ParseUpdate("DELETE Tags :tags", nil, values)
+	updated, _ = u.Apply(it)
+	if len(updated["Tags"].SS) != 1 || updated["Tags"].SS[0] != "Multiple Items" {
+		t.Errorf("unexpected update %+v", updated["Tags"])
+	}
+
+	u, _ = ParseUpdate("SET Absent.a = :one", nil, values)
+	if _, err := u.Apply(it); err == nil {
+		t.Errorf("setting a path under a missing attribute should fail")
+	}
+	u, _ = ParseUpdate("ADD Subject :one", nil, values)
+	if _, err := u.Apply(it); err == nil {
+		t.Errorf("adding a number to a string should fail")
+	}
+	u, _ = ParseUpdate("SET Hits = Absent + :one", nil, values)
+	if _, err := u.Apply(it); err == nil {
+		t.Errorf("arithmetic on a missing attribute should fail")
+	}
+}
+
+func TestNumberArithmetic(t *testing.T) {
+	n := func(s string) *attributevalue.AttributeValue {
+		return &attributevalue.AttributeValue{N: s}
+	}
+	sum, err := AddValue(n("1E-50"), n("1E-50"))
+	if err != nil || sum.N != "0."+strings.Repeat("0", 49)+"2" {
+		t.Errorf("unexpected sum %v %v", sum, err)
+	}
+	tiny := "0." + strings.Repeat("0", 38) + "1"
+	sum, err = arith("+", n(tiny), n("0"))
+	if err != nil || sum.N != tiny {
+		t.Errorf("unexpected sum %v %v", sum, err)
+	}
+	sum, err = arith("-", n("1E-130"), n("-1E-130"))
+	if err != nil || sum.N != "0."+strings.Repeat("0", 129)+"2" {
+		t.Errorf("unexpected difference %v %v", sum, err)
+	}
+	if sum, err = arith("-", n("0.1"), n("0.1")); err != nil || sum.N != "0" {
+		t.Errorf("unexpected difference %v %v", sum, err)
+	}
+	// 38 significant digits fit, 39 do not
+	if _, err = AddValue(n("1"+strings.Repeat("0", 37)), n("1")); err != nil {
+		t.Errorf("38 digits should be accepted, got %v", err)
+	}
+	if _, err = AddValue(n("1"+strings.Repeat("0", 38)), n("1")); err == nil {
+		t.Errorf("39 digits should be rejected")
+	}
+	if _, err = arith("+", n("1E-130"), n("1")); err == nil {
+		t.Errorf("1E-130 + 1 has more than 38 digits and should be rejected")
+	}
+	if _, err = AddValue(n("9.9999999999999999999999999999999999999E+125"), n("1E+125")); err == nil {
+		t.Errorf("overflow should be rejected")
+	}
+}
+
+func TestProjection(t *testing.T) {
+	it := decodeItem(t, threadItem)
+	p, err := ParseProjection("Subject, Replies[2], Replies[0], #p.FrontView, Absent",
+		expressionattributenames.ExpressionAttributeNames{"#p": "Pictures"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	projected := p.Apply(it)
+	if len(projected) != 3 || projected["Subject"] == nil || len(projected["Pictures"].M) != 1 {
+		t.Errorf("unexpected projection %+v", projected)
+	}
+	if r := projected["Replies"].L; len(r) != 2 || r[0].S != "r0" || r[1].S != "r2" {
+		t.Errorf("unexpected projected list %+v", r)
+	}
+	if s := p.Paths()[1].String(); s != "Replies[2]" {
+		t.Errorf("unexpected path %s", s)
+	}
+}
+
+func TestKeyCondition(t *testing.T) {
+	values := decodeValues(t, `{":f":{"S":"Amazon DynamoDB"},":pre":{"S":"How"},":a":{"S":"A"},":z":{"S":"Z"}}`)
+	k, err := ParseKeyCondition("ForumName = :f AND begins_with(Subject, :pre)", nil, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs := k.Conditions()
+	if len(cs) != 2 || cs["ForumName"].ComparisonOperator != aws_strings.OP_EQ ||
+		cs["Subject"].ComparisonOperator != aws_strings.OP_BEGINS_WITH {
+		t.Errorf("unexpected conditions %+v", cs)
+	}
+	if !k.Evaluate(decodeItem(t, threadItem)) {
+		t.Errorf("the key condition should match")
+	}
+	k, _ = ParseKeyCondition("ForumName = :f AND Subject BETWEEN :a AND :z", nil, values)
+	if k.Conditions()["Subject"].ComparisonOperator != aws_strings.OP_BETWEEN || !k.Evaluate(decodeItem(t, threadItem)) {
+		t.Errorf("unexpected key condition %+v", k)
+	}
+	bad := []string{
+		"ForumName = :f OR Subject = :a",
+		"ForumName <> :f",
+		"ForumName = :f AND Subject = :a AND Hits = :z",
+		"ForumName = :f AND ForumName = :a",
+		"contains(ForumName, :f)",
+		"Pictures.SideView = :f",
+		"ForumName = Subject",
+	}
+	for _, expr := range bad {
+		if _, err := ParseKeyCondition(expr, nil, values); err == nil {
+			t.Errorf("%q should be rejected", expr)
+		}
+	}
+}
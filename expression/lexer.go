@@ -0,0 +1,76 @@
+package expression
+
+import (
+	"strings"
+)
+
+const (
+	tok_eof = iota
+	tok_ident
+	tok_name
+	tok_value
+	tok_number
+	tok_punct
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// lex splits an expression into tokens. The text of an invalid token is returned
+// as the second value.
+func lex(src string) ([]token, *token) {
+	toks := make([]token, 0)
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#' || c == ':':
+			j := i + 1
+			for j < len(src) && isIdentChar(src[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, &token{kind: tok_punct, text: src[i : i+1], pos: i}
+			}
+			kind := tok_name
+			if c == ':' {
+				kind = tok_value
+			}
+			toks = append(toks, token{kind: kind, text: src[i:j], pos: i})
+			i = j
+		case '0' <= c && c <= '9':
+			j := i
+			for j < len(src) && '0' <= src[j] && src[j] <= '9' {
+				j++
+			}
+			toks = append(toks, token{kind: tok_number, text: src[i:j], pos: i})
+			i = j
+		case isIdentChar(c):
+			j := i
+			for j < len(src) && isIdentChar(src[j]) {
+				j++
+			}
+			toks = append(toks, token{kind: tok_ident, text: src[i:j], pos: i})
+			i = j
+		case strings.HasPrefix(src[i:], "<>") || strings.HasPrefix(src[i:], "<=") || strings.HasPrefix(src[i:], ">="):
+			toks = append(toks, token{kind: tok_punct, text: src[i : i+2], pos: i})
+			i += 2
+		case strings.IndexByte("()[],.=<>+-", c) >= 0:
+			toks = append(toks, token{kind: tok_punct, text: src[i : i+1], pos: i})
+			i++
+		default:
+			return nil, &token{kind: tok_punct, text: src[i : i+1], pos: i}
+		}
+	}
+	toks = append(toks, token{kind: tok_eof, text: "<EOF>", pos: len(src)})
+	return toks, nil
+}
@@ -0,0 +1,503 @@
+package expression
+
+import (
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/aws_strings"
+	"github.com/smugmug/godynamo/types/expressionattributenames"
+	"strconv"
+	"strings"
+)
+
+// parser is a recursive descent parser over the tokens of one expression. It resolves
+// placeholders as it goes and records which ones were used.
+type parser struct {
+	kind       string
+	src        string
+	toks       []token
+	i          int
+	names      expressionattributenames.ExpressionAttributeNames
+	values     attributevalue.AttributeValueMap
+	usedNames  map[string]bool
+	usedValues map[string]bool
+}
+
+func newParser(kind, src string, names expressionattributenames.ExpressionAttributeNames,
+	values attributevalue.AttributeValueMap) (*parser, error) {
+	p := &parser{kind: kind, src: src, names: names, values: values,
+		usedNames: make(map[string]bool), usedValues: make(map[string]bool)}
+	if strings.TrimSpace(src) == "" {
+		return nil, p.fail("The expression can not be empty;")
+	}
+	toks, bad := lex(src)
+	if bad != nil {
+		return nil, p.syntax(*bad)
+	}
+	p.toks = toks
+	return p, nil
+}
+
+func (p *parser) fail(msg string) error {
+	return errors.New(fmt.Sprintf("Invalid %s: %s", p.kind, msg))
+}
+
+func (p *parser) syntax(t token) error {
+	lo, hi := t.pos-10, t.pos+len(t.text)+10
+	if lo < 0 {
+		lo = 0
+	}
+	if hi > len(p.src) {
+		hi = len(p.src)
+	}
+	return p.fail(fmt.Sprintf("Syntax error; token: %q, near: %q", t.text, p.src[lo:hi]))
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tok_eof {
+		p.i++
+	}
+	return t
+}
+
+// is reports whether the next token is the punctuation or case-insensitive keyword s.
+func (p *parser) is(s string) bool {
+	t := p.peek()
+	return (t.kind == tok_punct || t.kind == tok_ident) && strings.EqualFold(t.text, s)
+}
+
+func (p *parser) accept(s string) bool {
+	if p.is(s) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		return p.syntax(p.peek())
+	}
+	return nil
+}
+
+func (p *parser) end() error {
+	if p.peek().kind != tok_eof {
+		return p.syntax(p.peek())
+	}
+	return nil
+}
+
+// isFunc reports whether the next tokens are a function call.
+func (p *parser) isFunc() bool {
+	return p.peek().kind == tok_ident && p.toks[p.i+1].kind == tok_punct && p.toks[p.i+1].text == "("
+}
+
+// pathElement resolves an attribute name or #name placeholder.
+func (p *parser) pathElement() (string, error) {
+	t := p.next()
+	switch t.kind {
+	case tok_ident:
+		if reserved[strings.ToUpper(t.text)] {
+			return "", p.fail("Attribute name is a reserved keyword; reserved keyword: " + t.text)
+		}
+		return t.text, nil
+	case tok_name:
+		name, ok := p.names[t.text]
+		if !ok {
+			return "", p.fail("An expression attribute name used in the document path is not defined; " +
+				"attribute name: " + t.text)
+		}
+		p.usedNames[t.text] = true
+		return name, nil
+	}
+	return "", p.syntax(t)
+}
+
+// path parses a document path such as a.b[1].#c.
+func (p *parser) path() (Path, error) {
+	name, name_err := p.pathElement()
+	if name_err != nil {
+		return nil, name_err
+	}
+	path := Path{PathElement{Name: name}}
+	for {
+		switch {
+		case p.accept("."):
+			name, name_err := p.pathElement()
+			if name_err != nil {
+				return nil, name_err
+			}
+			path = append(path, PathElement{Name: name})
+		case p.accept("["):
+			t := p.next()
+			if t.kind != tok_number {
+				return nil, p.syntax(t)
+			}
+			n, n_err := strconv.Atoi(t.text)
+			if n_err != nil {
+				return nil, p.syntax(t)
+			}
+			if c_err := p.expect("]"); c_err != nil {
+				return nil, c_err
+			}
+			path = append(path, PathElement{Index: n})
+		default:
+			return path, nil
+		}
+	}
+}
+
+// value resolves a :value placeholder.
+func (p *parser) value() (*attributevalue.AttributeValue, error) {
+	t := p.next()
+	if t.kind != tok_value {
+		return nil, p.syntax(t)
+	}
+	v, ok := p.values[t.text]
+	if !ok || v == nil {
+		return nil, p.fail("An expression attribute value used in expression is not defined; " +
+			"attribute value: " + t.text)
+	}
+	p.usedValues[t.text] = true
+	return v, nil
+}
+
+// operand parses a path, a value or a function returning a value. Only size may be used
+// in conditions; if_not_exists, list_append and arithmetic are permitted in SET.
+func (p *parser) operand(in_set bool) (operand, error) {
+	if p.peek().kind == tok_value {
+		v, v_err := p.value()
+		if v_err != nil {
+			return nil, v_err
+		}
+		return valueOp{v}, nil
+	}
+	if !p.isFunc() {
+		path, path_err := p.path()
+		if path_err != nil {
+			return nil, path_err
+		}
+		return pathOp{path}, nil
+	}
+	name := p.next().text
+	p.next()
+	switch {
+	case name == "size" && !in_set:
+		path, path_err := p.path()
+		if path_err != nil {
+			return nil, path_err
+		}
+		return sizeOp{path}, p.expect(")")
+	case name == "if_not_exists" && in_set:
+		path, path_err := p.path()
+		if path_err != nil {
+			return nil, path_err
+		}
+		if c_err := p.expect(","); c_err != nil {
+			return nil, c_err
+		}
+		def, def_err := p.operand(in_set)
+		if def_err != nil {
+			return nil, def_err
+		}
+		return ifNotExistsOp{path, def}, p.expect(")")
+	case name == "list_append" && in_set:
+		a, a_err := p.operand(in_set)
+		if a_err != nil {
+			return nil, a_err
+		}
+		if c_err := p.expect(","); c_err != nil {
+			return nil, c_err
+		}
+		b, b_err := p.operand(in_set)
+		if b_err != nil {
+			return nil, b_err
+		}
+		return listAppendOp{a, b}, p.expect(")")
+	case functions[name]:
+		return nil, p.fail("The function is not allowed to be used this way in an expression; function: " + name)
+	}
+	return nil, p.fail("Invalid function name; function: " + name)
+}
+
+// functions are the names of all the functions of the expression grammar.
+var functions = map[string]bool{
+	"attribute_exists":     true,
+	"attribute_not_exists": true,
+	"attribute_type":       true,
+	"begins_with":          true,
+	"contains":             true,
+	"size":                 true,
+	"if_not_exists":        true,
+	"list_append":          true,
+}
+
+var comparators = map[string]bool{"=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// condition parses OR, the lowest precedence operator.
+func (p *parser) condition() (condNode, error) {
+	l, l_err := p.and()
+	if l_err != nil {
+		return nil, l_err
+	}
+	for p.accept("OR") {
+		r, r_err := p.and()
+		if r_err != nil {
+			return nil, r_err
+		}
+		l = orNode{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) and() (condNode, error) {
+	l, l_err := p.not()
+	if l_err != nil {
+		return nil, l_err
+	}
+	for p.accept("AND") {
+		r, r_err := p.not()
+		if r_err != nil {
+			return nil, r_err
+		}
+		l = andNode{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) not() (condNode, error) {
+	if p.accept("NOT") {
+		c, c_err := p.not()
+		if c_err != nil {
+			return nil, c_err
+		}
+		return notNode{c}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (condNode, error) {
+	if p.accept("(") {
+		c, c_err := p.condition()
+		if c_err != nil {
+			return nil, c_err
+		}
+		return c, p.expect(")")
+	}
+	if p.isFunc() {
+		switch name := p.peek().text; name {
+		case "attribute_exists", "attribute_not_exists", "attribute_type", "begins_with", "contains":
+			return p.function()
+		}
+	}
+	l, l_err := p.operand(false)
+	if l_err != nil {
+		return nil, l_err
+	}
+	t := p.peek()
+	switch {
+	case t.kind == tok_punct && comparators[t.text]:
+		p.next()
+		r, r_err := p.operand(false)
+		if r_err != nil {
+			return nil, r_err
+		}
+		return cmpNode{t.text, l, r}, nil
+	case p.accept("BETWEEN"):
+		lo, lo_err := p.operand(false)
+		if lo_err != nil {
+			return nil, lo_err
+		}
+		if a_err := p.expect("AND"); a_err != nil {
+			return nil, a_err
+		}
+		hi, hi_err := p.operand(false)
+		if hi_err != nil {
+			return nil, hi_err
+		}
+		return betweenNode{l, lo, hi}, nil
+	case p.accept("IN"):
+		if o_err := p.expect("("); o_err != nil {
+			return nil, o_err
+		}
+		in := inNode{v: l}
+		for {
+			o, o_err := p.operand(false)
+			if o_err != nil {
+				return nil, o_err
+			}
+			in.list = append(in.list, o)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if len(in.list) > 100 {
+			return nil, p.fail("The IN operator is limited to 100 operands")
+		}
+		return in, p.expect(")")
+	}
+	return nil, p.syntax(t)
+}
+
+// function parses a function returning a boolean.
+func (p *parser) function() (condNode, error) {
+	name := p.next().text
+	p.next()
+	path, path_err := p.path()
+	if path_err != nil {
+		return nil, path_err
+	}
+	f := funcNode{name: name, path: path}
+	switch name {
+	case "attribute_type":
+		if c_err := p.expect(","); c_err != nil {
+			return nil, c_err
+		}
+		v, v_err := p.value()
+		if v_err != nil {
+			return nil, v_err
+		}
+		if !attributeTypes[v.S] {
+			return nil, p.fail("Invalid attribute type name found; type: " + v.S +
+				", valid types: { B,NULL,SS,BOOL,L,BS,N,NS,S,M }")
+		}
+		f.arg = valueOp{v}
+	case "begins_with", "contains":
+		if c_err := p.expect(","); c_err != nil {
+			return nil, c_err
+		}
+		arg, arg_err := p.operand(false)
+		if arg_err != nil {
+			return nil, arg_err
+		}
+		f.arg = arg
+	}
+	return f, p.expect(")")
+}
+
+var attributeTypes = map[string]bool{
+	aws_strings.S: true, aws_strings.N: true, aws_strings.B: true,
+	aws_strings.BOOL: true, aws_strings.NULL: true,
+	aws_strings.SS: true, aws_strings.NS: true, aws_strings.BS: true,
+	aws_strings.L: true, aws_strings.M: true,
+}
+
+// setValue parses the right hand side of a SET action.
+func (p *parser) setValue() (operand, error) {
+	l, l_err := p.operand(true)
+	if l_err != nil {
+		return nil, l_err
+	}
+	if op := p.peek().text; p.peek().kind == tok_punct && (op == "+" || op == "-") {
+		p.next()
+		r, r_err := p.operand(true)
+		if r_err != nil {
+			return nil, r_err
+		}
+		return arithOp{op, l, r}, nil
+	}
+	return l, nil
+}
+
+// update parses the clauses of an update expression.
+func (p *parser) update() (*Update, error) {
+	u := new(Update)
+	seen := make(map[string]bool)
+	for p.peek().kind != tok_eof {
+		t := p.next()
+		clause := strings.ToUpper(t.text)
+		if t.kind != tok_ident || (clause != CLAUSE_SET && clause != CLAUSE_REMOVE &&
+			clause != CLAUSE_ADD && clause != CLAUSE_DELETE) {
+			return nil, p.syntax(t)
+		}
+		if seen[clause] {
+			return nil, p.fail(fmt.Sprintf("The %q section can only be used once in an update expression;", clause))
+		}
+		seen[clause] = true
+		for {
+			path, path_err := p.path()
+			if path_err != nil {
+				return nil, path_err
+			}
+			switch clause {
+			case CLAUSE_SET:
+				if e_err := p.expect("="); e_err != nil {
+					return nil, e_err
+				}
+				v, v_err := p.setValue()
+				if v_err != nil {
+					return nil, v_err
+				}
+				u.sets = append(u.sets, setAction{path, v})
+			case CLAUSE_REMOVE:
+				u.removes = append(u.removes, path)
+			case CLAUSE_ADD, CLAUSE_DELETE:
+				v, v_err := p.value()
+				if v_err != nil {
+					return nil, v_err
+				}
+				t := TypeOf(v)
+				is_set := t == aws_strings.SS || t == aws_strings.NS || t == aws_strings.BS
+				if !is_set && (clause == CLAUSE_DELETE || t != aws_strings.N) {
+					return nil, p.fail(fmt.Sprintf("Incorrect operand type for operator or function; "+
+						"operator: %s, operand type: %s", clause, t))
+				}
+				if clause == CLAUSE_ADD {
+					u.adds = append(u.adds, valueAction{path, v})
+				} else {
+					u.deletes = append(u.deletes, valueAction{path, v})
+				}
+			}
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if o_err := checkOverlap(u.paths()); o_err != nil {
+		return nil, p.fail(o_err.Error())
+	}
+	return u, nil
+}
+
+// checkOverlap rejects paths that are equal to or a prefix of one another.
+func checkOverlap(paths []Path) error {
+	for i := range paths {
+		for j := i + 1; j < len(paths); j++ {
+			if paths[i].overlaps(paths[j]) {
+				e := fmt.Sprintf("Two document paths overlap with each other; "+
+					"must remove or rewrite one of these paths; path one: %s, path two: %s",
+					paths[i].String(), paths[j].String())
+				return errors.New(e)
+			}
+		}
+	}
+	return nil
+}
+
+// projection parses a comma separated list of paths.
+func (p *parser) projection() (*Projection, error) {
+	pr := new(Projection)
+	for {
+		path, path_err := p.path()
+		if path_err != nil {
+			return nil, path_err
+		}
+		pr.paths = append(pr.paths, path)
+		if !p.accept(",") {
+			break
+		}
+	}
+	if e_err := p.end(); e_err != nil {
+		return nil, e_err
+	}
+	if o_err := checkOverlap(pr.paths); o_err != nil {
+		return nil, p.fail(o_err.Error())
+	}
+	return pr, nil
+}
@@ -0,0 +1,361 @@
+package expression
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/aws_strings"
+	"github.com/smugmug/godynamo/types/cast"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+)
+
+// TypeOf returns the type designation of an AttributeValue (S, N, B, BOOL, NULL,
+// SS, NS, BS, L or M), or "" if it has none.
+func TypeOf(a *attributevalue.AttributeValue) string {
+	switch {
+	case a == nil:
+		return ""
+	case a.S != "":
+		return aws_strings.S
+	case a.N != "":
+		return aws_strings.N
+	case a.B != "":
+		return aws_strings.B
+	case a.BOOL != nil:
+		return aws_strings.BOOL
+	case a.NULL != nil:
+		return aws_strings.NULL
+	case len(a.SS) != 0:
+		return aws_strings.SS
+	case len(a.NS) != 0:
+		return aws_strings.NS
+	case len(a.BS) != 0:
+		return aws_strings.BS
+	case len(a.M) != 0:
+		return aws_strings.M
+	case len(a.L) != 0:
+		return aws_strings.L
+	}
+	return ""
+}
+
+func parseNumber(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		e := fmt.Sprintf("expression.parseNumber: %s is not a number", s)
+		return nil, errors.New(e)
+	}
+	return r, nil
+}
+
+// formatNumber renders r exactly as a decimal. Numbers DynamoDB cannot store, with more
+// than 38 significant digits or out of range, are rejected as by cast.AWSParseNumber.
+func formatNumber(r *big.Rat) (string, error) {
+	// r is a sum of decimals, so some power of ten times it is an integer
+	places := 0
+	scaled := new(big.Rat).Set(r)
+	ten := big.NewRat(10, 1)
+	for !scaled.IsInt() {
+		if places > cast.AWS_MAX_DIGITS-cast.AWS_MIN_EXPONENT {
+			e := fmt.Sprintf("expression.formatNumber: %s is out of range", r.RatString())
+			return "", errors.New(e)
+		}
+		scaled.Mul(scaled, ten)
+		places++
+	}
+	return cast.AWSParseNumber(r.FloatString(places))
+}
+
+func decodeB(s string) []byte {
+	b, b_err := base64.StdEncoding.DecodeString(s)
+	if b_err != nil {
+		return []byte(s)
+	}
+	return b
+}
+
+// canonical returns a string that is equal for equal scalar values.
+func canonical(t string, s string) string {
+	switch t {
+	case aws_strings.N:
+		if r, r_err := parseNumber(s); r_err == nil {
+			return r.RatString()
+		}
+	case aws_strings.B:
+		return string(decodeB(s))
+	}
+	return s
+}
+
+// members returns the canonical members of a set value.
+func members(a *attributevalue.AttributeValue) map[string]bool {
+	m := make(map[string]bool)
+	for _, s := range a.SS {
+		m[canonical(aws_strings.S, s)] = true
+	}
+	for _, n := range a.NS {
+		m[canonical(aws_strings.N, n)] = true
+	}
+	for _, b := range a.BS {
+		m[canonical(aws_strings.B, b)] = true
+	}
+	return m
+}
+
+// Equal reports whether two values are equal, recursing into lists and maps.
+// Numbers are compared by value and sets without regard to order.
+func Equal(a, b *attributevalue.AttributeValue) bool {
+	t := TypeOf(a)
+	if t == "" || t != TypeOf(b) {
+		return false
+	}
+	switch t {
+	case aws_strings.S:
+		return a.S == b.S
+	case aws_strings.N, aws_strings.B:
+		c, _ := Compare(a, b)
+		return c == 0
+	case aws_strings.BOOL:
+		return *a.BOOL == *b.BOOL
+	case aws_strings.NULL:
+		return *a.NULL == *b.NULL
+	case aws_strings.SS, aws_strings.NS, aws_strings.BS:
+		ma, mb := members(a), members(b)
+		if len(ma) != len(mb) {
+			return false
+		}
+		for k := range ma {
+			if !mb[k] {
+				return false
+			}
+		}
+		return true
+	case aws_strings.L:
+		if len(a.L) != len(b.L) {
+			return false
+		}
+		for i := range a.L {
+			if !Equal(a.L[i], b.L[i]) {
+				return false
+			}
+		}
+		return true
+	case aws_strings.M:
+		if len(a.M) != len(b.M) {
+			return false
+		}
+		for k, v := range a.M {
+			if !Equal(v, b.M[k]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Compare orders two values of the same scalar type S, N or B. ok is false if the
+// values are of different or non-scalar types, in which case they are not comparable.
+func Compare(a, b *attributevalue.AttributeValue) (cmp int, ok bool) {
+	t := TypeOf(a)
+	if t != TypeOf(b) {
+		return 0, false
+	}
+	switch t {
+	case aws_strings.S:
+		return strings.Compare(a.S, b.S), true
+	case aws_strings.N:
+		ra, a_err := parseNumber(a.N)
+		rb, b_err := parseNumber(b.N)
+		if a_err != nil || b_err != nil {
+			return 0, false
+		}
+		return ra.Cmp(rb), true
+	case aws_strings.B:
+		return bytes.Compare(decodeB(a.B), decodeB(b.B)), true
+	}
+	return 0, false
+}
+
+// BeginsWith reports whether the string or binary a starts with prefix.
+func BeginsWith(a, prefix *attributevalue.AttributeValue) bool {
+	switch {
+	case TypeOf(a) == aws_strings.S && TypeOf(prefix) == aws_strings.S:
+		return strings.HasPrefix(a.S, prefix.S)
+	case TypeOf(a) == aws_strings.B && TypeOf(prefix) == aws_strings.B:
+		return bytes.HasPrefix(decodeB(a.B), decodeB(prefix.B))
+	}
+	return false
+}
+
+// Contains reports whether the string a contains the substring b, the set a contains
+// the member b, or the list a contains an element equal to b.
+func Contains(a, b *attributevalue.AttributeValue) bool {
+	tb := TypeOf(b)
+	switch TypeOf(a) {
+	case aws_strings.S:
+		return tb == aws_strings.S && strings.Contains(a.S, b.S)
+	case aws_strings.B:
+		return tb == aws_strings.B && bytes.Contains(decodeB(a.B), decodeB(b.B))
+	case aws_strings.SS:
+		return tb == aws_strings.S && members(a)[canonical(tb, b.S)]
+	case aws_strings.NS:
+		return tb == aws_strings.N && members(a)[canonical(tb, b.N)]
+	case aws_strings.BS:
+		return tb == aws_strings.B && members(a)[canonical(tb, b.B)]
+	case aws_strings.L:
+		for _, v := range a.L {
+			if Equal(v, b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Size returns the value of the size function: the length of a string or binary,
+// or the number of elements of a set, list or map. ok is false for other types.
+func Size(a *attributevalue.AttributeValue) (n int, ok bool) {
+	switch TypeOf(a) {
+	case aws_strings.S:
+		return utf8.RuneCountInString(a.S), true
+	case aws_strings.B:
+		return len(decodeB(a.B)), true
+	case aws_strings.SS:
+		return len(a.SS), true
+	case aws_strings.NS:
+		return len(a.NS), true
+	case aws_strings.BS:
+		return len(a.BS), true
+	case aws_strings.L:
+		return len(a.L), true
+	case aws_strings.M:
+		return len(a.M), true
+	}
+	return 0, false
+}
+
+func copyValue(a *attributevalue.AttributeValue) *attributevalue.AttributeValue {
+	c := attributevalue.NewAttributeValue()
+	if a != nil {
+		_ = a.Copy(c)
+	}
+	return c
+}
+
+// AddValue implements the ADD action: it returns the sum of the numbers cur and v, or
+// the union of the sets cur and v. cur is nil if the attribute does not exist.
+func AddValue(cur, v *attributevalue.AttributeValue) (*attributevalue.AttributeValue, error) {
+	t := TypeOf(v)
+	if cur != nil && TypeOf(cur) != t {
+		return nil, errors.New("An operand in the update expression has an incorrect data type")
+	}
+	switch t {
+	case aws_strings.N:
+		sum, n_err := parseNumber(v.N)
+		if n_err != nil {
+			return nil, n_err
+		}
+		if cur != nil {
+			n, c_err := parseNumber(cur.N)
+			if c_err != nil {
+				return nil, c_err
+			}
+			sum.Add(sum, n)
+		}
+		n, f_err := formatNumber(sum)
+		if f_err != nil {
+			return nil, f_err
+		}
+		return &attributevalue.AttributeValue{N: n}, nil
+	case aws_strings.SS, aws_strings.NS, aws_strings.BS:
+		if cur == nil {
+			return copyValue(v), nil
+		}
+		merged := copyValue(cur)
+		have := members(cur)
+		for _, s := range v.SS {
+			if c := canonical(aws_strings.S, s); !have[c] {
+				have[c] = true
+				merged.SS = append(merged.SS, s)
+			}
+		}
+		for _, n := range v.NS {
+			if c := canonical(aws_strings.N, n); !have[c] {
+				have[c] = true
+				merged.NS = append(merged.NS, n)
+			}
+		}
+		for _, b := range v.BS {
+			if c := canonical(aws_strings.B, b); !have[c] {
+				have[c] = true
+				merged.BS = append(merged.BS, b)
+			}
+		}
+		return merged, nil
+	}
+	return nil, errors.New("An operand in the update expression has an incorrect data type")
+}
+
+// DeleteValue implements the DELETE action: it returns the set cur without the members
+// of the set v, or nil if no members remain. cur is nil if the attribute does not exist.
+func DeleteValue(cur, v *attributevalue.AttributeValue) (*attributevalue.AttributeValue, error) {
+	t := TypeOf(v)
+	if t != aws_strings.SS && t != aws_strings.NS && t != aws_strings.BS {
+		return nil, errors.New("An operand in the update expression has an incorrect data type")
+	}
+	if cur == nil {
+		return nil, nil
+	}
+	if TypeOf(cur) != t {
+		return nil, errors.New("An operand in the update expression has an incorrect data type")
+	}
+	remove := members(v)
+	kept := attributevalue.NewAttributeValue()
+	for _, s := range cur.SS {
+		if !remove[canonical(aws_strings.S, s)] {
+			kept.SS = append(kept.SS, s)
+		}
+	}
+	for _, n := range cur.NS {
+		if !remove[canonical(aws_strings.N, n)] {
+			kept.NS = append(kept.NS, n)
+		}
+	}
+	for _, b := range cur.BS {
+		if !remove[canonical(aws_strings.B, b)] {
+			kept.BS = append(kept.BS, b)
+		}
+	}
+	if TypeOf(kept) == "" {
+		return nil, nil
+	}
+	return kept, nil
+}
+
+// arith adds or subtracts two numbers for the + and - operators of SET.
+func arith(op string, a, b *attributevalue.AttributeValue) (*attributevalue.AttributeValue, error) {
+	if TypeOf(a) != aws_strings.N || TypeOf(b) != aws_strings.N {
+		return nil, errors.New("An operand in the update expression has an incorrect data type")
+	}
+	ra, a_err := parseNumber(a.N)
+	if a_err != nil {
+		return nil, a_err
+	}
+	rb, b_err := parseNumber(b.N)
+	if b_err != nil {
+		return nil, b_err
+	}
+	if op == "-" {
+		rb.Neg(rb)
+	}
+	n, f_err := formatNumber(ra.Add(ra, rb))
+	if f_err != nil {
+		return nil, f_err
+	}
+	return &attributevalue.AttributeValue{N: n}, nil
+}
@@ -1,4 +1,5 @@
 // A dummy package for installers who wish to get all GoDynamo depencies in one "go get" invocation.
+// It also provides Client, which wraps every endpoint with its own conf, transport and policies.
 package godynamo
 
 import (
@@ -0,0 +1,123 @@
+// Implements a token bucket that limits the capacity units consumed by requests.
+//
+// Capacity is often only known once a request completes, so a Limiter lets the
+// bucket go into debt: Wait blocks until the bucket is no longer in debt, and Charge
+// withdraws the units a request consumed, however many there are.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter refills Rate units per second, up to Burst. The zero value and a nil
+// Limiter do not limit.
+type Limiter struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// New returns a full Limiter of rate units per second that may save up to burst units.
+// With a burst of 0, requests are spaced evenly at the rate from the start.
+func New(rate, burst float64) *Limiter {
+	if burst < 0 {
+		burst = 0
+	}
+	return &Limiter{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Rate returns the units per second of l.
+func (l *Limiter) Rate() float64 {
+	if l == nil {
+		return 0
+	}
+	return l.rate
+}
+
+// refill adds the units earned since the last refill, holding lock.
+func (l *Limiter) refill(now time.Time) {
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+}
+
+// Wait blocks until the bucket is not in debt, or ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil || l.rate <= 0 {
+		return ctx.Err()
+	}
+	l.lock.Lock()
+	l.refill(time.Now())
+	var d time.Duration
+	if l.tokens < 0 {
+		d = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.lock.Unlock()
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Charge withdraws units from the bucket, delaying later calls to Wait by the time
+// they take to refill. A negative charge refunds units charged earlier, as when fewer
+// were consumed than estimated.
+func (l *Limiter) Charge(units float64) {
+	if l == nil || l.rate <= 0 {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.refill(time.Now())
+	l.tokens -= units
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+// Take is Wait and Charge in one step, for requests whose units can be estimated before
+// they are sent: units are charged at once, so that concurrent callers queue behind each
+// other, and Take returns when the debt of the callers before it has been repaid. If ctx
+// is done first, the units are refunded and ctx.Err() is returned.
+func (l *Limiter) Take(ctx context.Context, units float64) error {
+	if l == nil || l.rate <= 0 {
+		return ctx.Err()
+	}
+	l.lock.Lock()
+	l.refill(time.Now())
+	var d time.Duration
+	if l.tokens < 0 {
+		d = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.tokens -= units
+	l.lock.Unlock()
+	if d <= 0 {
+		if err := ctx.Err(); err != nil {
+			l.Charge(-units)
+			return err
+		}
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		l.Charge(-units)
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNil(t *testing.T) {
+	var l *Limiter
+	l.Charge(100)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Errorf("a nil limiter should not wait: %v", err)
+	}
+}
+
+func TestRate(t *testing.T) {
+	l := New(100, 0)
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := l.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		l.Charge(10)
+	}
+	// the first request is free, the four after it wait 100ms each
+	if elapsed := time.Since(start); elapsed < 350*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("expected about 400ms, took %v", elapsed)
+	}
+}
+
+func TestBurst(t *testing.T) {
+	l := New(10, 50)
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := l.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		l.Charge(10)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("the burst should not wait, took %v", elapsed)
+	}
+	l.Charge(10)
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(cctx); err != context.DeadlineExceeded {
+		t.Errorf("expected to wait past the deadline, got %v", err)
+	}
+}
+
+func TestTake(t *testing.T) {
+	l := New(100, 0)
+	ctx := context.Background()
+	start := time.Now()
+	done := make(chan error)
+	for i := 0; i < 5; i++ {
+		go func() {
+			done <- l.Take(ctx, 10)
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// concurrent takes queue behind each other
+	if elapsed := time.Since(start); elapsed < 350*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("expected about 400ms, took %v", elapsed)
+	}
+	// a cancelled take is refunded
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := l.Take(cctx, 1000); err != context.Canceled {
+		t.Errorf("expected a cancelled take, got %v", err)
+	}
+	if l.tokens < -20 {
+		t.Errorf("the cancelled take should be refunded, %f tokens", l.tokens)
+	}
+}
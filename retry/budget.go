@@ -0,0 +1,78 @@
+package retry
+
+import (
+	"sync"
+)
+
+const (
+	// Defaults for NewBudget, following the token bucket used by the AWS SDKs.
+	BUDGET_CAPACITY = 500
+	BUDGET_COST     = 5
+	BUDGET_REFUND   = 1
+)
+
+// Budget is a token bucket shared across goroutines that limits the number of retries
+// in flight. Every retry withdraws Cost tokens and every request that completes without
+// needing a retry deposits Refund tokens, up to Capacity. When the bucket runs dry,
+// policies using it stop retrying until enough requests succeed, so a throttling storm
+// degrades to single attempts rather than multiplying load.
+type Budget struct {
+	lock     sync.Mutex
+	tokens   float64
+	capacity float64
+	cost     float64
+	refund   float64
+}
+
+// NewBudget returns a full Budget. Non-positive arguments are replaced with the
+// BUDGET_* defaults.
+func NewBudget(capacity, cost, refund float64) *Budget {
+	if capacity <= 0 {
+		capacity = BUDGET_CAPACITY
+	}
+	if cost <= 0 {
+		cost = BUDGET_COST
+	}
+	if refund <= 0 {
+		refund = BUDGET_REFUND
+	}
+	return &Budget{tokens: capacity, capacity: capacity, cost: cost, refund: refund}
+}
+
+// Withdraw takes the cost of one retry from the bucket, returning false if there
+// are not enough tokens.
+func (b *Budget) Withdraw() bool {
+	if b == nil {
+		return true
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.tokens < b.cost {
+		return false
+	}
+	b.tokens -= b.cost
+	return true
+}
+
+// Deposit refunds the bucket after a success.
+func (b *Budget) Deposit() {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	b.tokens += b.refund
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.lock.Unlock()
+}
+
+// Available returns the current number of tokens.
+func (b *Budget) Available() float64 {
+	if b == nil {
+		return 0
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.tokens
+}
@@ -0,0 +1,272 @@
+// Implements pluggable retry policies for DynamoDB requests.
+//
+// A RetryPolicy decides whether a failed attempt should be retried and how long to wait
+// before the next attempt. Policies may be set on conf.AWS_Conf.RetryPolicy, or per call
+// by attaching one to a context with WithPolicy. If neither is set, Default is used, which
+// reproduces the historical godynamo backoff (see NewFullJitter).
+//
+// The built-in policies are safe for concurrent use. Any of them may share a *Budget with
+// other policies so that a throttling storm does not multiply the load sent to DynamoDB.
+//
+// See:
+// http://docs.aws.amazon.com/amazondynamodb/latest/developerguide/ErrorHandling.html
+// http://www.awsarchitectureblog.com/2015/03/backoff.html
+package retry
+
+import (
+	"context"
+	"github.com/smugmug/godynamo/aws_const"
+	"github.com/smugmug/godynamo/dynamoerr"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	// BASE_DELAY is the default base delay used to scale backoff.
+	BASE_DELAY = 100 * time.Millisecond
+	// MULTIPLIER is the default exponential growth of the full jitter window.
+	MULTIPLIER = 4
+)
+
+// Attempt describes the outcome of one request sent to DynamoDB.
+type Attempt struct {
+	// Number is the 1-based count of this attempt.
+	Number int
+	// Code is the http status code, or 0 if no response was received.
+	Code int
+	// Body is the response body, if any.
+	Body []byte
+	// Err is the transport error, if any.
+	Err error
+	// RequestID is the X-Amzn-Requestid of the response, if any.
+	RequestID string
+	// Elapsed is the time since the first attempt was sent, measured when this attempt completed.
+	Elapsed time.Duration
+	// PrevDelay is the backoff that was slept before this attempt (0 for the first attempt).
+	PrevDelay time.Duration
+}
+
+// RetryPolicy decides whether to retry an attempt and how long to wait before doing so.
+// ShouldRetry is called once per completed attempt; if it returns true, NextDelay is
+// called on the same attempt to obtain the backoff.
+type RetryPolicy interface {
+	ShouldRetry(a *Attempt) bool
+	NextDelay(a *Attempt) time.Duration
+}
+
+// SuccessRecorder may optionally be implemented by a RetryPolicy that wishes to be told
+// when a request completes without needing a retry, for example to refill a Budget.
+type SuccessRecorder interface {
+	RecordSuccess(a *Attempt)
+}
+
+// Default is the policy used when neither the conf nor the context provide one.
+var Default RetryPolicy = NewFullJitter()
+
+// Unprocessed is the backoff slept before resubmitting the UnprocessedItems of a
+// BatchWriteItem or the UnprocessedKeys of a BatchGetItem, with Attempt.Number counting
+// the resubmissions. These responses succeed, so no RetryPolicy is consulted for them.
+var Unprocessed = &FullJitter{Base: 50 * time.Millisecond, Multiplier: 2, Cap: 5 * time.Second}
+
+// Sleep waits for d to elapse, returning ctx.Err() if ctx is done first.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Retryable reports whether the attempt failed in a way that AWS deems transient:
+// a transport error, any 5xx, or a 400 caused by throttling or a transient client error
+// (see dynamoerr.APIError.Retryable).
+func Retryable(a *Attempt) bool {
+	if a == nil {
+		return false
+	}
+	if a.Err != nil {
+		return true
+	}
+	if a.Code >= http.StatusInternalServerError {
+		return true // all 5xx codes are deemed retryable by amazon
+	}
+	return dynamoerr.New(a.Body, a.Code, a.RequestID).Retryable()
+}
+
+// RecordSuccess notifies p of a successful attempt if p implements SuccessRecorder.
+func RecordSuccess(p RetryPolicy, a *Attempt) {
+	if r, ok := p.(SuccessRecorder); ok {
+		r.RecordSuccess(a)
+	}
+}
+
+type policyKey struct{}
+
+// WithPolicy returns a copy of ctx carrying p, which overrides the conf policy
+// for any request made with the returned context.
+func WithPolicy(ctx context.Context, p RetryPolicy) context.Context {
+	return context.WithValue(ctx, policyKey{}, p)
+}
+
+// PolicyFromContext returns the policy set by WithPolicy, if any.
+func PolicyFromContext(ctx context.Context) (RetryPolicy, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	p, ok := ctx.Value(policyKey{}).(RetryPolicy)
+	return p, ok && p != nil
+}
+
+// Limits are the stopping conditions common to the built-in policies.
+// A zero value disables the corresponding limit.
+type Limits struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+	// MaxElapsed bounds the total time spent on a request, including backoff.
+	MaxElapsed time.Duration
+	// Budget may be shared among policies to limit retries across goroutines.
+	Budget *Budget
+}
+
+// allow applies the common stopping conditions, withdrawing from the budget last
+// so that tokens are only spent on retries that will actually be made.
+func (l *Limits) allow(a *Attempt) bool {
+	if a == nil || !Retryable(a) {
+		return false
+	}
+	if l.MaxAttempts > 0 && a.Number >= l.MaxAttempts {
+		return false
+	}
+	if l.MaxElapsed > 0 && a.Elapsed >= l.MaxElapsed {
+		return false
+	}
+	if l.Budget != nil && !l.Budget.Withdraw() {
+		return false
+	}
+	return true
+}
+
+// clamp bounds d so that the next attempt is not sent after MaxElapsed.
+func (l *Limits) clamp(a *Attempt, d time.Duration) time.Duration {
+	if l.MaxElapsed > 0 && a.Elapsed+d > l.MaxElapsed {
+		d = l.MaxElapsed - a.Elapsed
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+// RecordSuccess refills the Budget, if any.
+func (l *Limits) RecordSuccess(a *Attempt) {
+	if l.Budget != nil {
+		l.Budget.Deposit()
+	}
+}
+
+// FullJitter sleeps a random duration in [0, min(Cap, Base*Multiplier^n)) after the
+// n'th attempt.
+type FullJitter struct {
+	Limits
+	Base       time.Duration
+	Multiplier float64
+	// Cap bounds the backoff window, 0 means no cap.
+	Cap time.Duration
+}
+
+// NewFullJitter returns a FullJitter policy that matches the historical godynamo backoff:
+// aws_const.RETRIES attempts with a window of 4^n*100ms.
+func NewFullJitter() *FullJitter {
+	f := new(FullJitter)
+	f.MaxAttempts = aws_const.RETRIES
+	f.Base = BASE_DELAY
+	f.Multiplier = MULTIPLIER
+	return f
+}
+
+func (f *FullJitter) ShouldRetry(a *Attempt) bool {
+	if f == nil {
+		return false
+	}
+	return f.allow(a)
+}
+
+func (f *FullJitter) NextDelay(a *Attempt) time.Duration {
+	if f == nil || a == nil {
+		return 0
+	}
+	window := float64(f.Base) * math.Pow(f.Multiplier, float64(a.Number))
+	if f.Cap > 0 && window > float64(f.Cap) {
+		window = float64(f.Cap)
+	}
+	if window > math.MaxInt64 {
+		window = math.MaxInt64
+	}
+	if window < 1 {
+		return f.clamp(a, 0)
+	}
+	return f.clamp(a, time.Duration(rand.Int63n(int64(window))))
+}
+
+// DecorrelatedJitter sleeps a random duration in [Base, PrevDelay*3), bounded by Cap.
+// This spreads competing clients apart more quickly than FullJitter.
+type DecorrelatedJitter struct {
+	Limits
+	Base time.Duration
+	// Cap bounds the backoff, 0 means no cap.
+	Cap time.Duration
+}
+
+// NewDecorrelatedJitter returns a DecorrelatedJitter policy with aws_const.RETRIES attempts,
+// a 100ms base and a 20s cap.
+func NewDecorrelatedJitter() *DecorrelatedJitter {
+	d := new(DecorrelatedJitter)
+	d.MaxAttempts = aws_const.RETRIES
+	d.Base = BASE_DELAY
+	d.Cap = 20 * time.Second
+	return d
+}
+
+func (d *DecorrelatedJitter) ShouldRetry(a *Attempt) bool {
+	if d == nil {
+		return false
+	}
+	return d.allow(a)
+}
+
+func (d *DecorrelatedJitter) NextDelay(a *Attempt) time.Duration {
+	if d == nil || a == nil {
+		return 0
+	}
+	prev := a.PrevDelay
+	if prev < d.Base {
+		prev = d.Base
+	}
+	delay := d.Base
+	if hi := int64(prev)*3 - int64(d.Base); hi > 0 {
+		delay += time.Duration(rand.Int63n(hi))
+	}
+	if d.Cap > 0 && delay > d.Cap {
+		delay = d.Cap
+	}
+	return d.clamp(a, delay)
+}
+
+// NoRetry never retries.
+type NoRetry struct{}
+
+func (n NoRetry) ShouldRetry(a *Attempt) bool {
+	return false
+}
+
+func (n NoRetry) NextDelay(a *Attempt) time.Duration {
+	return 0
+}
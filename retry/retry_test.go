@@ -0,0 +1,137 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryable(t *testing.T) {
+	cases := []struct {
+		a  Attempt
+		ok bool
+	}{
+		{Attempt{Err: errors.New("conn reset")}, true},
+		{Attempt{Code: http.StatusServiceUnavailable}, true},
+		{Attempt{Code: http.StatusBadRequest, Body: []byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ProvisionedThroughputExceededException"}`)}, true},
+		{Attempt{Code: http.StatusBadRequest, Body: []byte(`{"__type":"com.amazon.coral.availability#ThrottlingException"}`)}, true},
+		{Attempt{Code: http.StatusBadRequest, Body: []byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException"}`)}, false},
+		{Attempt{Code: http.StatusOK}, false},
+	}
+	for i, c := range cases {
+		if Retryable(&c.a) != c.ok {
+			t.Errorf("case %d: Retryable should be %v", i, c.ok)
+		}
+	}
+}
+
+func TestFullJitter(t *testing.T) {
+	f := NewFullJitter()
+	for n := 1; n < f.MaxAttempts; n++ {
+		a := &Attempt{Number: n, Code: http.StatusInternalServerError}
+		if !f.ShouldRetry(a) {
+			t.Errorf("attempt %d should be retried", n)
+		}
+		d := f.NextDelay(a)
+		window := BASE_DELAY
+		for i := 0; i < n; i++ {
+			window *= MULTIPLIER
+		}
+		if d < 0 || d >= window {
+			t.Errorf("attempt %d: delay %v outside [0,%v)", n, d, window)
+		}
+	}
+	if f.ShouldRetry(&Attempt{Number: f.MaxAttempts, Code: http.StatusInternalServerError}) {
+		t.Errorf("MaxAttempts should stop retries")
+	}
+	if f.ShouldRetry(&Attempt{Number: 1, Code: http.StatusOK}) {
+		t.Errorf("success should not be retried")
+	}
+}
+
+func TestMaxElapsed(t *testing.T) {
+	f := NewFullJitter()
+	f.MaxElapsed = time.Second
+	a := &Attempt{Number: 6, Code: http.StatusInternalServerError, Elapsed: 900 * time.Millisecond}
+	if !f.ShouldRetry(a) {
+		t.Errorf("should retry within MaxElapsed")
+	}
+	if d := f.NextDelay(a); d > 100*time.Millisecond {
+		t.Errorf("delay %v should be clamped to MaxElapsed", d)
+	}
+	a.Elapsed = time.Second
+	if f.ShouldRetry(a) {
+		t.Errorf("should not retry past MaxElapsed")
+	}
+}
+
+func TestDecorrelatedJitter(t *testing.T) {
+	d := NewDecorrelatedJitter()
+	prev := time.Duration(0)
+	for n := 1; n < d.MaxAttempts; n++ {
+		a := &Attempt{Number: n, Code: http.StatusInternalServerError, PrevDelay: prev}
+		delay := d.NextDelay(a)
+		lo := d.Base
+		hi := 3 * prev
+		if hi < 3*d.Base {
+			hi = 3 * d.Base
+		}
+		if delay < lo || delay >= hi || delay > d.Cap {
+			t.Errorf("attempt %d: delay %v outside [%v,%v)", n, delay, lo, hi)
+		}
+		prev = delay
+	}
+}
+
+func TestNoRetry(t *testing.T) {
+	var p RetryPolicy = NoRetry{}
+	if p.ShouldRetry(&Attempt{Number: 1, Code: http.StatusInternalServerError}) {
+		t.Errorf("NoRetry should not retry")
+	}
+}
+
+func TestBudget(t *testing.T) {
+	b := NewBudget(10, 5, 1)
+	f := NewFullJitter()
+	f.Budget = b
+	a := &Attempt{Number: 1, Code: http.StatusInternalServerError}
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if f.ShouldRetry(a) {
+				lock.Lock()
+				allowed++
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 2 {
+		t.Errorf("budget should allow 2 retries, allowed %d", allowed)
+	}
+	f.RecordSuccess(a)
+	if b.Available() != 1 {
+		t.Errorf("budget should have 1 token after success, has %v", b.Available())
+	}
+}
+
+func TestPolicyFromContext(t *testing.T) {
+	if _, ok := PolicyFromContext(context.Background()); ok {
+		t.Errorf("background context should have no policy")
+	}
+	ctx := WithPolicy(context.Background(), NoRetry{})
+	p, ok := PolicyFromContext(ctx)
+	if !ok {
+		t.Errorf("policy should be found")
+	}
+	if _, is_noretry := p.(NoRetry); !is_noretry {
+		t.Errorf("policy should be NoRetry")
+	}
+}
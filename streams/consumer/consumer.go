@@ -0,0 +1,346 @@
+// Implements a consumer of DynamoDB Streams that shares the shards of a stream between
+// worker processes, in the manner of the Kinesis Client Library.
+//
+// A Worker discovers the shards of a stream with DescribeStream and keeps one lease per
+// shard in a DynamoDB lease table (see CreateLeaseTable). Every worker sharing the lease
+// table takes leases that are unowned or whose owner has stopped renewing them, and
+// steals leases from the most loaded worker until the shards are balanced. A lease is
+// renewed by incrementing its leaseCounter with a conditional UpdateItem, so a worker
+// that loses a lease finds out on its next heartbeat.
+//
+// The records of each leased shard are read in order and passed to a RecordProcessor
+// made by the RecordProcessorFactory. A child shard created by a split is not processed
+// until its parent has been read to its end, so the records of an item are always seen
+// in order. The processor records its progress with the Checkpointer, which writes the
+// sequence number to the lease; a shard taken over by another worker resumes after the
+// last checkpoint. Records are delivered at least once: those processed after the last
+// checkpoint may be seen again after a failover.
+//
+// example use:
+//
+//	w, err := consumer.NewWorker(consumer.Config{
+//		StreamArn:  arn,
+//		LeaseTable: "ThreadConsumer",
+//	}, func() consumer.RecordProcessor { return &myProcessor{} }, c)
+//	err = w.Run(ctx)
+//
+package consumer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/streams"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// The checkpoint of a lease is a sequence number or one of these.
+	TRIM_HORIZON = streams.TRIM_HORIZON
+	LATEST       = streams.LATEST
+	SHARD_END    = "SHARD_END"
+
+	// Defaults of Config.
+	LEASE_DURATION = 10 * time.Second
+	IDLE_TIME      = time.Second
+)
+
+// ErrLeaseLost is returned by a Checkpointer once another worker has taken the lease.
+var ErrLeaseLost = errors.New("consumer: lease lost")
+
+// ShutdownReason tells a RecordProcessor why it is shut down.
+type ShutdownReason int
+
+const (
+	// TERMINATE means the shard has ended and all of its records were processed.
+	// The consumer checkpoints SHARD_END after Shutdown returns without an error.
+	TERMINATE ShutdownReason = iota
+	// ZOMBIE means the lease was taken by another worker; checkpointing will fail.
+	ZOMBIE
+	// REQUESTED means the worker is stopping; the processor may checkpoint its progress.
+	REQUESTED
+)
+
+func (r ShutdownReason) String() string {
+	switch r {
+	case TERMINATE:
+		return "TERMINATE"
+	case ZOMBIE:
+		return "ZOMBIE"
+	case REQUESTED:
+		return "REQUESTED"
+	}
+	return fmt.Sprintf("ShutdownReason(%d)", int(r))
+}
+
+// RecordProcessor processes the records of one shard. Its methods are called from a
+// single goroutine.
+type RecordProcessor interface {
+	// Initialize is called before the first records of shard_id, which are read after
+	// checkpoint.
+	Initialize(ctx context.Context, shard_id string, checkpoint string) error
+	// ProcessRecords is called with each batch of records in order. If it returns an
+	// error, the same batch is passed again after Config.IdleTime.
+	ProcessRecords(ctx context.Context, records []streams.Record, cp *Checkpointer) error
+	// Shutdown is called once the processor will get no more records.
+	Shutdown(ctx context.Context, reason ShutdownReason, cp *Checkpointer) error
+}
+
+// RecordProcessorFactory makes a RecordProcessor for each shard a worker processes.
+type RecordProcessorFactory func() RecordProcessor
+
+// Config describes the stream a Worker consumes and how it shares it.
+type Config struct {
+	// The stream to consume.
+	StreamArn string
+	// The lease table shared by the workers consuming the stream.
+	LeaseTable string
+	// The name of this worker in the lease table. Defaults to the hostname, the process
+	// id and a random suffix.
+	WorkerId string
+	// Where shards without a checkpoint start when the lease table is empty, TRIM_HORIZON
+	// (the default) or LATEST. Shards found later always start at TRIM_HORIZON.
+	InitialPosition string
+	// How long a lease may go without being renewed before other workers may take it.
+	LeaseDuration time.Duration
+	// How often held leases are renewed. Defaults to a third of LeaseDuration.
+	RenewInterval time.Duration
+	// How often shards are synced and leases are taken. Defaults to LeaseDuration.
+	TakeInterval time.Duration
+	// How long to wait after reading no records from an open shard.
+	IdleTime time.Duration
+	// The Limit of GetRecords requests; 0 uses the service default.
+	BatchSize uint64
+	// The most leases this worker will hold; 0 for no limit.
+	MaxLeases int
+	// If nil, the standard log package is used.
+	Logger conf.Logger
+}
+
+// Worker consumes a stream, sharing its shards with other workers through the lease table.
+type Worker struct {
+	cfg     Config
+	c       *conf.AWS_Conf
+	factory RecordProcessorFactory
+
+	// the leases of the last scan of the lease table
+	known map[string]*Lease
+	// when the counter of each known lease was first seen
+	observed map[string]observation
+	// the leases held by this worker
+	held map[string]*heldLease
+	// consumers of leases that were lost, until they return
+	stopping map[string]*shardConsumer
+	// consumers are sent here when they return
+	finished chan *shardConsumer
+	wg       sync.WaitGroup
+}
+
+// NewWorker returns a worker for cfg that sends its requests with c.
+func NewWorker(cfg Config, factory RecordProcessorFactory, c *conf.AWS_Conf) (*Worker, error) {
+	if factory == nil {
+		return nil, errors.New("consumer.NewWorker: factory is nil")
+	}
+	if !conf.IsValid(c) {
+		return nil, errors.New("consumer.NewWorker: conf not valid")
+	}
+	if cfg.StreamArn == "" || cfg.LeaseTable == "" {
+		return nil, errors.New("consumer.NewWorker: StreamArn and LeaseTable must be set")
+	}
+	switch cfg.InitialPosition {
+	case "":
+		cfg.InitialPosition = TRIM_HORIZON
+	case TRIM_HORIZON, LATEST:
+	default:
+		return nil, errors.New("consumer.NewWorker: InitialPosition must be TRIM_HORIZON or LATEST")
+	}
+	if cfg.WorkerId == "" {
+		host, _ := os.Hostname()
+		b := make([]byte, 4)
+		rand.Read(b)
+		cfg.WorkerId = fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+	}
+	if cfg.LeaseDuration <= 0 {
+		cfg.LeaseDuration = LEASE_DURATION
+	}
+	if cfg.RenewInterval <= 0 {
+		cfg.RenewInterval = cfg.LeaseDuration / 3
+	}
+	if cfg.TakeInterval <= 0 {
+		cfg.TakeInterval = cfg.LeaseDuration
+	}
+	if cfg.IdleTime <= 0 {
+		cfg.IdleTime = IDLE_TIME
+	}
+	if cfg.BatchSize > streams.GETRECORDS_LIMIT {
+		return nil, errors.New("consumer.NewWorker: BatchSize is more than the GetRecords limit")
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.Default()
+	}
+	w := &Worker{
+		cfg:      cfg,
+		c:        c,
+		factory:  factory,
+		known:    make(map[string]*Lease),
+		observed: make(map[string]observation),
+		held:     make(map[string]*heldLease),
+		stopping: make(map[string]*shardConsumer),
+		finished: make(chan *shardConsumer),
+	}
+	return w, nil
+}
+
+// WorkerId returns the name of the worker in the lease table.
+func (w *Worker) WorkerId() string {
+	if w == nil {
+		return ""
+	}
+	return w.cfg.WorkerId
+}
+
+// Run consumes the stream until ctx is done. The processors of the held shards are then
+// shut down with REQUESTED and the leases are released, so that other workers can take
+// them at once. Run returns an error if the stream or lease table cannot be read at all.
+func (w *Worker) Run(ctx context.Context) error {
+	if w == nil {
+		return errors.New("consumer.(Worker)Run: receiver is nil")
+	}
+	if ctx == nil {
+		return errors.New("consumer.(Worker)Run: ctx is nil")
+	}
+	if sync_err := w.sync(ctx); sync_err != nil {
+		return sync_err
+	}
+	w.take(ctx)
+	w.start(ctx)
+	renew := time.NewTicker(w.cfg.RenewInterval)
+	defer renew.Stop()
+	take := time.NewTicker(w.cfg.TakeInterval)
+	defer take.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			w.shutdown()
+			return nil
+		case <-renew.C:
+			w.renew(ctx)
+		case <-take.C:
+			if sync_err := w.sync(ctx); sync_err != nil {
+				w.cfg.Logger.Printf("consumer.(Worker)Run: %s", sync_err.Error())
+			}
+			w.take(ctx)
+			w.start(ctx)
+		case sc := <-w.finished:
+			w.done(sc)
+			// a finished shard may have children ready to be processed
+			if sync_err := w.sync(ctx); sync_err != nil {
+				w.cfg.Logger.Printf("consumer.(Worker)Run: %s", sync_err.Error())
+			}
+			w.start(ctx)
+		}
+	}
+}
+
+// start runs a consumer for every held lease that is ready to be processed.
+func (w *Worker) start(ctx context.Context) {
+	for shard_id, h := range w.held {
+		if h.consumer != nil || w.stopping[shard_id] != nil || !w.ready(shard_id) {
+			continue
+		}
+		sc := newShardConsumer(ctx, w, shard_id, h.checkpoint)
+		h.consumer = sc
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			sc.run()
+			w.finished <- sc
+		}()
+	}
+}
+
+// ready reports whether the parent of the shard, if it is still known, has been
+// read to its end.
+func (w *Worker) ready(shard_id string) bool {
+	l, ok := w.known[shard_id]
+	if !ok || l.Checkpoint == SHARD_END {
+		return false
+	}
+	parent, has_parent := w.known[l.ParentShardId]
+	return l.ParentShardId == "" || !has_parent || parent.Checkpoint == SHARD_END
+}
+
+// done forgets a consumer once it has returned.
+func (w *Worker) done(sc *shardConsumer) {
+	if w.stopping[sc.shard_id] == sc {
+		delete(w.stopping, sc.shard_id)
+	}
+	h, ok := w.held[sc.shard_id]
+	if !ok || h.consumer != sc {
+		return
+	}
+	h.consumer = nil
+	// a consumer started again for the lease resumes from the last checkpoint
+	h.checkpoint = sc.cp.last
+	if sc.ended {
+		// the lease was released along with the SHARD_END checkpoint
+		delete(w.held, sc.shard_id)
+		if l, known := w.known[sc.shard_id]; known {
+			l.Checkpoint = SHARD_END
+			l.LeaseOwner = ""
+		}
+	}
+}
+
+// stop shuts down the consumer of a held lease with reason, and forgets the lease.
+func (w *Worker) stop(shard_id string, reason ShutdownReason) {
+	h, ok := w.held[shard_id]
+	if !ok {
+		return
+	}
+	delete(w.held, shard_id)
+	if h.consumer != nil {
+		w.stopping[shard_id] = h.consumer
+		h.consumer.stop(reason)
+	}
+}
+
+// shutdown stops every consumer with REQUESTED, waits for them and releases the leases.
+func (w *Worker) shutdown() {
+	for _, h := range w.held {
+		if h.consumer != nil {
+			h.consumer.stop(REQUESTED)
+		}
+	}
+	for _, sc := range w.stopping {
+		sc.stop(REQUESTED)
+	}
+	// drain the consumers as they finish
+	all := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(all)
+	}()
+	for waiting := true; waiting; {
+		select {
+		case sc := <-w.finished:
+			w.done(sc)
+		case <-all:
+			waiting = false
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), w.cfg.LeaseDuration)
+	defer cancel()
+	for shard_id := range w.held {
+		if r_err := w.release(ctx, shard_id); r_err != nil {
+			w.cfg.Logger.Printf("consumer.(Worker)shutdown: cannot release %s: %s", shard_id, r_err.Error())
+		}
+		delete(w.held, shard_id)
+	}
+}
@@ -0,0 +1,316 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/emulator"
+	create_table "github.com/smugmug/godynamo/endpoints/create_table"
+	put_item "github.com/smugmug/godynamo/endpoints/put_item"
+	"github.com/smugmug/godynamo/streams"
+	"github.com/smugmug/godynamo/types/attributedefinition"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/aws_strings"
+	"github.com/smugmug/godynamo/types/item"
+	"github.com/smugmug/godynamo/types/keydefinition"
+	"github.com/smugmug/godynamo/types/streamspecification"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+const leaseTable = "ThreadLeases"
+
+var quiet = log.New(io.Discard, "", 0)
+
+// setup starts an emulator with a Thread table streaming NEW_IMAGE and an empty lease table.
+func setup(t *testing.T) (*emulator.Emulator, *conf.AWS_Conf, string) {
+	e := emulator.New()
+	c := e.Conf()
+	ct := create_table.NewCreateTable()
+	ct.TableName = "Thread"
+	ct.AttributeDefinitions = append(ct.AttributeDefinitions,
+		attributedefinition.AttributeDefinition{AttributeName: "Subject", AttributeType: aws_strings.S})
+	ct.KeySchema = append(ct.KeySchema,
+		keydefinition.KeyDefinition{AttributeName: "Subject", KeyType: aws_strings.HASH})
+	ct.ProvisionedThroughput.ReadCapacityUnits = 1
+	ct.ProvisionedThroughput.WriteCapacityUnits = 1
+	ct.StreamSpecification = streamspecification.NewStreamSpecification()
+	ct.StreamSpecification.StreamEnabled = true
+	ct.StreamSpecification.StreamViewType = streams.NEW_IMAGE
+	resp, err := ct.DoWithConf(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lt_err := CreateLeaseTable(context.Background(), leaseTable, 1, 1, c); lt_err != nil {
+		t.Fatal(lt_err)
+	}
+	return e, c, resp.TableDescription.LatestStreamArn
+}
+
+func put(t *testing.T, c *conf.AWS_Conf, subject string) {
+	p := put_item.NewPutItem()
+	p.TableName = "Thread"
+	p.Item["Subject"] = &attributevalue.AttributeValue{S: subject}
+	if _, err := p.DoWithConf(c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// recorder collects the subjects of the records processed by all of its processors.
+type recorder struct {
+	lock        sync.Mutex
+	subjects    []string
+	shards      []string
+	initialized int
+}
+
+func (r *recorder) get() ([]string, []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.subjects...), append([]string(nil), r.shards...)
+}
+
+func (r *recorder) factory() RecordProcessor {
+	return &processor{r: r}
+}
+
+// processor records every record and checkpoints each batch.
+type processor struct {
+	r        *recorder
+	shard_id string
+}
+
+func (p *processor) Initialize(ctx context.Context, shard_id string, checkpoint string) error {
+	p.shard_id = shard_id
+	p.r.lock.Lock()
+	p.r.initialized++
+	p.r.lock.Unlock()
+	return nil
+}
+
+func (p *processor) ProcessRecords(ctx context.Context, records []streams.Record, cp *Checkpointer) error {
+	p.r.lock.Lock()
+	for _, rec := range records {
+		p.r.subjects = append(p.r.subjects, rec.Dynamodb.NewImage["Subject"].S)
+		p.r.shards = append(p.r.shards, p.shard_id)
+	}
+	p.r.lock.Unlock()
+	return cp.CheckpointRecord(ctx, records[len(records)-1])
+}
+
+func (p *processor) Shutdown(ctx context.Context, reason ShutdownReason, cp *Checkpointer) error {
+	return nil
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func testConfig(arn, id string) Config {
+	return Config{
+		StreamArn:     arn,
+		LeaseTable:    leaseTable,
+		WorkerId:      id,
+		LeaseDuration: 300 * time.Millisecond,
+		IdleTime:      10 * time.Millisecond,
+		Logger:        quiet,
+	}
+}
+
+// run runs a worker until the returned func is called.
+func run(t *testing.T, w *Worker) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- w.Run(ctx)
+	}()
+	return func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestNilReceivers(t *testing.T) {
+	var w *Worker
+	if w.Run(context.Background()) == nil {
+		t.Errorf("Run on a nil worker should fail")
+	}
+	var cp *Checkpointer
+	if cp.Checkpoint(context.Background(), "1") == nil {
+		t.Errorf("Checkpoint on a nil checkpointer should fail")
+	}
+	if _, err := NewWorker(Config{StreamArn: "arn"}, (&recorder{}).factory, &conf.Vals); err == nil {
+		t.Errorf("a worker without a lease table should not be made")
+	}
+}
+
+func TestLineageAndResume(t *testing.T) {
+	e, c, arn := setup(t)
+	defer e.Close()
+	put(t, c, "a")
+	put(t, c, "b")
+	if err := e.SplitShard("Thread"); err != nil {
+		t.Fatal(err)
+	}
+	put(t, c, "c")
+	if err := e.SplitShard("Thread"); err != nil {
+		t.Fatal(err)
+	}
+	put(t, c, "d")
+
+	r := new(recorder)
+	w, err := NewWorker(testConfig(arn, "w1"), r.factory, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := run(t, w)
+	waitFor(t, "the records", func() bool {
+		subjects, _ := r.get()
+		return len(subjects) >= 4
+	})
+	stop()
+	subjects, shards := r.get()
+	if fmt.Sprint(subjects) != "[a b c d]" {
+		t.Errorf("records out of order: %v", subjects)
+	}
+	if shards[0] != shards[1] || shards[1] == shards[2] || shards[2] == shards[3] {
+		t.Errorf("unexpected shards %v", shards)
+	}
+
+	// the ended shards were checkpointed SHARD_END and the open one released
+	if s_err := w.scan(context.Background()); s_err != nil {
+		t.Fatal(s_err)
+	}
+	for _, shard_id := range shards {
+		l := w.known[shard_id]
+		if l == nil || l.LeaseOwner != "" {
+			t.Fatalf("unexpected lease %+v", l)
+		}
+		if shard_id != shards[3] && l.Checkpoint != SHARD_END {
+			t.Errorf("shard %s should have ended, got %s", shard_id, l.Checkpoint)
+		}
+	}
+	if w.known[shards[3]].Checkpoint == SHARD_END || w.known[shards[3]].Checkpoint == TRIM_HORIZON {
+		t.Errorf("the open shard should be checkpointed at a sequence number")
+	}
+
+	// another worker resumes after the checkpoint
+	put(t, c, "e")
+	r2 := new(recorder)
+	w2, _ := NewWorker(testConfig(arn, "w2"), r2.factory, c)
+	stop = run(t, w2)
+	waitFor(t, "the new record", func() bool {
+		subjects, _ := r2.get()
+		return len(subjects) >= 1
+	})
+	time.Sleep(50 * time.Millisecond)
+	stop()
+	if subjects, _ = r2.get(); fmt.Sprint(subjects) != "[e]" {
+		t.Errorf("expected to resume after the checkpoint, got %v", subjects)
+	}
+}
+
+func TestInitialPositionLatest(t *testing.T) {
+	e, c, arn := setup(t)
+	defer e.Close()
+	put(t, c, "old")
+	r := new(recorder)
+	cfg := testConfig(arn, "w1")
+	cfg.InitialPosition = LATEST
+	w, err := NewWorker(cfg, r.factory, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := run(t, w)
+	// wait until the shard is being read before writing
+	waitFor(t, "the processor", func() bool {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		return r.initialized != 0
+	})
+	time.Sleep(100 * time.Millisecond)
+	put(t, c, "new")
+	waitFor(t, "the new record", func() bool {
+		subjects, _ := r.get()
+		return len(subjects) >= 1
+	})
+	stop()
+	if subjects, _ := r.get(); fmt.Sprint(subjects) != "[new]" {
+		t.Errorf("expected only the records after LATEST, got %v", subjects)
+	}
+}
+
+// putLease writes a lease held by owner.
+func putLease(t *testing.T, c *conf.AWS_Conf, shard_id, owner string) {
+	it, err := item.Marshal(&Lease{LeaseKey: shard_id, Checkpoint: TRIM_HORIZON, LeaseOwner: owner, LeaseCounter: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := put_item.NewPutItem()
+	p.TableName = leaseTable
+	p.Item = it
+	if _, p_err := p.DoWithConf(c); p_err != nil {
+		t.Fatal(p_err)
+	}
+}
+
+func TestBalancing(t *testing.T) {
+	e, c, arn := setup(t)
+	defer e.Close()
+	for i := 0; i < 4; i++ {
+		putLease(t, c, fmt.Sprintf("shard-%d", i), "a")
+	}
+	ctx := context.Background()
+	w, _ := NewWorker(testConfig(arn, "b"), (&recorder{}).factory, c)
+	// the leases of a are live, so b steals one lease per round until both hold two
+	for round, expect := range []int{1, 2, 2} {
+		if err := w.scan(ctx); err != nil {
+			t.Fatal(err)
+		}
+		w.take(ctx)
+		if len(w.held) != expect {
+			t.Errorf("round %d: expected %d leases, got %d", round, expect, len(w.held))
+		}
+	}
+
+	// once a stops renewing, b takes the rest
+	if err := w.scan(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for k, o := range w.observed {
+		w.observed[k] = observation{counter: o.counter, at: o.at.Add(-time.Second)}
+	}
+	w.take(ctx)
+	if len(w.held) != 4 {
+		t.Errorf("expected to take the expired leases, holding %d", len(w.held))
+	}
+
+	// a lease taken by another worker is lost on the next renewal
+	a, _ := NewWorker(testConfig(arn, "a"), (&recorder{}).factory, c)
+	if err := a.scan(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !a.acquire(ctx, a.known["shard-0"]) {
+		t.Fatal("a could not take shard-0")
+	}
+	w.renew(ctx)
+	if _, ok := w.held["shard-0"]; ok || len(w.held) != 3 {
+		t.Errorf("shard-0 should have been lost, holding %d", len(w.held))
+	}
+	cp := &Checkpointer{w: w, shard_id: "shard-0"}
+	if err := cp.Checkpoint(ctx, "1"); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("checkpointing a lost lease should fail")
+	}
+}
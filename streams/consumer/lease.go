@@ -0,0 +1,469 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/dynamoerr"
+	create_table "github.com/smugmug/godynamo/endpoints/create_table"
+	delete_item "github.com/smugmug/godynamo/endpoints/delete_item"
+	describe_table "github.com/smugmug/godynamo/endpoints/describe_table"
+	put_item "github.com/smugmug/godynamo/endpoints/put_item"
+	scan "github.com/smugmug/godynamo/endpoints/scan"
+	update_item "github.com/smugmug/godynamo/endpoints/update_item"
+	"github.com/smugmug/godynamo/streams"
+	"github.com/smugmug/godynamo/types/attributedefinition"
+	"github.com/smugmug/godynamo/types/attributevalue"
+	"github.com/smugmug/godynamo/types/aws_strings"
+	"github.com/smugmug/godynamo/types/item"
+	"github.com/smugmug/godynamo/types/keydefinition"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+const (
+	// LEASE_KEY is the hash key of the lease table, the shard id.
+	LEASE_KEY = "leaseKey"
+	// the tries made polling for a new lease table to become active
+	LEASE_TABLE_POLL_TRIES = 10
+)
+
+// Lease is an item of the lease table, one per shard.
+type Lease struct {
+	// The shard id.
+	LeaseKey string `dynamodb:"leaseKey"`
+	// The sequence number of the last record processed, or TRIM_HORIZON, LATEST or SHARD_END.
+	Checkpoint string `dynamodb:"checkpoint"`
+	// The worker holding the lease, empty if none.
+	LeaseOwner string `dynamodb:"leaseOwner,omitempty"`
+	// Incremented whenever the lease is renewed or changes owner.
+	LeaseCounter int64 `dynamodb:"leaseCounter"`
+	// The shard this one was split from, if any.
+	ParentShardId string `dynamodb:"parentShardId,omitempty"`
+	// The times the lease changed owner since the last checkpoint.
+	OwnerSwitchesSinceCheckpoint int64 `dynamodb:"ownerSwitchesSinceCheckpoint"`
+}
+
+// observation is the counter of a lease and when this worker first saw it.
+type observation struct {
+	counter int64
+	at      time.Time
+}
+
+// heldLease is a lease held by this worker.
+type heldLease struct {
+	counter    int64
+	renewed    time.Time
+	checkpoint string
+	consumer   *shardConsumer
+}
+
+// CreateLeaseTable creates a lease table with the given throughput and waits for it to
+// become active. It returns nil if the table already exists.
+func CreateLeaseTable(ctx context.Context, table_name string, read, write uint64, c *conf.AWS_Conf) error {
+	if ctx == nil {
+		return errors.New("consumer.CreateLeaseTable: ctx is nil")
+	}
+	ct := create_table.NewCreateTable()
+	ct.TableName = table_name
+	ct.AttributeDefinitions = append(ct.AttributeDefinitions,
+		attributedefinition.AttributeDefinition{AttributeName: LEASE_KEY, AttributeType: aws_strings.S})
+	ct.KeySchema = append(ct.KeySchema,
+		keydefinition.KeyDefinition{AttributeName: LEASE_KEY, KeyType: aws_strings.HASH})
+	ct.ProvisionedThroughput.ReadCapacityUnits = read
+	ct.ProvisionedThroughput.WriteCapacityUnits = write
+	_, ct_err := ct.DoWithContext(ctx, c)
+	if ct_err != nil && !isCode(ct_err, dynamoerr.RESOURCE_IN_USE) {
+		return ct_err
+	}
+	active, poll_err := describe_table.PollTableStatusWithContext(ctx, table_name,
+		describe_table.ACTIVE, LEASE_TABLE_POLL_TRIES, c)
+	if poll_err != nil {
+		return poll_err
+	}
+	if !active {
+		return errors.New("consumer.CreateLeaseTable: " + table_name + " did not become active")
+	}
+	return nil
+}
+
+// isCode reports whether err is an *dynamoerr.APIError with code.
+func isCode(err error, code string) bool {
+	var api_err *dynamoerr.APIError
+	return errors.As(err, &api_err) && api_err.Code == code
+}
+
+// leaseKey returns the key of the lease of a shard.
+func leaseKey(shard_id string) item.Key {
+	k := item.NewKey()
+	k[LEASE_KEY] = &attributevalue.AttributeValue{S: shard_id}
+	return k
+}
+
+// update makes a conditional update of the lease of shard_id. values are named
+// by placeholder; attribute names are given their own name as placeholder, prefixed
+// with "#". It returns ErrLeaseLost if the condition failed.
+func (w *Worker) update(ctx context.Context, shard_id, update_expr, cond string,
+	names []string, values map[string]*attributevalue.AttributeValue) error {
+	u := update_item.NewUpdateItem()
+	u.TableName = w.cfg.LeaseTable
+	u.Key = leaseKey(shard_id)
+	u.UpdateExpression = update_expr
+	u.ConditionExpression = cond
+	for _, n := range names {
+		u.ExpressionAttributeNames["#"+n] = n
+	}
+	for p, v := range values {
+		u.ExpressionAttributeValues[p] = v
+	}
+	_, u_err := u.DoWithContext(ctx, w.c)
+	if isCode(u_err, dynamoerr.CONDITIONAL_CHECK_FAILED) {
+		return ErrLeaseLost
+	}
+	return u_err
+}
+
+func str(s string) *attributevalue.AttributeValue {
+	return &attributevalue.AttributeValue{S: s}
+}
+
+func num(i int64) *attributevalue.AttributeValue {
+	return &attributevalue.AttributeValue{N: strconv.FormatInt(i, 10)}
+}
+
+// describe returns every shard of the stream.
+func (w *Worker) describe(ctx context.Context) ([]streams.Shard, error) {
+	shards := make([]streams.Shard, 0)
+	d := streams.NewDescribeStream(w.cfg.StreamArn)
+	for {
+		resp, d_err := d.DoWithContext(ctx, w.c)
+		if d_err != nil {
+			return nil, d_err
+		}
+		shards = append(shards, resp.StreamDescription.Shards...)
+		if resp.StreamDescription.LastEvaluatedShardId == "" {
+			return shards, nil
+		}
+		d.ExclusiveStartShardId = resp.StreamDescription.LastEvaluatedShardId
+	}
+}
+
+// scan reads every lease of the lease table into w.known, noting when each lease
+// counter was first seen.
+func (w *Worker) scan(ctx context.Context) error {
+	known := make(map[string]*Lease)
+	s := scan.NewScan()
+	s.TableName = w.cfg.LeaseTable
+	for {
+		resp, s_err := s.DoWithContext(ctx, w.c)
+		if s_err != nil {
+			return s_err
+		}
+		for _, it := range resp.Items {
+			l := new(Lease)
+			if um_err := item.Unmarshal(it, l); um_err != nil {
+				return um_err
+			}
+			known[l.LeaseKey] = l
+		}
+		if len(resp.LastEvaluatedKey) == 0 {
+			break
+		}
+		s.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+	now := time.Now()
+	observed := make(map[string]observation)
+	for k, l := range known {
+		if o, ok := w.observed[k]; ok && o.counter == l.LeaseCounter {
+			observed[k] = o
+		} else {
+			observed[k] = observation{counter: l.LeaseCounter, at: now}
+		}
+	}
+	w.known = known
+	w.observed = observed
+	return nil
+}
+
+// sync adds a lease for every shard of the stream that has none, and deletes the
+// finished leases of shards that have been trimmed from the stream.
+func (w *Worker) sync(ctx context.Context) error {
+	shards, d_err := w.describe(ctx)
+	if d_err != nil {
+		return d_err
+	}
+	if s_err := w.scan(ctx); s_err != nil {
+		return s_err
+	}
+	in_stream := make(map[string]bool)
+	for _, sh := range shards {
+		in_stream[sh.ShardId] = true
+	}
+	initial := TRIM_HORIZON
+	if len(w.known) == 0 {
+		initial = w.cfg.InitialPosition
+	}
+	for _, sh := range shards {
+		if _, ok := w.known[sh.ShardId]; ok {
+			continue
+		}
+		l := &Lease{LeaseKey: sh.ShardId, Checkpoint: TRIM_HORIZON}
+		if in_stream[sh.ParentShardId] {
+			l.ParentShardId = sh.ParentShardId
+		}
+		if initial == LATEST {
+			// only the open shards are read, from their tip
+			l.Checkpoint = LATEST
+			if sh.Closed() {
+				l.Checkpoint = SHARD_END
+			}
+		}
+		it, m_err := item.Marshal(l)
+		if m_err != nil {
+			return m_err
+		}
+		p := put_item.NewPutItem()
+		p.TableName = w.cfg.LeaseTable
+		p.Item = it
+		p.ConditionExpression = "attribute_not_exists(#k)"
+		p.ExpressionAttributeNames["#k"] = LEASE_KEY
+		_, p_err := p.DoWithContext(ctx, w.c)
+		if p_err != nil && !isCode(p_err, dynamoerr.CONDITIONAL_CHECK_FAILED) {
+			return p_err
+		}
+		if p_err == nil {
+			w.known[l.LeaseKey] = l
+			w.observed[l.LeaseKey] = observation{counter: l.LeaseCounter, at: time.Now()}
+		}
+	}
+	for k, l := range w.known {
+		if in_stream[k] || l.Checkpoint != SHARD_END {
+			continue
+		}
+		d := delete_item.NewDeleteItem()
+		d.TableName = w.cfg.LeaseTable
+		d.Key = leaseKey(k)
+		d.ConditionExpression = "#c = :end"
+		d.ExpressionAttributeNames["#c"] = "checkpoint"
+		d.ExpressionAttributeValues[":end"] = str(SHARD_END)
+		_, del_err := d.DoWithContext(ctx, w.c)
+		if del_err != nil && !isCode(del_err, dynamoerr.CONDITIONAL_CHECK_FAILED) {
+			return del_err
+		}
+		delete(w.known, k)
+		delete(w.observed, k)
+	}
+	return nil
+}
+
+// expired reports whether the lease may be taken: it has no owner, or its counter has
+// not changed for LeaseDuration.
+func (w *Worker) expired(l *Lease, now time.Time) bool {
+	if l.LeaseOwner == "" {
+		return true
+	}
+	o, ok := w.observed[l.LeaseKey]
+	return ok && now.Sub(o.at) > w.cfg.LeaseDuration
+}
+
+// take takes leases until this worker holds its share of the unfinished leases,
+// counting the workers that renewed a lease lately. Expired leases are taken first; if
+// there are none, one lease is stolen from the most loaded worker if it holds more than
+// its share. It uses the leases of the last scan.
+func (w *Worker) take(ctx context.Context) {
+	now := time.Now()
+	counts := map[string]int{w.cfg.WorkerId: 0}
+	active := 0
+	available := make([]*Lease, 0)
+	for _, l := range w.known {
+		if l.Checkpoint == SHARD_END {
+			continue
+		}
+		active++
+		if _, mine := w.held[l.LeaseKey]; mine {
+			counts[w.cfg.WorkerId]++
+			continue
+		}
+		if w.expired(l, now) {
+			available = append(available, l)
+		} else {
+			counts[l.LeaseOwner]++
+		}
+	}
+	if active == 0 {
+		return
+	}
+	target := (active + len(counts) - 1) / len(counts)
+	if w.cfg.MaxLeases > 0 && target > w.cfg.MaxLeases {
+		target = w.cfg.MaxLeases
+	}
+	need := target - counts[w.cfg.WorkerId]
+	if need <= 0 {
+		return
+	}
+	// prefer leases whose shard is ready to be processed, and spread contention
+	rand.Shuffle(len(available), func(i, j int) { available[i], available[j] = available[j], available[i] })
+	ready := make([]*Lease, 0, len(available))
+	for _, l := range available {
+		if w.ready(l.LeaseKey) {
+			ready = append(ready, l)
+		}
+	}
+	for _, l := range available {
+		if !w.ready(l.LeaseKey) {
+			ready = append(ready, l)
+		}
+	}
+	for _, l := range ready {
+		if need == 0 {
+			return
+		}
+		if w.acquire(ctx, l) {
+			need--
+		}
+	}
+	if len(available) != 0 {
+		return
+	}
+	most, most_count := "", 0
+	for owner, n := range counts {
+		if owner != w.cfg.WorkerId && n > most_count {
+			most, most_count = owner, n
+		}
+	}
+	if most_count <= target {
+		return
+	}
+	victims := make([]*Lease, 0)
+	for _, l := range w.known {
+		if l.LeaseOwner == most && l.Checkpoint != SHARD_END {
+			victims = append(victims, l)
+		}
+	}
+	if len(victims) != 0 {
+		l := victims[rand.Intn(len(victims))]
+		w.cfg.Logger.Printf("consumer.(Worker)take: %s steals %s from %s", w.cfg.WorkerId, l.LeaseKey, most)
+		w.acquire(ctx, l)
+	}
+}
+
+// acquire takes the lease l as last scanned, failing if it has changed since.
+func (w *Worker) acquire(ctx context.Context, l *Lease) bool {
+	values := map[string]*attributevalue.AttributeValue{
+		":me":    str(w.cfg.WorkerId),
+		":c":     num(l.LeaseCounter),
+		":next":  num(l.LeaseCounter + 1),
+		":one":   num(1),
+		":start": num(0),
+	}
+	err := w.update(ctx, l.LeaseKey,
+		"SET #leaseOwner = :me, #leaseCounter = :next, "+
+			"#ownerSwitchesSinceCheckpoint = if_not_exists(#ownerSwitchesSinceCheckpoint, :start) + :one",
+		"#leaseCounter = :c",
+		[]string{"leaseOwner", "leaseCounter", "ownerSwitchesSinceCheckpoint"}, values)
+	if err != nil {
+		if err != ErrLeaseLost {
+			w.cfg.Logger.Printf("consumer.(Worker)acquire: %s: %s", l.LeaseKey, err.Error())
+		}
+		return false
+	}
+	l.LeaseOwner = w.cfg.WorkerId
+	l.LeaseCounter++
+	w.observed[l.LeaseKey] = observation{counter: l.LeaseCounter, at: time.Now()}
+	w.held[l.LeaseKey] = &heldLease{counter: l.LeaseCounter, renewed: time.Now(), checkpoint: l.Checkpoint}
+	return true
+}
+
+// renew increments the counter of every held lease. A lease whose counter or owner
+// has changed, or that could not be renewed for LeaseDuration, is lost and its consumer
+// is shut down with ZOMBIE.
+func (w *Worker) renew(ctx context.Context) {
+	for shard_id, h := range w.held {
+		values := map[string]*attributevalue.AttributeValue{
+			":me":   str(w.cfg.WorkerId),
+			":c":    num(h.counter),
+			":next": num(h.counter + 1),
+		}
+		err := w.update(ctx, shard_id, "SET #leaseCounter = :next",
+			"#leaseOwner = :me AND #leaseCounter = :c",
+			[]string{"leaseOwner", "leaseCounter"}, values)
+		switch {
+		case err == nil:
+			h.counter++
+			h.renewed = time.Now()
+			if l, ok := w.known[shard_id]; ok {
+				l.LeaseCounter = h.counter
+			}
+		case err == ErrLeaseLost || time.Since(h.renewed) > w.cfg.LeaseDuration:
+			w.cfg.Logger.Printf("consumer.(Worker)renew: lost lease %s", shard_id)
+			w.stop(shard_id, ZOMBIE)
+		default:
+			w.cfg.Logger.Printf("consumer.(Worker)renew: %s: %s", shard_id, err.Error())
+		}
+	}
+}
+
+// release gives up a held lease.
+func (w *Worker) release(ctx context.Context, shard_id string) error {
+	values := map[string]*attributevalue.AttributeValue{
+		":me":  str(w.cfg.WorkerId),
+		":one": num(1),
+	}
+	return w.update(ctx, shard_id, "REMOVE #leaseOwner SET #leaseCounter = #leaseCounter + :one",
+		"#leaseOwner = :me", []string{"leaseOwner", "leaseCounter"}, values)
+}
+
+// Checkpointer records the progress of a RecordProcessor in the lease of its shard.
+type Checkpointer struct {
+	w        *Worker
+	shard_id string
+	// the last checkpoint written
+	last string
+}
+
+// ShardId returns the shard the checkpoints are for.
+func (cp *Checkpointer) ShardId() string {
+	if cp == nil {
+		return ""
+	}
+	return cp.shard_id
+}
+
+// Checkpoint records that every record of the shard up to and including sequence_number
+// has been processed. It returns ErrLeaseLost if another worker holds the lease.
+func (cp *Checkpointer) Checkpoint(ctx context.Context, sequence_number string) error {
+	if cp == nil {
+		return errors.New("consumer.(Checkpointer)Checkpoint: receiver is nil")
+	}
+	if sequence_number == "" {
+		return errors.New("consumer.(Checkpointer)Checkpoint: sequence_number is empty")
+	}
+	return cp.write(ctx, sequence_number, false)
+}
+
+// CheckpointRecord is the same as Checkpoint with the sequence number of r.
+func (cp *Checkpointer) CheckpointRecord(ctx context.Context, r streams.Record) error {
+	return cp.Checkpoint(ctx, r.Dynamodb.SequenceNumber)
+}
+
+// write stores the checkpoint, releasing the lease as well if release is set.
+func (cp *Checkpointer) write(ctx context.Context, checkpoint string, release bool) error {
+	values := map[string]*attributevalue.AttributeValue{
+		":me":   str(cp.w.cfg.WorkerId),
+		":cp":   str(checkpoint),
+		":zero": num(0),
+	}
+	update_expr := "SET #checkpoint = :cp, #ownerSwitchesSinceCheckpoint = :zero"
+	names := []string{"leaseOwner", "checkpoint", "ownerSwitchesSinceCheckpoint"}
+	if release {
+		update_expr += " REMOVE #leaseOwner"
+	}
+	err := cp.w.update(ctx, cp.shard_id, update_expr, "#leaseOwner = :me", names, values)
+	if err != nil {
+		return fmt.Errorf("consumer.(Checkpointer)Checkpoint: %s: %w", cp.shard_id, err)
+	}
+	cp.last = checkpoint
+	return nil
+}
@@ -0,0 +1,186 @@
+package consumer
+
+import (
+	"context"
+	"github.com/smugmug/godynamo/dynamoerr"
+	"github.com/smugmug/godynamo/streams"
+	"sync"
+	"time"
+)
+
+// shardConsumer reads the records of one held shard and passes them to a RecordProcessor.
+type shardConsumer struct {
+	shard_id   string
+	checkpoint string
+	w          *Worker
+	ctx        context.Context
+	cancel     context.CancelFunc
+	cp         *Checkpointer
+
+	lock   sync.Mutex
+	reason ShutdownReason
+
+	// set by run if the shard was read to its end and SHARD_END was checkpointed
+	ended bool
+}
+
+func newShardConsumer(ctx context.Context, w *Worker, shard_id, checkpoint string) *shardConsumer {
+	sc := &shardConsumer{shard_id: shard_id, checkpoint: checkpoint, w: w, reason: REQUESTED}
+	sc.ctx, sc.cancel = context.WithCancel(ctx)
+	sc.cp = &Checkpointer{w: w, shard_id: shard_id, last: checkpoint}
+	return sc
+}
+
+// stop makes run return, shutting the processor down with reason.
+func (sc *shardConsumer) stop(reason ShutdownReason) {
+	sc.lock.Lock()
+	sc.reason = reason
+	sc.lock.Unlock()
+	sc.cancel()
+}
+
+// sleep waits for d, returning false if the consumer was stopped first.
+func (sc *shardConsumer) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-sc.ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// iterator returns an iterator positioned after checkpoint.
+func (sc *shardConsumer) iterator(checkpoint string) (string, error) {
+	var g *streams.GetShardIterator
+	switch checkpoint {
+	case TRIM_HORIZON, LATEST:
+		g = streams.NewGetShardIterator(sc.w.cfg.StreamArn, sc.shard_id, checkpoint)
+	default:
+		g = streams.NewGetShardIterator(sc.w.cfg.StreamArn, sc.shard_id, streams.AFTER_SEQUENCE_NUMBER)
+		g.SequenceNumber = checkpoint
+	}
+	resp, g_err := g.DoWithContext(sc.ctx, sc.w.c)
+	if g_err != nil {
+		return "", g_err
+	}
+	return resp.ShardIterator, nil
+}
+
+// run processes the shard until it ends or the consumer is stopped.
+func (sc *shardConsumer) run() {
+	cfg := sc.w.cfg
+	cp := sc.cp
+	p := sc.w.factory()
+	for {
+		i_err := p.Initialize(sc.ctx, sc.shard_id, sc.checkpoint)
+		if i_err == nil {
+			break
+		}
+		cfg.Logger.Printf("consumer.(shardConsumer)run: %s: Initialize: %s", sc.shard_id, i_err.Error())
+		if !sc.sleep(cfg.IdleTime) {
+			return
+		}
+	}
+	defer sc.shutdown(p, cp)
+	// position is where reading resumes if the iterator must be renewed
+	position := sc.checkpoint
+	it := ""
+	for {
+		if it == "" {
+			var it_err error
+			it, it_err = sc.iterator(position)
+			if it_err != nil {
+				if sc.ctx.Err() != nil {
+					return
+				}
+				if isCode(it_err, dynamoerr.TRIMMED_DATA_ACCESS) {
+					position = TRIM_HORIZON
+				}
+				cfg.Logger.Printf("consumer.(shardConsumer)run: %s: %s", sc.shard_id, it_err.Error())
+				if !sc.sleep(cfg.IdleTime) {
+					return
+				}
+				continue
+			}
+		}
+		g := streams.NewGetRecords(it)
+		g.Limit = cfg.BatchSize
+		resp, g_err := g.DoWithContext(sc.ctx, sc.w.c)
+		if g_err != nil {
+			if sc.ctx.Err() != nil {
+				return
+			}
+			switch {
+			case isCode(g_err, dynamoerr.EXPIRED_ITERATOR):
+				it = ""
+				continue
+			case isCode(g_err, dynamoerr.TRIMMED_DATA_ACCESS):
+				// the records after position are gone, read what remains
+				position = TRIM_HORIZON
+				it = ""
+			}
+			cfg.Logger.Printf("consumer.(shardConsumer)run: %s: %s", sc.shard_id, g_err.Error())
+			if !sc.sleep(cfg.IdleTime) {
+				return
+			}
+			continue
+		}
+		if len(resp.Records) != 0 {
+			for {
+				p_err := p.ProcessRecords(sc.ctx, resp.Records, cp)
+				if p_err == nil {
+					break
+				}
+				cfg.Logger.Printf("consumer.(shardConsumer)run: %s: ProcessRecords: %s", sc.shard_id, p_err.Error())
+				if !sc.sleep(cfg.IdleTime) {
+					return
+				}
+			}
+			position = resp.Records[len(resp.Records)-1].Dynamodb.SequenceNumber
+		}
+		if resp.NextShardIterator == "" {
+			sc.end(p, cp)
+			return
+		}
+		it = resp.NextShardIterator
+		if len(resp.Records) == 0 && !sc.sleep(cfg.IdleTime) {
+			return
+		}
+	}
+}
+
+// end shuts the processor down with TERMINATE once the shard has been read to its end,
+// and checkpoints SHARD_END, releasing the lease. If either fails, the lease expires
+// and the end of the shard is read again by the next owner.
+func (sc *shardConsumer) end(p RecordProcessor, cp *Checkpointer) {
+	sc.lock.Lock()
+	sc.reason = TERMINATE
+	sc.lock.Unlock()
+	if s_err := p.Shutdown(sc.ctx, TERMINATE, cp); s_err != nil {
+		sc.w.cfg.Logger.Printf("consumer.(shardConsumer)end: %s: Shutdown: %s", sc.shard_id, s_err.Error())
+		return
+	}
+	if cp_err := cp.write(sc.ctx, SHARD_END, true); cp_err != nil {
+		sc.w.cfg.Logger.Printf("consumer.(shardConsumer)end: %s", cp_err.Error())
+		return
+	}
+	sc.ended = true
+}
+
+// shutdown shuts the processor down with the reason given to stop, unless the shard
+// has ended. The processor is given LeaseDuration to checkpoint.
+func (sc *shardConsumer) shutdown(p RecordProcessor, cp *Checkpointer) {
+	sc.lock.Lock()
+	reason := sc.reason
+	sc.lock.Unlock()
+	if reason == TERMINATE {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sc.w.cfg.LeaseDuration)
+	defer cancel()
+	if s_err := p.Shutdown(ctx, reason, cp); s_err != nil {
+		sc.w.cfg.Logger.Printf("consumer.(shardConsumer)shutdown: %s: Shutdown: %s", sc.shard_id, s_err.Error())
+	}
+}
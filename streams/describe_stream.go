@@ -0,0 +1,117 @@
+// Support for the DynamoDB Streams DescribeStream endpoint.
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/smugmug/godynamo/authreq"
+	"github.com/smugmug/godynamo/aws_const"
+	"github.com/smugmug/godynamo/conf"
+	ep "github.com/smugmug/godynamo/endpoint"
+)
+
+const (
+	DESCRIBESTREAM_ENDPOINT_NAME = "DescribeStream"
+	DESCRIBESTREAM_ENDPOINT      = aws_const.STREAMS_ENDPOINT_PREFIX + DESCRIBESTREAM_ENDPOINT_NAME
+	// the most shards a DescribeStream response may return
+	DESCRIBESTREAM_LIMIT = 100
+)
+
+// DescribeStream describes the stream StreamArn and its shards. The shards are paginated;
+// set ExclusiveStartShardId to the LastEvaluatedShardId of the previous response.
+type DescribeStream struct {
+	ExclusiveStartShardId string `json:",omitempty"`
+	Limit                 uint64 `json:",omitempty"`
+	StreamArn             string
+}
+
+func NewDescribeStream(stream_arn string) *DescribeStream {
+	d := new(DescribeStream)
+	d.StreamArn = stream_arn
+	return d
+}
+
+type DescribeStreamResponse struct {
+	StreamDescription StreamDescription
+}
+
+func NewDescribeStreamResponse() *DescribeStreamResponse {
+	r := new(DescribeStreamResponse)
+	r.StreamDescription = *NewStreamDescription()
+	return r
+}
+
+// These implementations of EndpointReq use a parameterized conf and context.
+
+// EndpointReqWithContext sends the request to the streams endpoint of c.
+func (describe_stream *DescribeStream) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if describe_stream == nil {
+		return nil, 0, errors.New("streams.(DescribeStream)EndpointReqWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, 0, errors.New("streams.(DescribeStream)EndpointReqWithContext: ctx is nil")
+	}
+	if !conf.IsValid(c) {
+		return nil, 0, errors.New("streams.(DescribeStream)EndpointReqWithContext: c is not valid")
+	}
+	// returns resp_body,code,err
+	reqJSON, json_err := json.Marshal(describe_stream)
+	if json_err != nil {
+		return nil, 0, json_err
+	}
+	return authreq.RetryReqJSON_V4WithContext(withService(ctx, c), reqJSON, DESCRIBESTREAM_ENDPOINT, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (describe_stream *DescribeStream) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if describe_stream == nil {
+		return nil, 0, errors.New("streams.(DescribeStream)EndpointReqWithConf: receiver is nil")
+	}
+	return describe_stream.EndpointReqWithContext(context.Background(), c)
+}
+
+// These implementations of EndpointReq use the global conf.
+
+func (describe_stream *DescribeStream) EndpointReq() ([]byte, int, error) {
+	if describe_stream == nil {
+		return nil, 0, errors.New("streams.(DescribeStream)EndpointReq: receiver is nil")
+	}
+	return describe_stream.EndpointReqWithConf(&conf.Vals)
+}
+
+// These implementations of Do perform the request and decode the result into a DescribeStreamResponse.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded DescribeStreamResponse. Error responses are returned as a *dynamoerr.APIError.
+func (describe_stream *DescribeStream) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*DescribeStreamResponse, error) {
+	if describe_stream == nil {
+		return nil, errors.New("streams.(DescribeStream)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("streams.(DescribeStream)DoWithContext: ctx is nil")
+	}
+	body, code, err := describe_stream.EndpointReqWithContext(authreq.WithAPIErrors(ctx), c)
+	resp := NewDescribeStreamResponse()
+	if decode_err := ep.DecodeResponse(body, code, err, resp); decode_err != nil {
+		return nil, decode_err
+	}
+	return resp, nil
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (describe_stream *DescribeStream) DoWithConf(c *conf.AWS_Conf) (*DescribeStreamResponse, error) {
+	if describe_stream == nil {
+		return nil, errors.New("streams.(DescribeStream)DoWithConf: receiver is nil")
+	}
+	return describe_stream.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (describe_stream *DescribeStream) Do() (*DescribeStreamResponse, error) {
+	if describe_stream == nil {
+		return nil, errors.New("streams.(DescribeStream)Do: receiver is nil")
+	}
+	return describe_stream.DoWithConf(&conf.Vals)
+}
@@ -0,0 +1,135 @@
+// Support for the DynamoDB Streams GetRecords endpoint.
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/authreq"
+	"github.com/smugmug/godynamo/aws_const"
+	"github.com/smugmug/godynamo/conf"
+	ep "github.com/smugmug/godynamo/endpoint"
+)
+
+const (
+	GETRECORDS_ENDPOINT_NAME = "GetRecords"
+	GETRECORDS_ENDPOINT      = aws_const.STREAMS_ENDPOINT_PREFIX + GETRECORDS_ENDPOINT_NAME
+	// the most records a GetRecords response may return
+	GETRECORDS_LIMIT = 1000
+)
+
+// GetRecords reads the records of a shard from ShardIterator. Read the following
+// records with the NextShardIterator of the response; it is empty once a closed shard
+// has been read to its end. A response may have no Records while the shard is open.
+type GetRecords struct {
+	Limit         uint64 `json:",omitempty"`
+	ShardIterator string
+}
+
+func NewGetRecords(shard_iterator string) *GetRecords {
+	g := new(GetRecords)
+	g.ShardIterator = shard_iterator
+	return g
+}
+
+type GetRecordsResponse struct {
+	NextShardIterator string `json:",omitempty"`
+	Records           []Record
+}
+
+func NewGetRecordsResponse() *GetRecordsResponse {
+	r := new(GetRecordsResponse)
+	r.Records = make([]Record, 0)
+	return r
+}
+
+// Validate checks that ShardIterator is set and Limit is in range.
+func (get_records *GetRecords) Validate() error {
+	if get_records == nil {
+		return errors.New("streams.(GetRecords)Validate: receiver is nil")
+	}
+	if get_records.ShardIterator == "" {
+		return errors.New("streams.(GetRecords)Validate: ShardIterator must be set")
+	}
+	if get_records.Limit > GETRECORDS_LIMIT {
+		e := fmt.Sprintf("streams.(GetRecords)Validate: Limit %d is more than %d",
+			get_records.Limit, GETRECORDS_LIMIT)
+		return errors.New(e)
+	}
+	return nil
+}
+
+// These implementations of EndpointReq use a parameterized conf and context.
+
+// EndpointReqWithContext sends the request to the streams endpoint of c.
+func (get_records *GetRecords) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if get_records == nil {
+		return nil, 0, errors.New("streams.(GetRecords)EndpointReqWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, 0, errors.New("streams.(GetRecords)EndpointReqWithContext: ctx is nil")
+	}
+	if !conf.IsValid(c) {
+		return nil, 0, errors.New("streams.(GetRecords)EndpointReqWithContext: c is not valid")
+	}
+	// returns resp_body,code,err
+	reqJSON, json_err := json.Marshal(get_records)
+	if json_err != nil {
+		return nil, 0, json_err
+	}
+	return authreq.RetryReqJSON_V4WithContext(withService(ctx, c), reqJSON, GETRECORDS_ENDPOINT, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (get_records *GetRecords) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if get_records == nil {
+		return nil, 0, errors.New("streams.(GetRecords)EndpointReqWithConf: receiver is nil")
+	}
+	return get_records.EndpointReqWithContext(context.Background(), c)
+}
+
+// These implementations of EndpointReq use the global conf.
+
+func (get_records *GetRecords) EndpointReq() ([]byte, int, error) {
+	if get_records == nil {
+		return nil, 0, errors.New("streams.(GetRecords)EndpointReq: receiver is nil")
+	}
+	return get_records.EndpointReqWithConf(&conf.Vals)
+}
+
+// These implementations of Do perform the request and decode the result into a GetRecordsResponse.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded GetRecordsResponse. Error responses are returned as a *dynamoerr.APIError.
+func (get_records *GetRecords) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*GetRecordsResponse, error) {
+	if get_records == nil {
+		return nil, errors.New("streams.(GetRecords)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("streams.(GetRecords)DoWithContext: ctx is nil")
+	}
+	body, code, err := get_records.EndpointReqWithContext(authreq.WithAPIErrors(ctx), c)
+	resp := NewGetRecordsResponse()
+	if decode_err := ep.DecodeResponse(body, code, err, resp); decode_err != nil {
+		return nil, decode_err
+	}
+	return resp, nil
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (get_records *GetRecords) DoWithConf(c *conf.AWS_Conf) (*GetRecordsResponse, error) {
+	if get_records == nil {
+		return nil, errors.New("streams.(GetRecords)DoWithConf: receiver is nil")
+	}
+	return get_records.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (get_records *GetRecords) Do() (*GetRecordsResponse, error) {
+	if get_records == nil {
+		return nil, errors.New("streams.(GetRecords)Do: receiver is nil")
+	}
+	return get_records.DoWithConf(&conf.Vals)
+}
@@ -0,0 +1,150 @@
+// Support for the DynamoDB Streams GetShardIterator endpoint.
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/authreq"
+	"github.com/smugmug/godynamo/aws_const"
+	"github.com/smugmug/godynamo/conf"
+	ep "github.com/smugmug/godynamo/endpoint"
+)
+
+const (
+	GETSHARDITERATOR_ENDPOINT_NAME = "GetShardIterator"
+	GETSHARDITERATOR_ENDPOINT      = aws_const.STREAMS_ENDPOINT_PREFIX + GETSHARDITERATOR_ENDPOINT_NAME
+)
+
+// GetShardIterator returns an iterator for reading the records of a shard with
+// GetRecords. SequenceNumber is required for the AT_SEQUENCE_NUMBER and
+// AFTER_SEQUENCE_NUMBER ShardIteratorTypes, and must not be set for the others.
+// A shard iterator expires 15 minutes after it is returned.
+type GetShardIterator struct {
+	SequenceNumber    string `json:",omitempty"`
+	ShardId           string
+	ShardIteratorType string
+	StreamArn         string
+}
+
+func NewGetShardIterator(stream_arn, shard_id, iterator_type string) *GetShardIterator {
+	g := new(GetShardIterator)
+	g.StreamArn = stream_arn
+	g.ShardId = shard_id
+	g.ShardIteratorType = iterator_type
+	return g
+}
+
+type GetShardIteratorResponse struct {
+	// Empty if the shard is closed and has been trimmed.
+	ShardIterator string `json:",omitempty"`
+}
+
+func NewGetShardIteratorResponse() *GetShardIteratorResponse {
+	return new(GetShardIteratorResponse)
+}
+
+// Validate checks that the request names a shard and that SequenceNumber is set if
+// and only if the ShardIteratorType needs it.
+func (get_shard_iterator *GetShardIterator) Validate() error {
+	if get_shard_iterator == nil {
+		return errors.New("streams.(GetShardIterator)Validate: receiver is nil")
+	}
+	if get_shard_iterator.StreamArn == "" || get_shard_iterator.ShardId == "" {
+		return errors.New("streams.(GetShardIterator)Validate: StreamArn and ShardId must be set")
+	}
+	switch get_shard_iterator.ShardIteratorType {
+	case AT_SEQUENCE_NUMBER, AFTER_SEQUENCE_NUMBER:
+		if get_shard_iterator.SequenceNumber == "" {
+			e := fmt.Sprintf("streams.(GetShardIterator)Validate: %s requires a SequenceNumber",
+				get_shard_iterator.ShardIteratorType)
+			return errors.New(e)
+		}
+	case TRIM_HORIZON, LATEST:
+		if get_shard_iterator.SequenceNumber != "" {
+			e := fmt.Sprintf("streams.(GetShardIterator)Validate: %s cannot have a SequenceNumber",
+				get_shard_iterator.ShardIteratorType)
+			return errors.New(e)
+		}
+	default:
+		e := fmt.Sprintf("streams.(GetShardIterator)Validate: unknown ShardIteratorType %q",
+			get_shard_iterator.ShardIteratorType)
+		return errors.New(e)
+	}
+	return nil
+}
+
+// These implementations of EndpointReq use a parameterized conf and context.
+
+// EndpointReqWithContext sends the request to the streams endpoint of c.
+func (get_shard_iterator *GetShardIterator) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if get_shard_iterator == nil {
+		return nil, 0, errors.New("streams.(GetShardIterator)EndpointReqWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, 0, errors.New("streams.(GetShardIterator)EndpointReqWithContext: ctx is nil")
+	}
+	if !conf.IsValid(c) {
+		return nil, 0, errors.New("streams.(GetShardIterator)EndpointReqWithContext: c is not valid")
+	}
+	// returns resp_body,code,err
+	reqJSON, json_err := json.Marshal(get_shard_iterator)
+	if json_err != nil {
+		return nil, 0, json_err
+	}
+	return authreq.RetryReqJSON_V4WithContext(withService(ctx, c), reqJSON, GETSHARDITERATOR_ENDPOINT, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (get_shard_iterator *GetShardIterator) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if get_shard_iterator == nil {
+		return nil, 0, errors.New("streams.(GetShardIterator)EndpointReqWithConf: receiver is nil")
+	}
+	return get_shard_iterator.EndpointReqWithContext(context.Background(), c)
+}
+
+// These implementations of EndpointReq use the global conf.
+
+func (get_shard_iterator *GetShardIterator) EndpointReq() ([]byte, int, error) {
+	if get_shard_iterator == nil {
+		return nil, 0, errors.New("streams.(GetShardIterator)EndpointReq: receiver is nil")
+	}
+	return get_shard_iterator.EndpointReqWithConf(&conf.Vals)
+}
+
+// These implementations of Do perform the request and decode the result into a GetShardIteratorResponse.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded GetShardIteratorResponse. Error responses are returned as a *dynamoerr.APIError.
+func (get_shard_iterator *GetShardIterator) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*GetShardIteratorResponse, error) {
+	if get_shard_iterator == nil {
+		return nil, errors.New("streams.(GetShardIterator)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("streams.(GetShardIterator)DoWithContext: ctx is nil")
+	}
+	body, code, err := get_shard_iterator.EndpointReqWithContext(authreq.WithAPIErrors(ctx), c)
+	resp := NewGetShardIteratorResponse()
+	if decode_err := ep.DecodeResponse(body, code, err, resp); decode_err != nil {
+		return nil, decode_err
+	}
+	return resp, nil
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (get_shard_iterator *GetShardIterator) DoWithConf(c *conf.AWS_Conf) (*GetShardIteratorResponse, error) {
+	if get_shard_iterator == nil {
+		return nil, errors.New("streams.(GetShardIterator)DoWithConf: receiver is nil")
+	}
+	return get_shard_iterator.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (get_shard_iterator *GetShardIterator) Do() (*GetShardIteratorResponse, error) {
+	if get_shard_iterator == nil {
+		return nil, errors.New("streams.(GetShardIterator)Do: receiver is nil")
+	}
+	return get_shard_iterator.DoWithConf(&conf.Vals)
+}
@@ -0,0 +1,118 @@
+// Support for the DynamoDB Streams ListStreams endpoint.
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/smugmug/godynamo/authreq"
+	"github.com/smugmug/godynamo/aws_const"
+	"github.com/smugmug/godynamo/conf"
+	ep "github.com/smugmug/godynamo/endpoint"
+)
+
+const (
+	LISTSTREAMS_ENDPOINT_NAME = "ListStreams"
+	LISTSTREAMS_ENDPOINT      = aws_const.STREAMS_ENDPOINT_PREFIX + LISTSTREAMS_ENDPOINT_NAME
+	// the most streams a ListStreams response may return
+	LISTSTREAMS_LIMIT = 100
+)
+
+// ListStreams lists the streams of TableName, or of every table if it is empty.
+type ListStreams struct {
+	ExclusiveStartStreamArn string `json:",omitempty"`
+	Limit                   uint64 `json:",omitempty"`
+	TableName               string `json:",omitempty"`
+}
+
+func NewListStreams(table_name string) *ListStreams {
+	l := new(ListStreams)
+	l.TableName = table_name
+	return l
+}
+
+type ListStreamsResponse struct {
+	// Set if there are more streams to list.
+	LastEvaluatedStreamArn string `json:",omitempty"`
+	Streams                []Stream
+}
+
+func NewListStreamsResponse() *ListStreamsResponse {
+	r := new(ListStreamsResponse)
+	r.Streams = make([]Stream, 0)
+	return r
+}
+
+// These implementations of EndpointReq use a parameterized conf and context.
+
+// EndpointReqWithContext sends the request to the streams endpoint of c.
+func (list_streams *ListStreams) EndpointReqWithContext(ctx context.Context, c *conf.AWS_Conf) ([]byte, int, error) {
+	if list_streams == nil {
+		return nil, 0, errors.New("streams.(ListStreams)EndpointReqWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, 0, errors.New("streams.(ListStreams)EndpointReqWithContext: ctx is nil")
+	}
+	if !conf.IsValid(c) {
+		return nil, 0, errors.New("streams.(ListStreams)EndpointReqWithContext: c is not valid")
+	}
+	// returns resp_body,code,err
+	reqJSON, json_err := json.Marshal(list_streams)
+	if json_err != nil {
+		return nil, 0, json_err
+	}
+	return authreq.RetryReqJSON_V4WithContext(withService(ctx, c), reqJSON, LISTSTREAMS_ENDPOINT, c)
+}
+
+// These implementations of EndpointReq use a parameterized conf.
+
+func (list_streams *ListStreams) EndpointReqWithConf(c *conf.AWS_Conf) ([]byte, int, error) {
+	if list_streams == nil {
+		return nil, 0, errors.New("streams.(ListStreams)EndpointReqWithConf: receiver is nil")
+	}
+	return list_streams.EndpointReqWithContext(context.Background(), c)
+}
+
+// These implementations of EndpointReq use the global conf.
+
+func (list_streams *ListStreams) EndpointReq() ([]byte, int, error) {
+	if list_streams == nil {
+		return nil, 0, errors.New("streams.(ListStreams)EndpointReq: receiver is nil")
+	}
+	return list_streams.EndpointReqWithConf(&conf.Vals)
+}
+
+// These implementations of Do perform the request and decode the result into a ListStreamsResponse.
+
+// DoWithContext performs the request with a parameterized conf and context and returns
+// the decoded ListStreamsResponse. Error responses are returned as a *dynamoerr.APIError.
+func (list_streams *ListStreams) DoWithContext(ctx context.Context, c *conf.AWS_Conf) (*ListStreamsResponse, error) {
+	if list_streams == nil {
+		return nil, errors.New("streams.(ListStreams)DoWithContext: receiver is nil")
+	}
+	if ctx == nil {
+		return nil, errors.New("streams.(ListStreams)DoWithContext: ctx is nil")
+	}
+	body, code, err := list_streams.EndpointReqWithContext(authreq.WithAPIErrors(ctx), c)
+	resp := NewListStreamsResponse()
+	if decode_err := ep.DecodeResponse(body, code, err, resp); decode_err != nil {
+		return nil, decode_err
+	}
+	return resp, nil
+}
+
+// DoWithConf is the same as DoWithContext but uses a background context.
+func (list_streams *ListStreams) DoWithConf(c *conf.AWS_Conf) (*ListStreamsResponse, error) {
+	if list_streams == nil {
+		return nil, errors.New("streams.(ListStreams)DoWithConf: receiver is nil")
+	}
+	return list_streams.DoWithContext(context.Background(), c)
+}
+
+// Do is the same as DoWithConf but uses the global conf.Vals.
+func (list_streams *ListStreams) Do() (*ListStreamsResponse, error) {
+	if list_streams == nil {
+		return nil, errors.New("streams.(ListStreams)Do: receiver is nil")
+	}
+	return list_streams.DoWithConf(&conf.Vals)
+}
@@ -0,0 +1,179 @@
+// Support for the DynamoDB Streams endpoints ListStreams, DescribeStream,
+// GetShardIterator and GetRecords.
+//
+// DynamoDB Streams is a separate service from DynamoDB: requests are sent to the
+// endpoint in conf.Network.DynamoDBStreams with the DynamoDBStreams_20120810 target
+// prefix. If that endpoint is not set, it is derived from the DynamoDB endpoint of the
+// conf with conf.StreamsHost. AWS signs streams requests with the "dynamodb" service name,
+// which is the default Name of the Service returned by ServiceFor.
+//
+// The images and keys of a StreamRecord are item.Items, so they can be used with the
+// rest of godynamo, for example with item.Unmarshal.
+//
+// example use:
+//
+//	streams_resp, err := streams.NewListStreams("Thread").DoWithConf(c)
+//	desc, err := streams.NewDescribeStream(streams_resp.Streams[0].StreamArn).DoWithConf(c)
+//	shard := desc.StreamDescription.Shards[0]
+//	it, err := streams.NewGetShardIterator(desc.StreamDescription.StreamArn, shard.ShardId,
+//		streams.TRIM_HORIZON).DoWithConf(c)
+//	records, err := streams.NewGetRecords(it.ShardIterator).DoWithConf(c)
+//
+package streams
+
+import (
+	"context"
+	"github.com/smugmug/godynamo/auth_v4"
+	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/types/item"
+	"github.com/smugmug/godynamo/types/keydefinition"
+	"strings"
+)
+
+const (
+	// SERVICE_NAME is the service name of the credential scope of streams requests.
+	SERVICE_NAME = "dynamodb"
+
+	// ShardIteratorType values.
+	TRIM_HORIZON          = "TRIM_HORIZON"
+	LATEST                = "LATEST"
+	AT_SEQUENCE_NUMBER    = "AT_SEQUENCE_NUMBER"
+	AFTER_SEQUENCE_NUMBER = "AFTER_SEQUENCE_NUMBER"
+
+	// StreamViewType values.
+	KEYS_ONLY          = "KEYS_ONLY"
+	NEW_IMAGE          = "NEW_IMAGE"
+	OLD_IMAGE          = "OLD_IMAGE"
+	NEW_AND_OLD_IMAGES = "NEW_AND_OLD_IMAGES"
+
+	// StreamStatus values.
+	ENABLING  = "ENABLING"
+	ENABLED   = "ENABLED"
+	DISABLING = "DISABLING"
+	DISABLED  = "DISABLED"
+
+	// The eventName of a Record.
+	INSERT = "INSERT"
+	MODIFY = "MODIFY"
+	REMOVE = "REMOVE"
+
+	// The eventSource of a Record.
+	EVENT_SOURCE = "aws:dynamodb"
+)
+
+// Stream identifies the stream of a table.
+type Stream struct {
+	StreamArn   string
+	StreamLabel string
+	TableName   string
+}
+
+// SequenceNumberRange is the range of sequence numbers in a shard. The
+// EndingSequenceNumber is empty while the shard is open.
+type SequenceNumberRange struct {
+	EndingSequenceNumber   string `json:",omitempty"`
+	StartingSequenceNumber string `json:",omitempty"`
+}
+
+// Shard is a shard of a stream. ParentShardId is the shard this one was split from,
+// if any; its records precede the records of this shard.
+type Shard struct {
+	ParentShardId       string `json:",omitempty"`
+	SequenceNumberRange SequenceNumberRange
+	ShardId             string
+}
+
+// Closed reports whether the shard has an ending sequence number, and so will
+// receive no more records.
+func (s *Shard) Closed() bool {
+	return s != nil && s.SequenceNumberRange.EndingSequenceNumber != ""
+}
+
+type StreamDescription struct {
+	// Seconds since the epoch.
+	CreationRequestDateTime float64 `json:",omitempty"`
+	KeySchema               keydefinition.KeySchema
+	// Set if there are more shards to describe.
+	LastEvaluatedShardId string `json:",omitempty"`
+	Shards               []Shard
+	StreamArn            string
+	StreamLabel          string
+	StreamStatus         string
+	StreamViewType       string
+	TableName            string
+}
+
+func NewStreamDescription() *StreamDescription {
+	d := new(StreamDescription)
+	d.KeySchema = make(keydefinition.KeySchema, 0)
+	d.Shards = make([]Shard, 0)
+	return d
+}
+
+// StreamRecord is the change to one item. Which of NewImage and OldImage are set
+// depends on the StreamViewType of the stream and the eventName of the Record.
+type StreamRecord struct {
+	// Seconds since the epoch.
+	ApproximateCreationDateTime float64   `json:",omitempty"`
+	Keys                        item.Item `json:",omitempty"`
+	NewImage                    item.Item `json:",omitempty"`
+	OldImage                    item.Item `json:",omitempty"`
+	SequenceNumber              string
+	SizeBytes                   int64
+	StreamViewType              string
+}
+
+// Identity is the principal that made a change, set on deletions by Time to Live.
+type Identity struct {
+	PrincipalId string
+	Type        string
+}
+
+// Record is one event of a stream. Unlike the rest of the API, its top level
+// fields are in lower camel case.
+type Record struct {
+	AwsRegion    string       `json:"awsRegion"`
+	Dynamodb     StreamRecord `json:"dynamodb"`
+	EventID      string       `json:"eventID"`
+	EventName    string       `json:"eventName"`
+	EventSource  string       `json:"eventSource"`
+	EventVersion string       `json:"eventVersion"`
+	UserIdentity *Identity    `json:"userIdentity,omitempty"`
+}
+
+// ServiceFor returns the auth_v4.Service streams requests made with c are sent to.
+func ServiceFor(c *conf.AWS_Conf) auth_v4.Service {
+	c.ConfLock.RLock()
+	defer c.ConfLock.RUnlock()
+	s := auth_v4.Service{
+		Name: SERVICE_NAME,
+		URL:  c.Network.DynamoDBStreams.URL,
+		Host: c.Network.DynamoDBStreams.Host,
+		Port: c.Network.DynamoDBStreams.Port,
+		Zone: c.Network.DynamoDBStreams.Zone,
+	}
+	if s.Host != "" {
+		return s
+	}
+	// derive the endpoint from the DynamoDB endpoint
+	dynamo := c.Network.DynamoDB
+	s.Host = conf.StreamsHost(dynamo.Host)
+	if s.Port == "" {
+		s.Port = dynamo.Port
+	}
+	if s.Zone == "" {
+		s.Zone = dynamo.Zone
+	}
+	if s.URL == "" {
+		s.URL = dynamo.URL
+		if s.Host != dynamo.Host && dynamo.URL != "" {
+			s.URL = strings.Replace(dynamo.URL, "://"+dynamo.Host, "://"+s.Host, 1)
+		}
+	}
+	return s
+}
+
+// withService returns a copy of ctx that sends requests to the streams endpoint of c.
+func withService(ctx context.Context, c *conf.AWS_Conf) context.Context {
+	return auth_v4.WithService(ctx, ServiceFor(c))
+}
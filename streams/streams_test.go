@@ -0,0 +1,186 @@
+package streams
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/smugmug/godynamo/aws_const"
+	"github.com/smugmug/godynamo/conf"
+	"github.com/smugmug/godynamo/dynamoerr"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNil(t *testing.T) {
+	var l *ListStreams
+	if _, _, err := l.EndpointReqWithConf(&conf.AWS_Conf{}); err == nil {
+		t.Errorf("nil ListStreams receiver should return an error")
+	}
+	var d *DescribeStream
+	if _, err := d.DoWithConf(&conf.AWS_Conf{}); err == nil {
+		t.Errorf("nil DescribeStream receiver should return an error")
+	}
+	var g *GetShardIterator
+	if err := g.Validate(); err == nil {
+		t.Errorf("nil GetShardIterator receiver should return an error")
+	}
+	var r *GetRecords
+	if _, err := r.Do(); err == nil {
+		t.Errorf("nil GetRecords receiver should return an error")
+	}
+}
+
+func TestDescribeStreamResponseUnmarshal(t *testing.T) {
+	s := []byte(`{"StreamDescription":{"CreationRequestDateTime":1.46480646E9,"KeySchema":[{"AttributeName":"ForumName","KeyType":"HASH"},{"AttributeName":"Subject","KeyType":"RANGE"}],"Shards":[{"SequenceNumberRange":{"EndingSequenceNumber":"20500000000000000910398","StartingSequenceNumber":"20500000000000000910398"},"ShardId":"shardId-00000001414562045508-2bac9cd2"},{"ParentShardId":"shardId-00000001414562045508-2bac9cd2","SequenceNumberRange":{"StartingSequenceNumber":"21100000000000000948024"},"ShardId":"shardId-00000001414576573621-f55eea83"}],"StreamArn":"arn:aws:dynamodb:us-west-2:111122223333:table/Forum/stream/2015-05-20T20:51:10.252","StreamLabel":"2015-05-20T20:51:10.252","StreamStatus":"ENABLED","StreamViewType":"NEW_AND_OLD_IMAGES","TableName":"Forum"}}`)
+	r := NewDescribeStreamResponse()
+	um_err := json.Unmarshal(s, r)
+	if um_err != nil {
+		t.Errorf("cannot unmarshal DescribeStream response: %s", um_err.Error())
+		return
+	}
+	d := r.StreamDescription
+	if len(d.Shards) != 2 || len(d.KeySchema) != 2 || d.StreamViewType != NEW_AND_OLD_IMAGES {
+		t.Errorf("unexpected StreamDescription %v", d)
+		return
+	}
+	if !d.Shards[0].Closed() || d.Shards[1].Closed() {
+		t.Errorf("only the first shard should be closed")
+	}
+	if d.Shards[1].ParentShardId != d.Shards[0].ShardId {
+		t.Errorf("the second shard should be a child of the first")
+	}
+	_, json_err := json.Marshal(r)
+	if json_err != nil {
+		t.Errorf("cannot marshal DescribeStream response: %s", json_err.Error())
+	}
+}
+
+func TestGetRecordsResponseUnmarshal(t *testing.T) {
+	s := []byte(`{"NextShardIterator":"arn:aws:dynamodb:us-west-2:111122223333:table/Forum/stream/2015-05-20T20:51:10.252|1|AAAAAAAAAAGQBYshYDEe","Records":[{"awsRegion":"us-west-2","dynamodb":{"ApproximateCreationDateTime":1.46480646E9,"Keys":{"ForumName":{"S":"DynamoDB"},"Subject":{"S":"DynamoDB Thread 3"}},"SequenceNumber":"300000000000000499659","SizeBytes":41,"StreamViewType":"KEYS_ONLY"},"eventID":"e2fd9c34eff2d779b297b26f5fef4206","eventName":"INSERT","eventSource":"aws:dynamodb","eventVersion":"1.0"},{"awsRegion":"us-west-2","dynamodb":{"ApproximateCreationDateTime":1.46480527E9,"Keys":{"ForumName":{"S":"DynamoDB"},"Subject":{"S":"DynamoDB Thread 1"}},"NewImage":{"ForumName":{"S":"DynamoDB"},"Subject":{"S":"DynamoDB Thread 1"},"Views":{"N":"12345678901234567890"}},"OldImage":{"ForumName":{"S":"DynamoDB"},"Subject":{"S":"DynamoDB Thread 1"}},"SequenceNumber":"400000000000000499660","SizeBytes":91,"StreamViewType":"NEW_AND_OLD_IMAGES"},"eventID":"4b25bd0da9a181a155114127e4837252","eventName":"MODIFY","eventSource":"aws:dynamodb","eventVersion":"1.0"},{"awsRegion":"us-west-2","dynamodb":{"ApproximateCreationDateTime":1.46480646E9,"Keys":{"ForumName":{"S":"DynamoDB"},"Subject":{"S":"DynamoDB Thread 2"}},"SequenceNumber":"500000000000000499661","SizeBytes":41,"StreamViewType":"KEYS_ONLY"},"eventID":"740280c73a3df7842edab3548a1b08ad","eventName":"REMOVE","eventSource":"aws:dynamodb","eventVersion":"1.0","userIdentity":{"PrincipalId":"dynamodb.amazonaws.com","Type":"Service"}}]}`)
+	r := NewGetRecordsResponse()
+	um_err := json.Unmarshal(s, r)
+	if um_err != nil {
+		t.Errorf("cannot unmarshal GetRecords response: %s", um_err.Error())
+		return
+	}
+	if len(r.Records) != 3 {
+		t.Errorf("expected 3 records, got %d", len(r.Records))
+		return
+	}
+	modify := r.Records[1]
+	if modify.EventName != MODIFY || modify.EventSource != EVENT_SOURCE {
+		t.Errorf("unexpected record %v", modify)
+	}
+	if modify.Dynamodb.NewImage["Views"].N != "12345678901234567890" {
+		t.Errorf("NewImage should keep the number exactly, got %v", modify.Dynamodb.NewImage["Views"])
+	}
+	if modify.Dynamodb.Keys["Subject"].S != "DynamoDB Thread 1" || len(modify.Dynamodb.OldImage) != 2 {
+		t.Errorf("unexpected keys or old image %v", modify.Dynamodb)
+	}
+	if r.Records[2].UserIdentity == nil || r.Records[2].UserIdentity.Type != "Service" {
+		t.Errorf("expected a userIdentity on the ttl deletion")
+	}
+	b, json_err := json.Marshal(r.Records[0])
+	if json_err != nil {
+		t.Errorf("cannot marshal record: %s", json_err.Error())
+		return
+	}
+	if !strings.Contains(string(b), `"eventName":"INSERT"`) || strings.Contains(string(b), "userIdentity") {
+		t.Errorf("record should marshal with lower camel case fields: %s", string(b))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	g := NewGetShardIterator("arn", "shard", AFTER_SEQUENCE_NUMBER)
+	if g.Validate() == nil {
+		t.Errorf("AFTER_SEQUENCE_NUMBER without a SequenceNumber should not validate")
+	}
+	g.SequenceNumber = "300000000000000499659"
+	if v_err := g.Validate(); v_err != nil {
+		t.Error(v_err)
+	}
+	g.ShardIteratorType = LATEST
+	if g.Validate() == nil {
+		t.Errorf("LATEST with a SequenceNumber should not validate")
+	}
+	r := NewGetRecords("")
+	if r.Validate() == nil {
+		t.Errorf("GetRecords without a ShardIterator should not validate")
+	}
+	r.ShardIterator = "it"
+	r.Limit = GETRECORDS_LIMIT + 1
+	if r.Validate() == nil {
+		t.Errorf("GetRecords Limit should be bounded")
+	}
+}
+
+func TestServiceFor(t *testing.T) {
+	c := new(conf.AWS_Conf)
+	c.Network.DynamoDB.Host = "dynamodb.us-west-2.amazonaws.com"
+	c.Network.DynamoDB.Port = "443"
+	c.Network.DynamoDB.Zone = "us-west-2"
+	c.Network.DynamoDB.URL = "https://dynamodb.us-west-2.amazonaws.com:443"
+	s := ServiceFor(c)
+	if s.Name != SERVICE_NAME || s.Host != "streams.dynamodb.us-west-2.amazonaws.com" ||
+		s.URL != "https://streams.dynamodb.us-west-2.amazonaws.com:443" || s.Zone != "us-west-2" {
+		t.Errorf("unexpected derived service %v", s)
+	}
+	c.Network.DynamoDBStreams.Host = "streams.local"
+	c.Network.DynamoDBStreams.URL = "http://streams.local:8000"
+	if s := ServiceFor(c); s.Host != "streams.local" || s.URL != "http://streams.local:8000" {
+		t.Errorf("the configured streams endpoint should be used, got %v", s)
+	}
+}
+
+// TestRequest checks that requests are sent to the streams endpoint with the streams
+// target and signed for the streams host.
+func TestRequest(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Header().Set("X-Amzn-Requestid", "TEST")
+		if r.Header.Get(aws_const.AMZ_TARGET_HDR) == GETSHARDITERATOR_ENDPOINT {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#TrimmedDataAccessException","message":"trimmed"}`))
+			return
+		}
+		w.Write([]byte(`{"NextShardIterator":"next","Records":[]}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	c := new(conf.AWS_Conf)
+	c.Auth.AccessKey = "AKID"
+	c.Auth.Secret = "SECRET"
+	c.Network.DynamoDB.URL = "http://127.0.0.2:1"
+	c.Network.DynamoDB.Host = "127.0.0.2"
+	c.Network.DynamoDB.Port = "1"
+	c.Network.DynamoDB.Zone = "us-east-1"
+	c.Network.DynamoDBStreams.URL = srv.URL
+	c.Network.DynamoDBStreams.Host = u.Hostname()
+	c.Network.DynamoDBStreams.Port = u.Port()
+	c.Network.DynamoDBStreams.Zone = "us-west-2"
+	c.HTTPClient = srv.Client()
+	c.Initialized = true
+
+	resp, err := NewGetRecords("it").DoWithConf(c)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if resp.NextShardIterator != "next" {
+		t.Errorf("unexpected response %v", resp)
+	}
+	if target := got.Header.Get(aws_const.AMZ_TARGET_HDR); target != "DynamoDBStreams_20120810.GetRecords" {
+		t.Errorf("unexpected target %s", target)
+	}
+	if auth := got.Header.Get("Authorization"); !strings.Contains(auth, "/us-west-2/dynamodb/aws4_request") {
+		t.Errorf("unexpected credential scope in %s", auth)
+	}
+
+	_, err = NewGetShardIterator("arn", "shard", TRIM_HORIZON).DoWithConf(c)
+	if !errors.Is(err, dynamoerr.ErrTrimmedDataAccess) {
+		t.Errorf("expected a TrimmedDataAccessException, got %v", err)
+	}
+}
@@ -3,6 +3,7 @@
 package attributevalue
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -257,12 +258,14 @@ func (a *AttributeValue) InsertS(k string) error {
 	return nil
 }
 
-// InsertN sets the N field to number string k
+// InsertN sets the N field to number string k, which must be a number DynamoDB
+// can store (at most 38 significant digits). k is normalized without loss of
+// precision; see cast.AWSParseNumber.
 func (a *AttributeValue) InsertN(k string) error {
 	if a == nil {
 		return errors.New("AttributeValue.InsertN: pointer receiver is nil")
 	}
-	fs, ferr := cast.AWSParseFloat(k)
+	fs, ferr := cast.AWSParseNumber(k)
 	if ferr != nil {
 		return ferr
 	}
@@ -320,14 +323,14 @@ func (a *AttributeValue) InsertSS(k string) error {
 }
 
 // InsertNS adds a new number string to the ns (JSON: NS) set.
-// String is parsed to make sure it is a represents a valid float.
+// String is parsed to make sure it is a represents a valid number, as for InsertN.
 // NS is *generated* from an internal representation (UM_ns)
 // as it transforms a map into a list (a "set")
 func (a *AttributeValue) InsertNS(k string) error {
 	if a == nil {
 		return errors.New("AttributeValue.InsertNS: pointer receiver is nil")
 	}
-	fs, ferr := cast.AWSParseFloat(k)
+	fs, ferr := cast.AWSParseNumber(k)
 	if ferr != nil {
 		return ferr
 	}
@@ -506,9 +509,11 @@ func BasicJSONToAttributeValueMap(b []byte) (AttributeValueMap, error) {
 	if b == nil {
 		return nil, errors.New("AttributeValue.Insert.BasicJSONToAttributeValueMap: arg is nil")
 	}
-	// unmarshal the arbitrary json
+	// unmarshal the arbitrary json, keeping numbers exact
 	var i interface{}
-	um_err := json.Unmarshal(b, &i)
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	um_err := d.Decode(&i)
 	if um_err != nil {
 		return nil, um_err
 	}
@@ -541,9 +546,11 @@ func BasicJSONToAttributeValue(b []byte) (*AttributeValue, error) {
 	if b == nil {
 		return nil, errors.New("AttributeValue.Insert.BasicJSONToAttributeValue: arg is nil")
 	}
-	// unmarshal the arbitrary json
+	// unmarshal the arbitrary json, keeping numbers exact
 	var i interface{}
-	um_err := json.Unmarshal(b, &i)
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	um_err := d.Decode(&i)
 	if um_err != nil {
 		return nil, um_err
 	}
@@ -561,6 +568,9 @@ func InterfaceToAttributeValue(i interface{}) (*AttributeValue, error) {
 // without their type designations:
 // 1. binary will be dropped as the values will always be coerced to string.
 // 2. null (as a type, not a value) will always be coerced to bool.
+// Numbers may be float64, as produced by json.Unmarshal, or any of json.Number
+// (json.Decoder.UseNumber), Number, the Go integer types, *big.Int and *big.Float,
+// which are converted exactly.
 func CoerceToAttributeValue(i interface{}) (*AttributeValue, error) {
 	a := NewAttributeValue()
 
@@ -572,10 +582,13 @@ func CoerceToAttributeValue(i interface{}) (*AttributeValue, error) {
 		return a, nil
 	}
 
-	// number - float (the default unmarshal will always use this type)
-	n, n_ok := i.(float64)
+	// number - float is the default unmarshal type, json.Number the exact one
+	n, n_ok := numberString(i)
 	if n_ok {
-		a.N = strconv.FormatFloat(n, 'f', -1, 64)
+		nerr := a.InsertN(n)
+		if nerr != nil {
+			return nil, nerr
+		}
 		return a, nil
 	}
 
@@ -606,12 +619,12 @@ func CoerceToAttributeValue(i interface{}) (*AttributeValue, error) {
 	if l_ok {
 		l_len := len(l)
 
-		// check first if the list is composed strictly of floats or strings. If so,
+		// check first if the list is composed strictly of numbers or strings. If so,
 		// then we can make a NS or SS list
-		float_vals := make([]float64, 0)
+		float_vals := make([]string, 0)
 		string_vals := make([]string, 0)
 		for _, u := range l {
-			f, f_ok := u.(float64)
+			f, f_ok := numberString(u)
 			if f_ok {
 				float_vals = append(float_vals, f)
 			} else {
@@ -627,7 +640,7 @@ func CoerceToAttributeValue(i interface{}) (*AttributeValue, error) {
 		// the list is all floats, turn it into an NS
 		if (floats_len == l_len) && (strings_len == 0) {
 			for _, f := range float_vals {
-				ferr := a.InsertNS_float64(f)
+				ferr := a.InsertNS(f)
 				if ferr != nil {
 					return nil, ferr
 				}
@@ -659,8 +672,9 @@ func CoerceToAttributeValue(i interface{}) (*AttributeValue, error) {
 
 // ToBasicJSON provides a mapping from an AttributeValueMap to basic json
 // This allows for items from dynamo to be printed in a flat fashion if desired.
+// Numbers are written exactly as stored.
 func (a AttributeValueMap) ToBasicJSON() ([]byte, error) {
-	c, cerr := a.ToInterface()
+	c, cerr := a.ToInterfaceUseNumber()
 	if cerr != nil {
 		return nil, cerr
 	}
@@ -675,9 +689,19 @@ func (a AttributeValueMap) ToBasicJSON() ([]byte, error) {
 // AttributeValueMapToInterface converts the map into a map of the key names to interface types
 // that do not have type designations, and be marshaled into basic json
 func (a AttributeValueMap) ToInterface() (interface{}, error) {
+	return a.toInterface(false)
+}
+
+// ToInterfaceUseNumber works like ToInterface but numbers are json.Number, so
+// they are not rounded to float64.
+func (a AttributeValueMap) ToInterfaceUseNumber() (interface{}, error) {
+	return a.toInterface(true)
+}
+
+func (a AttributeValueMap) toInterface(use_number bool) (interface{}, error) {
 	m := make(map[string]interface{})
 	for k, v := range a {
-		c, cerr := v.ToInterface()
+		c, cerr := v.toInterface(use_number)
 		if cerr != nil {
 			return nil, cerr
 		} else {
@@ -689,11 +713,12 @@ func (a AttributeValueMap) ToInterface() (interface{}, error) {
 
 // ToBasicJSON provides a mapping from an AttributeValue to basic json
 // This allows for items from dynamo to be printed in a flat fashion if desired.
+// Numbers are written exactly as stored.
 func (a *AttributeValue) ToBasicJSON() ([]byte, error) {
 	if a == nil {
 		return nil, errors.New("AttributeValue.ToBasicJSON: pointer receiver is nil")
 	}
-	c, cerr := a.ToInterface()
+	c, cerr := a.ToInterfaceUseNumber()
 	if cerr != nil {
 		return nil, cerr
 	}
@@ -706,8 +731,25 @@ func (a *AttributeValue) ToBasicJSON() ([]byte, error) {
 }
 
 // AttributeValueToInterface strips the AttributeValue type designations and returns a structure
-// that can be marshaled into basic json.
+// that can be marshaled into basic json. Numbers are float64, which cannot hold every
+// value DynamoDB can; use ToInterfaceUseNumber to keep them exact.
 func (a *AttributeValue) ToInterface() (interface{}, error) {
+	if a == nil {
+		return "", errors.New("AttributeValue.ToInterface: pointer receiver is nil")
+	}
+	return a.toInterface(false)
+}
+
+// ToInterfaceUseNumber works like ToInterface but N is a json.Number and NS a
+// []json.Number, so numbers are not rounded to float64.
+func (a *AttributeValue) ToInterfaceUseNumber() (interface{}, error) {
+	if a == nil {
+		return "", errors.New("AttributeValue.ToInterfaceUseNumber: pointer receiver is nil")
+	}
+	return a.toInterface(true)
+}
+
+func (a *AttributeValue) toInterface(use_number bool) (interface{}, error) {
 	if a == nil {
 		return "", errors.New("AttributeValue.ToInterface: pointer receiver is nil")
 	}
@@ -724,6 +766,9 @@ func (a *AttributeValue) ToInterface() (interface{}, error) {
 		return a.B, nil
 	}
 	if a.N != "" {
+		if use_number {
+			return json.Number(a.N), nil
+		}
 		f, ferr := strconv.ParseFloat(a.N, 64)
 		if ferr != nil {
 			return nil, ferr
@@ -738,6 +783,13 @@ func (a *AttributeValue) ToInterface() (interface{}, error) {
 		return a.BS, nil
 	}
 	ns_len := len(a.NS)
+	if ns_len != 0 && use_number {
+		ns := make([]json.Number, ns_len)
+		for i, n := range a.NS {
+			ns[i] = json.Number(n)
+		}
+		return ns, nil
+	}
 	if ns_len != 0 {
 		ns := make([]float64, ns_len)
 		for i, n := range a.NS {
@@ -754,7 +806,7 @@ func (a *AttributeValue) ToInterface() (interface{}, error) {
 	if l_len != 0 {
 		ls := make([]interface{}, l_len)
 		for i, v := range a.L {
-			c, cerr := v.ToInterface()
+			c, cerr := v.toInterface(use_number)
 			if cerr != nil {
 				return nil, cerr
 			} else {
@@ -767,7 +819,7 @@ func (a *AttributeValue) ToInterface() (interface{}, error) {
 	if m_len != 0 {
 		m := make(map[string]interface{})
 		for k, v := range a.M {
-			c, cerr := v.ToInterface()
+			c, cerr := v.toInterface(use_number)
 			if cerr != nil {
 				return nil, cerr
 			} else {
@@ -0,0 +1,768 @@
+package attributevalue
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TAG is the struct tag read by MarshalItem and UnmarshalItem. Its value is the
+// attribute name followed by comma separated options:
+//
+//	omitempty  omit the attribute if the field is empty or marshals to NULL
+//	stringset  marshal a slice of strings as SS instead of L
+//	numberset  marshal a slice of numbers as NS instead of L
+//	binaryset  marshal a slice of []byte as BS instead of L
+//
+// A name of "-" skips the field. Untagged fields use the field name, and the fields
+// of untagged embedded structs are treated as fields of the outer struct.
+const TAG = "dynamodb"
+
+// Marshaler is implemented by types that convert themselves to an AttributeValue.
+type Marshaler interface {
+	MarshalAttributeValue() (*AttributeValue, error)
+}
+
+// Unmarshaler is implemented by types that set themselves from an AttributeValue.
+type Unmarshaler interface {
+	UnmarshalAttributeValue(*AttributeValue) error
+}
+
+var (
+	marshalerType      = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType    = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	attributeValueType = reflect.TypeOf(AttributeValue{})
+	timeType           = reflect.TypeOf(time.Time{})
+	numberType         = reflect.TypeOf(json.Number(""))
+	exactNumberType    = reflect.TypeOf(Number(""))
+	bigIntType         = reflect.TypeOf(big.Int{})
+	bigFloatType       = reflect.TypeOf(big.Float{})
+)
+
+type tagOptions struct {
+	omitempty bool
+	stringset bool
+	numberset bool
+	binaryset bool
+}
+
+func parseTag(tag string) (string, tagOptions) {
+	var opts tagOptions
+	parts := strings.Split(tag, ",")
+	for _, o := range parts[1:] {
+		switch o {
+		case "omitempty":
+			opts.omitempty = true
+		case "stringset":
+			opts.stringset = true
+		case "numberset":
+			opts.numberset = true
+		case "binaryset":
+			opts.binaryset = true
+		}
+	}
+	return parts[0], opts
+}
+
+// field is an attribute of a struct type, possibly promoted from an embedded struct.
+type field struct {
+	name   string
+	index  []int
+	tagged bool
+	opts   tagOptions
+}
+
+var fieldCache sync.Map
+
+// fieldsOf returns the attributes of a struct type, resolving promoted fields as
+// encoding/json does: the shallowest field wins, and of several at the same depth
+// only a single tagged one is kept.
+func fieldsOf(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+	type queued struct {
+		t     reflect.Type
+		index []int
+	}
+	fields := make([]field, 0)
+	seen := make(map[string]bool)
+	visited := make(map[reflect.Type]bool)
+	next := []queued{{t: t}}
+	for len(next) != 0 {
+		current := next
+		next = nil
+		level := make([]field, 0)
+		count := make(map[string]int)
+		for _, q := range current {
+			if visited[q.t] {
+				continue
+			}
+			visited[q.t] = true
+			for i := 0; i < q.t.NumField(); i++ {
+				sf := q.t.Field(i)
+				tag := sf.Tag.Get(TAG)
+				if tag == "-" {
+					continue
+				}
+				name, opts := parseTag(tag)
+				index := append(append([]int{}, q.index...), i)
+				if sf.Anonymous && name == "" {
+					ft := sf.Type
+					if ft.Kind() == reflect.Ptr {
+						ft = ft.Elem()
+					}
+					if ft.Kind() == reflect.Struct && ft != timeType {
+						next = append(next, queued{t: ft, index: index})
+						continue
+					}
+				}
+				if sf.PkgPath != "" {
+					continue
+				}
+				f := field{name: name, index: index, tagged: name != "", opts: opts}
+				if f.name == "" {
+					f.name = sf.Name
+				}
+				level = append(level, f)
+				count[f.name]++
+			}
+		}
+		for _, f := range level {
+			if seen[f.name] {
+				continue
+			}
+			if count[f.name] > 1 {
+				tagged := 0
+				for _, g := range level {
+					if g.name == f.name && g.tagged {
+						tagged++
+					}
+				}
+				if !f.tagged || tagged != 1 {
+					continue
+				}
+			}
+			fields = append(fields, f)
+		}
+		for _, f := range level {
+			seen[f.name] = true
+		}
+	}
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// fieldByIndex returns the field of v at index, or false if it is reached through a nil
+// embedded pointer. If alloc is set, nil embedded pointers are allocated instead.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface().(time.Time).IsZero()
+		}
+	}
+	return false
+}
+
+func null() *AttributeValue {
+	a := NewAttributeValue()
+	_ = a.InsertNULL(true)
+	return a
+}
+
+func isNull(a *AttributeValue) bool {
+	return a == nil || (a.NULL != nil && *a.NULL)
+}
+
+// join appends a struct field or map key to a field path.
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func fieldError(path string, msg string) error {
+	if path == "" {
+		return errors.New(msg)
+	}
+	return errors.New(fmt.Sprintf("field %s: %s", path, msg))
+}
+
+// Marshal converts a Go value to an AttributeValue. Strings, numbers and bools become
+// S, N and BOOL, and json.Number, Number, big.Int and big.Float become an exact N;
+// []byte becomes B; time.Time becomes an RFC 3339 S; slices and arrays become L;
+// structs and maps with string keys become M; nil pointers, slices, maps and empty
+// strings become NULL. Types implementing Marshaler convert themselves.
+func Marshal(v interface{}) (*AttributeValue, error) {
+	a, m_err := marshalValue(reflect.ValueOf(v), tagOptions{}, "")
+	if m_err != nil {
+		return nil, errors.New("attributevalue.Marshal: " + m_err.Error())
+	}
+	return a, nil
+}
+
+// MarshalItem converts a struct, or a map with string keys, to an AttributeValueMap
+// using the dynamodb struct tags of its fields (see TAG). Use item.Marshal for the
+// result as an item.Item.
+func MarshalItem(v interface{}) (AttributeValueMap, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, errors.New("attributevalue.MarshalItem: v is nil")
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil, errors.New("attributevalue.MarshalItem: v is nil")
+	}
+	if rv.Kind() != reflect.Struct && rv.Kind() != reflect.Map {
+		e := fmt.Sprintf("attributevalue.MarshalItem: cannot marshal %s as an item", rv.Type())
+		return nil, errors.New(e)
+	}
+	a, m_err := marshalValue(rv, tagOptions{}, "")
+	if m_err != nil {
+		return nil, errors.New("attributevalue.MarshalItem: " + m_err.Error())
+	}
+	if isNull(a) {
+		return NewAttributeValueMap(), nil
+	}
+	return AttributeValueMap(a.M), nil
+}
+
+func marshalValue(v reflect.Value, opts tagOptions, path string) (*AttributeValue, error) {
+	if !v.IsValid() {
+		return null(), nil
+	}
+	if v.Type().Implements(marshalerType) {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return null(), nil
+		}
+		a, m_err := v.Interface().(Marshaler).MarshalAttributeValue()
+		if m_err != nil {
+			return nil, fieldError(path, m_err.Error())
+		}
+		if a == nil {
+			return null(), nil
+		}
+		return a, nil
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() && v.Addr().Type().Implements(marshalerType) {
+		return marshalValue(v.Addr(), opts, path)
+	}
+	switch v.Type() {
+	case attributeValueType:
+		a := v.Interface().(AttributeValue)
+		return &a, nil
+	case timeType:
+		return &AttributeValue{S: v.Interface().(time.Time).Format(time.RFC3339Nano)}, nil
+	case numberType, exactNumberType:
+		if v.String() == "" {
+			return null(), nil
+		}
+		a := NewAttributeValue()
+		if n_err := a.InsertN(v.String()); n_err != nil {
+			return nil, fieldError(path, n_err.Error())
+		}
+		return a, nil
+	case bigIntType, bigFloatType:
+		if !v.CanAddr() {
+			c := reflect.New(v.Type())
+			c.Elem().Set(v)
+			v = c.Elem()
+		}
+		a := NewAttributeValue()
+		if n_err := a.InsertN_big(v.Addr().Interface()); n_err != nil {
+			return nil, fieldError(path, n_err.Error())
+		}
+		return a, nil
+	}
+	a := NewAttributeValue()
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return null(), nil
+		}
+		return marshalValue(v.Elem(), opts, path)
+	case reflect.String:
+		if v.Len() == 0 {
+			return null(), nil
+		}
+		a.S = v.String()
+	case reflect.Bool:
+		_ = a.InsertBOOL(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		a.N = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		a.N = strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		_ = a.InsertN_float64(v.Float())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return null(), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 && !opts.numberset {
+			if v.Len() == 0 {
+				return null(), nil
+			}
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			a.B = base64.StdEncoding.EncodeToString(b)
+			return a, nil
+		}
+		if opts.stringset || opts.numberset || opts.binaryset {
+			return marshalSet(v, opts, path)
+		}
+		for i := 0; i < v.Len(); i++ {
+			e, e_err := marshalValue(v.Index(i), tagOptions{}, fmt.Sprintf("%s[%d]", path, i))
+			if e_err != nil {
+				return nil, e_err
+			}
+			a.L = append(a.L, e)
+		}
+		if len(a.L) == 0 {
+			return null(), nil
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fieldError(path, "cannot marshal a map with "+v.Type().Key().String()+" keys")
+		}
+		if v.IsNil() {
+			return null(), nil
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			k := iter.Key().String()
+			e, e_err := marshalValue(iter.Value(), tagOptions{}, join(path, k))
+			if e_err != nil {
+				return nil, e_err
+			}
+			a.M[k] = e
+		}
+		if len(a.M) == 0 {
+			return null(), nil
+		}
+	case reflect.Struct:
+		for _, f := range fieldsOf(v.Type()) {
+			fv, ok := fieldByIndex(v, f.index, false)
+			if !ok || (f.opts.omitempty && isEmptyValue(fv)) {
+				continue
+			}
+			e, e_err := marshalValue(fv, f.opts, join(path, f.name))
+			if e_err != nil {
+				return nil, e_err
+			}
+			if f.opts.omitempty && isNull(e) {
+				continue
+			}
+			a.M[f.name] = e
+		}
+		if len(a.M) == 0 {
+			return null(), nil
+		}
+	default:
+		return nil, fieldError(path, "cannot marshal type "+v.Type().String())
+	}
+	return a, nil
+}
+
+// marshalSet marshals a slice or array as SS, NS or BS. Empty sets marshal as NULL.
+func marshalSet(v reflect.Value, opts tagOptions, path string) (*AttributeValue, error) {
+	a := NewAttributeValue()
+	seen := make(map[string]bool)
+	for i := 0; i < v.Len(); i++ {
+		e, e_err := marshalValue(v.Index(i), tagOptions{}, fmt.Sprintf("%s[%d]", path, i))
+		if e_err != nil {
+			return nil, e_err
+		}
+		var s string
+		var set *SetList
+		switch {
+		case opts.stringset && e.S != "":
+			s, set = e.S, &a.SS
+		case opts.numberset && e.N != "":
+			s, set = e.N, &a.NS
+		case opts.binaryset && e.B != "":
+			s, set = e.B, &a.BS
+		default:
+			return nil, fieldError(fmt.Sprintf("%s[%d]", path, i), "not a valid member of the set")
+		}
+		if seen[s] {
+			return nil, fieldError(fmt.Sprintf("%s[%d]", path, i), "duplicate member of the set")
+		}
+		seen[s] = true
+		*set = append(*set, s)
+	}
+	if len(seen) == 0 {
+		return null(), nil
+	}
+	return a, nil
+}
+
+// Unmarshal sets the value pointed to by v from an AttributeValue, the reverse of
+// Marshal. NULL sets the zero value. An interface{} is set to a string, json.Number,
+// []byte, bool, nil, []string, []json.Number, [][]byte, []interface{} or
+// map[string]interface{}, so numbers are not rounded to float64, as with
+// ToInterfaceUseNumber. Types implementing Unmarshaler set themselves.
+func Unmarshal(a *AttributeValue, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("attributevalue.Unmarshal: v must be a non-nil pointer")
+	}
+	if u_err := unmarshalValue(a, rv.Elem(), ""); u_err != nil {
+		return errors.New("attributevalue.Unmarshal: " + u_err.Error())
+	}
+	return nil
+}
+
+// UnmarshalItem sets the struct or map pointed to by v from m, the reverse of
+// MarshalItem. Attributes with no corresponding field are ignored. Use item.Unmarshal
+// to unmarshal an item.Item.
+func UnmarshalItem(m AttributeValueMap, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("attributevalue.UnmarshalItem: v must be a non-nil pointer")
+	}
+	if u_err := unmarshalValue(&AttributeValue{M: m}, rv.Elem(), ""); u_err != nil {
+		return errors.New("attributevalue.UnmarshalItem: " + u_err.Error())
+	}
+	return nil
+}
+
+// typeName names the type of a for error messages.
+func typeName(a *AttributeValue) string {
+	switch {
+	case a.S != "":
+		return "S"
+	case a.N != "":
+		return "N"
+	case a.B != "":
+		return "B"
+	case a.BOOL != nil:
+		return "BOOL"
+	case a.NULL != nil:
+		return "NULL"
+	case len(a.SS) != 0:
+		return "SS"
+	case len(a.NS) != 0:
+		return "NS"
+	case len(a.BS) != 0:
+		return "BS"
+	case len(a.L) != 0:
+		return "L"
+	case len(a.M) != 0:
+		return "M"
+	}
+	return "empty AttributeValue"
+}
+
+func mismatch(a *AttributeValue, v reflect.Value, path string) error {
+	return fieldError(path, fmt.Sprintf("cannot unmarshal %s into %s", typeName(a), v.Type()))
+}
+
+func unmarshalValue(a *AttributeValue, v reflect.Value, path string) error {
+	if v.Kind() != reflect.Ptr && v.CanAddr() && v.Addr().Type().Implements(unmarshalerType) {
+		if isNull(a) {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if u_err := v.Addr().Interface().(Unmarshaler).UnmarshalAttributeValue(a); u_err != nil {
+			return fieldError(path, u_err.Error())
+		}
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		if isNull(a) {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return unmarshalValue(a, v.Elem(), path)
+	}
+	if isNull(a) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	switch v.Type() {
+	case attributeValueType:
+		c := NewAttributeValue()
+		_ = a.Copy(c)
+		v.Set(reflect.ValueOf(*c))
+		return nil
+	case timeType:
+		var t time.Time
+		switch {
+		case a.S != "":
+			p, p_err := time.Parse(time.RFC3339Nano, a.S)
+			if p_err != nil {
+				return fieldError(path, p_err.Error())
+			}
+			t = p
+		case a.N != "":
+			secs, s_err := strconv.ParseInt(a.N, 10, 64)
+			if s_err != nil {
+				return fieldError(path, s_err.Error())
+			}
+			t = time.Unix(secs, 0)
+		default:
+			return mismatch(a, v, path)
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case numberType, exactNumberType:
+		if a.N == "" {
+			return mismatch(a, v, path)
+		}
+		v.SetString(a.N)
+		return nil
+	case bigIntType:
+		n, n_err := Number(a.N).BigInt()
+		if a.N == "" || n_err != nil {
+			return mismatch(a, v, path)
+		}
+		v.Set(reflect.ValueOf(*n))
+		return nil
+	case bigFloatType:
+		f, f_err := Number(a.N).BigFloat()
+		if a.N == "" || f_err != nil {
+			return mismatch(a, v, path)
+		}
+		v.Set(reflect.ValueOf(*f))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return mismatch(a, v, path)
+		}
+		i, i_err := natural(a)
+		if i_err != nil {
+			return fieldError(path, i_err.Error())
+		}
+		if i == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(i))
+		}
+	case reflect.String:
+		if a.S == "" {
+			return mismatch(a, v, path)
+		}
+		v.SetString(a.S)
+	case reflect.Bool:
+		if a.BOOL == nil {
+			return mismatch(a, v, path)
+		}
+		v.SetBool(*a.BOOL)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if a.N == "" {
+			return mismatch(a, v, path)
+		}
+		n, n_err := strconv.ParseInt(a.N, 10, 64)
+		if n_err != nil || v.OverflowInt(n) {
+			return fieldError(path, fmt.Sprintf("cannot unmarshal %s into %s", a.N, v.Type()))
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if a.N == "" {
+			return mismatch(a, v, path)
+		}
+		n, n_err := strconv.ParseUint(a.N, 10, 64)
+		if n_err != nil || v.OverflowUint(n) {
+			return fieldError(path, fmt.Sprintf("cannot unmarshal %s into %s", a.N, v.Type()))
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if a.N == "" {
+			return mismatch(a, v, path)
+		}
+		f, f_err := strconv.ParseFloat(a.N, v.Type().Bits())
+		if f_err != nil {
+			return fieldError(path, fmt.Sprintf("cannot unmarshal %s into %s", a.N, v.Type()))
+		}
+		v.SetFloat(f)
+	case reflect.Slice, reflect.Array:
+		return unmarshalList(a, v, path)
+	case reflect.Map:
+		if len(a.M) == 0 || v.Type().Key().Kind() != reflect.String {
+			return mismatch(a, v, path)
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(a.M)))
+		}
+		for k, e := range a.M {
+			ev := reflect.New(v.Type().Elem()).Elem()
+			if u_err := unmarshalValue(e, ev, join(path, k)); u_err != nil {
+				return u_err
+			}
+			v.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), ev)
+		}
+	case reflect.Struct:
+		if len(a.M) == 0 {
+			return mismatch(a, v, path)
+		}
+		for _, f := range fieldsOf(v.Type()) {
+			e, ok := a.M[f.name]
+			if !ok {
+				continue
+			}
+			fv, ok := fieldByIndex(v, f.index, true)
+			if !ok {
+				continue
+			}
+			if u_err := unmarshalValue(e, fv, join(path, f.name)); u_err != nil {
+				return u_err
+			}
+		}
+	default:
+		return fieldError(path, "cannot unmarshal into type "+v.Type().String())
+	}
+	return nil
+}
+
+// unmarshalList sets a slice or array from B, L, SS, NS or BS.
+func unmarshalList(a *AttributeValue, v reflect.Value, path string) error {
+	if a.B != "" {
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return mismatch(a, v, path)
+		}
+		b, b_err := base64.StdEncoding.DecodeString(a.B)
+		if b_err != nil {
+			return fieldError(path, b_err.Error())
+		}
+		if v.Kind() == reflect.Array {
+			if len(b) > v.Len() {
+				return fieldError(path, fmt.Sprintf("%d bytes do not fit in %s", len(b), v.Type()))
+			}
+			reflect.Copy(v, reflect.ValueOf(b))
+			return nil
+		}
+		v.Set(reflect.ValueOf(b).Convert(v.Type()))
+		return nil
+	}
+	elems := make([]*AttributeValue, 0)
+	switch {
+	case len(a.L) != 0:
+		elems = a.L
+	case len(a.SS) != 0:
+		for _, s := range a.SS {
+			elems = append(elems, &AttributeValue{S: s})
+		}
+	case len(a.NS) != 0:
+		for _, n := range a.NS {
+			elems = append(elems, &AttributeValue{N: n})
+		}
+	case len(a.BS) != 0:
+		for _, b := range a.BS {
+			elems = append(elems, &AttributeValue{B: b})
+		}
+	default:
+		return mismatch(a, v, path)
+	}
+	if v.Kind() == reflect.Array {
+		if len(elems) > v.Len() {
+			return fieldError(path, fmt.Sprintf("%d elements do not fit in %s", len(elems), v.Type()))
+		}
+	} else {
+		v.Set(reflect.MakeSlice(v.Type(), len(elems), len(elems)))
+	}
+	for i, e := range elems {
+		if u_err := unmarshalValue(e, v.Index(i), fmt.Sprintf("%s[%d]", path, i)); u_err != nil {
+			return u_err
+		}
+	}
+	return nil
+}
+
+// natural returns the Go value Unmarshal sets into an interface{}.
+func natural(a *AttributeValue) (interface{}, error) {
+	switch {
+	case a.S != "":
+		return a.S, nil
+	case a.N != "":
+		return json.Number(a.N), nil
+	case a.B != "":
+		return base64.StdEncoding.DecodeString(a.B)
+	case a.BOOL != nil:
+		return *a.BOOL, nil
+	case a.NULL != nil:
+		return nil, nil
+	case len(a.SS) != 0:
+		return append([]string{}, a.SS...), nil
+	case len(a.NS) != 0:
+		ns := make([]json.Number, len(a.NS))
+		for i, n := range a.NS {
+			ns[i] = json.Number(n)
+		}
+		return ns, nil
+	case len(a.BS) != 0:
+		bs := make([][]byte, len(a.BS))
+		for i, s := range a.BS {
+			b, b_err := base64.StdEncoding.DecodeString(s)
+			if b_err != nil {
+				return nil, b_err
+			}
+			bs[i] = b
+		}
+		return bs, nil
+	case len(a.L) != 0:
+		l := make([]interface{}, len(a.L))
+		for i, e := range a.L {
+			n, n_err := natural(e)
+			if n_err != nil {
+				return nil, n_err
+			}
+			l[i] = n
+		}
+		return l, nil
+	case len(a.M) != 0:
+		m := make(map[string]interface{}, len(a.M))
+		for k, e := range a.M {
+			n, n_err := natural(e)
+			if n_err != nil {
+				return nil, n_err
+			}
+			m[k] = n
+		}
+		return m, nil
+	}
+	return nil, errors.New("empty AttributeValue")
+}
@@ -0,0 +1,191 @@
+package attributevalue
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Base struct {
+	ID      string `dynamodb:"id"`
+	Version int
+}
+
+type Picture struct {
+	URL    string
+	Width  uint16
+	Height uint16 `dynamodb:",omitempty"`
+}
+
+type Celsius float64
+
+func (c Celsius) MarshalAttributeValue() (*AttributeValue, error) {
+	a := NewAttributeValue()
+	return a, a.InsertS(strconv.FormatFloat(float64(c), 'f', -1, 64) + "C")
+}
+
+func (c *Celsius) UnmarshalAttributeValue(a *AttributeValue) error {
+	f, err := strconv.ParseFloat(strings.TrimSuffix(a.S, "C"), 64)
+	if err != nil {
+		return err
+	}
+	*c = Celsius(f)
+	return nil
+}
+
+type Thread struct {
+	Base
+	Subject  string            `dynamodb:"subject"`
+	Views    int64             `dynamodb:",omitempty"`
+	Rating   *float64          `dynamodb:"rating,omitempty"`
+	Tags     []string          `dynamodb:"tags,stringset"`
+	Scores   []int             `dynamodb:"scores,numberset"`
+	Blobs    [][]byte          `dynamodb:"blobs,binaryset"`
+	Body     []byte            `dynamodb:"body"`
+	Pictures []Picture         `dynamodb:"pictures"`
+	Meta     map[string]string `dynamodb:"meta"`
+	Cover    *Picture          `dynamodb:"cover"`
+	Posted   time.Time         `dynamodb:"posted"`
+	Temp     Celsius           `dynamodb:"temp"`
+	Any      interface{}       `dynamodb:"any"`
+	Secret   string            `dynamodb:"-"`
+	hidden   string
+}
+
+func TestMarshalItem(t *testing.T) {
+	rating := 4.5
+	posted := time.Date(2015, 3, 14, 15, 9, 26, 0, time.UTC)
+	in := Thread{
+		Base:     Base{ID: "t1", Version: 3},
+		Subject:  "hello",
+		Rating:   &rating,
+		Tags:     []string{"a", "b"},
+		Scores:   []int{1, 2},
+		Blobs:    [][]byte{[]byte("x")},
+		Body:     []byte("hi there\n"),
+		Pictures: []Picture{{URL: "http://x", Width: 10}},
+		Meta:     map[string]string{"k": "v"},
+		Posted:   posted,
+		Temp:     21.5,
+		Any:      map[string]interface{}{"n": json.Number("1.5"), "l": []interface{}{"s", true}},
+		Secret:   "s",
+		hidden:   "h",
+	}
+	m, m_err := MarshalItem(&in)
+	if m_err != nil {
+		t.Fatal(m_err)
+	}
+	b, _ := json.Marshal(m["pictures"])
+	if string(b) != `{"L":[{"M":{"URL":{"S":"http://x"},"Width":{"N":"10"}}}]}` {
+		t.Errorf("unexpected pictures %s", b)
+	}
+	if m["id"].S != "t1" || m["Version"].N != "3" || m["rating"].N != "4.5" ||
+		len(m["tags"].SS) != 2 || len(m["scores"].NS) != 2 || len(m["blobs"].BS) != 1 ||
+		m["body"].B != "aGkgdGhlcmUK" || m["posted"].S != "2015-03-14T15:09:26Z" ||
+		m["temp"].S != "21.5C" || !isNull(m["cover"]) {
+		t.Errorf("unexpected item %v", m)
+	}
+	for _, k := range []string{"Views", "Secret", "hidden", "Base"} {
+		if _, ok := m[k]; ok {
+			t.Errorf("%s should not be marshaled", k)
+		}
+	}
+
+	var out Thread
+	if u_err := UnmarshalItem(m, &out); u_err != nil {
+		t.Fatal(u_err)
+	}
+	in.Secret, in.hidden = "", ""
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip failed\n%+v\n%+v", in, out)
+	}
+}
+
+func TestMarshalValues(t *testing.T) {
+	a, _ := Marshal("")
+	if !isNull(a) {
+		t.Errorf("empty string should marshal to NULL")
+	}
+	a, _ = Marshal(json.Number("1e3"))
+	if a.N != "1000" {
+		t.Errorf("unexpected number %v", a)
+	}
+	a, _ = Marshal([...]uint8{1, 2})
+	if a.B != "AQI=" {
+		t.Errorf("unexpected binary %v", a)
+	}
+	var s struct {
+		Tags []string `dynamodb:",stringset"`
+	}
+	s.Tags = []string{"a", "a"}
+	if _, err := MarshalItem(s); err == nil || !strings.Contains(err.Error(), "field Tags[1]") {
+		t.Errorf("duplicate set members should fail, got %v", err)
+	}
+	if _, err := MarshalItem(map[int]string{1: "a"}); err == nil {
+		t.Errorf("map with int keys should fail")
+	}
+	if _, err := MarshalItem(5); err == nil {
+		t.Errorf("a number is not an item")
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var m AttributeValueMap
+	json.Unmarshal([]byte(`{"pictures":{"L":[{"M":{"Width":{"N":"70000"}}}]}}`), &m)
+	var th Thread
+	err := UnmarshalItem(m, &th)
+	if err == nil || !strings.Contains(err.Error(), "field pictures[0].Width") {
+		t.Errorf("overflow should name the field, got %v", err)
+	}
+	m = nil
+	json.Unmarshal([]byte(`{"subject":{"N":"1"}}`), &m)
+	err = UnmarshalItem(m, &th)
+	if err == nil || err.Error() != "attributevalue.UnmarshalItem: field subject: cannot unmarshal N into string" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := UnmarshalItem(m, th); err == nil {
+		t.Errorf("a non-pointer should be rejected")
+	}
+
+	var any map[string]interface{}
+	m = nil
+	json.Unmarshal([]byte(`{"a":{"NS":["1","2"]},"b":{"NULL":true},"c":{"L":[{"S":"x"}]}}`), &m)
+	if err := UnmarshalItem(m, &any); err != nil {
+		t.Fatal(err)
+	}
+	// the members of a set have no order
+	if ns, ok := any["a"].([]json.Number); ok {
+		sort.Slice(ns, func(i, j int) bool { return ns[i] < ns[j] })
+	}
+	want := map[string]interface{}{"a": []json.Number{"1", "2"}, "b": nil, "c": []interface{}{"x"}}
+	if !reflect.DeepEqual(any, want) {
+		t.Errorf("unexpected %v", any)
+	}
+}
+
+func TestUnmarshalExactNumbers(t *testing.T) {
+	var m AttributeValueMap
+	json.Unmarshal([]byte(`{"id":{"N":"12345678901234567890"},"ids":{"NS":["9007199254740993"]},`+
+		`"l":{"L":[{"N":"0.1000000000000000000000000000000000001"}]}}`), &m)
+	var any map[string]interface{}
+	if err := UnmarshalItem(m, &any); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"id":  json.Number("12345678901234567890"),
+		"ids": []json.Number{"9007199254740993"},
+		"l":   []interface{}{json.Number("0.1000000000000000000000000000000000001")},
+	}
+	if !reflect.DeepEqual(any, want) {
+		t.Errorf("numbers should not be rounded, got %v", any)
+	}
+	back, m_err := MarshalItem(any)
+	if m_err != nil || back["id"].N != "12345678901234567890" || len(back["ids"].L) != 1 ||
+		back["ids"].L[0].N != "9007199254740993" {
+		t.Errorf("round trip failed %v: %v", back, m_err)
+	}
+}
@@ -0,0 +1,178 @@
+// Support for exact DynamoDB numbers.
+package attributevalue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/smugmug/godynamo/types/cast"
+	"math/big"
+	"strconv"
+)
+
+// Number is a DynamoDB number held as its decimal string, so that 64 bit integers
+// and 38 digit decimals survive conversions that would round them as float64.
+// It marshals to json as a bare number.
+type Number string
+
+// ParseNumber validates s as a number DynamoDB can store and returns it in the
+// normalized form used for N. See cast.AWSParseNumber.
+func ParseNumber(s string) (Number, error) {
+	n, n_err := cast.AWSParseNumber(s)
+	if n_err != nil {
+		return "", n_err
+	}
+	return Number(n), nil
+}
+
+// String returns the number as a string.
+func (n Number) String() string {
+	return string(n)
+}
+
+// MarshalJSON emits the number unquoted.
+func (n Number) MarshalJSON() ([]byte, error) {
+	if _, n_err := cast.AWSParseNumber(string(n)); n_err != nil {
+		return nil, n_err
+	}
+	return []byte(n), nil
+}
+
+// Int64 returns the number as an int64, failing if it is not an integer in range.
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+// Uint64 returns the number as a uint64, failing if it is not an integer in range.
+func (n Number) Uint64() (uint64, error) {
+	return strconv.ParseUint(string(n), 10, 64)
+}
+
+// Float64 returns the nearest float64 to the number.
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// BigInt returns the number as a big.Int, failing if it is not an integer.
+func (n Number) BigInt() (*big.Int, error) {
+	i, ok := new(big.Int).SetString(string(n), 10)
+	if !ok {
+		e := fmt.Sprintf("Number.BigInt: %q is not an integer", string(n))
+		return nil, errors.New(e)
+	}
+	return i, nil
+}
+
+// BigFloat returns the number as a big.Float with 128 bits of precision, enough that
+// formatting it with 38 significant digits gives back the number. Like any binary float
+// it does not hold most decimals, such as 0.1, exactly; use the Number itself, or BigInt
+// for integers, where the exact decimal is needed.
+func (n Number) BigFloat() (*big.Float, error) {
+	f, _, f_err := big.ParseFloat(string(n), 10, 128, big.ToNearestEven)
+	if f_err != nil {
+		return nil, f_err
+	}
+	return f, nil
+}
+
+// numberString returns the decimal string of the number i, which may be any Go
+// integer or float, a json.Number, a Number, a *big.Int or a *big.Float. The string is
+// not yet validated.
+func numberString(i interface{}) (string, bool) {
+	switch n := i.(type) {
+	case json.Number:
+		return string(n), true
+	case Number:
+		return string(n), true
+	case float64:
+		return strconv.FormatFloat(n, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(n), 'f', -1, 32), true
+	case int:
+		return strconv.FormatInt(int64(n), 10), true
+	case int8:
+		return strconv.FormatInt(int64(n), 10), true
+	case int16:
+		return strconv.FormatInt(int64(n), 10), true
+	case int32:
+		return strconv.FormatInt(int64(n), 10), true
+	case int64:
+		return strconv.FormatInt(n, 10), true
+	case uint:
+		return strconv.FormatUint(uint64(n), 10), true
+	case uint8:
+		return strconv.FormatUint(uint64(n), 10), true
+	case uint16:
+		return strconv.FormatUint(uint64(n), 10), true
+	case uint32:
+		return strconv.FormatUint(uint64(n), 10), true
+	case uint64:
+		return strconv.FormatUint(n, 10), true
+	case *big.Int:
+		if n == nil {
+			return "", false
+		}
+		return n.String(), true
+	case *big.Float:
+		if n == nil {
+			return "", false
+		}
+		return n.Text('g', -1), true
+	}
+	return "", false
+}
+
+// InsertN_int64 works like InsertN but takes an int64
+func (a *AttributeValue) InsertN_int64(i int64) error {
+	if a == nil {
+		return errors.New("AttributeValue.InsertN_int64: pointer receiver is nil")
+	}
+	a.N = strconv.FormatInt(i, 10)
+	return nil
+}
+
+// InsertN_uint64 works like InsertN but takes a uint64
+func (a *AttributeValue) InsertN_uint64(i uint64) error {
+	if a == nil {
+		return errors.New("AttributeValue.InsertN_uint64: pointer receiver is nil")
+	}
+	a.N = strconv.FormatUint(i, 10)
+	return nil
+}
+
+// InsertN_big works like InsertN but takes a *big.Int or *big.Float, which
+// must fit DynamoDB's 38 digits of precision.
+func (a *AttributeValue) InsertN_big(n interface{}) error {
+	if a == nil {
+		return errors.New("AttributeValue.InsertN_big: pointer receiver is nil")
+	}
+	switch n.(type) {
+	case *big.Int, *big.Float:
+	default:
+		return errors.New(fmt.Sprintf("AttributeValue.InsertN_big: %T is not a *big.Int or *big.Float", n))
+	}
+	s, ok := numberString(n)
+	if !ok {
+		return errors.New("AttributeValue.InsertN_big: arg is nil")
+	}
+	return a.InsertN(s)
+}
+
+// InsertN_number works like InsertN but takes a Number
+func (a *AttributeValue) InsertN_number(n Number) error {
+	if a == nil {
+		return errors.New("AttributeValue.InsertN_number: pointer receiver is nil")
+	}
+	return a.InsertN(string(n))
+}
+
+// Number returns the N field as a Number.
+func (a *AttributeValue) Number() (Number, error) {
+	if a == nil {
+		return "", errors.New("AttributeValue.Number: pointer receiver is nil")
+	}
+	if a.N == "" {
+		return "", errors.New("AttributeValue.Number: N is not set")
+	}
+	return Number(a.N), nil
+}
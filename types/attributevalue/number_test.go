@@ -0,0 +1,123 @@
+package attributevalue
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestParseNumber(t *testing.T) {
+	good := map[string]string{
+		"5":                                      "5",
+		"-0":                                     "0",
+		"007.50":                                 "7.5",
+		"1.50e3":                                 "1500",
+		"-1.5E-3":                                "-0.0015",
+		"+.5":                                    "0.5",
+		"9223372036854775807":                    "9223372036854775807",
+		"12345678901234567890123456789012345678": "12345678901234567890123456789012345678",
+		"1e125":                                  "1" + zeros(125),
+		"1e-130":                                 "0." + zeros(129) + "1",
+	}
+	for in, want := range good {
+		n, err := ParseNumber(in)
+		if err != nil || string(n) != want {
+			t.Errorf("ParseNumber(%q) = %q, %v; want %q", in, n, err, want)
+		}
+	}
+	bad := []string{"", "-", ".", "1e", "1e+", "NaN", "Inf", "0x10", "1.2.3", "1e1000000000000",
+		"123456789012345678901234567890123456789", "1e126", "1e-131"}
+	for _, in := range bad {
+		if _, err := ParseNumber(in); err == nil {
+			t.Errorf("ParseNumber(%q) should fail", in)
+		}
+	}
+}
+
+func zeros(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = '0'
+	}
+	return string(b)
+}
+
+func TestNumberLossless(t *testing.T) {
+	const id = `{"id":9007199254740993,"price":12345678901234567890.123456789,"ns":[1,18446744073709551615]}`
+	m, err := BasicJSONToAttributeValueMap([]byte(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["id"].N != "9007199254740993" || m["price"].N != "12345678901234567890.123456789" || len(m["ns"].NS) != 2 {
+		t.Errorf("numbers were rounded: %v", m)
+	}
+	b, b_err := AttributeValueMap{"id": m["id"], "price": m["price"]}.ToBasicJSON()
+	if b_err != nil || string(b) != `{"id":9007199254740993,"price":12345678901234567890.123456789}` {
+		t.Errorf("unexpected json %s %v", b, b_err)
+	}
+	i, _ := m["id"].ToInterface()
+	if _, ok := i.(float64); !ok {
+		t.Errorf("ToInterface should still produce float64")
+	}
+
+	n, _ := m["id"].Number()
+	if i, err := n.Int64(); err != nil || i != 9007199254740993 {
+		t.Errorf("unexpected int64 %d %v", i, err)
+	}
+	a := NewAttributeValue()
+	bi, _ := new(big.Int).SetString("-99999999999999999999999999999999999999", 10)
+	if err := a.InsertN_big(bi); err != nil || a.N != bi.String() {
+		t.Errorf("unexpected N %q %v", a.N, err)
+	}
+	bi.Mul(bi, big.NewInt(11))
+	if err := a.InsertN_big(bi); err == nil {
+		t.Errorf("39 digits should be rejected")
+	}
+	if err := a.InsertN_uint64(18446744073709551615); err != nil || a.N != "18446744073709551615" {
+		t.Errorf("unexpected N %q", a.N)
+	}
+	if err := a.InsertNS("NaN"); err == nil {
+		t.Errorf("NaN should be rejected")
+	}
+
+	c, c_err := CoerceToAttributeValue([]interface{}{json.Number("1.0"), int64(2), Number("3")})
+	if c_err != nil || len(c.NS) != 3 || c.NS[0] != "1" {
+		t.Errorf("unexpected coercion %v %v", c, c_err)
+	}
+
+	var s struct {
+		ID    Number
+		Big   *big.Int
+		Ratio big.Float
+	}
+	s.ID = "123456789012345678901234567890"
+	s.Big = bi.Div(bi, big.NewInt(11))
+	s.Ratio.SetFloat64(0.25)
+	item, m_err := MarshalItem(s)
+	if m_err != nil {
+		t.Fatal(m_err)
+	}
+	if item["ID"].N != string(s.ID) || item["Big"].N != s.Big.String() || item["Ratio"].N != "0.25" {
+		t.Errorf("unexpected item %v", item)
+	}
+	s.ID, s.Big = "", nil
+	if err := UnmarshalItem(item, &s); err != nil || s.ID != "123456789012345678901234567890" ||
+		s.Big.String() != "-99999999999999999999999999999999999999" {
+		t.Errorf("unexpected struct %+v %v", s, err)
+	}
+}
+
+func TestNumberBigFloat(t *testing.T) {
+	n := Number("1.2345678901234567890123456789012345678")
+	f, err := n.BigFloat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := f.Text('g', 38); s != string(n) {
+		t.Errorf("38 digits should be recovered, got %s", s)
+	}
+	tenth, _ := Number("0.1").BigFloat()
+	if r, _ := tenth.Rat(nil); r.Cmp(big.NewRat(1, 10)) == 0 {
+		t.Errorf("0.1 cannot be held exactly by a binary float")
+	}
+}
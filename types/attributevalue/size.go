@@ -0,0 +1,103 @@
+// Support for computing the stored size of items as DynamoDB does.
+package attributevalue
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+const (
+	// the most bytes DynamoDB stores for an item
+	ITEM_LIM_BYTES = 409600
+	// the overhead of a list or map, and of each of its elements
+	DOCUMENT_OVERHEAD_BYTES = 3
+	ELEMENT_OVERHEAD_BYTES  = 1
+)
+
+// Size returns the bytes DynamoDB counts for the value, not including its name:
+// the UTF-8 length of strings, the decoded length of binaries, a byte per two
+// significant digits of numbers plus one, a byte for BOOL and NULL, and for lists and
+// maps 3 bytes plus a byte and the name of each element.
+func (a *AttributeValue) Size() int {
+	if a == nil {
+		return 0
+	}
+	switch {
+	case a.S != "":
+		return len(a.S)
+	case a.N != "":
+		return numberSize(a.N)
+	case a.B != "":
+		return binarySize(a.B)
+	case a.BOOL != nil || a.NULL != nil:
+		return 1
+	case a.SS != nil:
+		size := 0
+		for _, s := range a.SS {
+			size += len(s)
+		}
+		return size
+	case a.NS != nil:
+		size := 0
+		for _, n := range a.NS {
+			size += numberSize(n)
+		}
+		return size
+	case a.BS != nil:
+		size := 0
+		for _, b := range a.BS {
+			size += binarySize(b)
+		}
+		return size
+	case a.L != nil:
+		size := DOCUMENT_OVERHEAD_BYTES
+		for _, v := range a.L {
+			size += ELEMENT_OVERHEAD_BYTES + v.Size()
+		}
+		return size
+	case a.M != nil:
+		size := DOCUMENT_OVERHEAD_BYTES
+		for k, v := range a.M {
+			size += ELEMENT_OVERHEAD_BYTES + len(k) + v.Size()
+		}
+		return size
+	}
+	// the empty string or binary
+	return 0
+}
+
+// Size returns the bytes DynamoDB counts for an item: the sum of the lengths of the
+// attribute names and the sizes of their values.
+func (a AttributeValueMap) Size() int {
+	size := 0
+	for k, v := range a {
+		size += len(k) + v.Size()
+	}
+	return size
+}
+
+// numberSize returns the size of the number n, stored as pairs of significant digits.
+func numberSize(n string) int {
+	s := strings.TrimSpace(n)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+	if e := strings.IndexAny(s, "eE"); e >= 0 {
+		s = s[:e]
+	}
+	s = strings.Replace(s, ".", "", 1)
+	s = strings.Trim(s, "0")
+	size := (len(s)+1)/2 + 1
+	if negative {
+		size++
+	}
+	return size
+}
+
+// binarySize returns the length of the base64 encoded binary b.
+func binarySize(b string) int {
+	raw, b64_err := base64.StdEncoding.DecodeString(b)
+	if b64_err != nil {
+		return len(b)
+	}
+	return len(raw)
+}
@@ -0,0 +1,43 @@
+package attributevalue
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSize(t *testing.T) {
+	tests := []struct {
+		json string
+		size int
+	}{
+		{`{"S":"abc"}`, 3},
+		{`{"S":"héllo"}`, 6},
+		{`{"N":"0"}`, 1},
+		{`{"N":"7"}`, 2},
+		{`{"N":"12345"}`, 4},
+		{`{"N":"-12345"}`, 5},
+		{`{"N":"1200.00"}`, 2},
+		{`{"N":"0.0012"}`, 2},
+		{`{"B":"AAEC"}`, 3},
+		{`{"BOOL":true}`, 1},
+		{`{"NULL":true}`, 1},
+		{`{"SS":["a","bc"]}`, 3},
+		{`{"NS":["1","22"]}`, 4},
+		{`{"L":[{"S":"ab"},{"N":"1"}]}`, 3 + 1 + 2 + 1 + 2},
+		{`{"M":{"k":{"S":"ab"},"mm":{"M":{}}}}`, 3 + 1 + 1 + 2 + 1 + 2 + 3},
+	}
+	for _, tc := range tests {
+		var a AttributeValue
+		if um_err := json.Unmarshal([]byte(tc.json), &a); um_err != nil {
+			t.Fatal(um_err)
+		}
+		if s := a.Size(); s != tc.size {
+			t.Errorf("%s: expected %d bytes, got %d", tc.json, tc.size, s)
+		}
+	}
+	var m AttributeValueMap
+	json.Unmarshal([]byte(`{"Name":{"S":"abc"},"Views":{"N":"10"}}`), &m)
+	if s := m.Size(); s != 4+3+5+2 {
+		t.Errorf("unexpected item size %d", s)
+	}
+}
@@ -45,6 +45,39 @@ func (c ConsumedCapacity) Empty() bool {
 	}
 }
 
+// Add adds the units of o to c, such as the capacity consumed by another page of
+// a Query or Scan. The TableName of c is set from o if empty.
+func (c *ConsumedCapacity) Add(o *ConsumedCapacity) {
+	if c == nil || o == nil {
+		return
+	}
+	if c.TableName == "" {
+		c.TableName = o.TableName
+	}
+	c.CapacityUnits += o.CapacityUnits
+	if o.Table != nil {
+		if c.Table == nil {
+			c.Table = new(ConsumedCapacityUnit_struct)
+		}
+		c.Table.CapacityUnits += o.Table.CapacityUnits
+	}
+	c.GlobalSecondaryIndexes = addUnits(c.GlobalSecondaryIndexes, o.GlobalSecondaryIndexes)
+	c.LocalSecondaryIndexes = addUnits(c.LocalSecondaryIndexes, o.LocalSecondaryIndexes)
+}
+
+func addUnits(a, b map[string]ConsumedCapacityUnit_struct) map[string]ConsumedCapacityUnit_struct {
+	if len(b) == 0 {
+		return a
+	}
+	if a == nil {
+		a = make(map[string]ConsumedCapacityUnit_struct)
+	}
+	for k, v := range b {
+		a[k] = ConsumedCapacityUnit_struct{CapacityUnits: a[k].CapacityUnits + v.CapacityUnits}
+	}
+	return a
+}
+
 func (c ConsumedCapacity) MarshalJSON() ([]byte, error) {
 	if c.Empty() {
 		return json.Marshal(nil)
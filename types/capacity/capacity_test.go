@@ -30,3 +30,17 @@ func TestCapacityMarshal(t *testing.T) {
 		}
 	}
 }
+
+func TestCapacityAdd(t *testing.T) {
+	var sum ConsumedCapacity
+	for i := 0; i < 2; i++ {
+		var a ConsumedCapacity
+		json.Unmarshal([]byte(`{"CapacityUnits":1.5,"TableName":"mytable","Table":{"CapacityUnits":1},"GlobalSecondaryIndexes":{"mygsi":{"CapacityUnits":0.5}}}`), &a)
+		sum.Add(&a)
+	}
+	sum.Add(nil)
+	b, _ := json.Marshal(sum)
+	if string(b) != `{"CapacityUnits":3,"GlobalSecondaryIndexes":{"mygsi":{"CapacityUnits":1}},"Table":{"CapacityUnits":2},"TableName":"mytable"}` {
+		t.Errorf("unexpected sum %s", string(b))
+	}
+}
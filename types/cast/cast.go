@@ -3,7 +3,11 @@ package cast
 
 import (
 	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
 	"strconv"
+	"strings"
 )
 
 // AWSParseFLoats normalizes numbers-as-strings for transport.
@@ -21,3 +25,105 @@ func AWSParseBinary(s string) error {
 	_, err := base64.StdEncoding.DecodeString(s)
 	return err
 }
+
+// The limits DynamoDB places on numbers: 38 significant digits and a magnitude
+// between 1E-130 and 9.9999999999999999999999999999999999999E+125.
+const (
+	AWS_MAX_DIGITS   = 38
+	AWS_MAX_EXPONENT = 125
+	AWS_MIN_EXPONENT = -130
+)
+
+// AWSParseNumber normalizes a decimal number string for transport without the
+// loss of precision of AWSParseFloat. Leading and trailing zeros are removed and
+// any exponent is expanded, so "1.50e3" becomes "1500". Numbers DynamoDB cannot
+// store, those with more than 38 significant digits or out of range, are rejected.
+func AWSParseNumber(s string) (string, error) {
+	invalid := errors.New(fmt.Sprintf("cast.AWSParseNumber: invalid number %q", s))
+	neg := false
+	r := s
+	if r != "" && (r[0] == '-' || r[0] == '+') {
+		neg = r[0] == '-'
+		r = r[1:]
+	}
+	mantissa, exponent := r, ""
+	if i := strings.IndexAny(r, "eE"); i >= 0 {
+		mantissa, exponent = r[:i], r[i+1:]
+		if exponent == "" {
+			return "", invalid
+		}
+	}
+	digits := make([]byte, 0, len(mantissa))
+	point := -1
+	for i := 0; i < len(mantissa); i++ {
+		c := mantissa[i]
+		switch {
+		case c >= '0' && c <= '9':
+			digits = append(digits, c)
+		case c == '.' && point < 0:
+			point = len(digits)
+		default:
+			return "", invalid
+		}
+	}
+	if len(digits) == 0 {
+		return "", invalid
+	}
+	if point < 0 {
+		point = len(digits)
+	}
+	exp := 0
+	if exponent != "" {
+		for i := 0; i < len(exponent); i++ {
+			c := exponent[i]
+			if (c < '0' || c > '9') && !(i == 0 && (c == '-' || c == '+') && len(exponent) > 1) {
+				return "", invalid
+			}
+		}
+		e, e_err := strconv.Atoi(exponent)
+		if e_err != nil {
+			e = math.MaxInt32
+			if exponent[0] == '-' {
+				e = math.MinInt32
+			}
+		}
+		exp = e
+	}
+	for len(digits) != 0 && digits[0] == '0' {
+		digits = digits[1:]
+		point--
+	}
+	for len(digits) != 0 && digits[len(digits)-1] == '0' {
+		digits = digits[:len(digits)-1]
+	}
+	if len(digits) == 0 {
+		return "0", nil
+	}
+	if len(digits) > AWS_MAX_DIGITS {
+		e := fmt.Sprintf("cast.AWSParseNumber: %q has more than %d significant digits", s, AWS_MAX_DIGITS)
+		return "", errors.New(e)
+	}
+	if exp > AWS_MAX_EXPONENT+1-point || exp < AWS_MIN_EXPONENT+1-point {
+		e := fmt.Sprintf("cast.AWSParseNumber: %q is out of range", s)
+		return "", errors.New(e)
+	}
+	point += exp
+	var b strings.Builder
+	if neg {
+		b.WriteByte('-')
+	}
+	switch {
+	case point <= 0:
+		b.WriteString("0.")
+		b.WriteString(strings.Repeat("0", -point))
+		b.Write(digits)
+	case point >= len(digits):
+		b.Write(digits)
+		b.WriteString(strings.Repeat("0", point-len(digits)))
+	default:
+		b.Write(digits[:point])
+		b.WriteByte('.')
+		b.Write(digits[point:])
+	}
+	return b.String(), nil
+}
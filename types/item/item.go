@@ -2,6 +2,7 @@
 package item
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/smugmug/godynamo/types/attributevalue"
@@ -49,6 +50,41 @@ type ItemLike interface {
 	FromItem(Item) (interface{}, error)
 }
 
+// Size returns the bytes DynamoDB counts for the item, see attributevalue.AttributeValueMap.Size.
+func (i Item) Size() int {
+	return attributevalue.AttributeValueMap(i).Size()
+}
+
+// SizeError reports an item or key that is larger than DynamoDB allows.
+type SizeError struct {
+	TableName string
+	// The key of the item, nil if it is not known.
+	Key Key
+	// The hex sha256 of the json of the item if its key is not known, which identifies
+	// it among the requests.
+	Digest string
+	// The position of the item among the requests for the table.
+	Index int
+	Size  int
+	Limit int
+}
+
+func (e *SizeError) Error() string {
+	if e == nil {
+		return "item.SizeError: nil"
+	}
+	what := fmt.Sprintf("request %d", e.Index)
+	if len(e.Key) != 0 {
+		if b, json_err := json.Marshal(e.Key); json_err == nil {
+			what = "key " + string(b)
+		}
+	} else if e.Digest != "" {
+		what = fmt.Sprintf("sha256 %s (request %d)", e.Digest, e.Index)
+	}
+	return fmt.Sprintf("item.SizeError: table %s: the item with %s is %d bytes, more than the limit of %d",
+		e.TableName, what, e.Size, e.Limit)
+}
+
 // GetItem and UpdateItem share a Key type which is another alias to AttributeValueMap
 type Key attributevalue.AttributeValueMap
 
@@ -56,3 +92,22 @@ func NewKey() Key {
 	a := attributevalue.NewAttributeValueMap()
 	return Key(a)
 }
+
+// Marshal converts the struct or map v to an Item using the dynamodb field tags
+// described by attributevalue.MarshalItem.
+func Marshal(v interface{}) (Item, error) {
+	m, m_err := attributevalue.MarshalItem(v)
+	if m_err != nil {
+		return nil, m_err
+	}
+	return Item(m), nil
+}
+
+// Unmarshal sets the struct or map pointed to by v from the Item i, as described by
+// attributevalue.UnmarshalItem.
+func Unmarshal(i Item, v interface{}) error {
+	if i == nil {
+		return errors.New("item.Unmarshal: Item is nil")
+	}
+	return attributevalue.UnmarshalItem(attributevalue.AttributeValueMap(i), v)
+}
@@ -54,3 +54,26 @@ func TestItemMarshal(t *testing.T) {
 
 	}
 }
+
+func TestItemStructMarshal(t *testing.T) {
+	type thread struct {
+		Name  string   `dynamodb:"name"`
+		Views int      `dynamodb:"views,omitempty"`
+		Tags  []string `dynamodb:"tags,stringset"`
+	}
+	in := thread{Name: "a", Tags: []string{"x", "y"}}
+	i, m_err := Marshal(in)
+	if m_err != nil {
+		t.Fatal(m_err)
+	}
+	if _, ok := i["views"]; ok || i["name"].S != "a" || len(i["tags"].SS) != 2 {
+		t.Errorf("unexpected item %v", i)
+	}
+	var out thread
+	if u_err := Unmarshal(i, &out); u_err != nil {
+		t.Fatal(u_err)
+	}
+	if out.Name != in.Name || len(out.Tags) != 2 {
+		t.Errorf("round trip failed: %v", out)
+	}
+}
@@ -0,0 +1,15 @@
+// Support for the StreamSpecification of a table.
+package streamspecification
+
+// StreamSpecification enables the DynamoDB Stream of a table. StreamViewType is one
+// of KEYS_ONLY, NEW_IMAGE, OLD_IMAGE and NEW_AND_OLD_IMAGES, and is required when
+// StreamEnabled is true.
+type StreamSpecification struct {
+	StreamEnabled  bool
+	StreamViewType string `json:",omitempty"`
+}
+
+func NewStreamSpecification() *StreamSpecification {
+	s := new(StreamSpecification)
+	return s
+}